@@ -0,0 +1,115 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/util"
+)
+
+// DevTool represents a helper tool some features depend on, such as gocode for autocompletion.
+type DevTool struct {
+	Name     string   `json:"name"`     // executable name without extension
+	Package  string   `json:"package"`  // package to install by go install, with a version suffix such as "@latest"
+	Features []string `json:"features"` // features depending on the tool
+}
+
+// DevTools holds the helper tools expected by Wide, the ones without features (gorename and dlv) are reserved for
+// the features to come.
+var DevTools = []*DevTool{
+	{Name: "gocode", Package: "github.com/mdempsky/gocode@latest", Features: []string{"autocomplete"}},
+	{Name: "gopls", Package: "golang.org/x/tools/gopls@latest",
+		Features: []string{"autocomplete", "expression info", "jump to declaration", "find usages"}}, // if Wide.Gopls
+	{Name: "goimports", Package: "golang.org/x/tools/cmd/goimports@latest",
+		Features: []string{"organize imports", "goimports format"}},
+	{Name: "gorename", Package: "golang.org/x/tools/cmd/gorename@latest", Features: []string{}},
+	{Name: "golangci-lint", Package: "github.com/golangci/golangci-lint/cmd/golangci-lint@latest",
+		Features: []string{"lint"}},
+	{Name: "dlv", Package: "github.com/go-delve/delve/cmd/dlv@latest", Features: []string{}},
+	{Name: "gotools", Package: "github.com/visualfc/gotools@latest",
+		Features: []string{"expression info", "jump to declaration", "find usages"}},
+}
+
+// Timeout of getting the version of a tool.
+const devToolVersionTimeout = 10 * time.Second
+
+// GetDevTool gets the helper tool with the specified name, returns nil if not found.
+func GetDevTool(name string) *DevTool {
+	for _, tool := range DevTools {
+		if tool.Name == name {
+			return tool
+		}
+	}
+
+	return nil
+}
+
+// Path gets the path of the executable of the tool, the GOBIN directories are looked up before PATH, returns "" if
+// the tool is not installed.
+func (t *DevTool) Path() string {
+	if ret := util.Go.GetExecutableInGOBIN(t.Name); util.File.IsExist(ret) {
+		return ret
+	}
+
+	if ret, err := exec.LookPath(t.Name); nil == err {
+		return ret
+	}
+
+	return ""
+}
+
+// Version gets the module version the executable specified by path is built from (reported by go version -m), such
+// as "v0.1.0", returns "" if unknown.
+func (t *DevTool) Version(path string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), devToolVersionTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "go", "version", "-m", path).Output()
+	if nil != err {
+		return ""
+	}
+
+	// the main module line is in the form of "\tmod\t<module path>\t<version>\t<sum>"
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if 3 <= len(fields) && "mod" == fields[0] {
+			return fields[2]
+		}
+	}
+
+	return ""
+}
+
+// InstallCmd gets the command installing the tool.
+func (t *DevTool) InstallCmd() string {
+	return "go install " + t.Package
+}
+
+// GetDevToolHint gets the hint of installing the helper tool with the specified name in the specified locale.
+func GetDevToolHint(locale, name string) string {
+	cmd := "go install " + name
+	if tool := GetDevTool(name); nil != tool {
+		cmd = tool.InstallCmd()
+	}
+
+	ret := strings.Replace(i18n.Get(locale, "devtool_not_found").(string), "{tool}", name, 1)
+
+	return strings.Replace(ret, "{cmd}", cmd, 1)
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/b3log/wide/util"
+)
+
+// DefaultKeybindings holds the default keyboard shortcuts, <action, key combo>.
+//
+// Key combos are in the form of CodeMirror key names, modifiers (Shift, Cmd, Ctrl, Alt) followed by a key joined by
+// "-", such as "Shift-Alt-F".
+var DefaultKeybindings = map[string]string{
+	// editor
+	"autocomplete":  "Ctrl-\\",
+	"jumpToDecl":    "Ctrl-B",
+	"exprInfo":      "Ctrl-I",
+	"findUsages":    "Alt-F7",
+	"format":        "Shift-Alt-F",
+	"gotoLine":      "Ctrl-L",
+	"deleteLine":    "Ctrl-E",
+	"toggleComment": "Ctrl-/",
+	"copyLinesUp":   "Shift-Ctrl-Up",
+	"copyLinesDown": "Shift-Ctrl-Down",
+	"moveLinesUp":   "Shift-Alt-Up",
+	"moveLinesDown": "Shift-Alt-Down",
+	"selectIdent":   "Shift-Alt-J",
+	"save":          "Ctrl-S",
+	"saveAll":       "Shift-Ctrl-S",
+	"fullScreen":    "Shift-Alt-Enter",
+
+	// workbench
+	"goFile":        "Shift-Alt-O",
+	"closeEditor":   "Ctrl-Q",
+	"switchTab":     "Ctrl-D",
+	"focusEditor":   "Ctrl-0",
+	"focusFileTree": "Ctrl-1",
+	"focusOutline":  "Ctrl-2",
+	"focusOutput":   "Ctrl-4",
+	"focusSearch":   "Ctrl-5",
+	"focusNotify":   "Ctrl-6",
+	"build":         "F5",
+	"buildRun":      "F6",
+	"clearOutput":   "Alt-C",
+	"searchInTree":  "Ctrl-F",
+	"renameInTree":  "Ctrl-R",
+}
+
+// Valid key of a key combo, a printable character or a function key.
+var keyRegexp = regexp.MustCompile("^([A-Z0-9`\\-=\\[\\]\\\\;',./]|F([1-9]|1[0-2]))$")
+
+// Named keys of key combos.
+var keyNames = []string{"Up", "Down", "Left", "Right", "Enter", "Tab", "Esc", "Space", "Backspace", "Delete", "Insert",
+	"Home", "End", "PageUp", "PageDown"}
+
+// Modifiers of key combos in the order of CodeMirror normalized key names.
+var keyModifiers = []string{"Shift", "Cmd", "Ctrl", "Alt"}
+
+// NormalizeKeyCombo validates the specified key combo and normalizes it (modifiers in the order of Shift, Cmd, Ctrl
+// and Alt, letters in upper case), such as "alt-shift-f" to "Shift-Alt-F".
+//
+// A combo must have a modifier other than Shift unless its key is a function key.
+func NormalizeKeyCombo(combo string) (string, error) {
+	combo = strings.TrimSpace(combo)
+	if "" == combo {
+		return "", errors.New("key combo is empty")
+	}
+
+	// the key may be "-" itself, such as "Ctrl--"
+	key, mods := combo, ""
+	if i := strings.LastIndex(combo[:len(combo)-1], "-"); -1 < i {
+		key, mods = combo[i+1:], combo[:i]
+	}
+
+	key = strings.ToUpper(key)
+	for _, name := range keyNames {
+		if strings.EqualFold(name, key) {
+			key = name
+		}
+	}
+	if !keyRegexp.MatchString(key) && !util.Str.Contains(key, keyNames) {
+		return "", errors.New("invalid key [" + key + "] of key combo [" + combo + "]")
+	}
+
+	has := map[string]bool{}
+	if "" != mods {
+		for _, mod := range strings.Split(mods, "-") {
+			normalized := ""
+			for _, m := range keyModifiers {
+				if strings.EqualFold(m, mod) {
+					normalized = m
+				}
+			}
+
+			if "" == normalized || has[normalized] {
+				return "", errors.New("invalid modifier [" + mod + "] of key combo [" + combo + "]")
+			}
+			has[normalized] = true
+		}
+	}
+
+	if !strings.HasPrefix(key, "F") || 1 == len(key) {
+		if !has["Cmd"] && !has["Ctrl"] && !has["Alt"] {
+			return "", errors.New("key combo [" + combo + "] requires Ctrl, Alt or Cmd")
+		}
+	}
+
+	ret := ""
+	for _, m := range keyModifiers {
+		if has[m] {
+			ret += m + "-"
+		}
+	}
+
+	return ret + key, nil
+}
+
+// ParseKeybindings validates the specified custom keybindings (<action, key combo>) and normalizes their key combos.
+//
+// Actions must be the ones of DefaultKeybindings, and a combo can't be bound to several actions, taking the default
+// combos of the other actions into account.
+func ParseKeybindings(keybindings map[string]string) (map[string]string, error) {
+	ret := map[string]string{}
+	for action, combo := range keybindings {
+		if _, ok := DefaultKeybindings[action]; !ok {
+			return nil, errors.New("unknown action [" + action + "]")
+		}
+
+		normalized, err := NormalizeKeyCombo(combo)
+		if nil != err {
+			return nil, err
+		}
+
+		if DefaultKeybindings[action] != normalized {
+			ret[action] = normalized
+		}
+	}
+
+	bindings := mergeKeybindings(ret)
+	actions := []string{}
+	for action := range bindings {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	bound := map[string]string{} // <key combo, action>
+	for _, action := range actions {
+		combo := bindings[action]
+		if other, ok := bound[combo]; ok {
+			return nil, errors.New("key combo [" + combo + "] is bound to both [" + other + "] and [" + action + "]")
+		}
+
+		bound[combo] = action
+	}
+
+	return ret, nil
+}
+
+// GetKeybindings gets the keyboard shortcuts of the user, the default ones overridden by the custom ones.
+func (u *User) GetKeybindings() map[string]string {
+	return mergeKeybindings(u.Keybindings)
+}
+
+// mergeKeybindings merges the specified custom keybindings into the default ones.
+func mergeKeybindings(custom map[string]string) map[string]string {
+	ret := map[string]string{}
+	for action, combo := range DefaultKeybindings {
+		ret[action] = combo
+	}
+
+	for action, combo := range custom {
+		if _, ok := ret[action]; ok {
+			ret[action] = combo
+		}
+	}
+
+	return ret
+}
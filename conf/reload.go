@@ -0,0 +1,139 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+
+	"github.com/b3log/wide/log"
+)
+
+// restartFields holds names of the configuration fields which can't be changed without restarting Wide (listen
+// address, routes, and the things initialized once at startup).
+var restartFields = []string{"IP", "Port", "Server", "StaticServer", "Context", "Channel", "TLSCert", "TLSKey",
+	"Playground", "UsersWorkspaces", "RuntimeMode", "StaticResourceVersion"}
+
+// reloadArgs parses wide.json again with the arguments of Load.
+var reloadArgs func() (*conf, error)
+
+// reloadMutex serializes reloads.
+var reloadMutex sync.Mutex
+
+// Reloaded represents the result of reloading configurations.
+type Reloaded struct {
+	Restart []string // names of the changed fields requiring a restart to take effect, they are kept unchanged
+	Added   []*User  // users added
+	Removed []*User  // users removed
+}
+
+// Reload reloads the Wide configurations from wide.json and users' configurations from users/{username}.json without
+// restarting Wide.
+//
+// Changed fields take effect for new operations (the logging level, logging format and Go max procs are applied),
+// except the ones in restartFields which are kept unchanged and reported. Workspaces of new users are created,
+// configurations of existing users are replaced by the ones on disk, the caller should release sessions of the removed
+// users and serve workspaces of the added users.
+//
+// The new configurations are published as new pointers (Wide and GetUsers), the ones got before are not changed.
+func Reload() (*Reloaded, error) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	c, err := reloadArgs()
+	if nil != err {
+		applyRuntime(Wide) // parsing applies the logging level and format
+
+		return nil, err
+	}
+
+	parsed, err := parseUsers()
+	if nil != err {
+		applyRuntime(Wide)
+
+		return nil, err
+	}
+
+	ret := &Reloaded{}
+
+	oldConf, newConf := reflect.ValueOf(Wide).Elem(), reflect.ValueOf(c).Elem()
+	for _, name := range restartFields {
+		oldField, newField := oldConf.FieldByName(name), newConf.FieldByName(name)
+		if !reflect.DeepEqual(oldField.Interface(), newField.Interface()) {
+			if "StaticResourceVersion" != name { // contains ${time}, changes on every parse
+				ret.Restart = append(ret.Restart, name)
+			}
+
+			newField.Set(oldField)
+		}
+	}
+
+	// publishes the new configurations instead of changing the ones referenced by handlers in progress
+	Wide = c
+	applyRuntime(c)
+
+	loaded := map[string]*User{}
+	for _, user := range parsed {
+		loaded[user.Name] = user
+	}
+
+	var kept []*User
+	for _, user := range GetUsers() {
+		u := loaded[user.Name]
+		if nil == u {
+			ret.Removed = append(ret.Removed, user)
+
+			continue
+		}
+
+		delete(loaded, user.Name)
+		kept = append(kept, u)
+	}
+
+	for _, user := range parsed {
+		if nil != loaded[user.Name] {
+			ret.Added = append(ret.Added, user)
+			kept = append(kept, user)
+		}
+	}
+
+	SetUsers(kept)
+
+	initWorkspaceDirs()
+	initCustomizedConfs()
+
+	return ret, nil
+}
+
+// applyRuntime applies the logging level, logging format and Go max procs of the specified configurations.
+func applyRuntime(c *conf) {
+	log.SetLevel(c.LogLevel)
+	log.SetFormat(c.LogFormat)
+	runtime.GOMAXPROCS(c.MaxProcs)
+}
+
+// SetLogLevel sets the logging level to the specified level (see log.SetLevel), it's kept in new configurations
+// published instead of changing the current ones, the same as Reload.
+func SetLogLevel(level string) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	c := *Wide
+	c.LogLevel = level
+	Wide = &c
+
+	log.SetLevel(level)
+}
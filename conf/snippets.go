@@ -0,0 +1,220 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/util"
+)
+
+// Snippet represents a code snippet expanded by autocompletion when its trigger is typed.
+//
+// Template placeholders are in the form of ${1:default}, ${1} or $1, $0 is the final cursor position. Variables are
+// in the form of ${NAME}, ${ERR} is the error variable inferred from the code before the cursor, ${EXPR} is the
+// expression before the dot of a postfix snippet.
+type Snippet struct {
+	Trigger     string   // trigger, such as "forr"
+	Description string   // description shown in the completion list
+	Template    string   // template with placeholders and variables
+	FileTypes   []string // file extensions (such as ".go") the snippet applies to, empty means all
+	Postfix     bool     // whether it's a postfix snippet triggered after an expression and a dot, such as "x.nn"
+}
+
+// Applies checks whether the snippet applies to the file specified by path.
+func (s *Snippet) Applies(path string) bool {
+	if 0 == len(s.FileTypes) {
+		return true
+	}
+
+	return util.Str.Contains(strings.ToLower(filepath.Ext(path)), s.FileTypes)
+}
+
+// builtinSnippets holds the built-in snippets, could be overridden by custom ones with the same trigger.
+var builtinSnippets = []*Snippet{
+	{Trigger: "forr", Description: "for range loop", FileTypes: []string{".go"},
+		Template: "for ${1:_}, ${2:v} := range ${3:items} {\n\t$0\n}"},
+	{Trigger: "fori", Description: "for index loop", FileTypes: []string{".go"},
+		Template: "for ${1:i} := 0; ${1:i} < ${2:n}; ${1:i}++ {\n\t$0\n}"},
+	{Trigger: "err", Description: "if err != nil return", FileTypes: []string{".go"},
+		Template: "if ${ERR} != nil {\n\treturn ${1:${ERR}}\n}$0"},
+	{Trigger: "main", Description: "main function", FileTypes: []string{".go"},
+		Template: "func main() {\n\t$0\n}"},
+	{Trigger: "func", Description: "function declaration", FileTypes: []string{".go"},
+		Template: "func ${1:name}($2) {\n\t$0\n}"},
+	{Trigger: "tys", Description: "struct type declaration", FileTypes: []string{".go"},
+		Template: "type ${1:Name} struct {\n\t$0\n}"},
+	{Trigger: "nn", Description: "if expr != nil", FileTypes: []string{".go"}, Postfix: true,
+		Template: "if ${EXPR} != nil {\n\t$0\n}"},
+	{Trigger: "nil", Description: "if expr == nil", FileTypes: []string{".go"}, Postfix: true,
+		Template: "if ${EXPR} == nil {\n\t$0\n}"},
+	{Trigger: "range", Description: "for range expr", FileTypes: []string{".go"}, Postfix: true,
+		Template: "for ${1:_}, ${2:v} := range ${EXPR} {\n\t$0\n}"},
+	{Trigger: "len", Description: "len(expr)", FileTypes: []string{".go"}, Postfix: true,
+		Template: "len(${EXPR})$0"},
+	{Trigger: "print", Description: "fmt.Println(expr)", FileTypes: []string{".go"}, Postfix: true,
+		Template: "fmt.Println(${EXPR})$0"},
+	{Trigger: "return", Description: "return expr", FileTypes: []string{".go"}, Postfix: true,
+		Template: "return ${EXPR}$0"},
+}
+
+var (
+	snippets      []*Snippet // built-in and custom snippets
+	snippetsMutex sync.RWMutex
+)
+
+// GetSnippets gets the snippets applying to the file specified by path, the built-in ones and the custom ones in
+// conf.Wide.Snippets.
+func GetSnippets(path string) []*Snippet {
+	snippetsMutex.RLock()
+	loaded := nil != snippets
+	snippetsMutex.RUnlock()
+
+	if !loaded {
+		LoadSnippets()
+	}
+
+	snippetsMutex.RLock()
+	defer snippetsMutex.RUnlock()
+
+	ret := []*Snippet{}
+	for _, snippet := range snippets {
+		if snippet.Applies(path) {
+			ret = append(ret, snippet)
+		}
+	}
+
+	return ret
+}
+
+// LoadSnippets loads the built-in snippets and scans the custom snippets directory.
+//
+// A custom snippets file is a JSON array of snippets named *.json, a custom snippet overrides the built-in one with
+// the same trigger (and postfix flag).
+func LoadSnippets() {
+	keyOf := func(s *Snippet) string {
+		if s.Postfix {
+			return "." + s.Trigger
+		}
+
+		return s.Trigger
+	}
+
+	byKey := map[string]*Snippet{}
+	for _, snippet := range builtinSnippets {
+		byKey[keyOf(snippet)] = snippet
+	}
+
+	if "" != Wide.Snippets {
+		for _, snippet := range scanSnippets(Wide.Snippets) {
+			byKey[keyOf(snippet)] = snippet
+		}
+	}
+
+	ret := []*Snippet{}
+	for _, snippet := range byKey {
+		ret = append(ret, snippet)
+	}
+	sort.Slice(ret, func(i, j int) bool { return keyOf(ret[i]) < keyOf(ret[j]) })
+
+	snippetsMutex.Lock()
+	defer snippetsMutex.Unlock()
+
+	snippets = ret
+}
+
+// FixedTimeLoadSnippets reloads snippets periodically (1 minute), so custom snippets could be dropped into the
+// directory without restarting.
+func FixedTimeLoadSnippets() {
+	go func() {
+		defer util.Recover()
+
+		for _ = range time.Tick(time.Minute) {
+			LoadSnippets()
+		}
+	}()
+}
+
+// scanSnippets scans the specified directory for snippets JSON files, returns the valid snippets.
+func scanSnippets(dir string) []*Snippet {
+	ret := []*Snippet{}
+
+	f, err := os.Open(dir)
+	if nil != err {
+		if !os.IsNotExist(err) {
+			logger.Warn(err)
+		}
+
+		return ret
+	}
+	names, _ := f.Readdirnames(-1)
+	f.Close()
+	sort.Strings(names)
+
+	for _, name := range names {
+		if ".json" != filepath.Ext(name) {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		data, err := ioutil.ReadFile(path)
+		if nil != err {
+			logger.Warn(err)
+
+			continue
+		}
+
+		fileSnippets := []*Snippet{}
+		if err := json.Unmarshal(data, &fileSnippets); nil != err {
+			logger.Warnf("Invalid snippets file [%s]: %s", path, err)
+
+			continue
+		}
+
+		for _, snippet := range fileSnippets {
+			snippet.Trigger = strings.TrimSpace(snippet.Trigger)
+			if "" == snippet.Trigger || "" == snippet.Template {
+				logger.Warnf("Snippet without trigger or template in [%s]", path)
+
+				continue
+			}
+
+			fileTypes := []string{}
+			for _, ext := range snippet.FileTypes {
+				ext = strings.ToLower(strings.TrimSpace(ext))
+				if "" == ext {
+					continue
+				}
+
+				if !strings.HasPrefix(ext, ".") {
+					ext = "." + ext
+				}
+				fileTypes = append(fileTypes, ext)
+			}
+			snippet.FileTypes = fileTypes
+
+			ret = append(ret, snippet)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/util"
+)
+
+// builtinEditorThemes is the directory of built-in editor themes.
+const builtinEditorThemes = "static/js/overwrite/codemirror/theme"
+
+// Valid editor theme name, also used as the CSS class (cm-s-{name}) by CodeMirror.
+var editorThemeNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+var (
+	editorThemes       []string          // names of built-in and custom editor themes
+	customEditorThemes map[string]string // <name, CSS file path> of custom editor themes
+	editorThemesMutex  sync.RWMutex
+)
+
+// GetEditorThemes gets the names of editor themes, the built-in ones and the custom ones in conf.Wide.EditorThemes.
+func GetEditorThemes() []string {
+	editorThemesMutex.RLock()
+	loaded := nil != editorThemes
+	editorThemesMutex.RUnlock()
+
+	if !loaded {
+		LoadEditorThemes()
+	}
+
+	editorThemesMutex.RLock()
+	defer editorThemesMutex.RUnlock()
+
+	return append([]string{}, editorThemes...)
+}
+
+// GetCustomEditorThemes gets the custom editor themes, <name, true>.
+func GetCustomEditorThemes() map[string]bool {
+	editorThemesMutex.RLock()
+	defer editorThemesMutex.RUnlock()
+
+	ret := map[string]bool{}
+	for name := range customEditorThemes {
+		ret[name] = true
+	}
+
+	return ret
+}
+
+// GetCustomEditorThemePath gets the CSS file path of the custom editor theme specified by name, returns false if not
+// found.
+func GetCustomEditorThemePath(name string) (string, bool) {
+	editorThemesMutex.RLock()
+	defer editorThemesMutex.RUnlock()
+
+	path, ok := customEditorThemes[name]
+
+	return path, ok
+}
+
+// IsEditorTheme checks whether the editor theme specified by name is available.
+func IsEditorTheme(name string) bool {
+	return util.Str.Contains(name, GetEditorThemes())
+}
+
+// LoadEditorThemes scans the built-in and custom editor themes directories.
+//
+// A custom theme is a CodeMirror theme CSS file named {name}.css, a custom theme with invalid name or the same name
+// as a built-in one is ignored.
+func LoadEditorThemes() {
+	names := []string{}
+	builtins := map[string]bool{}
+	for _, name := range scanEditorThemes(builtinEditorThemes) {
+		names = append(names, name)
+		builtins[name] = true
+	}
+
+	customs := map[string]string{}
+	if "" != Wide.EditorThemes {
+		for _, name := range scanEditorThemes(Wide.EditorThemes) {
+			if builtins[name] {
+				logger.Warnf("Custom editor theme [%s] conflicts with the built-in one, ignored", name)
+
+				continue
+			}
+
+			names = append(names, name)
+			customs[name] = filepath.Join(Wide.EditorThemes, name+".css")
+		}
+	}
+
+	sort.Strings(names)
+
+	editorThemesMutex.Lock()
+	defer editorThemesMutex.Unlock()
+
+	editorThemes = names
+	customEditorThemes = customs
+}
+
+// FixedTimeLoadEditorThemes reloads editor themes periodically (1 minute), so custom themes could be dropped into
+// the directory without restarting.
+func FixedTimeLoadEditorThemes() {
+	go func() {
+		defer util.Recover()
+
+		for _ = range time.Tick(time.Minute) {
+			LoadEditorThemes()
+		}
+	}()
+}
+
+// scanEditorThemes scans the specified directory for editor theme CSS files, returns the valid theme names.
+func scanEditorThemes(dir string) []string {
+	ret := []string{}
+
+	f, err := os.Open(dir)
+	if nil != err {
+		if !os.IsNotExist(err) {
+			logger.Warn(err)
+		}
+
+		return ret
+	}
+	names, _ := f.Readdirnames(-1)
+	f.Close()
+
+	for _, name := range names {
+		if ".css" != filepath.Ext(name) {
+			continue
+		}
+
+		theme := strings.TrimSuffix(name, ".css")
+		if !editorThemeNameRegexp.MatchString(theme) {
+			logger.Warnf("Invalid editor theme file name [%s] in [%s]", name, dir)
+
+			continue
+		}
+
+		ret = append(ret, theme)
+	}
+
+	return ret
+}
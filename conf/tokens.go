@@ -0,0 +1,104 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Scopes of API tokens.
+const (
+	TokenScopeRead = "read" // browses, builds and runs code, the same as a viewer
+	TokenScopeFull = "full" // all permissions of the user's role
+)
+
+// tokenPrefix is the prefix of API tokens, makes them recognizable.
+const tokenPrefix = "wide_"
+
+// APIToken represents an API token of a user, only the hash of the token is stored.
+type APIToken struct {
+	ID      string // the first characters of the token, identifies the token in listing and revoking
+	Name    string // description given by the user
+	Hash    string // SHA-256 of the token in hex
+	Scope   string // read/full
+	Created int64  // create time in unix nano
+	Used    int64  // the latest use time in unix nano, 0 means never
+}
+
+// NewAPIToken generates an API token with the specified name and scope for the user, returns the token which is
+// shown to the user once.
+func (u *User) NewAPIToken(name, scope string) (string, *APIToken, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); nil != err {
+		return "", nil, err
+	}
+
+	token := tokenPrefix + hex.EncodeToString(bytes)
+	ret := &APIToken{ID: token[:len(tokenPrefix)+8], Name: name, Hash: hashAPIToken(token), Scope: scope,
+		Created: time.Now().UnixNano()}
+
+	u.APITokens = append(u.APITokens, ret)
+
+	return token, ret, nil
+}
+
+// RevokeAPIToken removes the API token specified by id from the user, returns false if not found.
+func (u *User) RevokeAPIToken(id string) bool {
+	for i, token := range u.APITokens {
+		if token.ID == id {
+			u.APITokens = append(u.APITokens[:i], u.APITokens[i+1:]...)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetUserByAPIToken gets the user and the stored API token matching the specified token, returns nil if not found.
+func GetUserByAPIToken(token string) (*User, *APIToken) {
+	if !strings.HasPrefix(token, tokenPrefix) || len(tokenPrefix)+8 > len(token) {
+		return nil, nil
+	}
+
+	id := token[:len(tokenPrefix)+8]
+	hash := hashAPIToken(token)
+	for _, user := range GetUsers() {
+		for _, t := range user.APITokens {
+			if t.ID == id && 1 == subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hash)) {
+				return user, t
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// IsTokenScope checks whether the specified scope is a valid API token scope.
+func IsTokenScope(scope string) bool {
+	return TokenScopeRead == scope || TokenScopeFull == scope
+}
+
+// hashAPIToken hashes the specified API token.
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
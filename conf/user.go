@@ -23,7 +23,9 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/b3log/wide/util"
@@ -50,16 +52,74 @@ type LatestSessionContent struct {
 	Layout      *Layout  `json:"layout"`      // UI Layout
 }
 
+// User roles.
+const (
+	RoleAdmin  = "admin"  // all permissions
+	RoleEditor = "editor" // browses, modifies, builds and runs code
+	RoleViewer = "viewer" // browses, builds and runs code, can't modify files or install packages
+)
+
+// File tree sort orders.
+const (
+	TreeSortName     = "name"     // alphabetical, case-sensitive
+	TreeSortNameFold = "namefold" // alphabetical, case-insensitive
+	TreeSortModified = "modified" // the latest modified first
+)
+
+// IsTreeSort checks whether the specified sort is a file tree sort order, empty means TreeSortName.
+func IsTreeSort(sort string) bool {
+	return "" == sort || TreeSortName == sort || TreeSortNameFold == sort || TreeSortModified == sort
+}
+
+// RunConf represents a run configuration of a main package.
+type RunConf struct {
+	Args []string          // arguments passed to the executable as is, no shell quoting or splitting
+	Env  map[string]string // environment variables appended to the environment of Wide
+}
+
+// BuildProfile represents a named set of flags of go build and go run, such as "debug" and "release".
+type BuildProfile struct {
+	Name    string
+	LDFlags string            // value of -ldflags, such as "-s -w"
+	GCFlags string            // value of -gcflags, such as "all=-N -l"
+	Tags    []string          // build tags, joined as the value of -tags
+	Env     map[string]string // environment variables appended to the environment of the go command
+}
+
+// Built-in build profiles, could be overridden by the ones of a user with the same name.
+var builtinBuildProfiles = []*BuildProfile{
+	{Name: "debug", GCFlags: "all=-N -l"}, // disables optimizations and inlining for debuggers
+	{Name: "release", LDFlags: "-s -w"},   // omits the symbol table and DWARF
+}
+
+// Args gets the go command arguments of the build profile, each flag value is an argument as is.
+func (p *BuildProfile) Args() []string {
+	ret := []string{}
+	if "" != p.LDFlags {
+		ret = append(ret, "-ldflags", p.LDFlags)
+	}
+	if "" != p.GCFlags {
+		ret = append(ret, "-gcflags", p.GCFlags)
+	}
+	if 0 < len(p.Tags) {
+		ret = append(ret, "-tags", strings.Join(p.Tags, ","))
+	}
+
+	return ret
+}
+
 // User configuration.
 type User struct {
 	Name                  string
 	Password              string
 	Salt                  string
 	Email                 string
+	Role                  string // admin/editor/viewer, empty means editor
 	Gravatar              string // see http://gravatar.com
 	Workspace             string // the GOPATH of this user (maybe contain several paths splitted by os.PathListSeparator)
 	Locale                string
 	GoFormat              string
+	GoRoot                string // GOROOT of the selected Go toolchain, empty means the one running Wide
 	GoBuildArgsForLinux   string
 	GoBuildArgsForWindows string
 	GoBuildArgsForDarwin  string
@@ -67,11 +127,26 @@ type User struct {
 	FontSize              string
 	Theme                 string
 	Keymap                string // wide/vim
+	AutoTest              bool   // whether run go test automatically after saving a Go file
+	LintConf              string // path of golangci-lint configuration file (.golangci.yml), relative to the package if not absolute
+	LineEnding            string // line ending of saved files, "lf"/"crlf", empty means keeping the one of the file
+	StartupCmd            string // shell command run in the workspace once per new session, empty means none
+	MailNotify            bool   // whether notified by email when a long build/run/test done, see Wide.MailThreshold
+	TreeSort              string // sort order of file tree nodes, see TreeSortName, empty means TreeSortName
+	TreeMixed             bool   // whether directories are mixed with files in file tree instead of in front of them
 	Created               int64  // user create time in unix nano
 	Updated               int64  // preference update time in unix nano
 	Lived                 int64  // the latest session activity in unix nano
 	Editor                *editor
 	LatestSessionContent  *LatestSessionContent
+	RunConfs              map[string]*RunConf // <package directory, last-used run configuration>
+	BuildProfiles         []*BuildProfile     // custom build profiles, see BuildProfile
+	BuildProfileUses      map[string]string   // <package directory, name of the last-used build profile>
+	RecentFiles           []string            // paths of recently opened files, the most recent first
+	APITokens             []*APIToken         // tokens for programmatic access, see APIToken
+	Keybindings           map[string]string   // <action, key combo>, custom keyboard shortcuts, see DefaultKeybindings
+
+	mutex sync.RWMutex // guards RunConfs and BuildProfileUses
 }
 
 // Editor configuration of a user.
@@ -104,9 +179,27 @@ func NewUser(username, password, email, workspace string) *User {
 			Theme: "wide", TabSize: "4"}}
 }
 
+// IsViewer checks whether the user is a viewer (read-only user).
+func (u *User) IsViewer() bool {
+	return RoleViewer == u.Role
+}
+
+// IsAdmin checks whether the user is an administrator.
+func (u *User) IsAdmin() bool {
+	return RoleAdmin == u.Role
+}
+
+// GoEnvPath gets the path of the user's go environment configuration file (GOENV), conf/users/{username}.goenv, which
+// is written by `go env -w`.
+func (u *User) GoEnvPath() string {
+	return filepath.Join(Wide.WD, "conf", "users", u.Name+".goenv")
+}
+
 // Save saves the user's configurations in conf/users/{username}.json.
 func (u *User) Save() bool {
+	u.mutex.RLock()
 	bytes, err := json.MarshalIndent(u, "", "    ")
+	u.mutex.RUnlock()
 
 	if nil != err {
 		logger.Error(err)
@@ -164,10 +257,101 @@ func (u *User) BuildArgs(os string) []string {
 	return ret
 }
 
+// GetRunConf gets the last-used run configuration of the package specified by dir, returns nil if not found.
+func (u *User) GetRunConf(dir string) *RunConf {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	return u.RunConfs[dir]
+}
+
+// SetRunConf sets the last-used run configuration of the package specified by dir, nil means removing it.
+func (u *User) SetRunConf(dir string, runConf *RunConf) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if nil == runConf {
+		delete(u.RunConfs, dir)
+
+		return
+	}
+
+	if nil == u.RunConfs {
+		u.RunConfs = map[string]*RunConf{}
+	}
+
+	u.RunConfs[dir] = runConf
+}
+
+// GetBuildProfileUse gets the name of the last-used build profile of the package specified by dir, returns "" if
+// not found.
+func (u *User) GetBuildProfileUse(dir string) string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	return u.BuildProfileUses[dir]
+}
+
+// SetBuildProfileUse sets the name of the last-used build profile of the package specified by dir, "" means removing
+// it. Returns true if it's changed.
+func (u *User) SetBuildProfileUse(dir, name string) bool {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if name == u.BuildProfileUses[dir] {
+		return false
+	}
+
+	if "" == name {
+		delete(u.BuildProfileUses, dir)
+
+		return true
+	}
+
+	if nil == u.BuildProfileUses {
+		u.BuildProfileUses = map[string]string{}
+	}
+
+	u.BuildProfileUses[dir] = name
+
+	return true
+}
+
+// GetBuildProfiles gets the build profiles of the user, the built-in ones and the custom ones, sorted by name.
+func (u *User) GetBuildProfiles() []*BuildProfile {
+	byName := map[string]*BuildProfile{}
+	for _, profile := range builtinBuildProfiles {
+		byName[profile.Name] = profile
+	}
+	for _, profile := range u.BuildProfiles {
+		byName[profile.Name] = profile
+	}
+
+	ret := []*BuildProfile{}
+	for _, profile := range byName {
+		ret = append(ret, profile)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+
+	return ret
+}
+
+// GetBuildProfile gets the build profile of the user specified by name, returns nil if not found.
+func (u *User) GetBuildProfile(name string) *BuildProfile {
+	for _, profile := range u.GetBuildProfiles() {
+		if name == profile.Name {
+			return profile
+		}
+	}
+
+	return nil
+}
+
 // GetOwner gets the user the specified path belongs to. Returns "" if not found.
 func GetOwner(path string) string {
-	for _, user := range Users {
-		if strings.HasPrefix(path, user.WorkspacePath()) {
+	for _, user := range GetUsers() {
+		workspace := user.WorkspacePath()
+		if path == workspace || strings.HasPrefix(path, workspace+string(filepath.Separator)) {
 			return user.Name
 		}
 	}
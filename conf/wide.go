@@ -17,13 +17,16 @@ package conf
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"text/template"
 	"time"
 
@@ -55,23 +58,61 @@ func main() {
 
 // Configuration.
 type conf struct {
-	IP                    string // server ip, ${ip}
-	Port                  string // server port
-	Context               string // server context
-	Server                string // server host and port ({IP}:{Port})
-	StaticServer          string // static resources server scheme, host and port (http://{IP}:{Port})
-	LogLevel              string // logging level: trace/debug/info/warn/error
-	Channel               string // channel (ws://{IP}:{Port})
-	HTTPSessionMaxAge     int    // HTTP session max age (in seciond)
-	StaticResourceVersion string // version of static resources
-	MaxProcs              int    // Go max procs
-	RuntimeMode           string // runtime mode (dev/prod)
-	WD                    string // current working direcitory, ${pwd}
-	Locale                string // default locale
-	Playground            string // playground directory
-	UsersWorkspaces       string // users' workspaces directory (admin defaults to ${GOPATH}, others using this)
-	AllowRegister         bool   // allow register or not
-	Autocomplete          bool   // default autocomplete
+	IP                    string   // server ip, ${ip}
+	Port                  string   // server port
+	Context               string   // server context
+	Server                string   // server host and port ({IP}:{Port})
+	StaticServer          string   // static resources server scheme, host and port (http://{IP}:{Port})
+	LogLevel              string   // logging level: trace/debug/info/warn/error
+	LogFormat             string   // logging format: text/json
+	Channel               string   // channel (ws://{IP}:{Port})
+	HTTPSessionMaxAge     int      // HTTP session max age (in seciond)
+	CookieHTTPOnly        bool     // whether the session cookie is HttpOnly (invisible to scripts)
+	CookieSecure          bool     // whether the session cookie is sent over HTTPS only, always true if TLS is enabled
+	CookieSameSite        string   // SameSite attribute of the session cookie: lax/strict/none, defaults to lax
+	StaticResourceVersion string   // version of static resources
+	MaxProcs              int      // Go max procs
+	RuntimeMode           string   // runtime mode (dev/prod)
+	WD                    string   // current working direcitory, ${pwd}
+	Locale                string   // default locale
+	Playground            string   // playground directory
+	UsersWorkspaces       string   // users' workspaces directory (admin defaults to ${GOPATH}, others using this)
+	AllowRegister         bool     // allow register or not
+	Autocomplete          bool     // default autocomplete
+	Gopls                 bool     // use gopls instead of gocode & gotools for code intelligence
+	MetricsToken          string   // token required to access /metrics, empty means no protection
+	TLSCert               string   // path of TLS certificate file, serves HTTPS if both TLSCert and TLSKey are set
+	TLSKey                string   // path of TLS private key file
+	UserQuota             int64    // max disk usage of a user's workspace in bytes, 0 means unlimited
+	GitDecorations        bool     // whether annotates file tree nodes with git status
+	GoToolchains          []string // GOROOT directories of Go toolchains could be selected by users
+	AutosaveInterval      int      // interval of autosaving drafts of unsaved editors (in second), 0 means disabled
+	SearchMaxResults      int      // max results of a text search page
+	EditorThemes          string   // directory of custom editor themes (CodeMirror theme CSS), empty means built-in only
+	MaxUserProcs          int      // max concurrent go (and linter) processes of a user, 0 means unlimited
+	MaxProcsTotal         int      // max concurrent go (and linter) processes of all users, 0 means unlimited
+	StarterKit            string   // directory copied into workspaces of new users, empty means the hello world samples
+	SMTPServer            string   // SMTP server (host:port) sending email notifications, empty means disabled
+	SMTPUsername          string   // SMTP username, empty means no authentication
+	SMTPPassword          string   // SMTP password
+	SMTPFrom              string   // sender address of email notifications
+	MailThreshold         int      // min duration of a build/run/test to be notified by email (in second)
+	EvalNetwork           bool     // whether evaluated snippets could access network
+	TreeMaxDepth          int      // max depth of the file tree loaded at once, 0 means unlimited
+	TreeMaxNodes          int      // max count of nodes of the file tree loaded at once, 0 means unlimited
+	StopGracePeriod       int      // time given to a stopped process to exit after interrupted before killed (in second)
+	StaticMaxAge          int      // cache max age of versioned static resources (in second)
+	StaticShortMaxAge     int      // cache max age of other static resources (in second), revalidated by ETag after
+	RequestTimeout        int      // max duration of handling a HTTP request (in second), 0 means unlimited, downloads and uploads are exempt
+	Snippets              string   // directory of custom snippets (JSON), empty means built-in only
+	SearchIndexMaxSize    int64    // max total size of file contents cached by search indexes in bytes, 0 means disabled
+
+	// file extension (such as ".proto") to editor mode (MIME of a CodeMirror mode), overrides the detection of the editor
+	EditorModes map[string]string
+
+	// user role (admin/editor/viewer) to starter kit directory copied into workspaces of new users of the role, roles
+	// not configured use StarterKit
+	StarterKits map[string]string
 }
 
 // Logger.
@@ -80,8 +121,8 @@ var logger = log.NewLogger(os.Stdout)
 // Wide configurations.
 var Wide *conf
 
-// configurations of users.
-var Users []*User
+// configurations of users, a []*User replaced as a whole (see GetUsers and SetUsers).
+var users atomic.Value
 
 // Indicates whether runs via Docker.
 var Docker bool
@@ -91,26 +132,56 @@ func Load(confPath, confIP, confPort, confServer, confLogLevel, confStaticServer
 	confPlayground string, confDocker bool, confUsersWorkspaces string) {
 	// XXX: ugly args list....
 
+	reloadArgs = func() (*conf, error) {
+		return parseWide(confPath, confIP, confPort, confServer, confLogLevel, confStaticServer, confContext,
+			confChannel, confPlayground, confDocker, confUsersWorkspaces)
+	}
+
 	initWide(confPath, confIP, confPort, confServer, confLogLevel, confStaticServer, confContext, confChannel,
 		confPlayground, confDocker, confUsersWorkspaces)
 	initUsers()
 }
 
+// GetUsers gets the configurations of users. The returned slice is a snapshot which must not be modified, use SetUsers
+// to replace it.
+func GetUsers() []*User {
+	ret, _ := users.Load().([]*User)
+
+	return ret
+}
+
+// SetUsers replaces the configurations of users with the specified ones, readers get either the old ones or the new
+// ones. Callers changing users should be serialized, the specified slice is built from GetUsers for example.
+func SetUsers(u []*User) {
+	users.Store(u)
+}
+
 func initUsers() {
-	f, err := os.Open("conf/users")
+	parsed, err := parseUsers()
 	if nil != err {
 		logger.Error(err)
 
 		os.Exit(-1)
 	}
 
-	names, err := f.Readdirnames(-1)
-	if nil != err {
-		logger.Error(err)
+	SetUsers(parsed)
 
-		os.Exit(-1)
+	initWorkspaceDirs()
+	initCustomizedConfs()
+}
+
+// parseUsers parses users' configurations from conf/users/{username}.json, a user failed to parse is skipped.
+func parseUsers() (ret []*User, err error) {
+	f, err := os.Open("conf/users")
+	if nil != err {
+		return nil, err
 	}
+
+	names, err := f.Readdirnames(-1)
 	f.Close()
+	if nil != err {
+		return nil, err
+	}
 
 	for _, name := range names {
 		if strings.HasPrefix(name, ".") { // hiden files that not be created by Wide
@@ -148,126 +219,231 @@ func initUsers() {
 			user.GoBuildArgsForDarwin = "-i"
 		}
 
-		Users = append(Users, user)
+		ret = append(ret, user)
 	}
 
-	initWorkspaceDirs()
-	initCustomizedConfs()
+	return ret, nil
 }
 
 func initWide(confPath, confIP, confPort, confServer, confLogLevel, confStaticServer, confContext, confChannel,
 	confPlayground string, confDocker bool, confUsersWorkspaces string) {
-	bytes, err := ioutil.ReadFile(confPath)
+	c, err := parseWide(confPath, confIP, confPort, confServer, confLogLevel, confStaticServer, confContext,
+		confChannel, confPlayground, confDocker, confUsersWorkspaces)
 	if nil != err {
 		logger.Error(err)
 
 		os.Exit(-1)
 	}
 
-	Wide = &conf{}
+	Wide = c
+}
 
-	err = json.Unmarshal(bytes, Wide)
-	if err != nil {
-		logger.Error("Parses [wide.json] error: ", err)
+// parseWide parses wide.json specified by confPath, overrides the configurations with the specified non-empty
+// arguments (command line flags).
+func parseWide(confPath, confIP, confPort, confServer, confLogLevel, confStaticServer, confContext, confChannel,
+	confPlayground string, confDocker bool, confUsersWorkspaces string) (*conf, error) {
+	bytes, err := ioutil.ReadFile(confPath)
+	if nil != err {
+		return nil, err
+	}
 
-		os.Exit(-1)
+	ret := &conf{}
+
+	err = json.Unmarshal(bytes, ret)
+	if err != nil {
+		return nil, errors.New("parses [wide.json] error: " + err.Error())
 	}
 
 	// Logging Level
-	log.SetLevel(Wide.LogLevel)
+	log.SetLevel(ret.LogLevel)
+	log.SetFormat(ret.LogFormat)
 	if "" != confLogLevel {
-		Wide.LogLevel = confLogLevel
+		ret.LogLevel = confLogLevel
 		log.SetLevel(confLogLevel)
 	}
 
 	logger.Debug("Conf: \n" + string(bytes))
 
 	// Working Directory
-	Wide.WD = util.OS.Pwd()
-	logger.Debugf("${pwd} [%s]", Wide.WD)
+	ret.WD = util.OS.Pwd()
+	logger.Debugf("${pwd} [%s]", ret.WD)
 
 	// User Home
 	home, err := util.OS.Home()
 	if nil != err {
-		logger.Error("Can't get user's home, please report this issue to developer", err)
-
-		os.Exit(-1)
+		return nil, errors.New("can't get user's home, please report this issue to developer: " + err.Error())
 	}
 
 	logger.Debugf("${user.home} [%s]", home)
 
 	// Playground Directory
-	Wide.Playground = strings.Replace(Wide.Playground, "${home}", home, 1)
+	ret.Playground = strings.Replace(ret.Playground, "${home}", home, 1)
 	if "" != confPlayground {
-		Wide.Playground = confPlayground
+		ret.Playground = confPlayground
 	}
 
 	// Users' workspaces Directory
-	Wide.UsersWorkspaces = strings.Replace(Wide.UsersWorkspaces, "${WD}", Wide.WD, 1)
-	Wide.UsersWorkspaces = strings.Replace(Wide.UsersWorkspaces, "${home}", home, 1)
+	ret.UsersWorkspaces = strings.Replace(ret.UsersWorkspaces, "${WD}", ret.WD, 1)
+	ret.UsersWorkspaces = strings.Replace(ret.UsersWorkspaces, "${home}", home, 1)
 	if "" != confUsersWorkspaces {
-		Wide.UsersWorkspaces = confUsersWorkspaces
+		ret.UsersWorkspaces = confUsersWorkspaces
 	}
-	Wide.UsersWorkspaces = filepath.Clean(Wide.UsersWorkspaces)
-
-	if !util.File.IsExist(Wide.Playground) {
-		if err := os.Mkdir(Wide.Playground, 0775); nil != err {
-			logger.Errorf("Create Playground [%s] error", err)
+	ret.UsersWorkspaces = filepath.Clean(ret.UsersWorkspaces)
 
-			os.Exit(-1)
+	if !util.File.IsExist(ret.Playground) {
+		if err := os.Mkdir(ret.Playground, 0775); nil != err {
+			return nil, errors.New("create Playground error: " + err.Error())
 		}
 	}
 
 	// IP
 	if "" != confIP {
-		Wide.IP = confIP
+		ret.IP = confIP
 	} else {
 		ip, err := util.Net.LocalIP()
 		if nil != err {
-			logger.Error(err)
-
-			os.Exit(-1)
+			return nil, err
 		}
 
 		logger.Debugf("${ip} [%s]", ip)
-		Wide.IP = strings.Replace(Wide.IP, "${ip}", ip, 1)
+		ret.IP = strings.Replace(ret.IP, "${ip}", ip, 1)
 	}
 
 	if "" != confPort {
-		Wide.Port = confPort
+		ret.Port = confPort
 	}
 
 	// Docker flag
 	Docker = confDocker
 
 	// Server
-	Wide.Server = strings.Replace(Wide.Server, "{IP}", Wide.IP, 1)
-	Wide.Server = strings.Replace(Wide.Server, "{Port}", Wide.Port, 1)
+	ret.Server = strings.Replace(ret.Server, "{IP}", ret.IP, 1)
+	ret.Server = strings.Replace(ret.Server, "{Port}", ret.Port, 1)
 	if "" != confServer {
-		Wide.Server = confServer
+		ret.Server = confServer
 	}
 
 	// Static Server
-	Wide.StaticServer = strings.Replace(Wide.StaticServer, "{IP}", Wide.IP, 1)
-	Wide.StaticServer = strings.Replace(Wide.StaticServer, "{Port}", Wide.Port, 1)
+	ret.StaticServer = strings.Replace(ret.StaticServer, "{IP}", ret.IP, 1)
+	ret.StaticServer = strings.Replace(ret.StaticServer, "{Port}", ret.Port, 1)
 	if "" != confStaticServer {
-		Wide.StaticServer = confStaticServer
+		ret.StaticServer = confStaticServer
 	}
 
 	// Context
 	if "" != confContext {
-		Wide.Context = confContext
+		ret.Context = confContext
 	}
 
 	time := strconv.FormatInt(time.Now().UnixNano(), 10)
 	logger.Debugf("${time} [%s]", time)
-	Wide.StaticResourceVersion = strings.Replace(Wide.StaticResourceVersion, "${time}", time, 1)
+	ret.StaticResourceVersion = strings.Replace(ret.StaticResourceVersion, "${time}", time, 1)
 
 	// Channel
-	Wide.Channel = strings.Replace(Wide.Channel, "{IP}", Wide.IP, 1)
-	Wide.Channel = strings.Replace(Wide.Channel, "{Port}", Wide.Port, 1)
+	ret.Channel = strings.Replace(ret.Channel, "{IP}", ret.IP, 1)
+	ret.Channel = strings.Replace(ret.Channel, "{Port}", ret.Port, 1)
 	if "" != confChannel {
-		Wide.Channel = confChannel
+		ret.Channel = confChannel
+	}
+
+	ret.EditorThemes = strings.Replace(ret.EditorThemes, "${WD}", ret.WD, 1)
+	ret.Snippets = strings.Replace(ret.Snippets, "${WD}", ret.WD, 1)
+	ret.StarterKit = strings.Replace(ret.StarterKit, "${WD}", ret.WD, 1)
+	initStarterKits(ret)
+
+	// Editor Modes, extensions are matched case-insensitively with the leading dot
+	editorModes := map[string]string{}
+	for ext, mode := range ret.EditorModes {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		mode = strings.TrimSpace(mode)
+		if "" == ext || "" == mode {
+			continue
+		}
+
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+
+		editorModes[ext] = mode
+	}
+	ret.EditorModes = editorModes
+
+	// TLS
+	ret.TLSCert = strings.Replace(ret.TLSCert, "${WD}", ret.WD, 1)
+	ret.TLSKey = strings.Replace(ret.TLSKey, "${WD}", ret.WD, 1)
+	if ret.IsTLS() {
+		// browsers refuse plain WebSocket and resources from an HTTPS page
+		if strings.HasPrefix(ret.Channel, "ws://") {
+			ret.Channel = "wss://" + strings.TrimPrefix(ret.Channel, "ws://")
+		}
+		if strings.HasPrefix(ret.StaticServer, "http://") {
+			ret.StaticServer = "https://" + strings.TrimPrefix(ret.StaticServer, "http://")
+		}
+	}
+
+	return ret, nil
+}
+
+// IsTLS checks whether the server serves HTTPS, that is both TLSCert and TLSKey are set.
+func (c *conf) IsTLS() bool {
+	return "" != c.TLSCert && "" != c.TLSKey
+}
+
+// GetEditorMode gets the editor mode configured for the extension of the specified file path, returns "" if not
+// configured (the editor detects the mode itself).
+func (c *conf) GetEditorMode(path string) string {
+	return c.EditorModes[strings.ToLower(filepath.Ext(path))]
+}
+
+// GetStarterKit gets the starter kit directory of new users of the specified role (empty means editor), returns ""
+// if no starter kit is configured (the hello world samples are generated).
+func (c *conf) GetStarterKit(role string) string {
+	if "" == role {
+		role = RoleEditor
+	}
+
+	if kit, ok := c.StarterKits[role]; ok {
+		return kit
+	}
+
+	return c.StarterKit
+}
+
+// initStarterKits normalizes the starter kits of roles, drops the ones of unknown roles or not existing directories,
+// and warns about roles falling back to the default starter kit.
+func initStarterKits(c *conf) {
+	if 0 == len(c.StarterKits) {
+		return
+	}
+
+	kits := map[string]string{}
+	for role, kit := range c.StarterKits {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if RoleAdmin != role && RoleEditor != role && RoleViewer != role {
+			logger.Warnf("Unknown role [%s] of starter kit [%s]", role, kit)
+
+			continue
+		}
+
+		kit = strings.Replace(strings.TrimSpace(kit), "${WD}", c.WD, 1)
+		if "" != kit && !util.File.IsDir(kit) {
+			logger.Errorf("Starter kit [%s] of role [%s] is not a directory", kit, role)
+
+			continue
+		}
+
+		kits[role] = kit
+	}
+	c.StarterKits = kits
+
+	fallback := c.StarterKit
+	if "" == fallback {
+		fallback = "the hello world samples"
+	}
+	for _, role := range []string{RoleAdmin, RoleEditor, RoleViewer} {
+		if _, ok := kits[role]; !ok {
+			logger.Warnf("Role [%s] has no starter kit, falls back to [%s]", role, fallback)
+		}
 	}
 }
 
@@ -309,7 +485,8 @@ func checkEnv() {
 	if nil != err {
 		event.EventQueue <- &event.Event{Code: event.EvtCodeGocodeNotFound}
 
-		logger.Warnf("Not found gocode [%s], please install it with this command: go get github.com/nsf/gocode", gocode)
+		logger.Warnf("Not found gocode [%s], please install it with this command: %s", gocode,
+			GetDevTool("gocode").InstallCmd())
 	}
 
 	ideStub := util.Go.GetExecutableInGOBIN("gotools")
@@ -318,13 +495,14 @@ func checkEnv() {
 	if nil != err {
 		event.EventQueue <- &event.Event{Code: event.EvtCodeIDEStubNotFound}
 
-		logger.Warnf("Not found gotools [%s], please install it with this command: go get github.com/visualfc/gotools", ideStub)
+		logger.Warnf("Not found gotools [%s], please install it with this command: %s", ideStub,
+			GetDevTool("gotools").InstallCmd())
 	}
 }
 
 // GetUserWorkspace gets workspace path with the specified username, returns "" if not found.
 func GetUserWorkspace(username string) string {
-	for _, user := range Users {
+	for _, user := range GetUsers() {
 		if user.Name == username {
 			return user.WorkspacePath()
 		}
@@ -333,9 +511,34 @@ func GetUserWorkspace(username string) string {
 	return ""
 }
 
+// GetGoRoot gets the GOROOT of the Go toolchain selected by the user specified by username, returns the GOROOT of
+// Wide if the user hasn't selected one or the selected one is not in Wide.GoToolchains any more.
+func GetGoRoot(username string) string {
+	user := GetUser(username)
+	if nil != user && "" != user.GoRoot {
+		for _, goRoot := range Wide.GoToolchains {
+			if goRoot == user.GoRoot {
+				return goRoot
+			}
+		}
+	}
+
+	return runtime.GOROOT()
+}
+
+// GetGoExecutable gets the path of the go command of the Go toolchain specified by goRoot.
+func GetGoExecutable(goRoot string) string {
+	executable := "go"
+	if util.OS.IsWindows() {
+		executable += ".exe"
+	}
+
+	return filepath.Join(goRoot, "bin", executable)
+}
+
 // GetGoFmt gets the path of Go format tool, returns "gofmt" if not found "goimports".
 func GetGoFmt(username string) string {
-	for _, user := range Users {
+	for _, user := range GetUsers() {
 		if user.Name == username {
 			switch user.GoFormat {
 			case "gofmt":
@@ -359,7 +562,7 @@ func GetUser(username string) *User {
 		return NewUser("playground", "", "", "")
 	}
 
-	for _, user := range Users {
+	for _, user := range GetUsers() {
 		if user.Name == username {
 			return user
 		}
@@ -370,7 +573,7 @@ func GetUser(username string) *User {
 
 // initCustomizedConfs initializes the user customized configurations.
 func initCustomizedConfs() {
-	for _, user := range Users {
+	for _, user := range GetUsers() {
 		UpdateCustomizedConf(user.Name)
 	}
 }
@@ -380,7 +583,7 @@ func initCustomizedConfs() {
 //  1. /static/user/{username}/style.css
 func UpdateCustomizedConf(username string) {
 	var u *User
-	for _, user := range Users { // maybe it is a beauty of the trade-off of the another world between design and implementation
+	for _, user := range GetUsers() { // maybe it is a beauty of the trade-off of the another world between design and implementation
 		if user.Name == username {
 			u = user
 		}
@@ -429,7 +632,7 @@ func UpdateCustomizedConf(username string) {
 func initWorkspaceDirs() {
 	paths := []string{}
 
-	for _, user := range Users {
+	for _, user := range GetUsers() {
 		paths = append(paths, filepath.SplitList(user.WorkspacePath())...)
 	}
 
@@ -464,23 +667,6 @@ func createDir(path string) {
 	}
 }
 
-// GetEditorThemes gets the names of editor themes.
-func GetEditorThemes() []string {
-	ret := []string{}
-
-	f, _ := os.Open("static/js/overwrite/codemirror" + "/theme")
-	names, _ := f.Readdirnames(-1)
-	f.Close()
-
-	for _, name := range names {
-		ret = append(ret, name[:strings.LastIndex(name, ".")])
-	}
-
-	sort.Strings(ret)
-
-	return ret
-}
-
 // GetThemes gets the names of themes.
 func GetThemes() []string {
 	ret := []string{}
@@ -0,0 +1,92 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package editor
+
+import (
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// Commands of collaborative editing messages.
+const (
+	collabEdit   = "collab-edit"   // buffer changes, {path, changes: [{from, to, text}]}
+	collabCursor = "collab-cursor" // cursor position, {path, line, ch}
+)
+
+// handleCollab broadcasts the specified collaborative editing message of the wide session specified by sid to the
+// other sessions joined the shares containing the file (see session.Share), the message is stamped with the sender.
+//
+// Edits aren't transformed, receivers apply them as they are, so concurrent edits of the same region may diverge
+// until the file is saved and reopened (the last writer wins).
+func handleCollab(sid, username, cmd string, args map[string]interface{}) {
+	if collabEdit != cmd && collabCursor != cmd {
+		return
+	}
+
+	path, _ := args["path"].(string)
+	if "" == path || !session.CanAccess(username, path) {
+		return
+	}
+
+	sids := session.GetShareSids(sid, path)
+	if 1 > len(sids) {
+		return
+	}
+
+	msg := map[string]interface{}{"cmd": cmd, "path": path, "sid": sid, "username": username}
+	switch cmd {
+	case collabEdit:
+		changes, ok := args["changes"].([]interface{})
+		if !ok {
+			return
+		}
+
+		msg["changes"] = changes
+	case collabCursor:
+		msg["line"] = args["line"]
+		msg["ch"] = args["ch"]
+	}
+
+	for _, s := range sids {
+		if wsChannel := session.EditorWS[s]; nil != wsChannel {
+			if err := wsChannel.WriteJSON(&msg); nil != err {
+				logger.Warn(err)
+			}
+		}
+	}
+}
+
+// handlePresence updates presence of the wide session specified by sid with the specified message
+// {files, path, line, ch}, files not accessible by the user are ignored.
+func handlePresence(sid, username string, args map[string]interface{}) {
+	presence := &session.Presence{Sid: sid, Username: username, Files: []string{}}
+
+	if files, ok := args["files"].([]interface{}); ok {
+		for _, f := range files {
+			if path, ok := f.(string); ok && session.CanAccess(username, path) {
+				presence.Files = append(presence.Files, path)
+			}
+		}
+	}
+
+	if path, _ := args["path"].(string); util.Str.Contains(path, presence.Files) {
+		presence.Path = path
+		line, _ := args["line"].(float64)
+		ch, _ := args["ch"].(float64)
+		presence.Line, presence.Ch = int(line), int(ch)
+	}
+
+	session.UpdatePresence(presence)
+}
@@ -0,0 +1,270 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package editor
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/util"
+)
+
+const (
+	completionDocMaxLen   = 160              // max length (in runes) of the doc summary of a completion candidate
+	packageDocCacheTTL    = 30 * time.Second // time to live of a package docs cache item
+	packageDocCacheMaxLen = 64               // max length of package docs cache
+)
+
+// completionCandidate represents a completion candidate.
+//
+// Class, Name, Type and Package are in the same format of gocode (-f=json), the others are filled only if the
+// enrichment is requested.
+type completionCandidate struct {
+	Class     string `json:"class"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Package   string `json:"package"`
+	Kind      string `json:"kind,omitempty"`      // func/var/type/const/package
+	Signature string `json:"signature,omitempty"` // such as "func Println(a ...interface{}) (n int, err error)"
+	Doc       string `json:"doc,omitempty"`       // one-line doc summary, truncated to completionDocMaxLen
+
+	Snippet    string `json:"snippet,omitempty"`    // template of a snippet candidate (class "snippet"), see conf.Snippet
+	ReplaceLen int    `json:"replaceLen,omitempty"` // length (in runes) replaced before the prefix, "x." of "x.nn"
+}
+
+// enrich fills the kind, signature and doc summary of the candidate with the specified doc text.
+func (c *completionCandidate) enrich(doc string) {
+	c.Kind = c.Class
+	c.Signature = completionSignature(c.Class, c.Name, c.Type)
+	c.Doc = docSummary(doc)
+}
+
+// marshalCompletions marshals the specified candidates in the same JSON format of gocode (-f=json), that is
+// [prefixLen, candidates].
+func marshalCompletions(prefixLen int, candidates []*completionCandidate) ([]byte, error) {
+	if 0 == len(candidates) {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal([]interface{}{prefixLen, candidates})
+}
+
+// parseCompletions parses the specified output in the JSON format of gocode (-f=json), an empty output ("[]") is
+// parsed as no candidates.
+func parseCompletions(output []byte) (prefixLen int, candidates []*completionCandidate, err error) {
+	var raw []json.RawMessage
+	if err = json.Unmarshal(output, &raw); nil != err || 2 > len(raw) {
+		return
+	}
+
+	if err = json.Unmarshal(raw[0], &prefixLen); nil != err {
+		return
+	}
+
+	err = json.Unmarshal(raw[1], &candidates)
+
+	return
+}
+
+// enrichGocodeOutput enriches the candidates of the specified gocode output, the doc of a candidate is read from
+// sources of the package it's declared in. Returns the output as it is if it can't be parsed.
+func enrichGocodeOutput(username, path string, output []byte) []byte {
+	prefixLen, candidates, err := parseCompletions(output)
+	if nil != err || 0 == len(candidates) {
+		return output
+	}
+
+	for _, c := range candidates {
+		doc := ""
+		if "package" != c.Class {
+			doc = packageDocs.get(gocodePackageDir(username, path, c.Package))[c.Name]
+		}
+
+		c.enrich(doc)
+	}
+
+	ret, err := marshalCompletions(prefixLen, candidates)
+	if nil != err {
+		logger.Error(err)
+
+		return output
+	}
+
+	return ret
+}
+
+// gocodePackageDir gets the directory of the package with the specified import path reported by gocode, the
+// package of the file specified by path is used if the import path is empty. Returns "" if not found.
+//
+// The package is looked up in GOROOT and the workspaces of the user specified by username.
+func gocodePackageDir(username, path, importPath string) string {
+	if "" == importPath {
+		return filepath.Dir(path)
+	}
+
+	roots := append([]string{conf.GetGoRoot(username)}, filepath.SplitList(conf.GetUserWorkspace(username))...)
+	for _, root := range roots {
+		dir := filepath.Join(root, "src", filepath.FromSlash(importPath))
+		if util.File.IsDir(dir) {
+			return dir
+		}
+	}
+
+	return ""
+}
+
+// completionSignature gets the declaration signature of a candidate with the specified class, name and type.
+func completionSignature(class, name, typ string) string {
+	switch class {
+	case "func":
+		if strings.HasPrefix(typ, "func") {
+			return "func " + name + strings.TrimPrefix(typ, "func")
+		}
+
+		return strings.TrimSpace("func " + name + " " + typ)
+	case "package":
+		return "package " + name
+	default:
+		return strings.TrimSpace(class + " " + name + " " + typ)
+	}
+}
+
+// docSummary gets the first sentence of the specified doc text in one line, truncated to completionDocMaxLen.
+func docSummary(doc string) string {
+	ret := strings.Join(strings.Fields(doc), " ")
+	if i := strings.Index(ret, ". "); 0 <= i {
+		ret = ret[:i+1]
+	}
+
+	if r := []rune(ret); completionDocMaxLen < len(r) {
+		ret = string(r[:completionDocMaxLen-3]) + "..."
+	}
+
+	return ret
+}
+
+// packageDocCacheItem represents a package docs cache item.
+type packageDocCacheItem struct {
+	docs    map[string]string // <declaration name, doc text>
+	expired time.Time         // expire time
+}
+
+// packageDocCaches represents package docs caches.
+type packageDocCaches struct {
+	mutex sync.Mutex
+	items map[string]*packageDocCacheItem
+}
+
+// Package docs caches, <package directory, docs>.
+var packageDocs = &packageDocCaches{items: map[string]*packageDocCacheItem{}}
+
+// get gets the docs of the declarations in the package specified by dir, loads them if not cached or expired.
+func (c *packageDocCaches) get(dir string) map[string]string {
+	if "" == dir {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	if item := c.items[dir]; nil != item && now.Before(item.expired) {
+		return item.docs
+	}
+
+	if len(c.items) >= packageDocCacheMaxLen {
+		for k, item := range c.items {
+			if now.After(item.expired) {
+				delete(c.items, k)
+			}
+		}
+
+		if len(c.items) >= packageDocCacheMaxLen {
+			c.items = map[string]*packageDocCacheItem{}
+		}
+	}
+
+	docs := loadPackageDocs(dir)
+	c.items[dir] = &packageDocCacheItem{docs: docs, expired: now.Add(packageDocCacheTTL)}
+
+	return docs
+}
+
+// loadPackageDocs loads docs of the declarations in the package specified by dir.
+//
+// Methods are keyed by their names too, a package level declaration wins if they have the same name.
+func loadPackageDocs(dir string) map[string]string {
+	ret := map[string]string{}
+
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+
+	methods := map[string]string{}
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ParseComments)
+		if nil != err {
+			logger.Debugf("Parses file [%s] failed: %s", path, err)
+
+			continue
+		}
+
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if nil != d.Recv {
+					methods[d.Name.Name] = d.Doc.Text()
+				} else {
+					ret[d.Name.Name] = d.Doc.Text()
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					doc := d.Doc
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						if nil != s.Doc {
+							doc = s.Doc
+						}
+						ret[s.Name.Name] = doc.Text()
+					case *ast.ValueSpec:
+						if nil != s.Doc {
+							doc = s.Doc
+						}
+						for _, name := range s.Names {
+							ret[name.Name] = doc.Text()
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for name, doc := range methods {
+		if _, ok := ret[name]; !ok {
+			ret[name] = doc
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,466 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package editor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/output"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+const (
+	// diagnosticsDelay is the delay of diagnosing, a newer request of the same file arriving in the delay supersedes
+	// the older one.
+	diagnosticsDelay = 300 * time.Millisecond
+
+	// diagnosticsImportsTTL is the duration imported packages of a directory are cached, changes of them (such as
+	// editing another package of the workspace) are picked up after it expired.
+	diagnosticsImportsTTL = time.Minute
+)
+
+// diagnostic represents a problem of the code.
+type diagnostic struct {
+	Line     int    `json:"line"`     // line number, starts with 0
+	Ch       int    `json:"ch"`       // column number, starts with 0
+	Severity string `json:"severity"` // error/warning
+	Msg      string `json:"msg"`
+}
+
+// Latest diagnostics request sequences.
+//
+// <username:path, seq>
+var diagnosticsSeqs = map[string]uint64{}
+
+// Exclusive lock.
+var diagnosticsMutex sync.Mutex
+
+// diagnosticsImporter represents the importer of type-checking the packages in a directory of a user, imports are
+// resolved to export data by go list with the environment (GOPATH, GOROOT and go.env) of the user.
+type diagnosticsImporter struct {
+	username string
+	dir      string
+	ctx      *build.Context // GOOS, GOARCH and build tags of resolving
+	created  time.Time
+
+	mutex    sync.Mutex        // exclusive lock of the fields below, held while type-checking
+	fset     *token.FileSet    // positions of the imported packages
+	exports  map[string]string // <import path, export data file>
+	errs     map[string]string // <import path, error of resolving>
+	importer types.Importer    // gc importer reading the export data, it caches the imported packages
+}
+
+// Cached importers of type-checking.
+//
+// <username:dir:GOOS/GOARCH:tags, *diagnosticsImporter>
+var diagnosticsImporters = map[string]*diagnosticsImporter{}
+
+// Exclusive lock.
+var diagnosticsImportersMutex sync.Mutex
+
+// DiagnosticsHandler handles request of diagnosing the unsaved code of a go file.
+//
+// The code is parsed by go/parser, and type-checked by go/types with the other files of the package if argument
+// "types" is true. Requests are debounced per file, a superseded request returns with data "cancelled" set to true.
+func DiagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	path := args["path"].(string)
+	if util.Go.IsAPI(path) || !session.CanAccess(username, path) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	code := args["code"].(string)
+	typeCheck, _ := args["types"].(bool)
+
+	data := map[string]interface{}{"cancelled": false}
+	result.Data = data
+
+	key := username + ":" + path
+	diagnosticsMutex.Lock()
+	diagnosticsSeqs[key]++
+	seq := diagnosticsSeqs[key]
+	diagnosticsMutex.Unlock()
+
+	superseded := func() bool {
+		diagnosticsMutex.Lock()
+		defer diagnosticsMutex.Unlock()
+
+		return seq != diagnosticsSeqs[key]
+	}
+
+	defer func() {
+		diagnosticsMutex.Lock()
+		if seq == diagnosticsSeqs[key] {
+			delete(diagnosticsSeqs, key)
+		}
+		diagnosticsMutex.Unlock()
+	}()
+
+	select {
+	case <-r.Context().Done(): // client gave up
+		data["cancelled"] = true
+
+		return
+	case <-time.After(diagnosticsDelay):
+	}
+
+	if superseded() {
+		data["cancelled"] = true
+
+		return
+	}
+
+	diagnostics := diagnose(r.Context(), username, path, code, typeCheck, superseded)
+	if superseded() {
+		data["cancelled"] = true
+
+		return
+	}
+
+	data["diagnostics"] = diagnostics
+}
+
+// diagnose diagnoses the specified code of the go file specified by path of the user specified by username,
+// type-checking is skipped if the specified context is done or the specified cancelled function returns true.
+//
+// Only the files of the package matching the build constraints of the user (see getBuildContext) are type-checked
+// together, type-checking is skipped if the file itself doesn't match (foo_windows.go on Linux for example).
+func diagnose(ctx context.Context, username, path, code string, typeCheck bool, cancelled func() bool) []*diagnostic {
+	ret := []*diagnostic{}
+	lines := strings.Split(code, "\n")
+
+	appendDiagnostic := func(pos token.Position, severity, msg string) {
+		if line, ch, ok := toLineCh(lines, pos); ok {
+			ret = append(ret, &diagnostic{Line: line, Ch: ch, Severity: severity, Msg: msg})
+		}
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, code, parser.AllErrors)
+	if nil != err {
+		if errs, ok := err.(scanner.ErrorList); ok {
+			for _, e := range errs {
+				appendDiagnostic(e.Pos, "error", e.Msg)
+			}
+		}
+
+		return ret
+	}
+
+	if !typeCheck || nil != ctx.Err() || cancelled() {
+		return ret
+	}
+
+	dir := filepath.Dir(path)
+	buildCtx := getBuildContext(username, dir, path, code)
+	if match, err := buildCtx.MatchFile(dir, filepath.Base(path)); nil != err || !match {
+		return ret
+	}
+
+	files := []*ast.File{f}
+	others, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, other := range others {
+		if filepath.Clean(other) == filepath.Clean(path) ||
+			(strings.HasSuffix(other, "_test.go") && !strings.HasSuffix(path, "_test.go")) {
+			continue
+		}
+
+		if match, err := buildCtx.MatchFile(dir, filepath.Base(other)); nil != err || !match {
+			continue
+		}
+
+		otherFile, err := parser.ParseFile(fset, other, nil, 0)
+		if nil != err || otherFile.Name.Name != f.Name.Name {
+			continue
+		}
+
+		files = append(files, otherFile)
+	}
+
+	imp := getDiagnosticsImporter(username, dir, buildCtx)
+	imp.mutex.Lock()
+	defer imp.mutex.Unlock()
+
+	if err := imp.resolve(ctx, files); nil != err || cancelled() { // cancelled or too many processes
+		return ret
+	}
+
+	config := types.Config{
+		Importer:    imp,
+		FakeImportC: true,
+		Error: func(err error) {
+			typeErr, ok := err.(types.Error)
+			if !ok {
+				return
+			}
+
+			pos := fset.Position(typeErr.Pos)
+			if filepath.Clean(pos.Filename) != filepath.Clean(path) {
+				return
+			}
+
+			severity := "error"
+			if typeErr.Soft || strings.Contains(typeErr.Msg, "could not import") { // build reports it as well
+				severity = "warning"
+			}
+
+			appendDiagnostic(pos, severity, typeErr.Msg)
+		},
+	}
+	config.Check(f.Name.Name, fset, files, nil)
+
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Line < ret[j].Line })
+
+	return ret
+}
+
+// getBuildContext gets the build context of matching files in the specified directory for the user specified by
+// username: GOOS and GOARCH are the ones of setCmdEnv, overridden by the environment of the build profile last used
+// for the directory (see output.selectBuildProfile) with its build tags. The file specified by path is read from the
+// specified code.
+func getBuildContext(username, dir, path, code string) *build.Context {
+	ret := build.Default
+	ret.GOROOT = conf.GetGoRoot(username)
+	ret.GOPATH = conf.GetUserWorkspace(username)
+	ret.GOOS, ret.GOARCH = runtime.GOOS, runtime.GOARCH
+	ret.BuildTags = nil
+
+	if user := conf.GetUser(username); nil != user {
+		if profile := user.GetBuildProfile(user.GetBuildProfileUse(dir)); nil != profile {
+			if goos := profile.Env["GOOS"]; "" != goos {
+				ret.GOOS = goos
+			}
+			if goarch := profile.Env["GOARCH"]; "" != goarch {
+				ret.GOARCH = goarch
+			}
+			ret.BuildTags = profile.Tags
+		}
+	}
+
+	path = filepath.Clean(path)
+	ret.OpenFile = func(name string) (io.ReadCloser, error) {
+		if filepath.Clean(name) == path {
+			return ioutil.NopCloser(strings.NewReader(code)), nil
+		}
+
+		return os.Open(name)
+	}
+
+	return &ret
+}
+
+// getDiagnosticsImporter gets the importer of type-checking the packages in the specified directory of the user
+// specified by username with the specified build context, creates a new one if not cached or expired.
+func getDiagnosticsImporter(username, dir string, ctx *build.Context) *diagnosticsImporter {
+	key := username + ":" + dir + ":" + ctx.GOOS + "/" + ctx.GOARCH + ":" + strings.Join(ctx.BuildTags, ",")
+
+	diagnosticsImportersMutex.Lock()
+	defer diagnosticsImportersMutex.Unlock()
+
+	for k, imp := range diagnosticsImporters {
+		if time.Since(imp.created) > diagnosticsImportsTTL {
+			delete(diagnosticsImporters, k)
+		}
+	}
+
+	ret := diagnosticsImporters[key]
+	if nil == ret {
+		resolveCtx := *ctx
+		resolveCtx.OpenFile = nil // doesn't keep the code
+
+		ret = &diagnosticsImporter{username: username, dir: dir, ctx: &resolveCtx, created: time.Now(),
+			fset: token.NewFileSet(), exports: map[string]string{}, errs: map[string]string{}}
+		ret.importer = importer.ForCompiler(ret.fset, "gc", ret.lookup)
+		diagnosticsImporters[key] = ret
+	}
+
+	return ret
+}
+
+// resolve resolves the imports of the specified files which are not resolved yet (with their dependencies) to export
+// data by go list, returns an error if the specified context is done or the process limit of the user is reached (see
+// output.AcquireProcSlot), go list compiles the dependencies.
+//
+// The caller should hold the mutex.
+func (imp *diagnosticsImporter) resolve(ctx context.Context, files []*ast.File) error {
+	var paths []string
+	for _, f := range files {
+		for _, spec := range f.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if nil != err || "C" == path || "unsafe" == path {
+				continue
+			}
+
+			if _, ok := imp.exports[path]; !ok {
+				if _, ok := imp.errs[path]; !ok {
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+
+	if 1 > len(paths) {
+		return nil
+	}
+
+	release, err := output.AcquireProcSlot(imp.username)
+	if nil != err {
+		logger.Debugf("Skips resolving imports of [%s] for user [%s]: %s", imp.dir, imp.username, err)
+
+		return err
+	}
+	defer release()
+
+	args := []string{"list", "-e", "-export", "-deps", "-json"}
+	if 0 < len(imp.ctx.BuildTags) {
+		args = append(args, "-tags", strings.Join(imp.ctx.BuildTags, ","))
+	}
+	args = append(append(args, "--"), paths...)
+
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = imp.dir
+	setCmdEnv(cmd, imp.username)
+	cmd.Path = conf.GetGoExecutable(conf.GetGoRoot(imp.username))
+	cmd.Env = append(cmd.Env, "HOME="+os.Getenv("HOME"), "GO111MODULE=auto", "GOOS="+imp.ctx.GOOS,
+		"GOARCH="+imp.ctx.GOARCH)
+
+	out, err := cmd.Output()
+	if nil != ctx.Err() {
+		return ctx.Err()
+	}
+	if nil != err && 1 > len(out) {
+		logger.Warnf("Resolves imports %v of [%s] for user [%s] failed: %s", paths, imp.dir, imp.username, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for decoder.More() {
+		pkg := struct {
+			ImportPath string
+			Export     string
+			Error      *struct{ Err string }
+		}{}
+		if err := decoder.Decode(&pkg); nil != err {
+			break
+		}
+
+		if "" != pkg.Export {
+			imp.exports[pkg.ImportPath] = pkg.Export
+		} else if nil != pkg.Error {
+			imp.errs[pkg.ImportPath] = pkg.Error.Err
+		}
+	}
+
+	for _, path := range paths {
+		if _, ok := imp.exports[path]; !ok {
+			if _, ok := imp.errs[path]; !ok {
+				imp.errs[path] = "can't find package"
+			}
+		}
+	}
+
+	return nil
+}
+
+// Import imports the package specified by path from its export data resolved by resolve.
+func (imp *diagnosticsImporter) Import(path string) (*types.Package, error) {
+	if msg, ok := imp.errs[path]; ok {
+		return nil, errors.New(msg)
+	}
+
+	return imp.importer.Import(path)
+}
+
+// lookup opens the export data of the package specified by path.
+func (imp *diagnosticsImporter) lookup(path string) (io.ReadCloser, error) {
+	export, ok := imp.exports[path]
+	if !ok {
+		return nil, errors.New("can't find export data of package [" + path + "]")
+	}
+
+	return os.Open(export)
+}
+
+// toLineCh converts the specified position into 0-based line and column in the specified lines of code, returns false
+// if the line is out of range.
+//
+// The column counts UTF-16 code units as CodeMirror does, so a character out of the BMP (such as an emoji) counts 2.
+func toLineCh(lines []string, pos token.Position) (line, ch int, ok bool) {
+	line, ch = pos.Line-1, pos.Column-1
+	if 0 > line || line >= len(lines) {
+		return 0, 0, false
+	}
+
+	if 0 > ch {
+		ch = 0
+	}
+	if ch > len(lines[line]) {
+		ch = len(lines[line])
+	}
+
+	units := 0 // byte offset to UTF-16 offset
+	for _, r := range lines[line][:ch] {
+		if 0x10000 <= r {
+			units += 2
+		} else {
+			units++
+		}
+	}
+
+	return line, units, true
+}
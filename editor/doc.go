@@ -0,0 +1,216 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package editor
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// HoverDocHandler handles request of getting documentation of the symbol under the cursor.
+//
+// The symbol is resolved to its declaration (standard library included), then the declaration source file is parsed
+// to get the signature and the doc comment, like go doc does. The unsaved buffer (argument "code") overlays the file in
+// memory, the file isn't written.
+func HoverDocHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+	locale := conf.GetUser(username).Locale
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	path := args["path"].(string)
+	if util.Go.IsAPI(path) || !session.CanAccess(username, path) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	code := args["code"].(string) // the unsaved buffer, overlays the file without writing it
+	line := int(args["cursorLine"].(float64))
+	ch := int(args["cursorCh"].(float64))
+
+	declPath, declLine, declCh, err := findDeclaration(username, path, code, line, ch)
+	if nil != err {
+		logger.Warnf("Finds declaration for user [%s] failed: %s", username, err)
+	}
+
+	data := map[string]interface{}{"signature": "", "doc": ""}
+	result.Data = data
+
+	if "" != declPath {
+		var src interface{} // nil means reading the declaration file
+		if filepath.Clean(filepath.FromSlash(declPath)) == filepath.Clean(filepath.FromSlash(path)) {
+			src = code
+		}
+
+		signature, doc := getDeclDoc(declPath, src, declLine, declCh)
+		data["path"] = declPath
+		data["signature"] = signature
+		data["doc"] = doc
+	}
+
+	if "" == data["doc"] {
+		result.Msg = i18n.Get(locale, "no_doc").(string)
+	}
+}
+
+// getDeclDoc gets the signature and doc comment of the declaration at the specified position (line and ch are both
+// one-based) in the Go source file specified by path, the source is read from the file if the specified src is nil.
+//
+// Top-level declarations and fields (struct fields, interface methods) are supported, returns empty strings if not
+// found.
+func getDeclDoc(path string, src interface{}, line, ch int) (signature, doc string) {
+	fset := token.NewFileSet()
+
+	// a file with syntax errors may be partially parsed
+	f, _ := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if nil == f {
+		return "", ""
+	}
+
+	at := func(ident *ast.Ident) bool {
+		pos := fset.Position(ident.Pos())
+
+		return pos.Line == line && pos.Column <= ch && ch <= pos.Column+len(ident.Name)
+	}
+
+	printNode := func(node interface{}) string {
+		buf := &bytes.Buffer{}
+		printer.Fprint(buf, fset, node)
+
+		return buf.String()
+	}
+
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !at(d.Name) {
+				continue
+			}
+
+			fn := *d
+			fn.Body = nil
+			fn.Doc = nil
+
+			return printNode(&fn), d.Doc.Text()
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				specDoc := d.Doc
+				if d.Lparen.IsValid() { // grouped declaration, uses doc of the spec itself
+					specDoc = nil
+				}
+
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if !at(s.Name) {
+						continue
+					}
+
+					if nil != s.Doc {
+						specDoc = s.Doc
+					}
+
+					return "type " + printNode(s), specDoc.Text()
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						if !at(name) {
+							continue
+						}
+
+						if nil != s.Doc {
+							specDoc = s.Doc
+						} else if nil == specDoc {
+							specDoc = s.Comment
+						}
+
+						spec := *s
+						spec.Doc = nil
+						spec.Comment = nil
+
+						return d.Tok.String() + " " + printNode(&spec), specDoc.Text()
+					}
+				}
+			}
+		}
+	}
+
+	// struct fields and interface methods
+	ast.Inspect(f, func(node ast.Node) bool {
+		if "" != signature {
+			return false
+		}
+
+		field, ok := node.(*ast.Field)
+		if !ok {
+			return true
+		}
+
+		for _, name := range field.Names {
+			if !at(name) {
+				continue
+			}
+
+			fieldDoc := field.Doc
+			if nil == fieldDoc {
+				fieldDoc = field.Comment
+			}
+
+			names := []string{}
+			for _, n := range field.Names {
+				names = append(names, n.Name)
+			}
+
+			if fn, ok := field.Type.(*ast.FuncType); ok { // interface method
+				signature = name.Name + strings.TrimPrefix(printNode(fn), "func")
+			} else {
+				signature = strings.Join(names, ", ") + " " + printNode(field.Type)
+			}
+			doc = fieldDoc.Text()
+
+			return false
+		}
+
+		return true
+	})
+
+	return signature, doc
+}
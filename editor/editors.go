@@ -17,7 +17,12 @@ package editor
 
 import (
 	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
@@ -25,6 +30,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/b3log/wide/conf"
@@ -35,11 +41,90 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+const (
+	autocompleteTimeout     = 3 * time.Second // timeout of gocode
+	autocompleteCacheTTL    = 5 * time.Second // time to live of an autocomplete cache item
+	autocompleteCacheMaxLen = 512             // max length of autocomplete cache
+)
+
 // Logger.
 var logger = log.NewLogger(os.Stdout)
 
+// autocompleteCacheItem represents an autocomplete result cache item.
+type autocompleteCacheItem struct {
+	output  []byte    // gocode output
+	expired time.Time // expire time
+}
+
+// autocompleteCaches represents autocomplete result caches.
+type autocompleteCaches struct {
+	mutex sync.Mutex
+	items map[string]*autocompleteCacheItem
+}
+
+// Autocomplete result caches.
+var autocompleteCache = &autocompleteCaches{items: map[string]*autocompleteCacheItem{}}
+
+// get gets the cached output with the specified key, returns nil if not found or expired.
+func (c *autocompleteCaches) get(key string) []byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	item := c.items[key]
+	if nil == item {
+		return nil
+	}
+
+	if time.Now().After(item.expired) {
+		delete(c.items, key)
+
+		return nil
+	}
+
+	return item.output
+}
+
+// put caches the specified output with the specified key.
+func (c *autocompleteCaches) put(key string, output []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+
+	if len(c.items) >= autocompleteCacheMaxLen {
+		for k, item := range c.items {
+			if now.After(item.expired) {
+				delete(c.items, k)
+			}
+		}
+
+		if len(c.items) >= autocompleteCacheMaxLen {
+			c.items = map[string]*autocompleteCacheItem{}
+		}
+	}
+
+	c.items[key] = &autocompleteCacheItem{output: output, expired: now.Add(autocompleteCacheTTL)}
+}
+
+// getAutocompleteCacheKey gets autocomplete cache key with the specified username, file path, code and cursor offset.
+//
+// Only the code before the cursor offset is used, so typing after the cursor will not invalidate the cache.
+func getAutocompleteCacheKey(username, path, code string, offset int) string {
+	if offset > len(code) {
+		offset = len(code)
+	}
+
+	hash := md5.New()
+	hash.Write([]byte(code[:offset]))
+
+	return username + ":" + path + ":" + strconv.Itoa(offset) + ":" + hex.EncodeToString(hash.Sum(nil))
+}
+
 // WSHandler handles request of creating editor channel.
-// XXX: NOT used at present
+//
+// Messages with cmd "collab-*" are edits and cursors of shared sessions (see handleCollab), messages with cmd
+// "presence" are open files and cursors of the session (see handlePresence), the others are autocompletion requests
+// (XXX: NOT used at present).
 func WSHandler(w http.ResponseWriter, r *http.Request) {
 	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
 	if httpSession.IsNew {
@@ -49,6 +134,10 @@ func WSHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	sid := httpSession.Values["id"].(string)
+	if sids := r.URL.Query()["sid"]; 0 < len(sids) {
+		sid = sids[0] // wide session id
+	}
+	username := httpSession.Values["username"].(string)
 
 	conn, _ := websocket.Upgrade(w, r, nil, 1024, 1024)
 	editorChan := util.WSChannel{Sid: sid, Conn: conn, Request: r, Time: time.Now()}
@@ -63,12 +152,22 @@ func WSHandler(w http.ResponseWriter, r *http.Request) {
 
 	logger.Tracef("Open a new [Editor] with session [%s], %d", sid, len(session.EditorWS))
 
-	args := map[string]interface{}{}
 	for {
+		args := map[string]interface{}{}
 		if err := session.EditorWS[sid].ReadJSON(&args); err != nil {
 			return
 		}
 
+		if cmd, _ := args["cmd"].(string); strings.HasPrefix(cmd, "collab-") {
+			handleCollab(sid, username, cmd, args)
+
+			continue
+		} else if "presence" == cmd {
+			handlePresence(sid, username, args)
+
+			continue
+		}
+
 		code := args["code"].(string)
 		line := int(args["cursorLine"].(float64))
 		ch := int(args["cursorCh"].(float64))
@@ -101,6 +200,10 @@ func WSHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // AutocompleteHandler handles request of code autocompletion.
+//
+// The response is in the JSON format of gocode (-f=json), each candidate is enriched with its kind, signature and
+// one-line doc summary (see completionCandidate) unless the request argument "enrich" is false. Snippets whose
+// triggers match the identifier before the cursor are appended (see conf.Snippet).
 func AutocompleteHandler(w http.ResponseWriter, r *http.Request) {
 	var args map[string]interface{}
 
@@ -120,32 +223,66 @@ func AutocompleteHandler(w http.ResponseWriter, r *http.Request) {
 	username := session.Values["username"].(string)
 
 	path := args["path"].(string)
+	code := args["code"].(string)
+	if err := writeBuffer(r, username, path, code); nil != err {
+		if errBufferForbidden == err {
+			http.Error(w, "Forbidden", http.StatusForbidden)
 
-	fout, err := os.Create(path)
+			return
+		}
 
-	if nil != err {
 		logger.Error(err)
 		http.Error(w, err.Error(), 500)
 
 		return
 	}
 
-	code := args["code"].(string)
-	fout.WriteString(code)
+	line := int(args["cursorLine"].(float64))
+	ch := int(args["cursorCh"].(float64))
 
-	if err := fout.Close(); nil != err {
-		logger.Error(err)
-		http.Error(w, err.Error(), 500)
+	offset := getCursorOffset(code, line, ch)
+
+	logger.Tracef("offset: %d", offset)
+
+	enrich := true
+	if v, ok := args["enrich"].(bool); ok {
+		enrich = v
+	}
+
+	cacheKey := getAutocompleteCacheKey(username, path, code, offset)
+	if enrich {
+		cacheKey += ":enrich"
+	}
+	if output := autocompleteCache.get(cacheKey); nil != output {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(output)
 
 		return
 	}
 
-	line := int(args["cursorLine"].(float64))
-	ch := int(args["cursorCh"].(float64))
+	if conf.Wide.Gopls {
+		output, err := goplsAutocomplete(username, path, code, line, ch, enrich)
+		if errGoplsTimeout == err {
+			logger.Warnf("Autocomplete timeout [%s] for user [%s]", goplsTimeout, username)
 
-	offset := getCursorOffset(code, line, ch)
+			output, err = []byte("[]"), nil
+		}
 
-	logger.Tracef("offset: %d", offset)
+		if nil != err {
+			logger.Error(err)
+			http.Error(w, err.Error(), 500)
+
+			return
+		}
+
+		output = appendSnippets(output, path, code, line, ch, offset)
+		autocompleteCache.put(cacheKey, output)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(output)
+
+		return
+	}
 
 	userWorkspace := conf.GetUserWorkspace(username)
 	workspaces := filepath.SplitList(userWorkspace)
@@ -158,14 +295,32 @@ func AutocompleteHandler(w http.ResponseWriter, r *http.Request) {
 
 	logger.Tracef("gocode set lib-path [%s]", libPath)
 
+	ctx, cancel := context.WithTimeout(r.Context(), autocompleteTimeout)
+	defer cancel()
+
 	// FIXME: using gocode set lib-path has some issues while accrossing workspaces
-	gocode := util.Go.GetExecutableInGOBIN("gocode")
-	exec.Command(gocode, []string{"set", "lib-path", libPath}...).Run()
+	gocode := conf.GetDevTool("gocode").Path()
+	if "" == gocode {
+		http.Error(w, conf.GetDevToolHint(conf.GetUser(username).Locale, "gocode"), 500)
+
+		return
+	}
+	exec.CommandContext(ctx, gocode, []string{"set", "lib-path", libPath}...).Run()
 
 	argv := []string{"-f=json", "--in=" + path, "autocomplete", strconv.Itoa(offset)}
-	cmd := exec.Command(gocode, argv...)
+	cmd := exec.CommandContext(ctx, gocode, argv...)
 
 	output, err := cmd.CombinedOutput()
+	if context.DeadlineExceeded == ctx.Err() {
+		logger.Warnf("Autocomplete timeout [%s] for user [%s]", autocompleteTimeout, username)
+
+		// returns snippets only to keep the editor responsive
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(appendSnippets([]byte("[]"), path, code, line, ch, offset))
+
+		return
+	}
+
 	if nil != err {
 		logger.Error(err)
 		http.Error(w, err.Error(), 500)
@@ -173,6 +328,13 @@ func AutocompleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if enrich {
+		output = enrichGocodeOutput(username, path, output)
+	}
+	output = appendSnippets(output, path, code, line, ch, offset)
+
+	autocompleteCache.put(cacheKey, output)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(output)
 }
@@ -197,19 +359,14 @@ func GetExprInfoHandler(w http.ResponseWriter, r *http.Request) {
 	curDir := filepath.Dir(path)
 	filename := filepath.Base(path)
 
-	fout, err := os.Create(path)
-
-	if nil != err {
-		logger.Error(err)
-		result.Succ = false
-
-		return
-	}
-
 	code := args["code"].(string)
-	fout.WriteString(code)
+	if err := writeBuffer(r, username, path, code); nil != err {
+		if errBufferForbidden == err {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
 
-	if err := fout.Close(); nil != err {
 		logger.Error(err)
 		result.Succ = false
 
@@ -223,9 +380,26 @@ func GetExprInfoHandler(w http.ResponseWriter, r *http.Request) {
 
 	logger.Tracef("offset [%d]", offset)
 
+	if conf.Wide.Gopls {
+		exprInfo, err := goplsExprInfo(username, path, code, line, ch)
+		if nil != err {
+			logger.Error(err)
+		}
+
+		if "" == exprInfo {
+			result.Succ = false
+
+			return
+		}
+
+		result.Data = exprInfo
+
+		return
+	}
+
 	ideStub := util.Go.GetExecutableInGOBIN("gotools")
 	argv := []string{"types", "-pos", filename + ":" + strconv.Itoa(offset), "-info", "."}
-	cmd := exec.Command(ideStub, argv...)
+	cmd := exec.CommandContext(r.Context(), ideStub, argv...)
 	cmd.Dir = curDir
 
 	setCmdEnv(cmd, username)
@@ -253,13 +427,13 @@ func FindDeclarationHandler(w http.ResponseWriter, r *http.Request) {
 	result := util.NewResult()
 	defer util.RetResult(w, r, result)
 
-	session, _ := session.HTTPSession.Get(r, "wide-session")
-	if session.IsNew {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 
 		return
 	}
-	username := session.Values["username"].(string)
+	username := httpSession.Values["username"].(string)
 
 	var args map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
@@ -270,70 +444,95 @@ func FindDeclarationHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	path := args["path"].(string)
-	curDir := filepath.Dir(path)
-	filename := filepath.Base(path)
 
-	fout, err := os.Create(path)
+	code := args["code"].(string)
+	if err := writeBuffer(r, username, path, code); nil != err {
+		if errBufferForbidden == err {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
 
-	if nil != err {
 		logger.Error(err)
 		result.Succ = false
 
 		return
 	}
 
-	code := args["code"].(string)
-	fout.WriteString(code)
+	line := int(args["cursorLine"].(float64))
+	ch := int(args["cursorCh"].(float64))
 
-	if err := fout.Close(); nil != err {
+	declPath, cursorLine, cursorCh, err := findDeclaration(username, path, code, line, ch)
+	if nil != err {
 		logger.Error(err)
+		http.Error(w, err.Error(), 500)
+
+		return
+	}
+
+	if "" == declPath {
 		result.Succ = false
 
 		return
 	}
 
-	line := int(args["cursorLine"].(float64))
-	ch := int(args["cursorCh"].(float64))
+	if !filepath.IsAbs(declPath) {
+		declPath = filepath.Join(filepath.Dir(path), declPath)
+	}
+
+	if !session.CanAccess(username, declPath) {
+		// declaration in $GOROOT or the module cache, allows the user to open it in read-only mode
+		file.AllowReadOnly(username, declPath)
+	}
+
+	data := map[string]interface{}{}
+	result.Data = &data
+
+	data["path"] = filepath.ToSlash(declPath)
+	data["cursorLine"] = cursorLine
+	data["cursorCh"] = cursorCh
+}
+
+// findDeclaration finds the declaration of the symbol at the specified cursor (line and ch are both zero-based) in
+// the file specified by path, the returned cursor line and ch are both one-based. Returns an empty declaration path
+// if not found.
+//
+// The specified code (the unsaved buffer) overlays the file in memory, the file isn't read.
+func findDeclaration(username, path, code string, line, ch int) (declPath string, cursorLine, cursorCh int, err error) {
+	if conf.Wide.Gopls {
+		return goplsDefinition(username, path, code, line, ch)
+	}
 
 	offset := getCursorOffset(code, line, ch)
 
 	logger.Tracef("offset [%d]", offset)
 
 	ideStub := util.Go.GetExecutableInGOBIN("gotools")
-	argv := []string{"types", "-pos", filename + ":" + strconv.Itoa(offset), "-def", "."}
+	argv := []string{"types", "-pos", filepath.Base(path) + ":" + strconv.Itoa(offset), "-stdin", "-def", "."}
 	cmd := exec.Command(ideStub, argv...)
-	cmd.Dir = curDir
+	cmd.Dir = filepath.Dir(path)
+	cmd.Stdin = strings.NewReader(code)
 
 	setCmdEnv(cmd, username)
 
 	output, err := cmd.CombinedOutput()
 	if nil != err {
-		logger.Error(err)
-		http.Error(w, err.Error(), 500)
-
-		return
+		return "", 0, 0, err
 	}
 
 	found := strings.TrimSpace(string(output))
 	if "" == found {
-		result.Succ = false
-
-		return
+		return "", 0, 0, nil
 	}
 
 	part := found[:strings.LastIndex(found, ":")]
 	cursorSep := strings.LastIndex(part, ":")
-	path = found[:cursorSep]
+	declPath = found[:cursorSep]
 
-	cursorLine, _ := strconv.Atoi(found[cursorSep+1 : strings.LastIndex(found, ":")])
-	cursorCh, _ := strconv.Atoi(found[strings.LastIndex(found, ":")+1:])
+	cursorLine, _ = strconv.Atoi(found[cursorSep+1 : strings.LastIndex(found, ":")])
+	cursorCh, _ = strconv.Atoi(found[strings.LastIndex(found, ":")+1:])
 
-	data := map[string]interface{}{}
-	result.Data = &data
-
-	data["path"] = filepath.ToSlash(path)
-	data["cursorLine"] = cursorLine
-	data["cursorCh"] = cursorCh
+	return declPath, cursorLine, cursorCh, nil
 }
 
 // FindUsagesHandler handles request of finding usages.
@@ -362,19 +561,14 @@ func FindUsagesHandler(w http.ResponseWriter, r *http.Request) {
 	curDir := filepath.Dir(filePath)
 	filename := filepath.Base(filePath)
 
-	fout, err := os.Create(filePath)
-
-	if nil != err {
-		logger.Error(err)
-		result.Succ = false
-
-		return
-	}
-
 	code := args["code"].(string)
-	fout.WriteString(code)
+	if err := writeBuffer(r, username, filePath, code); nil != err {
+		if errBufferForbidden == err {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
 
-	if err := fout.Close(); nil != err {
 		logger.Error(err)
 		result.Succ = false
 
@@ -387,9 +581,26 @@ func FindUsagesHandler(w http.ResponseWriter, r *http.Request) {
 	offset := getCursorOffset(code, line, ch)
 	logger.Tracef("offset [%d]", offset)
 
+	if conf.Wide.Gopls {
+		usages, err := goplsReferences(username, filePath, code, line, ch)
+		if nil != err {
+			logger.Error(err)
+		}
+
+		if 0 == len(usages) {
+			result.Succ = false
+
+			return
+		}
+
+		result.Data = usages
+
+		return
+	}
+
 	ideStub := util.Go.GetExecutableInGOBIN("gotools")
 	argv := []string{"types", "-pos", filename + ":" + strconv.Itoa(offset), "-use", "."}
-	cmd := exec.Command(ideStub, argv...)
+	cmd := exec.CommandContext(r.Context(), ideStub, argv...)
 	cmd.Dir = curDir
 
 	setCmdEnv(cmd, username)
@@ -455,11 +666,43 @@ func getCursorOffset(code string, line, ch int) (offset int) {
 
 func setCmdEnv(cmd *exec.Cmd, username string) {
 	userWorkspace := conf.GetUserWorkspace(username)
+	goRoot := conf.GetGoRoot(username)
 
 	cmd.Env = append(cmd.Env,
 		"GOPATH="+userWorkspace,
 		"GOOS="+runtime.GOOS,
 		"GOARCH="+runtime.GOARCH,
-		"GOROOT="+runtime.GOROOT(),
-		"PATH="+os.Getenv("PATH"))
+		"GOROOT="+goRoot,
+		"PATH="+filepath.Join(goRoot, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// errBufferForbidden is the error of writing the buffer of a file the session user can't access.
+var errBufferForbidden = errors.New("forbidden")
+
+// writeBuffer writes the unsaved buffer (code) of the editor to the file specified by path for the tools reading files
+// (gocode and gotools for example), returns errBufferForbidden if the user specified by username can't access the
+// file.
+//
+// Files of Go API, locked files (see session.GetFileLock) and the requests which can't modify files (viewers and
+// read-only API tokens, see session.CanWrite) are not written, the tools read the files as they are.
+func writeBuffer(r *http.Request, username, path, code string) error {
+	if util.Go.IsAPI(path) {
+		return nil
+	}
+
+	if !session.CanAccess(username, path) {
+		return errBufferForbidden
+	}
+
+	if !session.CanWrite(r) {
+		return nil
+	}
+
+	if lock := session.GetFileLock(path); nil != lock {
+		logger.Debugf("Skips writing buffer of [%s] locked by [%s]", path, lock.Username)
+
+		return nil
+	}
+
+	return ioutil.WriteFile(path, []byte(code), 0644)
 }
@@ -0,0 +1,84 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package editor
+
+import (
+	"errors"
+	"go/format"
+	"strings"
+)
+
+// formatRange formats the specified range of Go source code.
+//
+// The range is lines [startLine, endLine] (0-based, inclusive) if args contains "startLine" and "endLine", or bytes
+// [start, end) if args contains "start" and "end". The region is formatted by go/format as a partial source file (a
+// list of declarations or statements), so it keeps the indentation of its first line. If the region isn't
+// independently parseable, it's returned as it is with formatted false and the parse error.
+//
+// The returned data contains the region ("code") and its new bounds, the client splices it into the editor.
+func formatRange(code string, args map[string]interface{}) (map[string]interface{}, error) {
+	ret := map[string]interface{}{}
+
+	region := ""
+	byLine := false
+	start := 0
+	if startLine, ok := args["startLine"].(float64); ok {
+		endLine, _ := args["endLine"].(float64)
+
+		lines := strings.SplitAfter(code, "\n")
+		end := int(endLine)
+		start = int(startLine)
+		if 0 > start || end < start || len(lines) <= end {
+			return nil, errors.New("invalid line range")
+		}
+
+		region = strings.Join(lines[start:end+1], "")
+		byLine = true
+	} else if startByte, ok := args["start"].(float64); ok {
+		endByte, _ := args["end"].(float64)
+
+		end := int(endByte)
+		start = int(startByte)
+		if 0 > start || end < start || len(code) < end {
+			return nil, errors.New("invalid byte range")
+		}
+
+		region = code[start:end]
+	} else {
+		return nil, errors.New("range not specified")
+	}
+
+	formatted := false
+	if "" != strings.TrimSpace(region) {
+		if src, err := format.Source([]byte(region)); nil != err {
+			ret["msg"] = err.Error() // not parseable independently, leaves it as it is
+		} else {
+			region = string(src)
+			formatted = true
+		}
+	}
+
+	ret["code"] = region
+	ret["formatted"] = formatted
+	if byLine {
+		ret["startLine"] = start
+		ret["endLine"] = start + strings.Count(strings.TrimSuffix(region, "\n"), "\n")
+	} else {
+		ret["start"] = start
+		ret["end"] = start + len(region)
+	}
+
+	return ret, nil
+}
@@ -15,10 +15,13 @@
 package editor
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/b3log/wide/conf"
 	"github.com/b3log/wide/session"
@@ -30,17 +33,23 @@ import (
 // This function will select a format tooll based on user's configuration:
 //  1. gofmt
 //  2. goimports
+//
+// If a range is specified (arguments "startLine" and "endLine", or "start" and "end"), only the range is formatted
+// (see formatRange) and the file isn't written.
+//
+// If argument "diff" is true, the file isn't written either, the changes formatting would make are returned as a
+// unified diff (data "diff") and hunks (data "hunks"), both are empty if the code is already formatted.
 func GoFmtHandler(w http.ResponseWriter, r *http.Request) {
 	result := util.NewResult()
 	defer util.RetResult(w, r, result)
 
-	session, _ := session.HTTPSession.Get(r, "wide-session")
-	if session.IsNew {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 
 		return
 	}
-	username := session.Values["username"].(string)
+	username := httpSession.Values["username"].(string)
 
 	var args map[string]interface{}
 
@@ -59,6 +68,41 @@ func GoFmtHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !session.CanAccess(username, filePath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if _, ok := args["startLine"]; ok {
+		fmtRange(result, args)
+
+		return
+	}
+	if _, ok := args["start"]; ok {
+		fmtRange(result, args)
+
+		return
+	}
+	if diff, _ := args["diff"].(bool); diff {
+		fmtDiff(result, username, filePath, args)
+
+		return
+	}
+
+	if !session.CanWrite(r) { // formatting in place writes the file
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if lock := session.GetFileLock(filePath); nil != lock {
+		result.Succ = false
+		result.Msg = "[" + filepath.Base(filePath) + "] is locked by [" + lock.Username + "]"
+
+		return
+	}
+
 	fout, err := os.Create(filePath)
 
 	if nil != err {
@@ -88,7 +132,7 @@ func GoFmtHandler(w http.ResponseWriter, r *http.Request) {
 	fmt := conf.GetGoFmt(username)
 
 	argv := []string{filePath}
-	cmd := exec.Command(fmt, argv...)
+	cmd := exec.CommandContext(r.Context(), fmt, argv...)
 
 	bytes, _ := cmd.Output()
 	output := string(bytes)
@@ -111,3 +155,51 @@ func GoFmtHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// fmtRange formats the range of code specified by args, fills the result.
+func fmtRange(result *util.Result, args map[string]interface{}) {
+	code, _ := args["code"].(string)
+
+	data, err := formatRange(code, args)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	result.Data = data
+}
+
+const (
+	fmtDiffMaxEdits = 1000 // max edit distance searched of a formatting diff, a larger diff is returned inexactly
+	fmtDiffContext  = 3    // count of context lines of hunks of a formatting diff
+)
+
+// fmtDiff formats the code specified by args with the formatter of the user specified by username, fills the result
+// with the diff between the code and the formatted one.
+func fmtDiff(result *util.Result, username, filePath string, args map[string]interface{}) {
+	code, _ := args["code"].(string)
+
+	cmd := exec.Command(conf.GetGoFmt(username))
+	cmd.Stdin = strings.NewReader(code)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	output, err := cmd.Output()
+	if nil != err {
+		result.Succ = false
+		result.Msg = strings.Replace(strings.TrimSpace(stderr.String()), "<standard input>", filepath.Base(filePath), -1)
+		if "" == result.Msg {
+			result.Msg = err.Error()
+		}
+
+		return
+	}
+
+	lines, exact := util.Diff.Lines(util.Diff.SplitLines(code), util.Diff.SplitLines(string(output)), fmtDiffMaxEdits)
+	hunks := util.Diff.Hunks(lines, fmtDiffContext)
+	name := filepath.ToSlash(filepath.Base(filePath))
+
+	result.Data = map[string]interface{}{"diff": util.Diff.Unified(name, name, hunks), "hunks": hunks, "exact": exact}
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package editor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// goDocTimeout is the timeout of go doc.
+const goDocTimeout = 10 * time.Second
+
+// Valid go doc query, such as "fmt", "fmt.Println", "net/http.Client.Do" and "github.com/b3log/wide/conf.User".
+var goDocQueryRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.~+/-]{0,255}$`)
+
+// Valid declaration of an exported symbol listed by go doc -short, such as "func Println(a ...any) (n int, err error)".
+var goDocSymbolRegexp = regexp.MustCompile(`^(func|type|const|var) ([A-Z]\w*)`)
+
+// goDocSymbol represents an exported symbol of a package.
+type goDocSymbol struct {
+	Kind string `json:"kind"` // func/type/const/var
+	Name string `json:"name"`
+	Decl string `json:"decl"` // one-line declaration, such as "func Println(a ...any) (n int, err error)"
+}
+
+// GoDocHandler handles request of getting documentation of a package or a symbol by go doc.
+//
+// Argument "query" is a package ("fmt"), or a symbol of a package ("fmt.Println", "net/http.Client.Do"). Argument
+// "path" is a file or a directory the query is resolved in, so both the standard library and the packages of the
+// module or GOPATH of the path are resolved, defaults to the user's workspace.
+//
+// Data "doc" is the rendered documentation text. If argument "list" is true, data "symbols" is the exported symbols
+// of the package as well (see goDocSymbol). Result is failed with message "no_such_doc" if the package or the symbol
+// is not found.
+func GoDocHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+	locale := conf.GetUser(username).Locale
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	query, _ := args["query"].(string)
+	query = strings.TrimSpace(query)
+	if !goDocQueryRegexp.MatchString(query) || strings.Contains(query, "..") {
+		result.Succ = false
+		result.Msg = "invalid query [" + query + "]"
+
+		return
+	}
+
+	dir := filepath.SplitList(conf.GetUserWorkspace(username))[0]
+	if path, _ := args["path"].(string); "" != path {
+		path = filepath.Clean(filepath.FromSlash(path))
+		if !session.CanAccess(username, path) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
+
+		dir = path
+		if !util.File.IsDir(dir) {
+			dir = filepath.Dir(dir)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), goDocTimeout)
+	defer cancel()
+
+	doc, found, err := goDoc(ctx, username, dir, query, false)
+	if nil != err {
+		logger.Warnf("Runs go doc [%s] for user [%s] failed: %s", query, username, err)
+		result.Succ = false
+
+		return
+	}
+
+	if !found {
+		result.Succ = false
+		result.Msg = strings.Replace(i18n.Get(locale, "no_such_doc").(string), "{query}", query, 1)
+
+		return
+	}
+
+	data := map[string]interface{}{"doc": doc}
+	result.Data = data
+
+	if list, _ := args["list"].(bool); list {
+		short, _, err := goDoc(ctx, username, dir, query, true)
+		if nil != err {
+			logger.Warnf("Runs go doc -short [%s] for user [%s] failed: %s", query, username, err)
+		}
+
+		data["symbols"] = parseGoDocSymbols(short)
+	}
+}
+
+// goDoc runs go doc with the specified query in the specified directory for the user specified by username, lists
+// exported symbols (go doc -short) if short is true. Returns found false if the package or the symbol is not found.
+func goDoc(ctx context.Context, username, dir, query string, short bool) (doc string, found bool, err error) {
+	argv := []string{"doc"}
+	if short {
+		argv = append(argv, "-short")
+	}
+	argv = append(argv, query)
+
+	cmd := exec.CommandContext(ctx, conf.GetGoExecutable(conf.GetGoRoot(username)), argv...)
+	cmd.Dir = dir
+	setCmdEnv(cmd, username)
+
+	output, err := cmd.CombinedOutput()
+	if nil != ctx.Err() {
+		return "", false, ctx.Err()
+	}
+
+	if nil != err {
+		if _, ok := err.(*exec.ExitError); ok { // no such package or symbol, or the package can't be loaded
+			logger.Debugf("go doc [%s] in [%s]: %s", query, dir, output)
+
+			return "", false, nil
+		}
+
+		return "", false, err
+	}
+
+	return string(output), true, nil
+}
+
+// parseGoDocSymbols parses the exported symbols of the specified output of go doc -short.
+func parseGoDocSymbols(output string) []*goDocSymbol {
+	ret := []*goDocSymbol{}
+	for _, line := range strings.Split(output, "\n") {
+		if m := goDocSymbolRegexp.FindStringSubmatch(line); nil != m {
+			ret = append(ret, &goDocSymbol{Kind: m[1], Name: m[2], Decl: strings.TrimSpace(line)})
+		}
+	}
+
+	return ret
+}
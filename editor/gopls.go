@@ -0,0 +1,760 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package editor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/file"
+	"github.com/b3log/wide/util"
+)
+
+const (
+	// goplsTimeout is the timeout of a gopls request.
+	goplsTimeout = 5 * time.Second
+
+	// goplsIdleTimeout is the duration a gopls process without requests is kept running.
+	goplsIdleTimeout = 10 * time.Minute
+)
+
+var (
+	errGoplsExited  = errors.New("gopls exited")
+	errGoplsTimeout = errors.New("gopls request timeout")
+)
+
+// goplsServer represents a gopls process speaking LSP (JSON-RPC 2.0) over its stdin/stdout.
+type goplsServer struct {
+	workspace string         // user workspace (GOPATH) served by this process
+	cmd       *exec.Cmd      // gopls process
+	stdin     io.WriteCloser // stdin of gopls
+
+	writeMutex sync.Mutex // exclusive lock of stdin
+
+	mutex    sync.Mutex                 // exclusive lock of the fields below
+	seq      int64                      // request id sequence
+	pending  map[int64]chan *lspMessage // <request id, response channel>
+	versions map[string]int             // <document uri, version>
+	exited   bool                       // whether the process has exited
+	used     time.Time                  // the latest time the process is got for a request
+}
+
+// goplsEntry represents the gopls process of a workspace, starting the process is serialized per workspace so a cold
+// start of a workspace doesn't block the others.
+type goplsEntry struct {
+	mutex  sync.Mutex   // exclusive lock of starting and stopping the process
+	server *goplsServer // running process, nil if not started
+}
+
+// lspMessage represents a message received from the language server.
+type lspMessage struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+	Result json.RawMessage  `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// lspPosition represents a position in a text document, line and character are both zero-based.
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// lspLocation represents a location inside a resource.
+type lspLocation struct {
+	URI   string `json:"uri"`
+	Range struct {
+		Start lspPosition `json:"start"`
+		End   lspPosition `json:"end"`
+	} `json:"range"`
+}
+
+// gopls processes.
+//
+// <workspace, *goplsEntry>
+var goplsServers = map[string]*goplsEntry{}
+
+// Exclusive lock of goplsServers, not held while starting a process.
+var goplsMutex sync.Mutex
+
+// Load initializes the editor handling, such as subscribing lifecycle events.
+func Load() {
+	event.Subscribe(event.EvtCodeUserDeleted, event.HandleFunc(func(e *event.Event) {
+		stopGopls(e.Data.(string))
+	}))
+}
+
+// getGopls gets the gopls process serving the workspace of the user with the specified username, starts a new one
+// if there is no running process.
+func getGopls(username string) (*goplsServer, error) {
+	workspace := conf.GetUserWorkspace(username)
+	if "" == workspace {
+		return nil, errors.New("user [" + username + "] not found")
+	}
+
+	for {
+		goplsMutex.Lock()
+		entry := goplsServers[workspace]
+		if nil == entry {
+			entry = &goplsEntry{}
+			goplsServers[workspace] = entry
+		}
+		goplsMutex.Unlock()
+
+		entry.mutex.Lock()
+		if !isGoplsEntry(workspace, entry) { // stopped meanwhile
+			entry.mutex.Unlock()
+
+			continue
+		}
+
+		s, err := entry.get(workspace, username)
+		entry.mutex.Unlock()
+
+		return s, err
+	}
+}
+
+// get gets the running gopls process of the entry, starts a new one for the specified workspace of the user specified
+// by username if there is no running process.
+//
+// The caller should hold the entry mutex.
+func (entry *goplsEntry) get(workspace, username string) (*goplsServer, error) {
+	if s := entry.server; nil != s && !s.isExited() {
+		s.touch()
+
+		return s, nil
+	}
+
+	s, err := startGopls(workspace, username)
+	if nil != err {
+		entry.server = nil
+
+		return nil, err
+	}
+
+	s.touch()
+	entry.server = s
+
+	return s, nil
+}
+
+// stop kills the running gopls process of the entry.
+//
+// The caller should hold the entry mutex.
+func (entry *goplsEntry) stop() {
+	if s := entry.server; nil != s && !s.isExited() {
+		s.stdin.Close()
+		s.cmd.Process.Kill()
+
+		logger.Debugf("Stopped gopls [pid=%d] for workspace [%s]", s.cmd.Process.Pid, s.workspace)
+	}
+
+	entry.server = nil
+}
+
+// isGoplsEntry checks whether the specified entry is the current one of the specified workspace.
+func isGoplsEntry(workspace string, entry *goplsEntry) bool {
+	goplsMutex.Lock()
+	defer goplsMutex.Unlock()
+
+	return goplsServers[workspace] == entry
+}
+
+// StopGopls kills all running gopls processes, it's used while shutting down the server.
+func StopGopls() {
+	stopGoplsIf(func(workspace string, s *goplsServer) bool { return true })
+}
+
+// FixedTimeStopIdleGopls stops gopls processes periodically (1 minute) which are idle longer than goplsIdleTimeout,
+// or whose workspace doesn't belong to any user or has been removed.
+func FixedTimeStopIdleGopls() {
+	go func() {
+		defer util.Recover()
+
+		for _ = range time.Tick(time.Minute) {
+			workspaces := map[string]bool{}
+			for _, user := range conf.GetUsers() {
+				workspaces[user.WorkspacePath()] = true
+			}
+
+			stopGoplsIf(func(workspace string, s *goplsServer) bool {
+				return nil == s || s.isExited() || goplsIdleTimeout < s.idle() || !workspaces[workspace] ||
+					!util.File.IsDir(filepath.SplitList(workspace)[0])
+			})
+		}
+	}()
+}
+
+// stopGopls kills the gopls process serving the specified workspace.
+func stopGopls(workspace string) {
+	stopGoplsIf(func(w string, s *goplsServer) bool { return w == workspace })
+}
+
+// stopGoplsIf kills the gopls processes (nil if not started) of workspaces which the specified function returns true
+// for, and removes them.
+func stopGoplsIf(f func(workspace string, s *goplsServer) bool) {
+	goplsMutex.Lock()
+	entries := map[string]*goplsEntry{}
+	for workspace, entry := range goplsServers {
+		entries[workspace] = entry
+	}
+	goplsMutex.Unlock()
+
+	for workspace, entry := range entries {
+		entry.mutex.Lock()
+		if f(workspace, entry.server) {
+			entry.stop()
+
+			goplsMutex.Lock()
+			if goplsServers[workspace] == entry {
+				delete(goplsServers, workspace)
+			}
+			goplsMutex.Unlock()
+		}
+		entry.mutex.Unlock()
+	}
+}
+
+// startGopls starts a gopls process for the specified workspace and initializes it.
+func startGopls(workspace, username string) (*goplsServer, error) {
+	gopls := conf.GetDevTool("gopls").Path()
+	if "" == gopls {
+		return nil, errors.New(conf.GetDevToolHint(conf.GetUser(username).Locale, "gopls"))
+	}
+
+	cmd := exec.Command(gopls)
+	setCmdEnv(cmd, username)
+	cmd.Env = append(cmd.Env, "HOME="+os.Getenv("HOME"), "GO111MODULE=auto")
+
+	stdin, err := cmd.StdinPipe()
+	if nil != err {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if nil != err {
+		return nil, err
+	}
+
+	if err := cmd.Start(); nil != err {
+		return nil, err
+	}
+
+	s := &goplsServer{
+		workspace: workspace,
+		cmd:       cmd,
+		stdin:     stdin,
+		pending:   map[int64]chan *lspMessage{},
+		versions:  map[string]int{},
+	}
+
+	go s.read(stdout)
+
+	folders := []map[string]interface{}{}
+	for _, path := range filepath.SplitList(workspace) {
+		folders = append(folders, map[string]interface{}{"uri": pathToURI(path), "name": filepath.Base(path)})
+	}
+
+	roots := filepath.SplitList(workspace)
+	params := map[string]interface{}{
+		"processId": os.Getpid(),
+		"rootUri":   pathToURI(roots[0]),
+		"capabilities": map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"hover": map[string]interface{}{"contentFormat": []string{"plaintext"}},
+				"completion": map[string]interface{}{"completionItem": map[string]interface{}{
+					"snippetSupport":      false,
+					"documentationFormat": []string{"plaintext"},
+				}},
+			},
+			"workspace": map[string]interface{}{"configuration": true, "workspaceFolders": true},
+		},
+		"workspaceFolders": folders,
+	}
+
+	if err := s.call("initialize", params, nil); nil != err {
+		s.stdin.Close()
+		s.cmd.Process.Kill()
+
+		return nil, err
+	}
+
+	if err := s.notify("initialized", map[string]interface{}{}); nil != err {
+		return nil, err
+	}
+
+	logger.Debugf("Started gopls [pid=%d] for workspace [%s]", cmd.Process.Pid, workspace)
+
+	return s, nil
+}
+
+// touch marks the gopls process used now.
+func (s *goplsServer) touch() {
+	s.mutex.Lock()
+	s.used = time.Now()
+	s.mutex.Unlock()
+}
+
+// idle gets the duration since the gopls process is used the latest time.
+func (s *goplsServer) idle() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return time.Since(s.used)
+}
+
+// isExited checks whether the gopls process has exited.
+func (s *goplsServer) isExited() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.exited
+}
+
+// read reads messages from the specified gopls stdout until the process exits.
+func (s *goplsServer) read(stdout io.Reader) {
+	defer util.Recover()
+
+	reader := bufio.NewReader(stdout)
+	for {
+		msg, err := readLSPMessage(reader)
+		if nil != err {
+			if io.EOF != err {
+				logger.Warnf("Reads gopls [%s] message failed: %s", s.workspace, err)
+			}
+
+			break
+		}
+
+		if "" == msg.Method { // response
+			var id int64
+			if nil == msg.ID || nil != json.Unmarshal(*msg.ID, &id) {
+				continue
+			}
+
+			s.mutex.Lock()
+			ch := s.pending[id]
+			delete(s.pending, id)
+			s.mutex.Unlock()
+
+			if nil != ch {
+				ch <- msg
+			}
+
+			continue
+		}
+
+		if nil != msg.ID { // request from server, such as workspace/configuration
+			s.reply(msg)
+		}
+
+		// notifications (diagnostics, logs, progress) are ignored
+	}
+
+	s.mutex.Lock()
+	s.exited = true
+	for id, ch := range s.pending {
+		close(ch)
+		delete(s.pending, id)
+	}
+	s.mutex.Unlock()
+
+	s.stdin.Close()
+	s.cmd.Wait()
+
+	logger.Debugf("gopls for workspace [%s] exited", s.workspace)
+}
+
+// reply replies the specified request from gopls with a default result.
+func (s *goplsServer) reply(request *lspMessage) {
+	var result interface{}
+
+	if "workspace/configuration" == request.Method {
+		params := struct {
+			Items []interface{} `json:"items"`
+		}{}
+		json.Unmarshal(request.Params, &params)
+
+		result = make([]interface{}, len(params.Items))
+	}
+
+	err := s.write(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": result})
+	if nil != err {
+		logger.Warnf("Replies gopls [%s] request [%s] failed: %s", s.workspace, request.Method, err)
+	}
+}
+
+// call sends a request with the specified method and params to gopls, waits for the response and unmarshals the
+// response result into the specified result.
+func (s *goplsServer) call(method string, params, result interface{}) error {
+	ch := make(chan *lspMessage, 1)
+
+	s.mutex.Lock()
+	if s.exited {
+		s.mutex.Unlock()
+
+		return errGoplsExited
+	}
+	s.seq++
+	id := s.seq
+	s.pending[id] = ch
+	s.mutex.Unlock()
+
+	if err := s.write(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}); nil != err {
+		s.mutex.Lock()
+		delete(s.pending, id)
+		s.mutex.Unlock()
+
+		return err
+	}
+
+	select {
+	case msg := <-ch:
+		if nil == msg {
+			return errGoplsExited
+		}
+
+		if nil != msg.Error {
+			return fmt.Errorf("gopls [%s] failed: %s", method, msg.Error.Message)
+		}
+
+		if nil == result || 0 == len(msg.Result) {
+			return nil
+		}
+
+		return json.Unmarshal(msg.Result, result)
+	case <-time.After(goplsTimeout):
+		s.mutex.Lock()
+		delete(s.pending, id)
+		s.mutex.Unlock()
+
+		s.notify("$/cancelRequest", map[string]interface{}{"id": id})
+
+		return errGoplsTimeout
+	}
+}
+
+// notify sends a notification with the specified method and params to gopls.
+func (s *goplsServer) notify(method string, params interface{}) error {
+	return s.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// write writes the specified message to gopls stdin with LSP base protocol header.
+func (s *goplsServer) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if nil != err {
+		return err
+	}
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if _, err := fmt.Fprintf(s.stdin, "Content-Length: %d\r\n\r\n", len(data)); nil != err {
+		return err
+	}
+
+	_, err = s.stdin.Write(data)
+
+	return err
+}
+
+// sync sends the latest code of the file specified by path to gopls, opens the document if it has not been opened.
+func (s *goplsServer) sync(path, code string) (uri string, err error) {
+	uri = pathToURI(path)
+
+	s.mutex.Lock()
+	version, opened := s.versions[uri]
+	version++
+	s.versions[uri] = version
+	s.mutex.Unlock()
+
+	if !opened {
+		return uri, s.notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "languageId": "go", "version": version, "text": code},
+		})
+	}
+
+	return uri, s.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": version},
+		"contentChanges": []map[string]interface{}{{"text": code}},
+	})
+}
+
+// position sends the code of the file specified by path to gopls and returns the text document position params.
+func (s *goplsServer) position(path, code string, line, ch int) (map[string]interface{}, error) {
+	uri, err := s.sync(path, code)
+	if nil != err {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     lspPosition{Line: line, Character: ch},
+	}, nil
+}
+
+// goplsAutocomplete gets completions by gopls, the returned output is in the same JSON format of gocode
+// (-f=json), so the front-end can handle both of them. The candidates are enriched with the documentation gopls
+// reports if enrich is true.
+func goplsAutocomplete(username, path, code string, line, ch int, enrich bool) ([]byte, error) {
+	s, err := getGopls(username)
+	if nil != err {
+		return nil, err
+	}
+
+	params, err := s.position(path, code, line, ch)
+	if nil != err {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	if err := s.call("textDocument/completion", params, &raw); nil != err {
+		return nil, err
+	}
+
+	type completionItem struct {
+		Label         string          `json:"label"`
+		Kind          int             `json:"kind"`
+		Detail        string          `json:"detail"`
+		Documentation json.RawMessage `json:"documentation"` // string | MarkupContent
+	}
+
+	list := struct {
+		Items []completionItem `json:"items"`
+	}{}
+	if nil != json.Unmarshal(raw, &list) { // CompletionItem[]
+		json.Unmarshal(raw, &list.Items)
+	}
+
+	candidates := []*completionCandidate{}
+	for _, item := range list.Items {
+		c := &completionCandidate{Class: completionItemClass(item.Kind), Name: item.Label, Type: item.Detail}
+		if enrich {
+			c.enrich(lspDocumentation(item.Documentation))
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	return marshalCompletions(identPrefixLen(code, line, ch), candidates)
+}
+
+// goplsExprInfo gets the expression information at the specified position by gopls.
+func goplsExprInfo(username, path, code string, line, ch int) (string, error) {
+	s, err := getGopls(username)
+	if nil != err {
+		return "", err
+	}
+
+	params, err := s.position(path, code, line, ch)
+	if nil != err {
+		return "", err
+	}
+
+	hover := struct {
+		Contents struct {
+			Value string `json:"value"`
+		} `json:"contents"`
+	}{}
+	if err := s.call("textDocument/hover", params, &hover); nil != err {
+		return "", err
+	}
+
+	return strings.TrimSpace(hover.Contents.Value), nil
+}
+
+// goplsDefinition finds the declaration at the specified position by gopls, the returned cursor line and ch are both
+// one-based as gotools does.
+func goplsDefinition(username, path, code string, line, ch int) (declPath string, cursorLine, cursorCh int, err error) {
+	s, err := getGopls(username)
+	if nil != err {
+		return "", 0, 0, err
+	}
+
+	params, err := s.position(path, code, line, ch)
+	if nil != err {
+		return "", 0, 0, err
+	}
+
+	locations := []lspLocation{}
+	if err := s.call("textDocument/definition", params, &locations); nil != err {
+		return "", 0, 0, err
+	}
+
+	if 0 == len(locations) {
+		return "", 0, 0, nil
+	}
+
+	loc := locations[0]
+
+	return uriToPath(loc.URI), loc.Range.Start.Line + 1, loc.Range.Start.Character + 1, nil
+}
+
+// goplsReferences finds the usages at the specified position by gopls.
+func goplsReferences(username, path, code string, line, ch int) ([]*file.Snippet, error) {
+	s, err := getGopls(username)
+	if nil != err {
+		return nil, err
+	}
+
+	params, err := s.position(path, code, line, ch)
+	if nil != err {
+		return nil, err
+	}
+	params["context"] = map[string]interface{}{"includeDeclaration": true}
+
+	locations := []lspLocation{}
+	if err := s.call("textDocument/references", params, &locations); nil != err {
+		return nil, err
+	}
+
+	usages := []*file.Snippet{}
+	for _, loc := range locations {
+		usages = append(usages, &file.Snippet{Path: filepath.ToSlash(uriToPath(loc.URI)),
+			Line: loc.Range.Start.Line + 1, Ch: loc.Range.Start.Character + 1, Contents: []string{""}})
+	}
+
+	return usages, nil
+}
+
+// completionItemClass gets gocode candidate class of the specified LSP completion item kind.
+func completionItemClass(kind int) string {
+	switch kind {
+	case 2, 3, 4: // Method, Function, Constructor
+		return "func"
+	case 7, 8, 22, 25: // Class, Interface, Struct, TypeParameter
+		return "type"
+	case 9: // Module
+		return "package"
+	case 21: // Constant
+		return "const"
+	default:
+		return "var"
+	}
+}
+
+// lspDocumentation gets text of the specified LSP documentation which is either a string or a MarkupContent.
+func lspDocumentation(raw json.RawMessage) string {
+	if 0 == len(raw) {
+		return ""
+	}
+
+	var ret string
+	if nil == json.Unmarshal(raw, &ret) {
+		return ret
+	}
+
+	content := struct {
+		Value string `json:"value"`
+	}{}
+	json.Unmarshal(raw, &content)
+
+	return content.Value
+}
+
+// identPrefixLen gets length (in runes) of the identifier part before the cursor, which is the length of the
+// partial name gocode reports as the first element of its output.
+func identPrefixLen(code string, line, ch int) int {
+	lines := strings.Split(code, "\n")
+	if line >= len(lines) {
+		return 0
+	}
+
+	r := []rune(lines[line])
+	if ch > len(r) {
+		ch = len(r)
+	}
+
+	ret := 0
+	for i := ch - 1; i >= 0; i-- {
+		if '_' != r[i] && !unicode.IsLetter(r[i]) && !unicode.IsDigit(r[i]) {
+			break
+		}
+
+		ret++
+	}
+
+	return ret
+}
+
+// readLSPMessage reads a message with LSP base protocol header from the specified reader.
+func readLSPMessage(reader *bufio.Reader) (*lspMessage, error) {
+	header, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if nil != err {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if nil != err {
+		return nil, fmt.Errorf("invalid Content-Length [%s]", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); nil != err {
+		return nil, err
+	}
+
+	ret := &lspMessage{}
+	if err := json.Unmarshal(body, ret); nil != err {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// pathToURI converts the specified file path to a file URI.
+func pathToURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") { // Windows volume, C:/foo
+		path = "/" + path
+	}
+
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// uriToPath converts the specified file URI to a file path.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if nil != err {
+		return uri
+	}
+
+	path := u.Path
+	if util.OS.IsWindows() {
+		path = strings.TrimPrefix(path, "/")
+	}
+
+	return filepath.FromSlash(path)
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package editor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// importsBlock represents the lines of import declarations of go code.
+type importsBlock struct {
+	startLine int // first line, starts with 0
+	endLine   int // line after the last line, equals startLine if there is no import
+}
+
+// OrganizeImportsHandler handles request of organizing imports (adding missing, removing unused, grouping and
+// sorting) of the unsaved code (argument "code") of the go file specified by argument "file" with goimports, the rest
+// of the code is not reformatted.
+//
+// Only the edit of the import declarations is returned: data "startLine" and "endLine" (0-based, exclusive) are the
+// lines to replace with data "imports", data "changed" is false if nothing needs to change.
+func OrganizeImportsHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	path, _ := args["file"].(string)
+	if util.Go.IsAPI(path) || !session.CanAccess(username, path) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	code, _ := args["code"].(string)
+
+	goimports := conf.GetDevTool("goimports").Path()
+	if "" == goimports {
+		result.Succ = false
+		result.Msg = conf.GetDevToolHint(conf.GetUser(username).Locale, "goimports")
+
+		return
+	}
+
+	cmd := exec.CommandContext(r.Context(), goimports, "-srcdir", filepath.Dir(path))
+	setCmdEnv(cmd, username)
+	cmd.Stdin = strings.NewReader(code)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	output, err := cmd.Output()
+	if nil != err {
+		result.Succ = false
+		result.Msg = strings.TrimSpace(stderr.String())
+		if "" == result.Msg {
+			result.Msg = err.Error()
+		}
+
+		return
+	}
+
+	data, err := diffImports(code, string(output))
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	result.Data = data
+}
+
+// diffImports returns the edit of import declarations from the specified code to the specified organized code.
+func diffImports(code, organized string) (map[string]interface{}, error) {
+	block, err := getImportsBlock(code)
+	if nil != err {
+		return nil, err
+	}
+
+	organizedBlock, err := getImportsBlock(organized)
+	if nil != err {
+		return nil, err
+	}
+
+	lines := strings.Split(code, "\n")
+	organizedLines := strings.Split(organized, "\n")
+
+	old := strings.Join(lines[block.startLine:block.endLine], "\n")
+	imports := strings.Join(organizedLines[organizedBlock.startLine:organizedBlock.endLine], "\n")
+	if block.startLine == block.endLine && organizedBlock.startLine != organizedBlock.endLine {
+		imports = "\n" + imports // separated from the package clause
+	}
+	if "" != imports {
+		imports += "\n"
+	}
+	if "" != old {
+		old += "\n"
+	}
+
+	return map[string]interface{}{"changed": old != imports, "startLine": block.startLine,
+		"endLine": block.endLine, "imports": imports}, nil
+}
+
+// getImportsBlock gets the lines of import declarations (with their doc comments) of the specified code, the lines
+// are right after the package clause if there is no import.
+func getImportsBlock(code string) (*importsBlock, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", code, parser.ImportsOnly|parser.ParseComments)
+	if nil != err {
+		return nil, err
+	}
+
+	if nil == f.Name {
+		return nil, errors.New("package clause not found")
+	}
+
+	ret := &importsBlock{}
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || token.IMPORT != genDecl.Tok {
+			break
+		}
+
+		start := genDecl.Pos()
+		if nil != genDecl.Doc {
+			start = genDecl.Doc.Pos()
+		}
+
+		if 0 == ret.endLine {
+			ret.startLine = fset.Position(start).Line - 1
+		}
+		ret.endLine = fset.Position(genDecl.End()).Line
+	}
+
+	if 0 == ret.endLine {
+		ret.startLine = fset.Position(f.Name.End()).Line
+		ret.endLine = ret.startLine
+	}
+
+	return ret, nil
+}
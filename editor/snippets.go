@@ -0,0 +1,132 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package editor
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+)
+
+var (
+	// Selector expression and the partial trigger typed after its dot, such as "resp.Body" and "n" of "resp.Body.n".
+	postfixExprRegexp = regexp.MustCompile(`([A-Za-z_][\w.]*)\.(\w*)$`)
+
+	// Error variable declarations, such as "n, err :=", "err =", "var err error" and "err error" of parameters.
+	errVarRegexp = regexp.MustCompile(`\b(\w*[eE]rr\w*)\s*(?:,\s*\w+\s*)*:?=[^=]|\b(\w+)\s+error\b`)
+)
+
+// appendSnippets appends the snippet candidates matching the identifier before the cursor to the specified
+// completions output. Returns the output as it is if no snippet matches or it can't be parsed.
+func appendSnippets(output []byte, path, code string, line, ch, offset int) []byte {
+	snippets := snippetCandidates(path, code, line, ch, offset)
+	if 0 == len(snippets) {
+		return output
+	}
+
+	prefixLen, candidates, err := parseCompletions(output)
+	if nil != err {
+		return output
+	}
+
+	if 0 == len(candidates) {
+		prefixLen = identPrefixLen(code, line, ch)
+	}
+
+	ret, err := marshalCompletions(prefixLen, append(candidates, snippets...))
+	if nil != err {
+		logger.Error(err)
+
+		return output
+	}
+
+	return ret
+}
+
+// snippetCandidates gets the candidates of snippets applying to the file specified by path whose triggers start with
+// the identifier before the cursor.
+//
+// Postfix snippets are matched only if the identifier follows an expression and a dot (an empty identifier matches
+// all of them), the others are matched only if not.
+func snippetCandidates(path, code string, line, ch, offset int) []*completionCandidate {
+	lines := strings.Split(code, "\n")
+	if line >= len(lines) {
+		return nil
+	}
+
+	r := []rune(lines[line])
+	if ch > len(r) {
+		ch = len(r)
+	}
+
+	expr, partial := "", ""
+	if m := postfixExprRegexp.FindStringSubmatch(string(r[:ch])); nil != m {
+		expr, partial = m[1], m[2]
+	} else {
+		partial = string(r[ch-identPrefixLen(code, line, ch) : ch])
+		if "" == partial {
+			return nil
+		}
+	}
+
+	if offset > len(code) {
+		offset = len(code)
+	}
+
+	ret := []*completionCandidate{}
+	for _, snippet := range conf.GetSnippets(path) {
+		if snippet.Postfix != ("" != expr) || !strings.HasPrefix(snippet.Trigger, partial) {
+			continue
+		}
+
+		c := &completionCandidate{Class: "snippet", Name: snippet.Trigger, Type: snippet.Description,
+			Snippet: expandSnippet(snippet.Template, code[:offset], expr)}
+		if snippet.Postfix {
+			c.ReplaceLen = len([]rune(expr)) + 1
+		}
+
+		ret = append(ret, c)
+	}
+
+	return ret
+}
+
+// expandSnippet replaces the variables in the specified snippet template, ${ERR} with the error variable inferred
+// from the specified code before the cursor and ${EXPR} with the specified postfix expression. Placeholders are kept
+// for the editor.
+func expandSnippet(template, codeBefore, expr string) string {
+	ret := strings.Replace(template, "${EXPR}", expr, -1)
+	if strings.Contains(ret, "${ERR}") {
+		ret = strings.Replace(ret, "${ERR}", inferErrVar(codeBefore), -1)
+	}
+
+	return ret
+}
+
+// inferErrVar gets name of the error variable declared last in the specified code, returns "err" if not found.
+func inferErrVar(code string) string {
+	matches := errVarRegexp.FindAllStringSubmatch(code, -1)
+	if 0 == len(matches) {
+		return "err"
+	}
+
+	last := matches[len(matches)-1]
+	if "" != last[1] {
+		return last[1]
+	}
+
+	return last[2]
+}
@@ -0,0 +1,299 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package editor
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// funcRange represents the range of a function, positions are rune based and start with 0.
+type funcRange struct {
+	Name    string `json:"name"` // function name, "func" for function literals
+	Line    int    `json:"line"` // line and ch are of the func keyword
+	Ch      int    `json:"ch"`
+	EndLine int    `json:"endLine"` // endLine and endCh are of the closing brace of the body
+	EndCh   int    `json:"endCh"`
+
+	start, end int // byte offsets of the func keyword and the closing brace
+}
+
+// bracketMatch represents a bracket and its matching one, positions are rune based and start with 0.
+type bracketMatch struct {
+	Line      int `json:"line"`
+	Ch        int `json:"ch"`
+	MatchLine int `json:"matchLine"`
+	MatchCh   int `json:"matchCh"`
+}
+
+// codeStructure represents the structural information of code used for navigation.
+type codeStructure struct {
+	funcs []*funcRange // functions with body
+	pairs map[int]int  // <byte offset of a bracket, byte offset of the matching one>, both directions
+}
+
+// StructureHandler handles request of getting structural positions at the cursor (arguments "code", "cursorLine"
+// and "cursorCh") of go code.
+//
+// Data "func" is the innermost function enclosing the cursor, data "bracket" is the bracket before or after the
+// cursor (the one before wins, as CodeMirror does) and its matching one, both are null if not found. The code is
+// parsed by go/parser, data "fallback" is true if it can't be parsed and the positions are matched by go/scanner
+// on a best-effort basis.
+func StructureHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	code, _ := args["code"].(string)
+	if tokensMaxSize < len(code) {
+		result.Succ = false
+		result.Msg = "code is too large to analyze"
+
+		return
+	}
+
+	cursorLine, _ := args["cursorLine"].(float64)
+	cursorCh, _ := args["cursorCh"].(float64)
+	lines := strings.Split(code, "\n")
+	line, ch := int(cursorLine), int(cursorCh)
+	if 0 > line || line >= len(lines) || 0 > ch || ch > utf8.RuneCountInString(lines[line]) {
+		result.Succ = false
+		result.Msg = "invalid cursor position"
+
+		return
+	}
+	offset := getCursorOffset(code, line, ch)
+
+	structure, fallback := parseStructure(code)
+
+	var fn *funcRange
+	for _, f := range structure.funcs {
+		if f.start <= offset && offset <= f.end && (nil == fn || fn.start < f.start) {
+			fn = f
+		}
+	}
+	if nil != fn {
+		fn.Line, fn.Ch = offsetToLineCh(code, fn.start)
+		fn.EndLine, fn.EndCh = offsetToLineCh(code, fn.end)
+	}
+
+	var bracket *bracketMatch
+	for _, at := range []int{offset - 1, offset} {
+		if match, ok := structure.pairs[at]; ok {
+			bracket = &bracketMatch{}
+			bracket.Line, bracket.Ch = offsetToLineCh(code, at)
+			bracket.MatchLine, bracket.MatchCh = offsetToLineCh(code, match)
+
+			break
+		}
+	}
+
+	result.Data = map[string]interface{}{"func": fn, "bracket": bracket, "fallback": fallback}
+}
+
+// parseStructure parses the structural information of the specified code by go/parser, falls back to
+// scanStructure if the code can't be parsed.
+func parseStructure(code string) (structure *codeStructure, fallback bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", code, 0)
+	if nil != err {
+		return scanStructure(code), true
+	}
+
+	tokFile := fset.File(f.Pos())
+	offset := tokFile.Offset
+	structure = &codeStructure{pairs: map[int]int{}}
+	pair := func(open, close token.Pos) {
+		if open.IsValid() && close.IsValid() {
+			structure.pairs[offset(open)] = offset(close)
+			structure.pairs[offset(close)] = offset(open)
+		}
+	}
+
+	ast.Inspect(f, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.FuncDecl:
+			if nil != n.Body {
+				structure.funcs = append(structure.funcs,
+					&funcRange{Name: n.Name.Name, start: offset(n.Pos()), end: offset(n.Body.Rbrace)})
+			}
+		case *ast.FuncLit:
+			structure.funcs = append(structure.funcs,
+				&funcRange{Name: "func", start: offset(n.Pos()), end: offset(n.Body.Rbrace)})
+		case *ast.BlockStmt:
+			pair(n.Lbrace, n.Rbrace)
+		case *ast.CompositeLit:
+			pair(n.Lbrace, n.Rbrace)
+		case *ast.FieldList:
+			pair(n.Opening, n.Closing)
+		case *ast.CallExpr:
+			pair(n.Lparen, n.Rparen)
+		case *ast.ParenExpr:
+			pair(n.Lparen, n.Rparen)
+		case *ast.IndexExpr:
+			pair(n.Lbrack, n.Rbrack)
+		case *ast.SliceExpr:
+			pair(n.Lbrack, n.Rbrack)
+		case *ast.TypeAssertExpr:
+			pair(n.Lparen, n.Rparen)
+		case *ast.GenDecl:
+			pair(n.Lparen, n.Rparen)
+		}
+
+		return true
+	})
+
+	// the AST doesn't record some brackets (such as "]" of array types), they are matched by the scanner
+	for open, close := range scanStructure(code).pairs {
+		if _, ok := structure.pairs[open]; !ok {
+			structure.pairs[open] = close
+		}
+	}
+
+	return structure, false
+}
+
+// scanStructure scans the structural information of the specified code by go/scanner on a best-effort basis, so
+// brackets in strings and comments are skipped.
+//
+// Brackets are paired by a stack, an unmatched closing bracket is ignored. The body of a function is the first
+// brace block following the func keyword at the same nesting level, a function without a closing brace ends at the
+// end of the code.
+func scanStructure(code string) *codeStructure {
+	ret := &codeStructure{pairs: map[int]int{}}
+
+	src := []byte(code)
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0)
+
+	type open struct {
+		offset int
+		tok    token.Token
+		fn     *funcRange // function whose body is opened by this brace
+	}
+
+	openings := map[token.Token]token.Token{token.RPAREN: token.LPAREN, token.RBRACK: token.LBRACK,
+		token.RBRACE: token.LBRACE}
+
+	stack := []*open{}
+	pending := map[int]*funcRange{} // <nesting level, function waiting for its body>
+
+	// naming a function, "func Name(" or "func (receiver) Name("
+	var naming *funcRange
+	namingLevel, namingParens, namingCandidate := 0, 0, ""
+
+	for {
+		pos, tok, lit := s.Scan()
+		if token.EOF == tok {
+			break
+		}
+
+		offset := file.Offset(pos)
+
+		if nil != naming && len(stack) == namingLevel {
+			switch {
+			case token.IDENT == tok && 0 == namingParens:
+				naming.Name, naming = lit, nil
+			case token.IDENT == tok && 1 == namingParens && "" == namingCandidate:
+				namingCandidate = lit
+			case token.LPAREN == tok && "" != namingCandidate:
+				naming.Name, naming = namingCandidate, nil
+			case token.LPAREN == tok:
+			case token.RPAREN == tok: // counted after popped
+			default:
+				naming = nil
+			}
+		}
+
+		switch tok {
+		case token.FUNC:
+			fn := &funcRange{Name: "func", start: offset, end: len(src)}
+			ret.funcs = append(ret.funcs, fn)
+			pending[len(stack)] = fn
+
+			naming = fn
+			namingLevel, namingParens, namingCandidate = len(stack), 0, ""
+		case token.SEMICOLON: // function types without body, such as "type F func()"
+			delete(pending, len(stack))
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			o := &open{offset: offset, tok: tok}
+			if token.LBRACE == tok {
+				o.fn = pending[len(stack)]
+				delete(pending, len(stack))
+			}
+			stack = append(stack, o)
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			if 0 == len(stack) || openings[tok] != stack[len(stack)-1].tok {
+				continue
+			}
+
+			o := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			delete(pending, len(stack)+1)
+
+			ret.pairs[o.offset] = offset
+			ret.pairs[offset] = o.offset
+			if nil != o.fn {
+				o.fn.end = offset
+			}
+
+			if nil != naming && token.RPAREN == tok && len(stack) == namingLevel {
+				namingParens++
+			}
+		}
+	}
+
+	return ret
+}
+
+// offsetToLineCh converts the specified byte offset of the specified code to line and rune based column, both start
+// with 0.
+func offsetToLineCh(code string, offset int) (line, ch int) {
+	if offset > len(code) {
+		offset = len(code)
+	}
+
+	before := code[:offset]
+	line = strings.Count(before, "\n")
+	ch = utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:])
+
+	return
+}
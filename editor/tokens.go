@@ -0,0 +1,148 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package editor
+
+import (
+	"encoding/json"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"net/http"
+	"strings"
+
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// tokensMaxSize is the max size of code to tokenize (1M).
+const tokensMaxSize = 1024 * 1024
+
+// syntaxToken represents a token of go code for syntax highlighting, positions are rune based and start with 0.
+type syntaxToken struct {
+	Line    int    `json:"line"`
+	Ch      int    `json:"ch"`
+	EndLine int    `json:"endLine"` // differs from line for raw strings and general comments
+	EndCh   int    `json:"endCh"`   // exclusive
+	Type    string `json:"type"`    // keyword/ident/builtin/string/char/number/comment/operator/illegal
+}
+
+// TokensHandler handles request of tokenizing go code (argument "code") for syntax highlighting by go/scanner.
+//
+// Invalid code is tokenized as far as possible, scanning errors are returned as diagnostics in data "errors" along
+// with the tokens in data "tokens".
+func TokensHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	code, _ := args["code"].(string)
+	if tokensMaxSize < len(code) {
+		result.Succ = false
+		result.Msg = "code is too large to tokenize"
+
+		return
+	}
+
+	tokens, errs := tokenize(code)
+	result.Data = map[string]interface{}{"tokens": tokens, "errors": errs}
+}
+
+// tokenize scans the specified code into syntax tokens, scanning errors are returned as diagnostics.
+func tokenize(code string) ([]*syntaxToken, []*diagnostic) {
+	tokens := []*syntaxToken{}
+	errs := []*diagnostic{}
+	lines := strings.Split(code, "\n")
+
+	src := []byte(code)
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+
+	var s scanner.Scanner
+	s.Init(file, src, func(pos token.Position, msg string) {
+		if line, ch, ok := toLineCh(lines, pos); ok {
+			errs = append(errs, &diagnostic{Line: line, Ch: ch, Severity: "error", Msg: msg})
+		}
+	}, scanner.ScanComments)
+
+	for {
+		pos, tok, lit := s.Scan()
+		if token.EOF == tok {
+			break
+		}
+
+		if token.SEMICOLON == tok && "\n" == lit { // automatically inserted
+			continue
+		}
+
+		text := lit
+		if "" == text {
+			text = tok.String()
+		}
+
+		start := file.Offset(pos)
+		end := start + len(text)
+		if end > len(src) {
+			end = len(src)
+		}
+
+		line, ch, _ := toLineCh(lines, fset.Position(pos))
+		endLine, endCh, _ := toLineCh(lines, file.Position(file.Pos(end)))
+
+		tokens = append(tokens, &syntaxToken{Line: line, Ch: ch, EndLine: endLine, EndCh: endCh,
+			Type: getTokenType(tok, lit)})
+	}
+
+	return tokens, errs
+}
+
+// getTokenType gets the syntax token type of the specified token and literal.
+func getTokenType(tok token.Token, lit string) string {
+	switch {
+	case tok.IsKeyword():
+		return "keyword"
+	case token.IDENT == tok:
+		if nil != types.Universe.Lookup(lit) {
+			return "builtin"
+		}
+
+		return "ident"
+	case token.STRING == tok:
+		return "string"
+	case token.CHAR == tok:
+		return "char"
+	case token.INT == tok || token.FLOAT == tok || token.IMAG == tok:
+		return "number"
+	case token.COMMENT == tok:
+		return "comment"
+	case tok.IsOperator():
+		return "operator"
+	default:
+		return "illegal"
+	}
+}
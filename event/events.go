@@ -17,6 +17,8 @@ package event
 
 import (
 	"os"
+	"sync"
+	"time"
 
 	"github.com/b3log/wide/log"
 	"github.com/b3log/wide/util"
@@ -33,11 +35,47 @@ const (
 	EvtCodeIDEStubNotFound
 	// EvtCodeServerInternalError indicates an event: server internal error
 	EvtCodeServerInternalError
+
+	// EvtCodeBuildStarted indicates a lifecycle event: build started
+	EvtCodeBuildStarted
+	// EvtCodeBuildDone indicates a lifecycle event: build done
+	EvtCodeBuildDone
+	// EvtCodeRunStarted indicates a lifecycle event: run started
+	EvtCodeRunStarted
+	// EvtCodeRunExited indicates a lifecycle event: run exited
+	EvtCodeRunExited
+	// EvtCodeTestStarted indicates a lifecycle event: go test started
+	EvtCodeTestStarted
+	// EvtCodeTestDone indicates a lifecycle event: go test done
+	EvtCodeTestDone
+	// EvtCodeFileSaved indicates a lifecycle event: file saved
+	EvtCodeFileSaved
+	// EvtCodeFileCreated indicates a lifecycle event: file or directory created
+	EvtCodeFileCreated
+	// EvtCodeFileRemoved indicates a lifecycle event: file or directory removed
+	EvtCodeFileRemoved
+	// EvtCodeFileRenamed indicates a lifecycle event: file or directory renamed
+	EvtCodeFileRenamed
+	// EvtCodeServerShutdown indicates an event: server is shutting down
+	EvtCodeServerShutdown
+	// EvtCodeSessionCreated indicates a lifecycle event: wide session created
+	EvtCodeSessionCreated
+	// EvtCodeStartupSucceeded indicates an event: startup command of the user succeeded
+	EvtCodeStartupSucceeded
+	// EvtCodeStartupFailed indicates an event: startup command of the user failed
+	EvtCodeStartupFailed
+	// EvtCodeSessionRemoved indicates a lifecycle event: wide session removed
+	EvtCodeSessionRemoved
+	// EvtCodeUserDeleted indicates a lifecycle event: user deleted, the data is the workspace path of the user
+	EvtCodeUserDeleted
 )
 
 // Max length of queue.
 const maxQueueLength = 10
 
+// Max length of lifecycle queue.
+const maxLifecycleQueueLength = 128
+
 // Logger.
 var logger = log.NewLogger(os.Stdout)
 
@@ -55,9 +93,10 @@ var EventQueue = make(chan *Event, maxQueueLength)
 
 // UserEventQueue represents a user event queue.
 type UserEventQueue struct {
-	Sid      string      // wide session id related
-	Queue    chan *Event // queue
-	Handlers []Handler   // event handlers
+	Sid      string       // wide session id related
+	Queue    chan *Event  // queue
+	Handlers []Handler    // event handlers
+	mutex    sync.RWMutex // exclusive lock for Handlers
 }
 
 type queues map[string]*UserEventQueue
@@ -67,8 +106,77 @@ type queues map[string]*UserEventQueue
 // <sid, *UserEventQueue>
 var UserEventQueues = queues{}
 
+// Lifecycle represents the data of a lifecycle event.
+type Lifecycle struct {
+	Username string `json:"username"` // user related
+	Path     string `json:"path"`     // file or directory path related
+	NewPath  string `json:"newPath"`  // new path, file renamed event only
+	Pid      int    `json:"pid"`      // process id, run events only
+	Succ     bool   `json:"succ"`     // successful or not, done/exited events only
+
+	Elapsed time.Duration `json:"elapsed"` // elapsed time since started, done/exited events only
+	Errors  []string      `json:"errors"`  // first errors (such as compiler errors or failed tests), done events only
+}
+
+// Lifecycle event queue.
+//
+// Every event in this queue will be dispatched to handlers subscribed to its code.
+var lifecycleQueue = make(chan *Event, maxLifecycleQueueLength)
+
+// Lifecycle event subscribers.
+//
+// <code, []Handler>
+var subscribers = map[int][]Handler{}
+
+// Exclusive lock for subscribers.
+var subscribersMutex sync.RWMutex
+
+// Subscribe subscribes the specified handlers to lifecycle events with the specified code.
+//
+// Handlers will be invoked in a dedicated goroutine in order of publishing, so a handler should not block for long.
+func Subscribe(code int, handlers ...Handler) {
+	subscribersMutex.Lock()
+	defer subscribersMutex.Unlock()
+
+	subscribers[code] = append(subscribers[code], handlers...)
+}
+
+// Publish publishes the specified lifecycle event to its subscribers.
+//
+// This function never blocks the caller, the event will be discarded if the lifecycle queue is full.
+func Publish(e *Event) {
+	select {
+	case lifecycleQueue <- e:
+	default:
+		logger.Warnf("Lifecycle queue is full, discarded an event [code=%d, sid=%s]", e.Code, e.Sid)
+	}
+}
+
+// dispatch invokes each handler subscribed to the code of the specified event.
+func dispatch(e *Event) {
+	subscribersMutex.RLock()
+	handlers := subscribers[e.Code]
+	subscribersMutex.RUnlock()
+
+	for _, handler := range handlers {
+		func() {
+			defer util.Recover()
+
+			handler.Handle(e)
+		}()
+	}
+}
+
 // Load initializes the event handling.
 func Load() {
+	go func() {
+		for e := range lifecycleQueue {
+			logger.Tracef("Received a lifecycle event [code=%d, sid=%s]", e.Code, e.Sid)
+
+			dispatch(e)
+		}
+	}()
+
 	go func() {
 		defer util.Recover()
 
@@ -87,6 +195,9 @@ func Load() {
 
 // AddHandler adds the specified handlers to user event queues.
 func (uq *UserEventQueue) AddHandler(handlers ...Handler) {
+	uq.mutex.Lock()
+	defer uq.mutex.Unlock()
+
 	uq.Handlers = append(uq.Handlers, handlers...)
 }
 
@@ -112,8 +223,12 @@ func (ueqs queues) New(sid string) *UserEventQueue {
 		for evt := range q.Queue {
 			logger.Debugf("Session [%s] received an event [%d]", sid, evt.Code)
 
+			q.mutex.RLock()
+			handlers := q.Handlers
+			q.mutex.RUnlock()
+
 			// process event by each handlers
-			for _, handler := range q.Handlers {
+			for _, handler := range handlers {
 				handler.Handle(evt)
 			}
 		}
@@ -0,0 +1,204 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// batchResult represents the result of a batch operation on a path.
+type batchResult struct {
+	Path    string `json:"path"`
+	NewPath string `json:"newPath,omitempty"`
+	Succ    bool   `json:"succ"`
+	Msg     string `json:"msg,omitempty"`
+}
+
+// batchArgs represents arguments of batch operations.
+type batchArgs struct {
+	Sid       string   `json:"sid"`
+	Paths     []string `json:"paths"`
+	Dir       string   `json:"dir"`       // target directory of moving
+	Permanent bool     `json:"permanent"` // removes permanently or moves into trash
+}
+
+// BatchRemoveFileHandler handles request of removing files and directories.
+//
+// Continues on failure and reports the result of each path, the data also contains the refreshed children of the
+// affected directories.
+func BatchRemoveFileHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	args := &batchArgs{}
+	if err := json.NewDecoder(r.Body).Decode(args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	results := []*batchResult{}
+	dirs := map[string]bool{}
+	for _, path := range args.Paths {
+		path = filepath.Clean(filepath.FromSlash(path))
+		ret := &batchResult{Path: filepath.ToSlash(path)}
+		results = append(results, ret)
+
+		if util.Go.IsAPI(path) || !session.CanAccess(username, path) {
+			ret.Msg = "Forbidden"
+
+			continue
+		}
+
+		if err := checkFileLock(path); nil != err {
+			ret.Msg = err.Error()
+
+			continue
+		}
+
+		if args.Permanent {
+			ret.Succ = removeFile(path)
+		} else {
+			ret.Succ = moveToTrash(username, path)
+		}
+
+		if !ret.Succ {
+			ret.Msg = "can't remove file " + path
+
+			continue
+		}
+
+		dirs[filepath.Dir(path)] = true
+
+		event.Publish(&event.Event{Code: event.EvtCodeFileRemoved, Sid: args.Sid,
+			Data: &event.Lifecycle{Username: username, Path: path, Succ: true}})
+	}
+
+	logger.Debugf("User [%s] removed paths in batch", username)
+
+	result.Data = map[string]interface{}{"results": results, "refresh": getRefreshNodes(dirs, getUserTreeOrder(username))}
+}
+
+// BatchMoveFileHandler handles request of moving files and directories into a directory.
+//
+// Continues on failure and reports the result of each path, the data also contains the refreshed children of the
+// affected directories.
+func BatchMoveFileHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	args := &batchArgs{}
+	if err := json.NewDecoder(r.Body).Decode(args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	dir := filepath.Clean(filepath.FromSlash(args.Dir))
+	if util.Go.IsAPI(dir) || !session.CanAccess(username, dir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if !util.File.IsDir(dir) {
+		result.Succ = false
+		result.Msg = "Can't find directory [" + args.Dir + "]"
+
+		return
+	}
+
+	results := []*batchResult{}
+	dirs := map[string]bool{dir: true}
+	for _, path := range args.Paths {
+		path = filepath.Clean(filepath.FromSlash(path))
+		newPath := filepath.Join(dir, filepath.Base(path))
+		ret := &batchResult{Path: filepath.ToSlash(path), NewPath: filepath.ToSlash(newPath)}
+		results = append(results, ret)
+
+		if util.Go.IsAPI(path) || !session.CanAccess(username, path) {
+			ret.Msg = "Forbidden"
+
+			continue
+		}
+
+		if err := checkFileLock(path); nil != err {
+			ret.Msg = err.Error()
+
+			continue
+		}
+
+		if util.File.IsExist(newPath) {
+			ret.Msg = "[" + newPath + "] already exists"
+
+			continue
+		}
+
+		if ret.Succ = renameFile(path, newPath); !ret.Succ {
+			ret.Msg = "can't rename file " + path
+
+			continue
+		}
+
+		dirs[filepath.Dir(path)] = true
+
+		event.Publish(&event.Event{Code: event.EvtCodeFileRenamed, Sid: args.Sid,
+			Data: &event.Lifecycle{Username: username, Path: path, NewPath: newPath, Succ: true}})
+	}
+
+	logger.Debugf("User [%s] moved paths in batch to [%s]", username, dir)
+
+	result.Data = map[string]interface{}{"results": results, "refresh": getRefreshNodes(dirs, getUserTreeOrder(username))}
+}
+
+// getRefreshNodes gets the children nodes (sorted by the specified order) of the specified directories for file tree
+// refreshing.
+//
+// <dir, children>
+func getRefreshNodes(dirs map[string]bool, order treeOrder) map[string][]*Node {
+	ret := map[string][]*Node{}
+	for dir := range dirs {
+		node := Node{Name: "root", Path: dir, IconSkin: "ico-ztree-dir ", Type: "d", Children: []*Node{}}
+		walk(dir, &node, true, true, false, order)
+
+		ret[filepath.ToSlash(dir)] = node.Children
+	}
+
+	return ret
+}
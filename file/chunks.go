@@ -0,0 +1,299 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+const (
+	uploadMaxChunks   = 10000          // max chunk count of an upload
+	uploadAbandonedIn = 24 * time.Hour // partial uploads not touched within this duration will be removed
+)
+
+// Valid upload id.
+var uploadIDRegexp = regexp.MustCompile("^[A-Za-z0-9_-]{1,64}$")
+
+// Exclusive lock of assembling uploads.
+var uploadsMutex sync.Mutex
+
+// UploadChunkHandler handles request of chunked (resumable) file upload.
+//
+// Query parameters: path is the target directory, name is the file name, uploadId identifies the upload, index is
+// the chunk index (starts with 0) and total is the chunk count. The chunk is the request body, or the first file of
+// a multipart request body.
+//
+// Chunks are saved into a temporary directory and assembled after the last one arrived. A GET request returns the
+// received chunk indices, so the client can resume by uploading the missing ones.
+func UploadChunkHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	q := r.URL.Query()
+	uploadID := q.Get("uploadId")
+	total, _ := strconv.Atoi(q.Get("total"))
+	name := filepath.Base(filepath.FromSlash(q.Get("name")))
+	if !uploadIDRegexp.MatchString(uploadID) || 1 > total || total > uploadMaxChunks || "." == name || "" == name ||
+		string(filepath.Separator) == name {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+
+		return
+	}
+
+	path := filepath.Join(filepath.Clean(filepath.FromSlash(q.Get("path"))), name)
+	if util.Go.IsAPI(path) || !session.CanAccess(username, path) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	chunkDir := filepath.Join(getUploadsDir(), username+"-"+uploadID)
+	data := map[string]interface{}{"path": filepath.ToSlash(path), "total": total, "done": false}
+	result.Data = data
+
+	if http.MethodGet == r.Method {
+		data["received"] = getReceivedChunks(chunkDir)
+
+		return
+	}
+
+	index, err := strconv.Atoi(q.Get("index"))
+	if nil != err || 0 > index || index >= total {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+
+		return
+	}
+
+	// received chunks are not in the workspace yet, counts them as well
+	pending := getChunksSize(chunkDir, index)
+	size := pending
+	if 0 < r.ContentLength {
+		size += r.ContentLength
+	}
+	if err := checkQuota(username, path, size); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	if err := saveChunk(r, chunkDir, index, getRemainingQuota(username, path, pending)); nil != err {
+		logger.Errorf("Saves chunk [%d] of upload [%s] for user [%s] failed: %s", index, uploadID, username, err)
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	uploadsMutex.Lock()
+	defer uploadsMutex.Unlock()
+
+	received := getReceivedChunks(chunkDir)
+	data["received"] = received
+	if len(received) < total {
+		return
+	}
+
+	if err := assembleChunks(chunkDir, total, path); nil != err {
+		logger.Errorf("Assembles upload [%s] for user [%s] failed: %s", uploadID, username, err)
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	os.RemoveAll(chunkDir)
+	data["done"] = true
+	addUsage(username, path, util.File.GetFileSize(path))
+
+	logger.Debugf("User [%s] uploaded [%s] in [%d] chunks", username, path, total)
+}
+
+// FixedTimeCleanUploads removes abandoned partial uploads periodically (1 hour).
+func FixedTimeCleanUploads() {
+	go func() {
+		defer util.Recover()
+
+		for _ = range time.Tick(time.Hour) {
+			dirs, err := ioutil.ReadDir(getUploadsDir())
+			if nil != err {
+				continue
+			}
+
+			threshold := time.Now().Add(-uploadAbandonedIn)
+			for _, dir := range dirs {
+				if dir.ModTime().Before(threshold) {
+					logger.Debugf("Removes abandoned upload [%s]", dir.Name())
+
+					os.RemoveAll(filepath.Join(getUploadsDir(), dir.Name()))
+				}
+			}
+		}
+	}()
+}
+
+// getUploadsDir gets the temporary directory of partial uploads.
+func getUploadsDir() string {
+	return filepath.Join(os.TempDir(), "wide-uploads")
+}
+
+// saveChunk saves the chunk with the specified index of the specified request into the specified chunk directory,
+// returns errQuotaExceeded if the chunk is larger than the specified remaining quota.
+func saveChunk(r *http.Request, chunkDir string, index int, remaining int64) error {
+	if err := os.MkdirAll(chunkDir, 0755); nil != err {
+		return err
+	}
+
+	var reader io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		mr, err := r.MultipartReader()
+		if nil != err {
+			return err
+		}
+
+		for {
+			part, err := mr.NextPart()
+			if nil != err {
+				return err
+			}
+
+			if "" != part.FileName() {
+				reader = part
+
+				break
+			}
+		}
+	}
+
+	chunkPath := filepath.Join(chunkDir, strconv.Itoa(index))
+	tmp := chunkPath + ".tmp"
+	f, err := os.Create(tmp)
+	if nil != err {
+		return err
+	}
+
+	_, err = copyWithinQuota(f, reader, remaining)
+	f.Close()
+	if nil != err {
+		os.Remove(tmp)
+
+		return err
+	}
+
+	// renames after written, so a broken chunk will not be treated as received
+	if err := os.Rename(tmp, chunkPath); nil != err {
+		return err
+	}
+
+	now := time.Now()
+
+	return os.Chtimes(chunkDir, now, now)
+}
+
+// getReceivedChunks gets the sorted indices of chunks in the specified chunk directory.
+func getReceivedChunks(chunkDir string) []int {
+	ret := []int{}
+
+	files, err := ioutil.ReadDir(chunkDir)
+	if nil != err {
+		return ret
+	}
+
+	for _, f := range files {
+		if index, err := strconv.Atoi(f.Name()); nil == err {
+			ret = append(ret, index)
+		}
+	}
+
+	sort.Ints(ret)
+
+	return ret
+}
+
+// getChunksSize gets the total size of chunks in the specified chunk directory except the one with the specified
+// index (which is being received again).
+func getChunksSize(chunkDir string, except int) int64 {
+	files, err := ioutil.ReadDir(chunkDir)
+	if nil != err {
+		return 0
+	}
+
+	var ret int64
+	for _, f := range files {
+		if index, err := strconv.Atoi(f.Name()); nil == err && index != except {
+			ret += f.Size()
+		}
+	}
+
+	return ret
+}
+
+// assembleChunks assembles chunks in the specified chunk directory to the file specified by path.
+func assembleChunks(chunkDir string, total int, path string) error {
+	tmp := filepath.Join(chunkDir, "assembled")
+	f, err := os.Create(tmp)
+	if nil != err {
+		return err
+	}
+
+	for i := 0; i < total; i++ {
+		chunk, err := os.Open(filepath.Join(chunkDir, strconv.Itoa(i)))
+		if nil != err {
+			f.Close()
+
+			return err
+		}
+
+		_, err = io.Copy(f, chunk)
+		chunk.Close()
+		if nil != err {
+			f.Close()
+
+			return err
+		}
+	}
+
+	if err := f.Close(); nil != err {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); nil == err {
+		return nil
+	}
+
+	// the temporary directory may be on another device, copies it
+	return util.File.CopyFile(tmp, path)
+}
@@ -0,0 +1,335 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// CopyFileHandler handles request of duplicating the file or directory specified by argument "path" into the
+// directory specified by argument "dest" (defaults to the parent of the source), directories are copied recursively
+// with permissions.
+//
+// The copy is named after the source, suffixed if exists ("foo copy.go", "foo copy 2.go"). Symbolic links are copied
+// as regular files, or kept as links if argument "preserveSymlinks" is true, links pointing out of the workspace are
+// skipped. Returns the new file tree node.
+func CopyFileHandler(w http.ResponseWriter, r *http.Request) {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	var args map[string]interface{}
+
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	source, _ := args["path"].(string)
+	srcPath, err := session.SafePath(username, source)
+	if util.Go.IsAPI(srcPath) || nil != err {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	dest, _ := args["dest"].(string)
+	if "" == dest {
+		dest = filepath.Dir(srcPath)
+	}
+	destDir, err := session.SafePath(username, dest)
+	if util.Go.IsAPI(destDir) || nil != err {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	srcInfo, err := os.Stat(srcPath)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	if !util.File.IsDir(destDir) {
+		result.Succ = false
+		result.Msg = "[" + destDir + "] is not a directory"
+
+		return
+	}
+
+	realSrc, _ := filepath.EvalSymlinks(srcPath)
+	realDest, _ := filepath.EvalSymlinks(destDir)
+	if srcInfo.IsDir() && (realSrc == realDest || strings.HasPrefix(realDest, realSrc+string(filepath.Separator))) {
+		result.Succ = false
+		result.Msg = "can't copy [" + srcPath + "] into itself"
+
+		return
+	}
+
+	preserveSymlinks, _ := args["preserveSymlinks"].(bool)
+	c := &copier{username: username, preserveSymlinks: preserveSymlinks, visiting: map[string]bool{}}
+
+	c.measure(srcPath)
+	if err := checkQuota(username, destDir, c.size); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	destPath := getCopyPath(destDir, filepath.Base(srcPath), srcInfo.IsDir())
+	if err := c.copy(srcPath, destPath); nil != err {
+		logger.Error(err)
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	addUsage(username, destDir, c.size)
+
+	logger.Debugf("Copied [%s] to [%s] by user [%s]", srcPath, destPath, username)
+
+	name := filepath.Base(destPath)
+	node := &Node{
+		Id:        filepath.ToSlash(destPath),
+		Name:      name,
+		Path:      filepath.ToSlash(destPath),
+		Creatable: true,
+		Removable: true,
+		Children:  []*Node{}}
+	if srcInfo.IsDir() {
+		node.Type = "d"
+		node.IconSkin = "ico-ztree-dir "
+		node.IsParent = true
+
+		walk(destPath, node, true, true, false, getUserTreeOrder(username))
+	} else {
+		node.Type = "f"
+		node.IconSkin = getIconSkin(filepath.Ext(name))
+	}
+	result.Data = node
+
+	sid, _ := args["sid"].(string)
+	event.Publish(&event.Event{Code: event.EvtCodeFileCreated, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: destPath, Succ: true}})
+}
+
+// getCopyPath gets a non-existing path in the specified directory for a copy of the specified name.
+func getCopyPath(dir, name string, isDir bool) string {
+	ext := ""
+	if !isDir {
+		ext = filepath.Ext(name)
+	}
+	base := strings.TrimSuffix(name, ext)
+
+	ret := filepath.Join(dir, name)
+	for i := 1; util.File.IsExist(ret); i++ {
+		suffix := " copy"
+		if 1 < i {
+			suffix = fmt.Sprintf(" copy %d", i)
+		}
+
+		ret = filepath.Join(dir, base+suffix+ext)
+	}
+
+	return ret
+}
+
+// copier copies files and directories of a user.
+type copier struct {
+	username         string
+	preserveSymlinks bool            // whether keeps symbolic links as links
+	size             int64           // total size of files to copy, see measure
+	visiting         map[string]bool // real paths of directories being copied, for symbolic link loops
+}
+
+// enter marks the specified directory is being visited, returns false if it's visited already (a link loop).
+func (c *copier) enter(dir string) bool {
+	real, err := filepath.EvalSymlinks(dir)
+	if nil != err || c.visiting[real] {
+		logger.Warnf("Skipped directory [%s] in a link loop", dir)
+
+		return false
+	}
+	c.visiting[real] = true
+
+	return true
+}
+
+// leave unmarks the specified directory visited by enter.
+func (c *copier) leave(dir string) {
+	real, _ := filepath.EvalSymlinks(dir)
+	delete(c.visiting, real)
+}
+
+// isSafeLink checks whether the specified symbolic link points to a path in the workspace of the user.
+func (c *copier) isSafeLink(path string) bool {
+	if _, err := session.SafePath(c.username, path); nil != err {
+		logger.Warnf("Skipped link [%s] pointing out of the workspace of user [%s]", path, c.username)
+
+		return false
+	}
+
+	return true
+}
+
+// measure measures the total size of files to copy from the specified path.
+func (c *copier) measure(path string) {
+	info, err := os.Lstat(path)
+	if nil != err {
+		return
+	}
+
+	if os.ModeSymlink == info.Mode()&os.ModeSymlink {
+		if c.preserveSymlinks || !c.isSafeLink(path) {
+			return
+		}
+
+		if info, err = os.Stat(path); nil != err {
+			return
+		}
+	}
+
+	if !info.IsDir() {
+		c.size += info.Size()
+
+		return
+	}
+
+	if !c.enter(path) {
+		return
+	}
+	defer c.leave(path)
+
+	for _, name := range readDirNames(path) {
+		c.measure(filepath.Join(path, name))
+	}
+}
+
+// copy copies the specified source to the specified dest recursively.
+func (c *copier) copy(source, dest string) error {
+	info, err := os.Lstat(source)
+	if nil != err {
+		return err
+	}
+
+	if os.ModeSymlink == info.Mode()&os.ModeSymlink {
+		if !c.isSafeLink(source) {
+			return nil
+		}
+
+		if c.preserveSymlinks {
+			target, err := os.Readlink(source)
+			if nil != err {
+				return err
+			}
+
+			return os.Symlink(target, dest)
+		}
+
+		if info, err = os.Stat(source); nil != err {
+			return err
+		}
+	}
+
+	if !info.IsDir() {
+		return copyRegularFile(source, dest, info.Mode().Perm())
+	}
+
+	if !c.enter(source) {
+		return nil
+	}
+	defer c.leave(source)
+
+	if err := os.Mkdir(dest, info.Mode().Perm()); nil != err {
+		return err
+	}
+
+	if real, err := filepath.EvalSymlinks(dest); nil == err { // never copies the copy through a link
+		c.visiting[real] = true
+	}
+
+	for _, name := range readDirNames(source) {
+		if err := c.copy(filepath.Join(source, name), filepath.Join(dest, name)); nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readDirNames reads names of all entries in the specified directory, unlike listFiles nothing is excluded.
+func readDirNames(dir string) []string {
+	f, err := os.Open(dir)
+	if nil != err {
+		logger.Warn(err)
+
+		return []string{}
+	}
+	defer f.Close()
+
+	names, _ := f.Readdirnames(-1)
+
+	return names
+}
+
+// copyRegularFile copies content of the specified source file to the specified dest file with the specified
+// permissions.
+func copyRegularFile(source, dest string, perm os.FileMode) error {
+	in, err := os.Open(source)
+	if nil != err {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if nil != err {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); nil != err {
+		out.Close()
+
+		return err
+	}
+
+	if err := out.Close(); nil != err {
+		return err
+	}
+
+	return os.Chmod(dest, perm) // not affected by umask
+}
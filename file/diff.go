@@ -0,0 +1,155 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+const (
+	diffMaxSize     = 5 * 1024 * 1024 // max size of each side to diff (5M)
+	diffMaxEdits    = 1000            // max edit distance searched, a larger diff is returned inexactly
+	diffContext     = 3               // default count of context lines of hunks
+	diffFormatUnify = "unified"       // unified diff text
+	diffFormatLines = "structured"    // hunks with per-line operations
+)
+
+// DiffHandler handles request of diffing two files, or a file against the specified content.
+//
+// Argument "file" specifies the old side. The new side is the file specified by argument "other", or argument "code"
+// (an editor buffer for example) if "other" is not specified. Argument "format" is unified (default) or structured,
+// argument "context" is the count of context lines of hunks (defaults to 3).
+//
+// The data contains "exact", false if the diff is too large to find the minimal one.
+func DiffHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	oldPath := filepath.Clean(filepath.FromSlash(args["file"].(string)))
+	if !session.CanAccess(username, oldPath) && !util.Go.IsAPI(oldPath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	oldContent, err := readDiffSide(oldPath)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	newName := oldPath
+	newContent := ""
+	if other, _ := args["other"].(string); "" != other {
+		newName = filepath.Clean(filepath.FromSlash(other))
+		if !session.CanAccess(username, newName) && !util.Go.IsAPI(newName) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
+
+		if newContent, err = readDiffSide(newName); nil != err {
+			result.Succ = false
+			result.Msg = err.Error()
+
+			return
+		}
+	} else if code, ok := args["code"].(string); ok {
+		if diffMaxSize < len(code) {
+			result.Succ = false
+			result.Msg = "content is too large to diff"
+
+			return
+		}
+
+		newContent = code
+	} else {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+
+		return
+	}
+
+	context := diffContext
+	if c, ok := args["context"].(float64); ok && 0 <= c {
+		context = int(c)
+	}
+
+	lines, exact := util.Diff.Lines(util.Diff.SplitLines(oldContent), util.Diff.SplitLines(newContent), diffMaxEdits)
+	hunks := util.Diff.Hunks(lines, context)
+
+	data := map[string]interface{}{"exact": exact}
+	switch format, _ := args["format"].(string); format {
+	case "", diffFormatUnify:
+		data["format"] = diffFormatUnify
+		data["diff"] = util.Diff.Unified(filepath.ToSlash(oldPath), filepath.ToSlash(newName), hunks)
+	case diffFormatLines:
+		data["format"] = diffFormatLines
+		data["hunks"] = hunks
+	default:
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+
+		return
+	}
+
+	result.Data = data
+}
+
+// readDiffSide reads content of the file specified by path for diffing.
+func readDiffSide(path string) (string, error) {
+	if util.File.IsDir(path) {
+		return "", errors.New(filepath.Base(path) + " is a directory")
+	}
+
+	if diffMaxSize < util.File.GetFileSize(path) {
+		return "", errors.New(filepath.Base(path) + " is too large to diff")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return "", err
+	}
+
+	content := string(data)
+	if util.File.IsBinary(content) {
+		return "", errors.New("can't diff a binary file " + filepath.Base(path))
+	}
+
+	return content, nil
+}
@@ -0,0 +1,245 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+const (
+	draftDirName  = ".wide-drafts"  // draft directory name under user workspace
+	draftMaxSize  = 5 * 1024 * 1024 // max content size of a draft (5M)
+	draftMaxCount = 64              // max count of drafts of a user, the oldest ones are removed if exceeding
+)
+
+// draft represents unsaved content of a file autosaved by the editor.
+type draft struct {
+	Path    string    `json:"path"`    // path of the file
+	Content string    `json:"content"` // unsaved content
+	Saved   time.Time `json:"saved"`   // autosaved time
+}
+
+// Exclusive lock of drafts.
+var draftMutex sync.Mutex
+
+// SaveDraftHandler handles request of autosaving a draft of a file.
+//
+// The draft is stored separately and the file is left untouched, it will be offered to restore when the file is
+// opened next time if it is newer than the file.
+func SaveDraftHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	filePath := args["file"].(string)
+	if util.Go.IsAPI(filePath) || !session.CanAccess(username, filePath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	code := args["code"].(string)
+	if draftMaxSize < len(code) {
+		result.Succ = false
+		result.Msg = "draft is too large"
+
+		return
+	}
+
+	if err := saveDraft(username, &draft{Path: filePath, Content: code, Saved: time.Now()}); nil != err {
+		logger.Errorf("Saves draft of [%s] for user [%s] failed: %s", filePath, username, err)
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+}
+
+// DiscardDraftHandler handles request of discarding the draft of a file.
+func DiscardDraftHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	filePath := args["file"].(string)
+	if util.Go.IsAPI(filePath) || !session.CanAccess(username, filePath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	removeDraft(username, filePath)
+}
+
+// getNewerDraft gets the draft of the file specified by path for the user specified by username, returns nil if not
+// found or it is not newer than the file.
+func getNewerDraft(username, path string) *draft {
+	draftMutex.Lock()
+	defer draftMutex.Unlock()
+
+	data, err := ioutil.ReadFile(getDraftPath(username, path))
+	if nil != err {
+		return nil
+	}
+
+	ret := &draft{}
+	if err := json.Unmarshal(data, ret); nil != err {
+		logger.Warn(err)
+
+		return nil
+	}
+
+	if ret.Path != path { // hash collision
+		return nil
+	}
+
+	if info, err := os.Stat(path); nil == err && !ret.Saved.After(info.ModTime()) {
+		return nil
+	}
+
+	return ret
+}
+
+// saveDraft saves the specified draft for the user specified by username, the oldest drafts are removed if the
+// count exceeds draftMaxCount.
+//
+// Drafts are under the user workspace so they are counted toward the quota, returns errQuotaExceeded if exceeding.
+func saveDraft(username string, d *draft) error {
+	draftMutex.Lock()
+	defer draftMutex.Unlock()
+
+	dir := getDraftDir(username)
+	if err := os.MkdirAll(dir, 0755); nil != err {
+		return err
+	}
+
+	data, err := json.Marshal(d)
+	if nil != err {
+		return err
+	}
+
+	path := getDraftPath(username, d.Path)
+	delta := int64(len(data))
+	if info, err := os.Stat(path); nil == err {
+		delta -= info.Size()
+	}
+	if err := checkQuota(username, path, delta); nil != err {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, data, 0644); nil != err {
+		return err
+	}
+	addUsage(username, path, delta)
+
+	infos, err := ioutil.ReadDir(dir)
+	if nil != err {
+		return err
+	}
+
+	if len(infos) <= draftMaxCount {
+		return nil
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().Before(infos[j].ModTime()) })
+	for _, info := range infos[:len(infos)-draftMaxCount] {
+		if err := os.Remove(filepath.Join(dir, info.Name())); nil != err {
+			logger.Warn(err)
+
+			continue
+		}
+
+		addUsage(username, dir, -info.Size())
+	}
+
+	return nil
+}
+
+// removeDraft removes the draft of the file specified by path for the user specified by username.
+func removeDraft(username, path string) {
+	draftMutex.Lock()
+	defer draftMutex.Unlock()
+
+	draftPath := getDraftPath(username, path)
+	info, err := os.Stat(draftPath)
+	if nil != err {
+		return
+	}
+
+	if err := os.Remove(draftPath); nil != err {
+		logger.Warn(err)
+
+		return
+	}
+
+	addUsage(username, draftPath, -info.Size())
+}
+
+// getDraftDir gets the draft directory of the user specified by username.
+func getDraftDir(username string) string {
+	workspaces := filepath.SplitList(conf.GetUserWorkspace(username))
+
+	return filepath.Join(workspaces[0], draftDirName)
+}
+
+// getDraftPath gets the path of the draft of the file specified by path for the user specified by username.
+func getDraftPath(username, path string) string {
+	hash := sha1.Sum([]byte(path))
+
+	return filepath.Join(getDraftDir(username), hex.EncodeToString(hash[:])+".json")
+}
@@ -0,0 +1,321 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/util"
+)
+
+// editorConfigName is the file name of EditorConfig (https://editorconfig.org).
+const editorConfigName = ".editorconfig"
+
+// editorConfig represents the EditorConfig properties applied to a file.
+type editorConfig struct {
+	IndentStyle            string `json:"indentStyle,omitempty"` // "tab" or "space"
+	IndentSize             int    `json:"indentSize,omitempty"`
+	EndOfLine              string `json:"endOfLine,omitempty"` // lineEndingLF, lineEndingCRLF or lineEndingCR
+	InsertFinalNewline     *bool  `json:"insertFinalNewline,omitempty"`
+	TrimTrailingWhitespace *bool  `json:"trimTrailingWhitespace,omitempty"`
+}
+
+// editorConfigSection represents a section of an .editorconfig file.
+type editorConfigSection struct {
+	glob  *regexp.Regexp
+	props map[string]string
+}
+
+// getEditorConfig gets the EditorConfig properties of the file specified by path.
+//
+// .editorconfig files are looked up from the directory of the file to the root of the workspace containing it, stops
+// at a file declaring "root = true". Properties of a closer file take precedence. Returns nil if there is no
+// property.
+func getEditorConfig(username, path string) *editorConfig {
+	root := ""
+	for _, workspace := range filepath.SplitList(conf.GetUserWorkspace(username)) {
+		if strings.HasPrefix(path, workspace+string(os.PathSeparator)) {
+			root = workspace
+
+			break
+		}
+	}
+	if "" == root {
+		return nil
+	}
+
+	var files []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		f := filepath.Join(dir, editorConfigName)
+		if util.File.IsExist(f) {
+			files = append(files, f)
+			if isEditorConfigRoot(f) {
+				break
+			}
+		}
+
+		if dir == root || !strings.HasPrefix(dir, root) {
+			break
+		}
+	}
+
+	props := map[string]string{}
+	for i := len(files) - 1; i >= 0; i-- { // the outermost first
+		f := files[i]
+		rel, _ := filepath.Rel(filepath.Dir(f), path)
+		rel = filepath.ToSlash(rel)
+
+		for _, section := range parseEditorConfig(f) {
+			if !section.glob.MatchString(rel) {
+				continue
+			}
+
+			for k, v := range section.props {
+				props[k] = v
+			}
+		}
+	}
+
+	if 1 > len(props) {
+		return nil
+	}
+
+	ret := &editorConfig{}
+	if v := props["indent_style"]; "tab" == v || "space" == v {
+		ret.IndentStyle = v
+	}
+	if size, err := strconv.Atoi(props["indent_size"]); nil == err && 0 < size {
+		ret.IndentSize = size
+	} else if "tab" == props["indent_size"] {
+		if size, err := strconv.Atoi(props["tab_width"]); nil == err && 0 < size {
+			ret.IndentSize = size
+		}
+	}
+	if _, ok := lineBreaks[props["end_of_line"]]; ok {
+		ret.EndOfLine = props["end_of_line"]
+	}
+	ret.InsertFinalNewline = parseEditorConfigBool(props["insert_final_newline"])
+	ret.TrimTrailingWhitespace = parseEditorConfigBool(props["trim_trailing_whitespace"])
+
+	return ret
+}
+
+// apply applies the newline and whitespace properties to the specified content.
+func (ec *editorConfig) apply(content string) string {
+	if nil != ec.TrimTrailingWhitespace && *ec.TrimTrailingWhitespace {
+		lines := strings.SplitAfter(content, "\n")
+		for i, line := range lines {
+			eol := ""
+			if strings.HasSuffix(line, "\r\n") {
+				eol = "\r\n"
+			} else if strings.HasSuffix(line, "\n") {
+				eol = "\n"
+			}
+
+			lines[i] = strings.TrimRight(strings.TrimSuffix(line, eol), " \t") + eol
+		}
+
+		content = strings.Join(lines, "")
+	}
+
+	eol := lineBreaks[ec.EndOfLine]
+	if "" != eol {
+		content = normalizeLineEndings(content, eol)
+	}
+
+	if nil != ec.InsertFinalNewline && "" != content {
+		trimmed := strings.TrimRight(content, "\r\n")
+		if *ec.InsertFinalNewline {
+			if trimmed == content {
+				if "" == eol {
+					eol = detectLineEnding(content)
+				}
+
+				content += eol
+			}
+		} else {
+			content = trimmed
+		}
+	}
+
+	return content
+}
+
+// parseEditorConfig parses the .editorconfig file specified by path, invalid lines are ignored.
+func parseEditorConfig(path string) []*editorConfigSection {
+	ret := []*editorConfigSection{}
+
+	f, err := os.Open(path)
+	if nil != err {
+		logger.Warn(err)
+
+		return ret
+	}
+	defer f.Close()
+
+	var section *editorConfigSection
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if "" == line || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = nil
+
+			glob, err := regexp.Compile(editorConfigGlob(line[1 : len(line)-1]))
+			if nil != err {
+				logger.Warnf("Invalid section [%s] of [%s]", line, path)
+
+				continue
+			}
+
+			section = &editorConfigSection{glob: glob, props: map[string]string{}}
+			ret = append(ret, section)
+
+			continue
+		}
+
+		if nil == section { // preamble properties, only "root" is defined
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if 2 != len(kv) {
+			continue
+		}
+
+		section.props[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.ToLower(strings.TrimSpace(kv[1]))
+	}
+
+	return ret
+}
+
+// isEditorConfigRoot checks whether the .editorconfig file specified by path declares "root = true".
+func isEditorConfigRoot(path string) bool {
+	f, err := os.Open(path)
+	if nil != err {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			return false
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if 2 == len(kv) && "root" == strings.ToLower(strings.TrimSpace(kv[0])) {
+			return "true" == strings.ToLower(strings.TrimSpace(kv[1]))
+		}
+	}
+
+	return false
+}
+
+// editorConfigGlob converts the specified EditorConfig section glob to a regular expression matching slash separated
+// paths relative to the directory of the .editorconfig file.
+func editorConfigGlob(glob string) string {
+	if !strings.Contains(glob, "/") {
+		glob = "**/" + glob
+	}
+	glob = strings.TrimPrefix(glob, "/")
+
+	buf := "^"
+	braces := 0
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && '*' == glob[i+1] {
+				i++
+				if i+1 < len(glob) && '/' == glob[i+1] {
+					i++
+					buf += "(?:.*/)?"
+				} else {
+					buf += ".*"
+				}
+			} else {
+				buf += "[^/]*"
+			}
+		case '?':
+			buf += "[^/]"
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if 0 > end {
+				buf += `\[`
+
+				continue
+			}
+
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf += "[" + strings.Replace(class, `\`, `\\`, -1) + "]"
+			i += end
+		case '{':
+			braces++
+			buf += "(?:"
+		case '}':
+			if 0 < braces {
+				braces--
+				buf += ")"
+			} else {
+				buf += `\}`
+			}
+		case ',':
+			if 0 < braces {
+				buf += "|"
+			} else {
+				buf += ","
+			}
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				buf += regexp.QuoteMeta(string(glob[i]))
+			}
+		default:
+			buf += regexp.QuoteMeta(string(c))
+		}
+	}
+
+	return buf + "$"
+}
+
+// parseEditorConfigBool parses the specified EditorConfig boolean value, returns nil if unset or invalid.
+func parseEditorConfigBool(value string) *bool {
+	switch value {
+	case "true":
+		ret := true
+
+		return &ret
+	case "false":
+		ret := false
+
+		return &ret
+	}
+
+	return nil
+}
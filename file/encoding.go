@@ -0,0 +1,339 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+// Encodings of text files.
+const (
+	encodingUTF8    = "utf-8"
+	encodingUTF8BOM = "utf-8-bom"
+	encodingUTF16LE = "utf-16le" // with BOM
+	encodingUTF16BE = "utf-16be" // with BOM
+	encodingGBK     = "gbk"
+	encodingGB18030 = "gb18030"
+	encodingLatin1  = "latin1" // ISO-8859-1
+)
+
+// Supported encodings.
+var encodings = []string{encodingUTF8, encodingUTF8BOM, encodingUTF16LE, encodingUTF16BE, encodingGBK, encodingGB18030,
+	encodingLatin1}
+
+// Byte order marks.
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// EncodingHandler handles request of getting or converting the encoding of the file specified by argument "path".
+//
+// Returns the detected encoding (data "encoding", empty if the file is binary) and the supported encodings (data
+// "encodings"). If argument "to" is specified, the file is converted from the detected encoding (or the one specified
+// by argument "from" if the detection is wrong) to it.
+func EncodingHandler(w http.ResponseWriter, r *http.Request) {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	path, _ := args["path"].(string)
+	path, err := session.SafePath(username, path)
+	if util.Go.IsAPI(path) || nil != err || !util.File.IsExist(path) || util.File.IsDir(path) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if fileMaxSize < util.File.GetFileSize(path) {
+		result.Succ = false
+		result.Msg = "This file is too large to open :("
+
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	from := detectEncoding(data)
+	if enc, _ := args["from"].(string); "" != enc {
+		from = enc
+	}
+	result.Data = map[string]interface{}{"encoding": from, "encodings": encodings}
+
+	to, _ := args["to"].(string)
+	if "" == to {
+		return
+	}
+
+	if err := checkFileLock(path); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	content, err := decodeContent(data, from)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	converted, err := encodeContent(content, to)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	delta := int64(len(converted) - len(data))
+	if err := checkQuota(username, path, delta); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	saveVersion(username, path, string(converted))
+
+	info, _ := os.Stat(path)
+	if err := ioutil.WriteFile(path, converted, info.Mode()); nil != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	addUsage(username, path, delta)
+	result.Data = map[string]interface{}{"encoding": to, "encodings": encodings}
+}
+
+// detectEncoding detects the encoding of the specified content by the byte order mark, or by checking whether it
+// could be decoded cleanly. Returns "" if no encoding fits, that is the content is binary.
+func detectEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return encodingUTF8BOM
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return encodingUTF16LE
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return encodingUTF16BE
+	}
+
+	if 0 <= bytes.IndexByte(data, 0) {
+		return ""
+	}
+
+	if utf8.Valid(data) {
+		return encodingUTF8
+	}
+
+	if isGBK(data) {
+		return encodingGBK
+	}
+
+	if isLatin1(data) {
+		return encodingLatin1
+	}
+
+	return ""
+}
+
+// isGBK checks whether the specified content is GBK encoded text, that is it's decoded without invalid bytes and
+// the non-ASCII characters are mostly Chinese.
+func isGBK(data []byte) bool {
+	decoded, _, err := transform.Bytes(simplifiedchinese.GBK.NewDecoder(), data)
+	if nil != err || 0 <= bytes.IndexRune(decoded, utf8.RuneError) {
+		return false
+	}
+
+	nonASCII, cjk := 0, 0
+	for _, r := range string(decoded) {
+		if utf8.RuneSelf > r {
+			continue
+		}
+
+		nonASCII++
+		if unicode.Is(unicode.Han, r) || (0x3000 <= r && 0x303F >= r) || (0xFF00 <= r && 0xFFEF >= r) {
+			cjk++
+		}
+	}
+
+	return cjk*10 >= nonASCII*8
+}
+
+// isLatin1 checks whether the specified content is ISO-8859-1 encoded text, that is it contains no control
+// characters but whitespaces.
+func isLatin1(data []byte) bool {
+	for _, b := range data {
+		if ('\t' != b && '\n' != b && '\r' != b && '\f' != b && 0x20 > b) || (0x7F <= b && 0x9F >= b) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// decodeContent decodes the specified content of the specified encoding to UTF-8.
+func decodeContent(data []byte, encoding string) (string, error) {
+	switch encoding {
+	case encodingUTF8:
+		return string(data), nil
+	case encodingUTF8BOM:
+		return string(bytes.TrimPrefix(data, bomUTF8)), nil
+	case encodingUTF16LE, encodingUTF16BE:
+		var order binary.ByteOrder = binary.LittleEndian
+		bom := bomUTF16LE
+		if encodingUTF16BE == encoding {
+			order, bom = binary.BigEndian, bomUTF16BE
+		}
+
+		data = bytes.TrimPrefix(data, bom)
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[i*2:])
+		}
+
+		return string(utf16.Decode(units)), nil
+	case encodingGBK:
+		ret, _, err := transform.Bytes(simplifiedchinese.GBK.NewDecoder(), data)
+
+		return string(ret), err
+	case encodingGB18030:
+		ret, _, err := transform.Bytes(simplifiedchinese.GB18030.NewDecoder(), data)
+
+		return string(ret), err
+	case encodingLatin1:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+
+		return string(runes), nil
+	}
+
+	return "", errors.New("unsupported encoding [" + encoding + "]")
+}
+
+// encodeContent encodes the specified UTF-8 content to the specified encoding, returns an error if some characters
+// can't be represented in the encoding.
+func encodeContent(content, encoding string) ([]byte, error) {
+	errUnrepresentable := errors.New("the content can't be saved in " + encoding + ", please convert the file to " +
+		encodingUTF8)
+
+	switch encoding {
+	case encodingUTF8:
+		return []byte(content), nil
+	case encodingUTF8BOM:
+		return append(append([]byte{}, bomUTF8...), content...), nil
+	case encodingUTF16LE, encodingUTF16BE:
+		var order binary.ByteOrder = binary.LittleEndian
+		bom := bomUTF16LE
+		if encodingUTF16BE == encoding {
+			order, bom = binary.BigEndian, bomUTF16BE
+		}
+
+		units := utf16.Encode([]rune(content))
+		ret := make([]byte, len(bom)+len(units)*2)
+		copy(ret, bom)
+		for i, unit := range units {
+			order.PutUint16(ret[len(bom)+i*2:], unit)
+		}
+
+		return ret, nil
+	case encodingGBK, encodingGB18030:
+		enc := simplifiedchinese.GBK
+		if encodingGB18030 == encoding {
+			enc = simplifiedchinese.GB18030
+		}
+
+		// the encoder may replace unrepresentable characters silently, so checks by decoding back
+		ret, _, err := transform.Bytes(enc.NewEncoder(), []byte(content))
+		if nil != err {
+			return nil, errUnrepresentable
+		}
+		if decoded, _, err := transform.Bytes(enc.NewDecoder(), ret); nil != err || string(decoded) != content {
+			return nil, errUnrepresentable
+		}
+
+		return ret, nil
+	case encodingLatin1:
+		ret := make([]byte, 0, len(content))
+		for _, r := range content {
+			if 0xFF < r {
+				return nil, errUnrepresentable
+			}
+
+			ret = append(ret, byte(r))
+		}
+
+		return ret, nil
+	}
+
+	return nil, errors.New("unsupported encoding [" + encoding + "]")
+}
+
+// getSaveEncoding gets the encoding for saving the file specified by path, that is the one of the existing file,
+// defaults to UTF-8.
+func getSaveEncoding(path string) string {
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return encodingUTF8
+	}
+
+	if ret := detectEncoding(data); "" != ret {
+		return ret
+	}
+
+	return encodingUTF8
+}
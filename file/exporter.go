@@ -15,18 +15,66 @@
 package file
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/session"
 	"github.com/b3log/wide/util"
 )
 
+const (
+	zipMaxSize    = 512 * 1024 * 1024 // max total uncompressed size of files to zip
+	zipMaxEntries = 10000             // max number of entries to zip
+)
+
+// zipEntry represents an entry to archive (zip or tar).
+type zipEntry struct {
+	name string // name in the archive
+	path string // local path
+	link string // target of a symbolic link kept as is, tar only
+}
+
 // GetZipHandler handles request of retrieving zip file.
+//
+// If parameter "format" is "tar.gz", the directory or file specified by parameter "path" is archived as a gzipped
+// tarball and streamed directly (without creating by CreateZipHandler), file modes and symbolic links inside the
+// workspace are kept, the same limits as zip apply.
 func GetZipHandler(w http.ResponseWriter, r *http.Request) {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
 	q := r.URL.Query()
-	path := q["path"][0]
+	path, err := session.SafePath(username, q.Get("path"))
+	if nil != err {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if "tar.gz" == q.Get("format") {
+		if util.Go.IsAPI(path) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
+
+		streamTarGz(w, username, path)
+
+		return
+	}
 
 	if ".zip" != filepath.Ext(path) {
 		http.Error(w, "Bad Request", 400)
@@ -50,10 +98,21 @@ func GetZipHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // CreateZipHandler handles request of creating zip.
+//
+// The path to zip must be inside the user's workspace, symbolic links pointing outside the workspace are skipped, and
+// the total size and entry count are limited.
 func CreateZipHandler(w http.ResponseWriter, r *http.Request) {
 	data := util.NewResult()
 	defer util.RetResult(w, r, data)
 
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
 	var args map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
 		logger.Error(err)
@@ -62,13 +121,19 @@ func CreateZipHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := args["path"].(string)
+	path, err := session.SafePath(username, args["path"].(string))
+	if util.Go.IsAPI(path) || nil != err {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
 	var name string
 
 	base := filepath.Base(path)
 
 	if nil != args["name"] {
-		name = args["name"].(string)
+		name = filepath.Base(args["name"].(string))
 	} else {
 		name = base
 	}
@@ -82,6 +147,15 @@ func CreateZipHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	entries, err := listZipEntries(username, base, path, false)
+	if nil != err {
+		logger.Warnf("User [%s] zips [%s] failed: %s", username, path, err)
+		data.Succ = false
+		data.Msg = err.Error()
+
+		return
+	}
+
 	zipPath := filepath.Join(dir, name)
 	zipFile, err := util.Zip.Create(zipPath + ".zip")
 	if nil != err {
@@ -90,13 +164,155 @@ func CreateZipHandler(w http.ResponseWriter, r *http.Request) {
 
 		return
 	}
-	defer zipFile.Close()
 
-	if util.File.IsDir(path) {
-		zipFile.AddDirectory(base, path)
-	} else {
-		zipFile.AddEntry(base, path)
+	for _, entry := range entries {
+		if err = zipFile.AddEntry(entry.name, entry.path); nil != err {
+			break
+		}
+	}
+
+	if closeErr := zipFile.Close(); nil == err {
+		err = closeErr
+	}
+
+	if nil != err {
+		logger.Error(err)
+		os.Remove(zipPath + ".zip") // do not leave a corrupt archive
+		data.Succ = false
+		data.Msg = err.Error()
+
+		return
 	}
 
 	data.Data = zipPath
 }
+
+// listZipEntries lists entries to archive of the specified path, symbolic links are kept as links if the specified
+// keepLinks is true, followed otherwise.
+//
+// Symbolic links pointing outside the user's workspace and followed symbolic links of directories are skipped. Returns
+// an error if the total size or entry count exceeds the limits.
+func listZipEntries(username, name, path string, keepLinks bool) ([]*zipEntry, error) {
+	ret := []*zipEntry{}
+	var size int64
+
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if nil != err {
+			return err
+		}
+
+		link := ""
+		if 0 != info.Mode()&os.ModeSymlink {
+			target, err := filepath.EvalSymlinks(p)
+			if nil != err || !session.CanAccess(username, target) {
+				logger.Debugf("Skips symbolic link [%s] while zipping for user [%s]", p, username)
+
+				return nil
+			}
+
+			if keepLinks {
+				if link, err = os.Readlink(p); nil != err {
+					return nil
+				}
+			} else if info, err = os.Stat(target); nil != err || info.IsDir() {
+				return nil
+			}
+		}
+
+		size += info.Size()
+		if size > zipMaxSize {
+			return fmt.Errorf("The total size of files exceeds the limit [%dM]", zipMaxSize/1024/1024)
+		}
+
+		if len(ret) >= zipMaxEntries {
+			return fmt.Errorf("The number of files exceeds the limit [%d]", zipMaxEntries)
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, path), conf.PathSeparator)
+		ret = append(ret, &zipEntry{name: filepath.Join(name, rel), path: p, link: link})
+
+		return nil
+	})
+
+	return ret, err
+}
+
+// streamTarGz writes the specified path of the user specified by username as a gzipped tarball to the response.
+func streamTarGz(w http.ResponseWriter, username, path string) {
+	if !util.File.IsExist(path) {
+		http.Error(w, "Not Found", 404)
+
+		return
+	}
+
+	base := filepath.Base(path)
+	entries, err := listZipEntries(username, base, path, true)
+	if nil != err {
+		logger.Warnf("User [%s] archives [%s] failed: %s", username, path, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename="+base+".tar.gz")
+	w.Header().Set("Content-Type", "application/gzip")
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		if err = addTarEntry(tw, entry); nil != err {
+			break
+		}
+	}
+
+	if closeErr := tw.Close(); nil == err {
+		err = closeErr
+	}
+	if closeErr := gz.Close(); nil == err {
+		err = closeErr
+	}
+
+	if nil != err { // the response has been started, a truncated archive is detected by the client
+		logger.Errorf("Streams tarball of [%s] for user [%s] failed: %s", path, username, err)
+	}
+}
+
+// addTarEntry writes the specified entry with its mode into the specified tar writer.
+func addTarEntry(tw *tar.Writer, entry *zipEntry) error {
+	info, err := os.Lstat(entry.path)
+	if nil != err {
+		return err
+	}
+	if "" == entry.link && 0 != info.Mode()&os.ModeSymlink { // a followed link
+		if info, err = os.Stat(entry.path); nil != err {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, entry.link)
+	if nil != err {
+		return err
+	}
+	header.Name = filepath.ToSlash(entry.name)
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	if err := tw.WriteHeader(header); nil != err {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(entry.path)
+	if nil != err {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.CopyN(tw, f, header.Size) // the file may grow while archiving
+
+	return err
+}
@@ -46,6 +46,11 @@ type Node struct {
 	Removable bool    `json:"removable"` // whether can remove this file node
 	IsGoAPI   bool    `json:"isGOAPI"`
 	Mode      string  `json:"mode"`
+	GitStatus string  `json:"gitStatus,omitempty"` // XY status code of `git status --porcelain`, empty if unchanged
+	Locked    string  `json:"locked,omitempty"`    // holder of the file lock, empty if not locked
+	Symlink   string  `json:"symlink,omitempty"`   // target of the symbolic link, empty if not a link
+	Dangling  bool    `json:"dangling,omitempty"`  // whether the link isn't followed: dangling, outside or a loop
+	Truncated bool    `json:"truncated,omitempty"` // whether some children are not listed for the tree limits
 	Children  []*Node `json:"children"`
 }
 
@@ -66,13 +71,16 @@ func initAPINode() {
 	apiNode = &Node{Name: "Go API", Path: apiPath, IconSkin: "ico-ztree-dir-api ", Type: "d",
 		Creatable: false, Removable: false, IsGoAPI: true, Children: []*Node{}}
 
-	walk(apiPath, apiNode, false, false, true)
+	walk(apiPath, apiNode, false, false, true, defaultTreeOrder)
 }
 
 // GetFilesHandler handles request of constructing user workspace file tree.
 //
 // The Go API source code package also as a child node,
 // so that users can easily view the Go API source code in file tree.
+//
+// Arguments "sort" and "mixed" specify the sort order of nodes (see getTreeOrder), the Go API node is always sorted by
+// the default order.
 func GetFilesHandler(w http.ResponseWriter, r *http.Request) {
 	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
 	if httpSession.IsNew {
@@ -87,6 +95,7 @@ func GetFilesHandler(w http.ResponseWriter, r *http.Request) {
 
 	userWorkspace := conf.GetUserWorkspace(username)
 	workspaces := filepath.SplitList(userWorkspace)
+	order := getTreeOrder(r, username)
 
 	root := Node{Name: "root", Path: "", IconSkin: "ico-ztree-dir ", Type: "d", IsParent: true, Children: []*Node{}}
 
@@ -109,12 +118,36 @@ func GetFilesHandler(w http.ResponseWriter, r *http.Request) {
 			IsGoAPI:   false,
 			Children:  []*Node{}}
 
-		walk(workspacePath, &workspaceNode, true, true, false)
+		walk(workspacePath, &workspaceNode, true, true, false, order)
+		annotateGitStatus(&workspaceNode)
 
 		// add workspace node
 		root.Children = append(root.Children, &workspaceNode)
 	}
 
+	// shared directories of other users
+	for _, share := range session.GetShares(username) {
+		if share.Owner == username {
+			continue
+		}
+
+		sharePath := filepath.FromSlash(share.Dir)
+		shareNode := Node{
+			Id:        share.Dir,
+			Name:      share.Owner + ": " + filepath.Base(sharePath),
+			Path:      share.Dir,
+			IconSkin:  "ico-ztree-dir-workspace ",
+			Type:      "d",
+			Creatable: true,
+			Removable: false,
+			IsGoAPI:   false,
+			Children:  []*Node{}}
+
+		walkUnder(sharePath, sharePath, &shareNode, true, true, false, order)
+
+		root.Children = append(root.Children, &shareNode)
+	}
+
 	// add Go API node
 	root.Children = append(root.Children, apiNode)
 
@@ -122,6 +155,9 @@ func GetFilesHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // RefreshDirectoryHandler handles request of refresh a directory of file tree.
+//
+// Arguments "sort" and "mixed" specify the sort order of nodes as GetFilesHandler does, so lazy-loaded subtrees are
+// consistent with the tree.
 func RefreshDirectoryHandler(w http.ResponseWriter, r *http.Request) {
 	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
 	if httpSession.IsNew {
@@ -140,9 +176,17 @@ func RefreshDirectoryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	order := defaultTreeOrder
+	if !util.Go.IsAPI(path) {
+		order = getTreeOrder(r, username)
+	}
+
 	node := Node{Name: "root", Path: path, IconSkin: "ico-ztree-dir ", Type: "d", Children: []*Node{}}
 
-	walk(path, &node, true, true, false)
+	walk(path, &node, true, true, false, order)
+	if !util.Go.IsAPI(path) {
+		annotateGitStatus(&node)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	data, err := json.Marshal(node.Children)
@@ -155,6 +199,13 @@ func RefreshDirectoryHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetFileHandler handles request of opening file by editor.
+//
+// Optional arguments "line" and "column" (1-based) specify the target position, which is clamped into the file and
+// returned as the data "line" and "column". A file larger than 5M can be opened only with a target line, lines around
+// it are returned read-only with the data "truncated" and "startLine" (the line number of the first returned line).
+//
+// The content is decoded to UTF-8 from the detected encoding returned as the data "encoding" (see detectEncoding), a
+// file failed the detection is binary.
 func GetFileHandler(w http.ResponseWriter, r *http.Request) {
 	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
 	if httpSession.IsNew {
@@ -178,25 +229,49 @@ func GetFileHandler(w http.ResponseWriter, r *http.Request) {
 
 	path := args["path"].(string)
 
-	if !util.Go.IsAPI(path) && !session.CanAccess(username, path) {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	readOnly := util.Go.IsAPI(path) || IsReadOnly(username, path)
+	if !readOnly {
+		safePath, err := session.SafePath(username, path)
+		if nil != err {
+			http.Error(w, "Forbidden", http.StatusForbidden)
 
-		return
+			return
+		}
+		path = safePath
 	}
 
-	size := util.File.GetFileSize(path)
-	if size > 5242880 { // 5M
-		result.Succ = false
-		result.Msg = "This file is too large to open :("
+	line, column := getTargetPos(args)
 
-		return
+	// a file too large is opened partially around the target line
+	var buf []byte
+	truncated := false
+	startLine := 1
+	if size := util.File.GetFileSize(path); size > fileMaxSize {
+		if 1 > line || util.File.IsImg(filepath.Ext(path)) {
+			result.Succ = false
+			result.Msg = "This file is too large to open :("
+
+			return
+		}
+
+		window, start, err := readLineWindow(path, line)
+		if nil != err {
+			logger.Error(err)
+			result.Succ = false
+
+			return
+		}
+
+		buf = []byte(window)
+		truncated = true
+		startLine = start
+	} else {
+		buf, _ = ioutil.ReadFile(path)
 	}
 
 	data := map[string]interface{}{}
 	result.Data = &data
 
-	buf, _ := ioutil.ReadFile(path)
-
 	extension := filepath.Ext(path)
 
 	if util.File.IsImg(extension) {
@@ -206,7 +281,7 @@ func GetFileHandler(w http.ResponseWriter, r *http.Request) {
 
 		username := conf.GetOwner(path)
 		if "" == username {
-			logger.Warnf("The path [%s] has no owner")
+			logger.Warnf("The path [%s] has no owner", path)
 			data["path"] = ""
 
 			return
@@ -219,18 +294,65 @@ func GetFileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	content := string(buf)
+	encoding := detectEncoding(buf)
+	content, err := decodeContent(buf, encoding)
 
-	if util.File.IsBinary(content) {
+	if nil != err || util.File.IsBinary(content) {
 		result.Succ = false
 		result.Msg = "Can't open a binary file :("
 	} else {
-		data["content"] = content
+		// the editor always sees LF, the file is untouched until saved
+		data["encoding"] = encoding
+		data["lineEnding"] = getLineEnding(content)
+		data["mixedLineEndings"] = lineEndingMixed == data["lineEnding"]
+		data["content"] = normalizeLineEndings(content, "\n")
 		data["path"] = path
+		if mode := conf.Wide.GetEditorMode(path); "" != mode {
+			data["mode"] = mode
+		}
+		user := conf.GetUser(username)
+		data["readOnly"] = readOnly || (nil != user && user.IsViewer())
+		if lock := session.GetFileLock(path); nil != lock {
+			data["lock"] = lock
+			data["readOnly"] = true
+		}
+
+		if 0 < line {
+			line, column = clampPos(data["content"].(string), line-startLine+1, column)
+			data["line"] = line + startLine - 1
+			data["column"] = column
+		}
+
+		if truncated { // a part of the file can't be saved
+			data["truncated"] = true
+			data["startLine"] = startLine
+			data["readOnly"] = true
+
+			return
+		}
+
+		if ec := getEditorConfig(username, path); nil != ec && ".go" != extension {
+			data["editorConfig"] = ec
+		}
+
+		addRecentFile(username, path)
+
+		if d := getNewerDraft(username, path); nil != d {
+			data["draft"] = map[string]interface{}{"content": d.Content, "saved": d.Saved}
+		}
 	}
 }
 
 // SaveFileHandler handles request of saving file.
+//
+// The previous content is kept as a version (see VersionsHandler).
+//
+// Line endings are normalized to the user preference, or the ones used by the file if not set (see
+// getSaveLineBreak). The newline and whitespace properties of .editorconfig are applied to non-Go files. The data
+// contains the content saved ("code") if it's changed.
+//
+// The content is saved in the encoding of the existing file (see getSaveEncoding), or converted to the one specified
+// by argument "encoding".
 func SaveFileHandler(w http.ResponseWriter, r *http.Request) {
 	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
 	if httpSession.IsNew {
@@ -255,38 +377,105 @@ func SaveFileHandler(w http.ResponseWriter, r *http.Request) {
 	filePath := args["file"].(string)
 	sid := args["sid"].(string)
 
-	if util.Go.IsAPI(filePath) || !session.CanAccess(username, filePath) {
+	filePath, err := session.SafePath(username, filePath)
+	if util.Go.IsAPI(filePath) || nil != err {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 
 		return
 	}
 
-	fout, err := os.Create(filePath)
+	if err := checkFileLock(filePath); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
 
+		return
+	}
+
+	encoding := getSaveEncoding(filePath)
+	if enc, _ := args["encoding"].(string); "" != enc { // converts on saving
+		encoding = enc
+	}
+
+	code, formatted, err := saveFile(username, filePath, args["code"].(string), encoding)
 	if nil != err {
-		logger.Error(err)
 		result.Succ = false
 
+		if _, ok := err.(*os.PathError); !ok {
+			result.Msg = err.Error()
+		} else if wSession := session.WideSessions.Get(sid); nil != wSession {
+			wSession.EventQueue.Queue <- &event.Event{Code: event.EvtCodeServerInternalError, Sid: sid,
+				Data: "can't save file " + filePath}
+		}
+
 		return
 	}
 
-	code := args["code"].(string)
+	if formatted {
+		result.Data = map[string]interface{}{"code": code}
+	}
 
-	fout.WriteString(code)
+	event.Publish(&event.Event{Code: event.EvtCodeFileSaved, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: filePath, Succ: true}})
+}
 
-	if err := fout.Close(); nil != err {
+// saveFile saves the specified code to the file specified by path for the user specified by username, the way the
+// editor saves: the line ending and .editorconfig are applied, the code is encoded in the specified encoding and
+// counted toward the quota, the current content is kept as a version and the mode of the file is kept.
+//
+// Returns the saved code and whether it's changed by .editorconfig.
+func saveFile(username, path, code, encoding string) (string, bool, error) {
+	if eol := getSaveLineBreak(username, path); "" != eol {
+		code = normalizeLineEndings(code, eol)
+	}
+
+	formatted := false
+	if ec := getEditorConfig(username, path); nil != ec && ".go" != filepath.Ext(path) { // gofmt rules Go files
+		if applied := ec.apply(code); applied != code {
+			code, formatted = applied, true
+		}
+	}
+
+	encoded, err := encodeContent(code, encoding)
+	if nil != err {
+		return "", false, err
+	}
+
+	delta := int64(len(encoded))
+	if util.File.IsExist(path) {
+		delta -= util.File.GetFileSize(path)
+	}
+	if err := checkQuota(username, path, delta); nil != err {
+		return "", false, err
+	}
+
+	saveVersion(username, path, string(encoded))
+
+	// truncates an existing file, so its mode is kept
+	fout, err := os.Create(path)
+	if nil != err {
 		logger.Error(err)
-		result.Succ = false
 
-		wSession := session.WideSessions.Get(sid)
-		wSession.EventQueue.Queue <- &event.Event{Code: event.EvtCodeServerInternalError, Sid: sid,
-			Data: "can't save file " + filePath}
+		return "", false, err
+	}
 
-		return
+	fout.Write(encoded)
+
+	if err := fout.Close(); nil != err {
+		logger.Error(err)
+
+		return "", false, err
 	}
+
+	addUsage(username, path, delta)
+	removeDraft(username, path)
+
+	return code, formatted, nil
 }
 
 // NewFileHandler handles request of creating file or directory.
+//
+// A new Go file is filled with the template specified by argument "template" (see conf/templates), or a package
+// clause if not specified.
 func NewFileHandler(w http.ResponseWriter, r *http.Request) {
 	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
 	if httpSession.IsNew {
@@ -308,9 +497,8 @@ func NewFileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := args["path"].(string)
-
-	if util.Go.IsAPI(path) || !session.CanAccess(username, path) {
+	path, err := session.SafePath(username, args["path"].(string))
+	if util.Go.IsAPI(path) || nil != err {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 
 		return
@@ -321,6 +509,13 @@ func NewFileHandler(w http.ResponseWriter, r *http.Request) {
 
 	wSession := session.WideSessions.Get(sid)
 
+	if err := checkQuota(username, path, 0); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
 	if !createFile(path, fileType) {
 		result.Succ = false
 
@@ -332,13 +527,105 @@ func NewFileHandler(w http.ResponseWriter, r *http.Request) {
 
 	if "f" == fileType {
 		logger.Debugf("Created a file [%s] by user [%s]", path, wSession.Username)
+
+		if ".go" == filepath.Ext(path) {
+			templateName, _ := args["template"].(string)
+			if err := applyFileTemplate(username, path, templateName); nil != err {
+				logger.Warnf("Applies template [%s] to [%s] failed: %s", templateName, path, err)
+			}
+		}
+
+		addUsage(username, path, util.File.GetFileSize(path))
 	} else {
 		logger.Debugf("Created a dir [%s] by user [%s]", path, wSession.Username)
 	}
 
+	event.Publish(&event.Event{Code: event.EvtCodeFileCreated, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: path, Succ: true}})
+}
+
+// NewDirHandler handles request of creating a directory.
+//
+// Arguments: path is the parent directory, name is the new directory name. Returns the new file tree node.
+func NewDirHandler(w http.ResponseWriter, r *http.Request) {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	var args map[string]interface{}
+
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	parent, err := session.SafePath(username, args["path"].(string))
+	if util.Go.IsAPI(parent) || nil != err {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	name := args["name"].(string)
+	if !isValidFileName(name) {
+		result.Succ = false
+		result.Msg = "Invalid directory name [" + name + "]"
+
+		return
+	}
+
+	if !util.File.IsDir(parent) {
+		result.Succ = false
+		result.Msg = "[" + parent + "] is not a directory"
+
+		return
+	}
+
+	path := filepath.Join(parent, name)
+	if util.File.IsExist(path) {
+		result.Succ = false
+		result.Msg = "[" + path + "] already exists"
+
+		return
+	}
+
+	if !createFile(path, "d") {
+		result.Succ = false
+		result.Msg = "Can't create directory [" + path + "]"
+
+		return
+	}
+
+	logger.Debugf("Created a dir [%s] by user [%s]", path, username)
+
+	result.Data = &Node{
+		Id:        filepath.ToSlash(path),
+		Name:      name,
+		Path:      filepath.ToSlash(path),
+		IconSkin:  "ico-ztree-dir ",
+		IsParent:  true,
+		Type:      "d",
+		Creatable: true,
+		Removable: true,
+		Children:  []*Node{}}
+
+	sid, _ := args["sid"].(string)
+	event.Publish(&event.Event{Code: event.EvtCodeFileCreated, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: path, Succ: true}})
 }
 
 // RemoveFileHandler handles request of removing file or directory.
+//
+// The file or directory is moved into trash, unless argument "permanent" is true.
 func RemoveFileHandler(w http.ResponseWriter, r *http.Request) {
 	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
 	if httpSession.IsNew {
@@ -360,19 +647,32 @@ func RemoveFileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := args["path"].(string)
-
-	if util.Go.IsAPI(path) || !session.CanAccess(username, path) {
+	path, err := session.SafePath(username, args["path"].(string))
+	if util.Go.IsAPI(path) || nil != err {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 
 		return
 	}
 
+	if err := checkFileLock(path); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
 	sid := args["sid"].(string)
 
 	wSession := session.WideSessions.Get(sid)
 
-	if !removeFile(path) {
+	removed := false
+	if permanent, _ := args["permanent"].(bool); permanent {
+		removed = removeFile(path)
+	} else {
+		removed = moveToTrash(username, path)
+	}
+
+	if !removed {
 		result.Succ = false
 
 		wSession.EventQueue.Queue <- &event.Event{Code: event.EvtCodeServerInternalError, Sid: sid,
@@ -382,6 +682,9 @@ func RemoveFileHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Debugf("Removed a file [%s] by user [%s]", path, wSession.Username)
+
+	event.Publish(&event.Event{Code: event.EvtCodeFileRemoved, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: path, Succ: true}})
 }
 
 // RenameFileHandler handles request of renaming file or directory.
@@ -406,21 +709,27 @@ func RenameFileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	oldPath := args["oldPath"].(string)
-	if util.Go.IsAPI(oldPath) ||
-		!session.CanAccess(username, oldPath) {
+	oldPath, err := session.SafePath(username, args["oldPath"].(string))
+	if util.Go.IsAPI(oldPath) || nil != err {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 
 		return
 	}
 
-	newPath := args["newPath"].(string)
-	if util.Go.IsAPI(newPath) || !session.CanAccess(username, newPath) {
+	newPath, err := session.SafePath(username, args["newPath"].(string))
+	if util.Go.IsAPI(newPath) || nil != err {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 
 		return
 	}
 
+	if err := checkFileLock(oldPath); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
 	sid := args["sid"].(string)
 
 	wSession := session.WideSessions.Get(sid)
@@ -435,6 +744,9 @@ func RenameFileHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Debugf("Renamed a file [%s] to [%s] by user [%s]", oldPath, newPath, wSession.Username)
+
+	event.Publish(&event.Event{Code: event.EvtCodeFileRenamed, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: oldPath, NewPath: newPath, Succ: true}})
 }
 
 // Use to find results sorting.
@@ -450,6 +762,9 @@ func (f foundPaths) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
 func (f foundPaths) Less(i, j int) bool { return f[i].score > f[j].score }
 
 // FindHandler handles request of find files under the specified directory with the specified filename pattern.
+//
+// If argument "fuzzy" is true, the name is matched as a subsequence (see fuzzyScore), case-insensitively unless
+// argument "caseSensitive" is true, and the results are ranked by score and recency.
 func FindHandler(w http.ResponseWriter, r *http.Request) {
 	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
 	if httpSession.IsNew {
@@ -482,6 +797,24 @@ func FindHandler(w http.ResponseWriter, r *http.Request) {
 	userWorkspace := conf.GetUserWorkspace(username)
 	workspaces := filepath.SplitList(userWorkspace)
 
+	if fuzzy, _ := args["fuzzy"].(bool); fuzzy {
+		caseSensitive, _ := args["caseSensitive"].(bool)
+
+		limit := fuzzyMaxResults
+		if l, ok := args["limit"].(float64); ok && 0 < l && int(l) < limit {
+			limit = int(l)
+		}
+
+		dirs := []string{}
+		for _, workspace := range workspaces {
+			dirs = append(dirs, filepath.Join(workspace, "src"))
+		}
+
+		result.Data = fuzzyFind(dirs, name, caseSensitive, limit)
+
+		return
+	}
+
 	if "" != path && !util.File.IsDir(path) {
 		path = filepath.Dir(path)
 	}
@@ -504,6 +837,13 @@ func FindHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // SearchTextHandler handles request of searching files under the specified directory with the specified keyword.
+//
+// Arguments "offset" and "limit" specify the page of results, the limit is capped to conf.Wide.SearchMaxResults.
+// Arguments "include" and "exclude" are file name globs (for example *.go and *_test.go) narrowing the search, and
+// "extension" is still supported as a file name suffix filter.
+//
+// A directory in a workspace is searched by the search index of the workspace (see searchIndex) if it's warm,
+// otherwise it's walked directly.
 func SearchTextHandler(w http.ResponseWriter, r *http.Request) {
 	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
 	if httpSession.IsNew {
@@ -541,24 +881,99 @@ func SearchTextHandler(w http.ResponseWriter, r *http.Request) {
 		dir = workspaces[0]
 	}
 
-	extension := args["extension"].(string)
-	text := args["text"].(string)
+	dir = filepath.Clean(filepath.FromSlash(dir))
+	if !util.Go.IsAPI(dir) && !session.CanAccess(wSession.Username, dir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
 
-	founds := []*Snippet{}
+		return
+	}
+
+	ts := newTextSearch(r.Context(), args)
+	indexed := false
 	if util.File.IsDir(dir) {
-		founds = search(dir, extension, text, []*Snippet{})
+		if indexed = ts.searchIndexed(dir); !indexed {
+			ts.search(dir)
+		}
 	} else {
-		founds = searchInFile(dir, text)
+		ts.collect(searchInFile(dir, ts.text))
 	}
 
-	result.Data = founds
+	result.Data = map[string]interface{}{
+		"snippets":  ts.snippets,
+		"offset":    ts.offset,
+		"total":     ts.total,
+		"truncated": ts.truncated(),
+		"estimated": ts.stopped, // total is a lower bound if counting stopped
+		"indexed":   indexed,    // whether searched by the search index instead of walking
+	}
 }
 
 // walk traverses the specified path to build a file tree.
-func walk(path string, node *Node, creatable, removable, isGOAPI bool) {
-	files := listFiles(path)
+//
+// Symbolic links are followed only if they resolve inside the workspace containing the path (the Go API directory if
+// isGOAPI), see walkUnder. Nodes are sorted by the specified order.
+func walk(path string, node *Node, creatable, removable, isGOAPI bool, order treeOrder) {
+	base := path
+	if isGOAPI {
+		base = util.Go.GetAPIPath()
+	} else if owner := conf.GetOwner(path); "" != owner {
+		for _, workspace := range filepath.SplitList(conf.GetUserWorkspace(owner)) {
+			if _, err := util.File.SafeJoin(workspace, path); nil == err {
+				base = workspace
+
+				break
+			}
+		}
+	}
+
+	walkUnder(base, path, node, creatable, removable, isGOAPI, order)
+}
+
+// walkUnder traverses the specified path to build a file tree, symbolic links resolving inside the specified base
+// directory are followed, the others (pointing outside, dangling or making a loop) are marked as dangling leaves.
+func walkUnder(base, path string, node *Node, creatable, removable, isGOAPI bool, order treeOrder) {
+	real, err := filepath.EvalSymlinks(path)
+	if nil != err {
+		real = path
+	}
+
+	t := &treeWalker{base: base, visiting: map[string]bool{real: true}, order: order}
+	t.walk(path, real, node, creatable, removable, isGOAPI)
+}
+
+// treeWalker builds a file tree following symbolic links.
+//
+// The tree is limited to conf.Wide.TreeMaxDepth levels and conf.Wide.TreeMaxNodes nodes, a directory with children
+// not listed is marked as truncated, its subtree could be listed by RefreshDirectoryHandler.
+type treeWalker struct {
+	base     string          // directory the links could point into
+	visiting map[string]bool // real paths of the directories being traversed, for symbolic link loops
+	depth    int             // depth of the directory being traversed, 0 is the root
+	nodes    int             // count of nodes built
+	order    treeOrder       // sort order of nodes
+}
+
+// walk traverses the specified path (resolved to the specified real path) to build a file tree.
+func (t *treeWalker) walk(path, realPath string, node *Node, creatable, removable, isGOAPI bool) {
+	if 0 < conf.Wide.TreeMaxDepth && conf.Wide.TreeMaxDepth <= t.depth {
+		node.Truncated = true
+
+		return
+	}
+
+	t.depth++
+	defer func() { t.depth-- }()
+
+	files := listFiles(path, t.order)
 
 	for _, filename := range files {
+		if 0 < conf.Wide.TreeMaxNodes && conf.Wide.TreeMaxNodes <= t.nodes {
+			node.Truncated = true
+
+			return
+		}
+		t.nodes++
+
 		fpath := filepath.Join(path, filename)
 
 		fio, _ := os.Lstat(fpath)
@@ -578,16 +993,36 @@ func walk(path string, node *Node, creatable, removable, isGOAPI bool) {
 			continue
 		}
 
+		real := filepath.Join(realPath, filename)
+		if 0 != fio.Mode()&os.ModeSymlink {
+			child.Symlink, _ = os.Readlink(fpath)
+			child.Symlink = filepath.ToSlash(child.Symlink)
+
+			fio, real = t.resolve(fpath)
+			if nil == fio {
+				child.Dangling = true
+				child.Type = "f"
+				child.IconSkin = getIconSkin(filepath.Ext(fpath))
+
+				continue
+			}
+		}
+
 		if fio.IsDir() {
 			child.Type = "d"
 			child.Creatable = creatable
 			child.IconSkin = "ico-ztree-dir "
 			child.IsParent = true
 
-			walk(fpath, &child, creatable, removable, isGOAPI)
+			t.visiting[real] = true
+			t.walk(fpath, real, &child, creatable, removable, isGOAPI)
+			delete(t.visiting, real)
 		} else {
 			child.Type = "f"
 			child.Creatable = creatable
+			if lock := session.GetFileLock(fpath); nil != lock {
+				child.Locked = lock.Username
+			}
 			ext := filepath.Ext(fpath)
 
 			child.IconSkin = getIconSkin(ext)
@@ -597,19 +1032,105 @@ func walk(path string, node *Node, creatable, removable, isGOAPI bool) {
 	return
 }
 
-// listFiles lists names of files under the specified dirname.
-func listFiles(dirname string) []string {
+// resolve resolves the symbolic link specified by path, returns the file info and the real path of the target, or
+// nil if the link shouldn't be followed: dangling, pointing outside the base directory or to a directory being
+// traversed (a loop).
+func (t *treeWalker) resolve(path string) (os.FileInfo, string) {
+	if _, err := util.File.SafeJoin(t.base, path); nil != err {
+		return nil, ""
+	}
+
+	real, err := filepath.EvalSymlinks(path)
+	if nil != err || t.visiting[real] {
+		return nil, ""
+	}
+
+	fio, err := os.Stat(real)
+	if nil != err {
+		return nil, ""
+	}
+
+	return fio, real
+}
+
+// treeOrder represents a sort order of file tree nodes.
+type treeOrder struct {
+	sort  string // conf.TreeSortName/TreeSortNameFold/TreeSortModified
+	mixed bool   // whether directories are mixed with files instead of in front of them
+}
+
+// The default sort order of file tree nodes: directories in front of files, alphabetical and case-sensitive.
+var defaultTreeOrder = treeOrder{sort: conf.TreeSortName}
+
+// getTreeOrder gets the sort order of file tree nodes specified by arguments "sort" (see conf.TreeSortName) and
+// "mixed" ("true"/"false") of the specified request, the preference of the user specified by username is used for
+// the absent or invalid ones.
+func getTreeOrder(r *http.Request, username string) treeOrder {
+	ret := getUserTreeOrder(username)
+
+	if value := r.FormValue("sort"); "" != value && conf.IsTreeSort(value) {
+		ret.sort = value
+	}
+
+	switch r.FormValue("mixed") {
+	case "true":
+		ret.mixed = true
+	case "false":
+		ret.mixed = false
+	}
+
+	return ret
+}
+
+// getUserTreeOrder gets the sort order of file tree nodes preferred by the user specified by username.
+func getUserTreeOrder(username string) treeOrder {
+	ret := defaultTreeOrder
+	if user := conf.GetUser(username); nil != user {
+		if "" != user.TreeSort {
+			ret.sort = user.TreeSort
+		}
+		ret.mixed = user.TreeMixed
+	}
+
+	return ret
+}
+
+// treeEntry represents a file listed for file tree sorting.
+type treeEntry struct {
+	name    string
+	dir     bool
+	modTime int64 // modification time in unix nano
+}
+
+// less checks whether the entry i should sort before the entry j by the tree order. Names are unique in a directory
+// and break the ties, so the order is stable.
+func (o treeOrder) less(i, j *treeEntry) bool {
+	if !o.mixed && i.dir != j.dir {
+		return i.dir
+	}
+
+	switch o.sort {
+	case conf.TreeSortNameFold:
+		if fi, fj := strings.ToLower(i.name), strings.ToLower(j.name); fi != fj {
+			return fi < fj
+		}
+	case conf.TreeSortModified:
+		if i.modTime != j.modTime {
+			return i.modTime > j.modTime
+		}
+	}
+
+	return i.name < j.name
+}
+
+// listFiles lists names of files under the specified dirname sorted by the specified order.
+func listFiles(dirname string, order treeOrder) []string {
 	f, _ := os.Open(dirname)
 
 	names, _ := f.Readdirnames(-1)
 	f.Close()
 
-	sort.Strings(names)
-
-	dirs := []string{}
-	files := []string{}
-
-	// sort: directories in front of files
+	entries := []*treeEntry{}
 	for _, name := range names {
 		path := filepath.Join(dirname, name)
 		fio, err := os.Lstat(path)
@@ -620,24 +1141,35 @@ func listFiles(dirname string) []string {
 			continue
 		}
 
+		if 0 != fio.Mode()&os.ModeSymlink { // sorts a link as its target
+			if target, err := os.Stat(path); nil == err {
+				fio = target
+			}
+		}
+
 		if fio.IsDir() {
 			// exclude the .git, .svn, .hg direcitory
-			if ".git" == fio.Name() || ".svn" == fio.Name() || ".hg" == fio.Name() {
+			if util.Str.Contains(fio.Name(), ignoredDirs) {
 				continue
 			}
-
-			dirs = append(dirs, name)
 		} else {
 			// exclude the .DS_Store directory on Mac OS X
 			if ".DS_Store" == fio.Name() {
 				continue
 			}
-
-			files = append(files, name)
 		}
+
+		entries = append(entries, &treeEntry{name: name, dir: fio.IsDir(), modTime: fio.ModTime().UnixNano()})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return order.less(entries[i], entries[j]) })
+
+	ret := []string{}
+	for _, entry := range entries {
+		ret = append(ret, entry.name)
 	}
 
-	return append(dirs, files...)
+	return ret
 }
 
 // getIconSkin gets CSS class name of icon with the specified filename extension.
@@ -712,6 +1244,30 @@ func createFile(path, fileType string) bool {
 	}
 }
 
+// Reserved file names, can't be used on Windows.
+var reservedFileNames = []string{"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9"}
+
+// isValidFileName checks whether the specified name is a valid file (directory) name.
+//
+// A valid name is not empty, contains no path separators and is not reserved.
+func isValidFileName(name string) bool {
+	if "" == strings.TrimSpace(name) || "." == name || ".." == name || trashDirName == name ||
+		draftDirName == name || versionDirName == name || strings.ContainsAny(name, "/\\\x00") {
+		return false
+	}
+
+	base := strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+	for _, reserved := range reservedFileNames {
+		if reserved == base {
+			return false
+		}
+	}
+
+	return true
+}
+
 // removeFile removes file on the specified path.
 func removeFile(path string) bool {
 	if err := os.RemoveAll(path); nil != err {
@@ -739,6 +1295,9 @@ func renameFile(oldPath, newPath string) bool {
 }
 
 // Default exclude file name patterns when find.
+// Directories not listed in file tree.
+var ignoredDirs = []string{".git", ".svn", ".hg"}
+
 var defaultExcludesFind = []string{".git", ".svn", ".repository", "CVS", "RCS", "SCCS", ".bzr", ".metadata", ".hg"}
 
 // find finds files under the specified dir and its sub-directoryies with the specified name,
@@ -790,56 +1349,27 @@ func find(dir, name string, results []*string) []*string {
 	return results
 }
 
-// search finds file under the specified dir and its sub-directories with the specified text, likes the command 'grep'
-// or 'findstr'.
-func search(dir, extension, text string, snippets []*Snippet) []*Snippet {
-	if !strings.HasSuffix(dir, conf.PathSeparator) {
-		dir += conf.PathSeparator
-	}
-
-	f, _ := os.Open(dir)
-	fileInfos, err := f.Readdir(-1)
-	f.Close()
-
-	if nil != err {
-		logger.Errorf("Read dir [%s] failed: [%s]", dir, err.Error())
-
-		return snippets
-	}
-
-	for _, fileInfo := range fileInfos {
-		path := dir + fileInfo.Name()
-
-		if fileInfo.IsDir() {
-			// enter the directory recursively
-			snippets = search(path, extension, text, snippets)
-		} else if strings.HasSuffix(path, extension) {
-			// grep in file
-			ss := searchInFile(path, text)
-
-			snippets = append(snippets, ss...)
-		}
-	}
-
-	return snippets
-}
-
 // searchInFile finds file with the specified path and text.
 func searchInFile(path string, text string) []*Snippet {
-	ret := []*Snippet{}
-
 	bytes, err := ioutil.ReadFile(path)
 	if nil != err {
 		logger.Errorf("Read file [%s] failed: [%s]", path, err.Error())
 
-		return ret
+		return []*Snippet{}
 	}
 
 	content := string(bytes)
 	if util.File.IsBinary(content) {
-		return ret
+		return []*Snippet{}
 	}
 
+	return searchInContent(path, content, text)
+}
+
+// searchInContent finds the text in the specified content of the file specified by path.
+func searchInContent(path, content, text string) []*Snippet {
+	ret := []*Snippet{}
+
 	lines := strings.Split(content, "\n")
 
 	for idx, line := range lines {
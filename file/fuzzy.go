@@ -0,0 +1,166 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/b3log/wide/util"
+)
+
+const (
+	fuzzyMaxResults    = 50 // max results of fuzzy finding
+	fuzzyBonusMatch    = 1  // score of a matched character
+	fuzzyBonusAdjacent = 5  // bonus of a character matched right after the previous matched one
+	fuzzyBonusBoundary = 8  // bonus of a character matched at a word boundary (file_new, fileNew, file.go)
+	fuzzyBonusPrefix   = 10 // bonus of matching the first character of the name
+)
+
+// fuzzyFound represents a file found by fuzzy finding.
+type fuzzyFound struct {
+	Path    string    `json:"path"`
+	Score   int       `json:"score"`
+	modTime time.Time // used to rank results of the same score, the recently modified first
+}
+
+// fuzzyFind finds files under the specified directories whose names (or relative paths if the pattern contains a
+// path separator) match the specified pattern as a subsequence, the results are ranked by score and recency.
+//
+// Directories ignored by the file tree and the default excluded directories (VCS metadata) are skipped.
+func fuzzyFind(dirs []string, pattern string, caseSensitive bool, limit int) []*fuzzyFound {
+	ret := []*fuzzyFound{}
+	if "" == pattern {
+		return ret
+	}
+
+	matchPath := strings.ContainsAny(pattern, "/\\")
+	pattern = filepath.ToSlash(pattern)
+
+	for _, dir := range dirs {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if nil != err {
+				return nil
+			}
+
+			name := info.Name()
+			if info.IsDir() {
+				if path != dir && (util.Str.Contains(name, ignoredDirs) || util.Str.Contains(name, defaultExcludesFind)) {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			target := name
+			if matchPath {
+				rel, _ := filepath.Rel(dir, path)
+				target = filepath.ToSlash(rel)
+			}
+
+			score := fuzzyScore(pattern, target, caseSensitive)
+			if 0 > score {
+				return nil
+			}
+
+			ret = append(ret, &fuzzyFound{Path: filepath.ToSlash(path), Score: score, modTime: info.ModTime()})
+
+			return nil
+		})
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Score != ret[j].Score {
+			return ret[i].Score > ret[j].Score
+		}
+
+		return ret[i].modTime.After(ret[j].modTime)
+	})
+
+	if limit < len(ret) {
+		ret = ret[:limit]
+	}
+
+	return ret
+}
+
+// fuzzyScore scores how the specified pattern matches the specified name as a subsequence, for example "fnf" matches
+// "file_new_func.go", returns -1 if not matched.
+func fuzzyScore(pattern, name string, caseSensitive bool) int {
+	if !caseSensitive {
+		pattern = strings.ToLower(pattern)
+	}
+
+	ret := 0
+	patternRunes := []rune(pattern)
+	p := 0
+	prevMatched := false
+	var prev rune
+	for i, c := range name {
+		if p >= len(patternRunes) {
+			break
+		}
+
+		matched := c == patternRunes[p]
+		if !matched && !caseSensitive {
+			matched = unicode.ToLower(c) == patternRunes[p]
+		}
+
+		if matched {
+			ret += fuzzyBonusMatch
+			if 0 == i {
+				ret += fuzzyBonusPrefix
+			} else if isWordBoundary(prev, c) {
+				ret += fuzzyBonusBoundary
+			}
+			if prevMatched {
+				ret += fuzzyBonusAdjacent
+			}
+
+			p++
+		}
+
+		prevMatched = matched
+		prev = c
+	}
+
+	if p < len(patternRunes) {
+		return -1
+	}
+
+	// prefers shorter names with the same matches
+	if penalty := utf8.RuneCountInString(name) / 8; penalty < ret {
+		ret -= penalty
+	} else {
+		ret = 0
+	}
+
+	return ret
+}
+
+// isWordBoundary checks whether the character c following prev starts a word.
+func isWordBoundary(prev, c rune) bool {
+	switch prev {
+	case '_', '-', '.', '/', ' ':
+		return true
+	}
+
+	return unicode.IsLower(prev) && unicode.IsUpper(c)
+}
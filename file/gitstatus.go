@@ -0,0 +1,82 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"path/filepath"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/scm/git"
+	"github.com/b3log/wide/util"
+)
+
+// annotateGitStatus annotates the specified node and its descendants with git status.
+//
+// Runs one `git status` for the repository containing the node, and one for each nested repository found in the
+// tree. Does nothing if conf.Wide.GitDecorations is disabled.
+func annotateGitStatus(node *Node) {
+	if !conf.Wide.GitDecorations {
+		return
+	}
+
+	codes := map[string]string{}
+	addGitStatus(filepath.FromSlash(node.Path), codes)
+
+	annotate(node, codes)
+}
+
+// annotate annotates the specified node and its descendants with the specified git status codes, a nested repository
+// found is added into the codes.
+//
+// <path, XY status code>
+func annotate(node *Node, codes map[string]string) {
+	for _, child := range node.Children {
+		if "d" == child.Type && util.File.IsExist(filepath.Join(filepath.FromSlash(child.Path), ".git")) {
+			addGitStatus(filepath.FromSlash(child.Path), codes)
+		}
+
+		child.GitStatus = codes[child.Path]
+
+		annotate(child, codes)
+	}
+}
+
+// addGitStatus adds git status codes of the repository containing the specified path into the specified codes.
+func addGitStatus(path string, codes map[string]string) {
+	status, err := git.GetStatus(path)
+	if nil != err { // not a repository
+		return
+	}
+
+	set := func(path string, index int, code string) {
+		xy := []byte(codes[path])
+		if 2 != len(xy) {
+			xy = []byte("  ")
+		}
+		xy[index] = code[0]
+
+		codes[path] = string(xy)
+	}
+
+	for _, s := range status.Staged {
+		set(s.Path, 0, s.Code)
+	}
+	for _, s := range status.Unstaged {
+		set(s.Path, 1, s.Code)
+	}
+	for _, s := range status.Untracked {
+		codes[s.Path] = "??"
+	}
+}
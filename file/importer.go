@@ -15,38 +15,69 @@
 package file
 
 import (
+	"archive/zip"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/b3log/wide/session"
 	"github.com/b3log/wide/util"
 )
 
+// Conflict policies of importing zip.
+const (
+	conflictSkip      = "skip"      // keeps the existing file
+	conflictOverwrite = "overwrite" // overwrites the existing file
+	conflictRename    = "rename"    // extracts to a new name, for example main_1.go
+)
+
 type fileInfo struct {
 	Name  string `json:"name"`
 	Type  string `json:"type"`
 	Error string `json:"error,omitempty"`
 }
 
-func handleUpload(p *multipart.Part, dir string) (fi *fileInfo) {
+// handleUpload saves the specified part into the specified directory, the file is removed if it is larger than the
+// specified remaining quota.
+func handleUpload(p *multipart.Part, dir string, remaining int64) (fi *fileInfo) {
 	fi = &fileInfo{
 		Name: p.FileName(),
 		Type: p.Header.Get("Content-Type"),
 	}
 
-	path := filepath.Clean(dir + "/" + fi.Name)
-	f, _ := os.Create(path)
+	path, err := util.File.SafeJoin(dir, fi.Name)
+	if nil != err || path == dir {
+		fi.Error = "invalid file name"
+
+		return
+	}
 
-	io.Copy(f, p)
+	f, err := os.Create(path)
+	if nil != err {
+		logger.Error(err)
+		fi.Error = err.Error()
 
+		return
+	}
+
+	_, err = copyWithinQuota(f, p, remaining)
 	f.Close()
+	if errQuotaExceeded == err {
+		os.Remove(path)
+		fi.Error = err.Error()
+	}
 
 	return
 }
 
-func handleUploads(r *http.Request, dir string) (fileInfos []*fileInfo) {
+// handleUploads saves the uploaded files of the specified request into the specified directory within the remaining
+// quota of the user specified by username.
+func handleUploads(r *http.Request, dir, username string) (fileInfos []*fileInfo) {
 	fileInfos = make([]*fileInfo, 0)
 	mr, err := r.MultipartReader()
 
@@ -55,7 +86,12 @@ func handleUploads(r *http.Request, dir string) (fileInfos []*fileInfo) {
 	for err == nil {
 		if name := part.FormName(); name != "" {
 			if part.FileName() != "" {
-				fileInfos = append(fileInfos, handleUpload(part, dir))
+				fi := handleUpload(part, dir, getRemainingQuota(username, dir, 0))
+				if "" == fi.Error {
+					addUsage(username, dir, util.File.GetFileSize(filepath.Join(dir, fi.Name)))
+				}
+
+				fileInfos = append(fileInfos, fi)
 			}
 		}
 
@@ -70,8 +106,260 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 	result := util.NewResult()
 	defer util.RetResult(w, r, result)
 
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	dir, err := session.SafePath(username, r.URL.Query().Get("path"))
+	if util.Go.IsAPI(dir) || nil != err || !util.File.IsDir(dir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if err := checkQuota(username, dir, r.ContentLength); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	result.Data = handleUploads(r, dir, username)
+}
+
+// importManifest represents the outcome of importing a zip.
+type importManifest struct {
+	Created     []string `json:"created"`     // paths of created files and directories
+	Overwritten []string `json:"overwritten"` // paths of overwritten files
+	Skipped     []string `json:"skipped"`     // paths of existing files kept
+	Rejected    []string `json:"rejected"`    // names of entries escaping the target directory or symbolic links
+}
+
+// ImportZipHandler handles request of importing an uploaded zip into a directory.
+//
+// Query parameters: path is the target directory, policy is the conflict policy (skip/overwrite/rename, defaults to
+// skip).
+func ImportZipHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
 	q := r.URL.Query()
-	dir := q["path"][0]
+	dir := filepath.Clean(filepath.FromSlash(q.Get("path")))
+	if util.Go.IsAPI(dir) || !session.CanAccess(username, dir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if !util.File.IsDir(dir) {
+		result.Succ = false
+		result.Msg = "Can't find directory [" + dir + "]"
+
+		return
+	}
+
+	policy := q.Get("policy")
+	switch policy {
+	case conflictSkip, conflictOverwrite, conflictRename:
+	case "":
+		policy = conflictSkip
+	default:
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+
+		return
+	}
+
+	zipPath, err := receiveZip(r)
+	if nil != err {
+		logger.Error(err)
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+	defer os.Remove(zipPath)
+
+	size, err := getZipUncompressedSize(zipPath)
+	if nil == err {
+		err = checkQuota(username, dir, size)
+	}
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	manifest, written, err := importZip(zipPath, dir, policy)
+	addUsage(username, dir, written)
+	if nil != err {
+		logger.Errorf("User [%s] imports zip into [%s] failed: %s", username, dir, err)
+		result.Succ = false
+		result.Msg = err.Error()
+	}
+
+	result.Data = manifest
+}
+
+// receiveZip saves the first uploaded file of the specified request into a temporary file and returns its path.
+func receiveZip(r *http.Request) (string, error) {
+	mr, err := r.MultipartReader()
+	if nil != err {
+		return "", err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if nil != err {
+			return "", err
+		}
 
-	result.Data = handleUploads(r, dir)
+		if "" == part.FileName() {
+			continue
+		}
+
+		f, err := ioutil.TempFile("", "wide-import-")
+		if nil != err {
+			return "", err
+		}
+
+		_, err = io.Copy(f, part)
+		f.Close()
+		if nil != err {
+			os.Remove(f.Name())
+
+			return "", err
+		}
+
+		return f.Name(), nil
+	}
+}
+
+// importZip extracts the zip specified by zipPath into the specified directory with the specified conflict policy.
+//
+// Entries escaping the directory (zip slip, including through the symbolic links in the directory) and symbolic links
+// are rejected. Returns the bytes the directory grew by (even if failed), that is the sizes of the extracted files minus
+// the ones of the overwritten files.
+func importZip(zipPath, dir, policy string) (*importManifest, int64, error) {
+	manifest := &importManifest{Created: []string{}, Overwritten: []string{}, Skipped: []string{}, Rejected: []string{}}
+
+	var written int64
+	reader, err := zip.OpenReader(zipPath)
+	if nil != err {
+		return manifest, written, err
+	}
+	defer reader.Close()
+
+	for _, entry := range reader.File {
+		// resolves symbolic links already in the directory, an entry can't be written through them
+		path, err := util.File.SafeJoin(dir, entry.Name)
+		if nil != err || path == filepath.Clean(dir) || 0 != entry.Mode()&os.ModeSymlink {
+			manifest.Rejected = append(manifest.Rejected, entry.Name)
+
+			continue
+		}
+
+		if entry.FileInfo().IsDir() {
+			if util.File.IsExist(path) {
+				continue
+			}
+
+			if err := os.MkdirAll(path, 0755); nil != err {
+				return manifest, written, err
+			}
+			manifest.Created = append(manifest.Created, filepath.ToSlash(path))
+
+			continue
+		}
+
+		overwrite := false
+		if util.File.IsExist(path) {
+			switch policy {
+			case conflictSkip:
+				manifest.Skipped = append(manifest.Skipped, filepath.ToSlash(path))
+
+				continue
+			case conflictOverwrite:
+				if util.File.IsDir(path) {
+					manifest.Skipped = append(manifest.Skipped, filepath.ToSlash(path))
+
+					continue
+				}
+
+				overwrite = true
+			case conflictRename:
+				path = getNonConflictPath(path)
+			}
+		}
+
+		var oldSize int64
+		if overwrite {
+			oldSize = util.File.GetFileSize(path)
+		}
+
+		err = extractZipEntry(entry, path)
+		if util.File.IsExist(path) { // partly written if failed
+			written += util.File.GetFileSize(path) - oldSize
+		}
+		if nil != err {
+			return manifest, written, err
+		}
+
+		if overwrite {
+			manifest.Overwritten = append(manifest.Overwritten, filepath.ToSlash(path))
+		} else {
+			manifest.Created = append(manifest.Created, filepath.ToSlash(path))
+		}
+	}
+
+	return manifest, written, nil
+}
+
+// extractZipEntry extracts the specified zip entry to the specified path.
+func extractZipEntry(entry *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); nil != err {
+		return err
+	}
+
+	rc, err := entry.Open()
+	if nil != err {
+		return err
+	}
+	defer rc.Close()
+
+	f, err := os.Create(path)
+	if nil != err {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, rc)
+
+	return err
+}
+
+// getNonConflictPath gets a path which does not exist by appending a sequence to the file name of the specified path,
+// for example main.go to main_1.go.
+func getNonConflictPath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+
+	for i := 1; ; i++ {
+		ret := base + "_" + strconv.Itoa(i) + ext
+		if !util.File.IsExist(ret) {
+			return ret
+		}
+	}
 }
@@ -0,0 +1,111 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+)
+
+// Line endings.
+const (
+	lineEndingLF    = "lf"
+	lineEndingCRLF  = "crlf"
+	lineEndingCR    = "cr"
+	lineEndingMixed = "mixed"
+)
+
+// Line breaks of line endings.
+var lineBreaks = map[string]string{lineEndingLF: "\n", lineEndingCRLF: "\r\n", lineEndingCR: "\r"}
+
+// normalizeLineEndings converts all line breaks (CRLF, LF and CR) of the specified content to the specified one.
+func normalizeLineEndings(content, eol string) string {
+	content = strings.Replace(content, "\r\n", "\n", -1)
+	content = strings.Replace(content, "\r", "\n", -1)
+	if "\n" != eol {
+		content = strings.Replace(content, "\n", eol, -1)
+	}
+
+	return content
+}
+
+// detectLineEnding detects the line break used by the specified content, the first one found, defaults to LF.
+func detectLineEnding(content string) string {
+	if i := strings.IndexAny(content, "\r\n"); 0 <= i && '\r' == content[i] {
+		if i+1 < len(content) && '\n' == content[i+1] {
+			return "\r\n"
+		}
+
+		return "\r"
+	}
+
+	return "\n"
+}
+
+// getLineEnding gets the line ending (lf/crlf/cr) of the specified content, returns "mixed" if more than one kind of
+// line breaks are used, or empty if there is no line break.
+func getLineEnding(content string) string {
+	crlf := strings.Count(content, "\r\n")
+	cr := strings.Count(content, "\r") - crlf
+	lf := strings.Count(content, "\n") - crlf
+
+	ret := ""
+	for ending, count := range map[string]int{lineEndingLF: lf, lineEndingCRLF: crlf, lineEndingCR: cr} {
+		if 0 == count {
+			continue
+		}
+
+		if "" != ret {
+			return lineEndingMixed
+		}
+
+		ret = ending
+	}
+
+	return ret
+}
+
+// getSaveLineBreak gets the line break for saving the file specified by path for the user specified by username.
+//
+// Returns the line break of the user preference if set, otherwise the one used by the existing file (the first one
+// found if mixed), or empty if there is no line break in the existing file or the file doesn't exist.
+func getSaveLineBreak(username, path string) string {
+	if user := conf.GetUser(username); nil != user {
+		if eol, ok := lineBreaks[user.LineEnding]; ok {
+			return eol
+		}
+	}
+
+	f, err := os.Open(path)
+	if nil != err {
+		return ""
+	}
+	defer f.Close()
+
+	buf := make([]byte, 64*1024)
+	n, _ := io.ReadFull(f, buf)
+	head := string(buf[:n])
+	if decoded, err := decodeContent(buf[:n], detectEncoding(buf[:n])); nil == err {
+		head = decoded
+	}
+	if !strings.ContainsAny(head, "\r\n") {
+		return ""
+	}
+
+	return detectLineEnding(head)
+}
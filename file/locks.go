@@ -0,0 +1,104 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// LockFileHandler handles request of locking or unlocking a file (read-only).
+//
+// Argument "lock" is true to lock the file specified by argument "path", false to unlock it. Only the holder or an
+// admin could unlock a file. The lock is released when the holding session is removed.
+func LockFileHandler(w http.ResponseWriter, r *http.Request) {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	path := filepath.Clean(filepath.FromSlash(args["path"].(string)))
+	if util.Go.IsAPI(path) || !session.CanAccess(username, path) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if !util.File.IsExist(path) || util.File.IsDir(path) {
+		result.Succ = false
+		result.Msg = "[" + filepath.Base(path) + "] is not a file"
+
+		return
+	}
+
+	sid := args["sid"].(string)
+
+	if lock, _ := args["lock"].(bool); lock {
+		l := session.LockFile(sid, username, path)
+		if l.Username != username {
+			result.Succ = false
+			result.Msg = "[" + filepath.Base(path) + "] has been locked by [" + l.Username + "]"
+		}
+
+		result.Data = l
+
+		return
+	}
+
+	l := session.GetFileLock(path)
+	if nil == l {
+		return
+	}
+
+	if user := conf.GetUser(username); l.Username != username && (nil == user || !user.IsAdmin()) {
+		result.Succ = false
+		result.Msg = "[" + filepath.Base(path) + "] is locked by [" + l.Username + "], only the holder could unlock it"
+
+		return
+	}
+
+	session.UnlockFile(path)
+
+	logger.Debugf("User [%s] unlocked [%s] locked by [%s]", username, path, l.Username)
+}
+
+// checkFileLock checks whether the file specified by path, or any file under it if it's a directory, is locked.
+func checkFileLock(path string) error {
+	if lock := session.GetFileLockUnder(path); nil != lock {
+		return errors.New("[" + filepath.Base(lock.Path) + "] is locked by [" + lock.Username + "]")
+	}
+
+	return nil
+}
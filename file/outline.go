@@ -20,6 +20,7 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"net/http"
 	"strings"
 
@@ -32,7 +33,19 @@ type element struct {
 	Ch   int
 }
 
+// symbol represents a top-level declaration of a go file.
+type symbol struct {
+	Kind     string `json:"kind"`     // func/method/type/var/const
+	Name     string `json:"name"`     // name
+	Receiver string `json:"receiver"` // receiver type of a method, for example *Foo
+	Line     int    `json:"line"`     // line number, starts with 0
+	Ch       int    `json:"ch"`       // column number, starts with 0
+}
+
 // GetOutlineHandler gets outfile of a go file.
+//
+// The data also contains "symbols", the top-level declarations ordered by position. If the code has syntax errors,
+// the symbols could be parsed are returned and "error" is set to true.
 func GetOutlineHandler(w http.ResponseWriter, r *http.Request) {
 	result := util.NewResult()
 	defer util.RetResult(w, r, result)
@@ -50,7 +63,7 @@ func GetOutlineHandler(w http.ResponseWriter, r *http.Request) {
 
 	fset := token.NewFileSet()
 	f, err := parser.ParseFile(fset, "", code, 0)
-	if err != nil {
+	if nil == f || nil == f.Name || "" == f.Name.Name { // not a go file at all
 		result.Succ = false
 
 		return
@@ -59,6 +72,8 @@ func GetOutlineHandler(w http.ResponseWriter, r *http.Request) {
 	data := map[string]interface{}{}
 	result.Data = &data
 
+	data["error"] = nil != err
+
 	// ast.Print(fset, f)
 
 	line, ch := getCursor(code, int(f.Name.Pos()))
@@ -78,6 +93,7 @@ func GetOutlineHandler(w http.ResponseWriter, r *http.Request) {
 	structDecls := []*element{}
 	interfaceDecls := []*element{}
 	typeDecls := []*element{}
+	symbols := []*symbol{}
 	for _, decl := range f.Decls {
 		switch decl.(type) {
 		case *ast.FuncDecl:
@@ -86,6 +102,13 @@ func GetOutlineHandler(w http.ResponseWriter, r *http.Request) {
 			line, ch := getCursor(code, int(funcDecl.Name.Pos()))
 
 			funcDecls = append(funcDecls, &element{Name: funcDecl.Name.Name, Line: line, Ch: ch})
+
+			if nil != funcDecl.Recv && 0 < len(funcDecl.Recv.List) {
+				symbols = append(symbols, &symbol{Kind: "method", Name: funcDecl.Name.Name,
+					Receiver: types.ExprString(funcDecl.Recv.List[0].Type), Line: line, Ch: ch})
+			} else {
+				symbols = append(symbols, &symbol{Kind: "func", Name: funcDecl.Name.Name, Line: line, Ch: ch})
+			}
 		case *ast.GenDecl:
 			genDecl := decl.(*ast.GenDecl)
 
@@ -99,6 +122,7 @@ func GetOutlineHandler(w http.ResponseWriter, r *http.Request) {
 						line, ch := getCursor(code, int(varName.Pos()))
 
 						varDecls = append(varDecls, &element{Name: varName.Name, Line: line, Ch: ch})
+						symbols = append(symbols, &symbol{Kind: "var", Name: varName.Name, Line: line, Ch: ch})
 					}
 				case token.TYPE:
 					typeSpec := spec.(*ast.TypeSpec)
@@ -112,6 +136,8 @@ func GetOutlineHandler(w http.ResponseWriter, r *http.Request) {
 					case *ast.Ident:
 						typeDecls = append(typeDecls, &element{Name: typeSpec.Name.Name, Line: line, Ch: ch})
 					}
+
+					symbols = append(symbols, &symbol{Kind: "type", Name: typeSpec.Name.Name, Line: line, Ch: ch})
 				case token.CONST:
 					constSpec := spec.(*ast.ValueSpec)
 
@@ -119,6 +145,7 @@ func GetOutlineHandler(w http.ResponseWriter, r *http.Request) {
 						line, ch := getCursor(code, int(constName.Pos()))
 
 						constDecls = append(constDecls, &element{Name: constName.Name, Line: line, Ch: ch})
+						symbols = append(symbols, &symbol{Kind: "const", Name: constName.Name, Line: line, Ch: ch})
 					}
 				}
 			}
@@ -131,6 +158,7 @@ func GetOutlineHandler(w http.ResponseWriter, r *http.Request) {
 	data["structDecls"] = structDecls
 	data["interfaceDecls"] = interfaceDecls
 	data["typeDecls"] = typeDecls
+	data["symbols"] = symbols
 }
 
 // getCursor calculates the cursor position (line, ch) by the specified offset.
@@ -0,0 +1,119 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	fileMaxSize     = 5 * 1024 * 1024 // max size of a file opened by editor entirely (5M)
+	fileWindowLines = 2000            // max count of lines returned around the target line of a larger file
+)
+
+// getTargetPos gets the target position (1-based line and column) of opening a file from the specified request
+// arguments, the line is 0 if not specified.
+func getTargetPos(args map[string]interface{}) (line, column int) {
+	if l, ok := args["line"].(float64); ok && 1 <= l {
+		line = int(l)
+	}
+
+	column = 1
+	if c, ok := args["column"].(float64); ok && 1 <= c {
+		column = int(c)
+	}
+
+	return
+}
+
+// clampPos clamps the specified 1-based position into the specified content, a line after the last one is moved to
+// the last one and a column after the end of the line is moved to the end.
+func clampPos(content string, line, column int) (int, int) {
+	lines := strings.Split(content, "\n")
+	if 1 > line {
+		line = 1
+	}
+	if len(lines) < line {
+		line = len(lines)
+	}
+
+	if max := utf8.RuneCountInString(lines[line-1]) + 1; max < column {
+		column = max
+	}
+	if 1 > column {
+		column = 1
+	}
+
+	return line, column
+}
+
+// readLineWindow reads lines around the specified 1-based line of the file specified by path, returns the lines and
+// the line number of the first one.
+//
+// At most fileWindowLines lines and fileMaxSize bytes are returned, the target line is always included (truncated to
+// fileMaxSize bytes if it's even longer). If the file has less lines, the window ends with the last line.
+func readLineWindow(path string, line int) (string, int, error) {
+	f, err := os.Open(path)
+	if nil != err {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	window := []string{}
+	size := 0
+	startLine := 1
+	lineNo := 0
+	reader := bufio.NewReader(f)
+	for {
+		text, err := reader.ReadString('\n')
+		if "" != text {
+			lineNo++
+			if fileMaxSize < len(text) {
+				text = text[:fileMaxSize]
+			}
+
+			if lineNo <= line { // lines before the target keep at most half of the window
+				window = append(window, text)
+				size += len(text)
+				for 1 < len(window) && (fileWindowLines/2 < len(window) || fileMaxSize/2 < size) {
+					size -= len(window[0])
+					window = window[1:]
+					startLine++
+				}
+			} else {
+				if fileWindowLines <= len(window) || fileMaxSize < size+len(text) {
+					break
+				}
+
+				window = append(window, text)
+				size += len(text)
+			}
+		}
+
+		if nil != err {
+			if io.EOF != err {
+				return "", 0, err
+			}
+
+			break
+		}
+	}
+
+	return strings.Join(window, ""), startLine, nil
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// projectTemplatesDir is the directory of project templates, each template is a directory.
+//
+// Files with suffix .tmpl are executed as text/template with projectData and the suffix is trimmed, the others are
+// copied as they are. "_package_" in file names is replaced with the package name.
+const projectTemplatesDir = "conf/templates/projects"
+
+// Valid module path.
+var modulePathRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._~\-]*(/[A-Za-z0-9._~\-]+)*$`)
+
+// projectData represents variables could be used in a project template.
+type projectData struct {
+	Module   string // module path
+	Name     string // project directory name
+	Package  string // package name inferred from the project directory name
+	Username string // creator
+	Date     string // creation date, for example 2018-01-02
+}
+
+// ProjectTemplatesHandler handles request of listing project templates.
+func ProjectTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	ret := []string{}
+	files, err := ioutil.ReadDir(projectTemplatesDir)
+	if nil != err {
+		logger.Error(err)
+	}
+
+	for _, f := range files {
+		if f.IsDir() && templateNameRegexp.MatchString(f.Name()) {
+			ret = append(ret, f.Name())
+		}
+	}
+
+	result.Data = ret
+}
+
+// NewProjectHandler handles request of scaffolding a new Go project.
+//
+// Arguments: path is the parent directory, name is the project directory name, template is the project template
+// name (see projectTemplatesDir), module is the module path (defaults to the import path of the directory if it's
+// under a workspace src directory, or name). Runs `go mod init` if the template doesn't contain go.mod.
+//
+// Returns the file tree node of the project directory.
+func NewProjectHandler(w http.ResponseWriter, r *http.Request) {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	parent := filepath.Clean(filepath.FromSlash(args["path"].(string)))
+	if util.Go.IsAPI(parent) || !session.CanAccess(username, parent) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	name, _ := args["name"].(string)
+	if !isValidFileName(name) || strings.HasPrefix(name, ".") {
+		result.Succ = false
+		result.Msg = "Invalid project name [" + name + "]"
+
+		return
+	}
+
+	templateName, _ := args["template"].(string)
+	templateDir := filepath.Join(projectTemplatesDir, templateName)
+	if !templateNameRegexp.MatchString(templateName) || !util.File.IsDir(templateDir) {
+		result.Succ = false
+		result.Msg = "Project template [" + templateName + "] not found"
+
+		return
+	}
+
+	path := filepath.Join(parent, name)
+	if util.File.IsExist(path) {
+		result.Succ = false
+		result.Msg = "[" + path + "] already exists"
+
+		return
+	}
+
+	module, _ := args["module"].(string)
+	if "" == module {
+		module = getDefaultModulePath(username, path)
+	}
+	if !modulePathRegexp.MatchString(module) {
+		result.Succ = false
+		result.Msg = "Invalid module path [" + module + "]"
+
+		return
+	}
+
+	if err := checkQuota(username, path, 0); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	data := &projectData{
+		Module:   module,
+		Name:     name,
+		Package:  getPackageName(path),
+		Username: username,
+		Date:     time.Now().Format("2006-01-02"),
+	}
+
+	err := applyProjectTemplate(templateDir, path, data)
+	if nil == err && !util.File.IsExist(filepath.Join(path, "go.mod")) {
+		err = goModInit(username, path, module)
+	}
+	if nil != err {
+		logger.Warnf("Scaffolds project [%s] with template [%s] failed: %s", path, templateName, err)
+		os.RemoveAll(path)
+
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	addUsage(username, path, getSize(path))
+
+	logger.Debugf("Created a project [%s] with template [%s] by user [%s]", path, templateName, username)
+
+	node := &Node{
+		Id:        filepath.ToSlash(path),
+		Name:      name,
+		Path:      filepath.ToSlash(path),
+		IconSkin:  "ico-ztree-dir ",
+		IsParent:  true,
+		Type:      "d",
+		Creatable: true,
+		Removable: true,
+		Children:  []*Node{}}
+	walk(path, node, true, true, false, getUserTreeOrder(username))
+
+	result.Data = node
+
+	sid, _ := args["sid"].(string)
+	event.Publish(&event.Event{Code: event.EvtCodeFileCreated, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: path, Succ: true}})
+}
+
+// applyProjectTemplate creates the project directory specified by path with the specified template directory.
+func applyProjectTemplate(templateDir, path string, data *projectData) error {
+	return filepath.Walk(templateDir, func(src string, info os.FileInfo, err error) error {
+		if nil != err {
+			return err
+		}
+
+		rel, _ := filepath.Rel(templateDir, src)
+		rel = strings.Replace(rel, "_package_", data.Package, -1)
+		dest := filepath.Join(path, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+
+		content, err := ioutil.ReadFile(src)
+		if nil != err {
+			return err
+		}
+
+		if ".tmpl" == filepath.Ext(src) {
+			dest = strings.TrimSuffix(dest, ".tmpl")
+
+			tpl, err := template.New(rel).Parse(string(content))
+			if nil != err {
+				return err
+			}
+
+			buf := &bytes.Buffer{}
+			if err := tpl.Execute(buf, data); nil != err {
+				return err
+			}
+			content = buf.Bytes()
+		}
+
+		return ioutil.WriteFile(dest, content, 0644)
+	})
+}
+
+// goModInit runs `go mod init` with the specified module path in the specified directory.
+func goModInit(username, dir, module string) error {
+	goRoot := conf.GetGoRoot(username)
+
+	cmd := exec.Command(conf.GetGoExecutable(goRoot), "mod", "init", module)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOPATH="+conf.GetUserWorkspace(username), "GOROOT="+goRoot)
+	if user := conf.GetUser(username); nil != user {
+		cmd.Env = append(cmd.Env, "GOENV="+user.GoEnvPath())
+	}
+
+	if output, err := cmd.CombinedOutput(); nil != err {
+		return errors.New(strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// getDefaultModulePath gets the default module path of the project directory specified by path, the import path if
+// it's under a workspace src directory, otherwise the directory name.
+func getDefaultModulePath(username, path string) string {
+	for _, workspace := range filepath.SplitList(conf.GetUserWorkspace(username)) {
+		src := filepath.Join(workspace, "src") + string(os.PathSeparator)
+		if strings.HasPrefix(path, src) {
+			return filepath.ToSlash(path[len(src):])
+		}
+	}
+
+	return filepath.Base(path)
+}
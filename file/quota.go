@@ -0,0 +1,164 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"math"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/conf"
+)
+
+// usageTTL is the duration a computed workspace usage is cached, writes through Wide update the cached usage
+// immediately, other changes (such as building or removing) are picked up after it expired.
+const usageTTL = 10 * time.Minute
+
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// usage represents the disk usage of a user's workspace.
+type usage struct {
+	size    int64     // in bytes
+	updated time.Time // computed time
+}
+
+// Cached disk usages.
+//
+// <username, *usage>
+var usages = map[string]*usage{}
+
+// Exclusive lock.
+var usagesMutex sync.Mutex
+
+// checkQuota checks whether the specified size more bytes could be written to the specified path by the user
+// specified by username, returns errQuotaExceeded if the usage of the owner of the path (see getQuotaOwner) would
+// exceed conf.Wide.UserQuota.
+//
+// The trash directory is under the user workspace, so trashed items are counted as well.
+func checkQuota(username, path string, size int64) error {
+	if 0 >= conf.Wide.UserQuota {
+		return nil
+	}
+
+	owner := getQuotaOwner(username, path)
+	if getUsage(owner)+size > conf.Wide.UserQuota {
+		logger.Warnf("User [%s] exceeds the quota [%d] of user [%s] by writing [%d] bytes to [%s]", username,
+			conf.Wide.UserQuota, owner, size, path)
+
+		return errQuotaExceeded
+	}
+
+	return nil
+}
+
+// getRemainingQuota gets the bytes could be written more to the specified path by the user specified by username,
+// besides the specified pending bytes (received but not in the workspace yet), returns math.MaxInt64 if
+// conf.Wide.UserQuota is unlimited.
+func getRemainingQuota(username, path string, pending int64) int64 {
+	if 0 >= conf.Wide.UserQuota {
+		return math.MaxInt64
+	}
+
+	return conf.Wide.UserQuota - getUsage(getQuotaOwner(username, path)) - pending
+}
+
+// getQuotaOwner gets the name of the user writes to the specified path are charged to, that is the owner of the path
+// (which may be shared with the user specified by username), or the user specified by username if not found.
+func getQuotaOwner(username, path string) string {
+	if owner := conf.GetOwner(path); "" != owner {
+		return owner
+	}
+
+	return username
+}
+
+// copyWithinQuota copies from src to dst until EOF or the specified remaining bytes are copied, returns
+// errQuotaExceeded if src has more.
+func copyWithinQuota(dst io.Writer, src io.Reader, remaining int64) (int64, error) {
+	if 0 > remaining {
+		return 0, errQuotaExceeded
+	}
+
+	if math.MaxInt64 == remaining {
+		return io.Copy(dst, src)
+	}
+
+	// reads one more byte to tell whether src exceeds
+	n, err := io.Copy(dst, io.LimitReader(src, remaining+1))
+	if nil == err && n > remaining {
+		err = errQuotaExceeded
+	}
+
+	return n, err
+}
+
+// addUsage adds the specified delta (negative if shrunk) written to the specified path by the user specified by
+// username to the cached usage of the owner of the path (see getQuotaOwner).
+func addUsage(username, path string, delta int64) {
+	if 0 >= conf.Wide.UserQuota {
+		return
+	}
+
+	owner := getQuotaOwner(username, path)
+
+	usagesMutex.Lock()
+	defer usagesMutex.Unlock()
+
+	if u := usages[owner]; nil != u {
+		u.size += delta
+	}
+}
+
+// getUsage gets the disk usage of the workspace of the user specified by username.
+func getUsage(username string) int64 {
+	usagesMutex.Lock()
+	u := usages[username]
+	usagesMutex.Unlock()
+
+	if nil != u && time.Since(u.updated) < usageTTL {
+		return u.size
+	}
+
+	var size int64
+	for _, workspace := range filepath.SplitList(conf.GetUserWorkspace(username)) {
+		size += getSize(workspace)
+	}
+
+	usagesMutex.Lock()
+	usages[username] = &usage{size: size, updated: time.Now()}
+	usagesMutex.Unlock()
+
+	return size
+}
+
+// getZipUncompressedSize gets the total uncompressed size of entries of the zip specified by zipPath.
+func getZipUncompressedSize(zipPath string) (int64, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if nil != err {
+		return 0, err
+	}
+	defer reader.Close()
+
+	var ret int64
+	for _, entry := range reader.File {
+		ret += int64(entry.UncompressedSize64)
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/util"
+)
+
+// readOnlyPathTTL is the time to live of a read-only path.
+const readOnlyPathTTL = time.Hour
+
+// Paths of Go source files outside of users' workspaces (such as $GOROOT and the module cache) reported by
+// definition lookups, users are allowed to open these files in read-only mode.
+//
+// <username, <path, expire time>>
+var readOnlyPaths = map[string]map[string]time.Time{}
+
+// Exclusive lock.
+var readOnlyMutex sync.Mutex
+
+// AllowReadOnly allows the user specified by username to open the Go source file specified by path in read-only mode.
+//
+// Only paths reported by tools (gotools, gopls) should be passed in, never paths from requests.
+func AllowReadOnly(username, path string) {
+	path = filepath.Clean(path)
+	if ".go" != filepath.Ext(path) || !util.File.IsExist(path) || util.File.IsDir(path) {
+		return
+	}
+
+	readOnlyMutex.Lock()
+	defer readOnlyMutex.Unlock()
+
+	paths := readOnlyPaths[username]
+	if nil == paths {
+		paths = map[string]time.Time{}
+		readOnlyPaths[username] = paths
+	}
+
+	now := time.Now()
+	for p, expired := range paths {
+		if now.After(expired) {
+			delete(paths, p)
+		}
+	}
+
+	paths[path] = now.Add(readOnlyPathTTL)
+}
+
+// IsReadOnly checks whether the user specified by username is allowed to open the file specified by path in
+// read-only mode.
+func IsReadOnly(username, path string) bool {
+	path = filepath.Clean(filepath.FromSlash(path))
+
+	readOnlyMutex.Lock()
+	defer readOnlyMutex.Unlock()
+
+	expired, ok := readOnlyPaths[username][path]
+
+	return ok && time.Now().Before(expired)
+}
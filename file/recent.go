@@ -0,0 +1,90 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// recentMaxCount is the max count of recently opened files of a user.
+const recentMaxCount = 50
+
+// Exclusive lock of recent files.
+var recentMutex sync.Mutex
+
+// RecentFilesHandler handles request of listing files recently opened by the current user, the most recent first.
+//
+// Files no longer exist are pruned.
+func RecentFilesHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	user := conf.GetUser(username)
+	if nil == user {
+		result.Succ = false
+
+		return
+	}
+
+	recentMutex.Lock()
+	defer recentMutex.Unlock()
+
+	files := []string{}
+	for _, path := range user.RecentFiles {
+		if util.File.IsExist(filepath.FromSlash(path)) {
+			files = append(files, path)
+		}
+	}
+	user.RecentFiles = files // session.FixedTimeSave() will persist it
+
+	result.Data = files
+}
+
+// addRecentFile adds the file specified by path to the front of recently opened files of the user specified by
+// username.
+func addRecentFile(username, path string) {
+	user := conf.GetUser(username)
+	if nil == user {
+		return
+	}
+
+	path = filepath.ToSlash(path)
+
+	recentMutex.Lock()
+	defer recentMutex.Unlock()
+
+	files := []string{path}
+	for _, p := range user.RecentFiles {
+		if p != path && recentMaxCount > len(files) {
+			files = append(files, p)
+		}
+	}
+
+	user.RecentFiles = files
+}
@@ -0,0 +1,404 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// importChange represents an import path rewritten in a file.
+type importChange struct {
+	Line int    `json:"line"` // line of the import path, starts with 1
+	Old  string `json:"old"`  // old import path
+	New  string `json:"new"`  // new import path
+}
+
+// importRewrite represents a Go file whose import paths are rewritten.
+type importRewrite struct {
+	Path    string          `json:"path"` // path of the file after the package directory renamed
+	Changes []*importChange `json:"changes"`
+
+	oldPath string // path of the file before the package directory renamed
+	content string // content after rewriting
+	size    int64  // size before rewriting
+}
+
+// RenamePackageHandler handles request of renaming a package directory (argument "oldPath") to argument "newPath"
+// and rewriting the import statements referring the old import path (and the ones of its sub-packages).
+//
+// Import statements are rewritten across the module of the package (the directory containing go.mod) or the GOPATH
+// src directory of the workspace if there is no go.mod, the new path must be in the same scope. Nested modules,
+// vendor and testdata directories are left as they are. The package clauses are unchanged, so are the identifiers
+// referring to the packages.
+//
+// Data "oldImport" and "newImport" are the import paths, data "files" is the rewritten files (see importRewrite).
+// Nothing is renamed or written if argument "dryRun" is true, so users could preview the changes.
+func RenamePackageHandler(w http.ResponseWriter, r *http.Request) {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	oldPath, _ := args["oldPath"].(string)
+	oldPath, err := session.SafePath(username, oldPath)
+	if util.Go.IsAPI(oldPath) || nil != err {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	newPath, _ := args["newPath"].(string)
+	newPath, err = session.SafePath(username, newPath)
+	if util.Go.IsAPI(newPath) || nil != err {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	dryRun, _ := args["dryRun"].(bool)
+
+	if !util.File.IsDir(oldPath) {
+		result.Succ = false
+		result.Msg = "[" + filepath.Base(oldPath) + "] is not a directory"
+
+		return
+	}
+
+	if _, err := util.File.SafeJoin(oldPath, newPath); nil == err {
+		result.Succ = false
+		result.Msg = "can't move [" + filepath.Base(oldPath) + "] into itself"
+
+		return
+	}
+
+	if util.File.IsExist(newPath) {
+		result.Succ = false
+		result.Msg = "[" + newPath + "] already exists"
+
+		return
+	}
+
+	root, oldImport, newImport, err := getPackageImports(username, oldPath, newPath)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	rewrites, err := rewriteImports(root, oldPath, newPath, oldImport, newImport)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	result.Data = map[string]interface{}{"oldImport": oldImport, "newImport": newImport, "files": rewrites}
+
+	if dryRun {
+		return
+	}
+
+	if err := checkFileLock(oldPath); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	delta := int64(0)
+	for _, rewrite := range rewrites {
+		if err := checkFileLock(rewrite.oldPath); nil != err {
+			result.Succ = false
+			result.Msg = err.Error()
+
+			return
+		}
+
+		delta += int64(len(rewrite.content)) - rewrite.size
+	}
+
+	if err := checkQuota(username, newPath, delta); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); nil != err || !renameFile(oldPath, newPath) {
+		result.Succ = false
+		result.Msg = "can't rename [" + oldPath + "]"
+
+		return
+	}
+
+	sid, _ := args["sid"].(string)
+	event.Publish(&event.Event{Code: event.EvtCodeFileRenamed, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: oldPath, NewPath: newPath, Succ: true}})
+
+	for _, rewrite := range rewrites {
+		saveVersion(username, rewrite.Path, rewrite.content)
+
+		info, err := os.Stat(rewrite.Path)
+		if nil != err {
+			logger.Error(err)
+
+			continue
+		}
+
+		if err := ioutil.WriteFile(rewrite.Path, []byte(rewrite.content), info.Mode()); nil != err {
+			logger.Error(err)
+			result.Succ = false
+			result.Msg = "can't write [" + rewrite.Path + "]"
+
+			continue
+		}
+
+		removeDraft(username, rewrite.Path)
+
+		event.Publish(&event.Event{Code: event.EvtCodeFileSaved, Sid: sid,
+			Data: &event.Lifecycle{Username: username, Path: rewrite.Path, Succ: true}})
+	}
+	addUsage(username, newPath, delta)
+
+	logger.Debugf("User [%s] renamed package [%s] to [%s], rewrote imports of [%d] files", username, oldImport,
+		newImport, len(rewrites))
+}
+
+// getPackageImports gets the import paths of the package directory specified by oldPath and the one specified by
+// newPath it's renamed to, and the root directory (module or GOPATH src) whose files could import the package.
+func getPackageImports(username, oldPath, newPath string) (root, oldImport, newImport string, err error) {
+	modRoot, module := findModule(username, oldPath)
+	if "" != modRoot {
+		root = modRoot
+	} else {
+		for _, workspace := range filepath.SplitList(conf.GetUserWorkspace(username)) {
+			src := filepath.Join(workspace, "src")
+			if _, err := util.File.SafeJoin(src, oldPath); nil == err && src != oldPath {
+				root = src
+
+				break
+			}
+		}
+
+		if "" == root {
+			return "", "", "", errors.New("[" + oldPath + "] is neither in a module nor in a GOPATH src directory")
+		}
+	}
+
+	if root == oldPath {
+		return "", "", "", errors.New("can't rename the root of a module")
+	}
+
+	newRel, err := filepath.Rel(root, newPath)
+	if nil != err || strings.HasPrefix(newRel, "..") || "." == newRel {
+		return "", "", "", errors.New("[" + newPath + "] is out of [" + root + "]")
+	}
+
+	oldRel, _ := filepath.Rel(root, oldPath)
+	if "" != module {
+		return root, module + "/" + filepath.ToSlash(oldRel), module + "/" + filepath.ToSlash(newRel), nil
+	}
+
+	return root, filepath.ToSlash(oldRel), filepath.ToSlash(newRel), nil
+}
+
+// findModule finds the module (go.mod) containing the specified path in the workspaces of the user specified by
+// username, returns the module directory and the module path, or empty ones if not found.
+func findModule(username, path string) (dir, module string) {
+	for dir = path; session.CanAccess(username, dir); dir = filepath.Dir(dir) {
+		if module = getModulePath(filepath.Join(dir, "go.mod")); "" != module {
+			return dir, module
+		}
+
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	return "", ""
+}
+
+// getModulePath gets the module path declared in the go.mod file specified by path, returns "" if not found.
+func getModulePath(path string) string {
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return ""
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "module") {
+			continue
+		}
+
+		line = strings.TrimSpace(strings.TrimPrefix(line, "module"))
+		if i := strings.Index(line, "//"); -1 < i {
+			line = strings.TrimSpace(line[:i])
+		}
+		if module, err := strconv.Unquote(line); nil == err {
+			line = module
+		}
+
+		return line
+	}
+
+	return ""
+}
+
+// rewriteImports finds the Go files under the specified root importing the specified old import path (or its
+// sub-packages), and rewrites them to the specified new import path by go/ast positions of the import specs, so the
+// rest of the files are kept as they are. Paths of the files under the package directory specified by oldPath are
+// mapped into newPath.
+func rewriteImports(root, oldPath, newPath, oldImport, newImport string) ([]*importRewrite, error) {
+	ret := []*importRewrite{}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if nil != err {
+			logger.Warn(err)
+
+			return nil
+		}
+
+		name := info.Name()
+		if info.IsDir() {
+			if path == root {
+				return nil
+			}
+
+			if "vendor" == name || "testdata" == name || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") ||
+				trashDirName == name || draftDirName == name || versionDirName == name ||
+				util.File.IsExist(filepath.Join(path, "go.mod")) { // nested module
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if ".go" != filepath.Ext(name) || fileMaxSize < info.Size() {
+			return nil
+		}
+
+		rewrite, err := rewriteFileImports(path, oldImport, newImport)
+		if nil != err {
+			logger.Debugf("Skipped rewriting imports of [%s]: %s", path, err)
+
+			return nil
+		}
+
+		if nil != rewrite {
+			rewrite.oldPath = path
+			rewrite.Path = path
+			if _, err := util.File.SafeJoin(oldPath, path); nil == err {
+				rel, _ := filepath.Rel(oldPath, path)
+				rewrite.Path = filepath.Join(newPath, rel)
+			}
+			rewrite.size = info.Size()
+
+			ret = append(ret, rewrite)
+		}
+
+		return nil
+	})
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Path < ret[j].Path })
+	for _, rewrite := range ret {
+		rewrite.Path = filepath.ToSlash(rewrite.Path)
+	}
+
+	return ret, err
+}
+
+// rewriteFileImports rewrites the import paths of the Go file specified by path from the specified old import path
+// (or its sub-packages) to the specified new one, returns nil if nothing is rewritten.
+func rewriteFileImports(path, oldImport, newImport string) (*importRewrite, error) {
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, data, parser.ImportsOnly)
+	if nil != err {
+		return nil, err
+	}
+
+	ret := &importRewrite{Changes: []*importChange{}}
+	content := string(data)
+
+	// rewrites backward so the offsets of the preceding specs are kept
+	for i := len(f.Imports) - 1; i >= 0; i-- {
+		lit := f.Imports[i].Path
+		importPath, err := strconv.Unquote(lit.Value)
+		if nil != err {
+			continue
+		}
+
+		var rewritten string
+		switch {
+		case importPath == oldImport:
+			rewritten = newImport
+		case strings.HasPrefix(importPath, oldImport+"/"):
+			rewritten = newImport + importPath[len(oldImport):]
+		default:
+			continue
+		}
+
+		start := fset.Position(lit.Pos()).Offset
+		end := fset.Position(lit.End()).Offset
+		content = content[:start] + strconv.Quote(rewritten) + content[end:]
+
+		change := &importChange{Line: fset.Position(lit.Pos()).Line, Old: importPath, New: rewritten}
+		ret.Changes = append([]*importChange{change}, ret.Changes...)
+	}
+
+	if 0 == len(ret.Changes) {
+		return nil, nil
+	}
+
+	ret.content = content
+
+	return ret, nil
+}
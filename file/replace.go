@@ -0,0 +1,192 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// errEmptyFind indicates the text to find is empty.
+var errEmptyFind = errors.New("nothing to find")
+
+// ReplaceInFileHandler handles request of finding and replacing in the file specified by argument "path".
+//
+// Argument "find" is a regular expression if argument "regexp" is true, then argument "replacement" could refer to
+// the capture groups ($1, ${name}) as regexp.Regexp.Expand, otherwise both are literal. Arguments "ignoreCase" and
+// "wholeWord" make the match case-insensitive and of whole words only.
+//
+// The content (data "content") after replacing and the count of replacements (data "count") are returned, the file
+// is written (in its encoding, see SaveFileHandler) only if argument "dryRun" is not true and anything is replaced.
+func ReplaceInFileHandler(w http.ResponseWriter, r *http.Request) {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	path, _ := args["path"].(string)
+	path, err := session.SafePath(username, path)
+	if util.Go.IsAPI(path) || nil != err || !util.File.IsExist(path) || util.File.IsDir(path) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	find, _ := args["find"].(string)
+	replacement, _ := args["replacement"].(string)
+	isRegexp, _ := args["regexp"].(bool)
+	ignoreCase, _ := args["ignoreCase"].(bool)
+	wholeWord, _ := args["wholeWord"].(bool)
+	dryRun, _ := args["dryRun"].(bool)
+
+	re, err := compileFindRegexp(find, isRegexp, ignoreCase, wholeWord)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	if fileMaxSize < util.File.GetFileSize(path) {
+		result.Succ = false
+		result.Msg = "This file is too large to open :("
+
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	encoding := detectEncoding(data)
+	content, err := decodeContent(data, encoding)
+	if nil != err || util.File.IsBinary(content) {
+		result.Succ = false
+		result.Msg = "Can't open a binary file :("
+
+		return
+	}
+
+	count := len(re.FindAllStringIndex(content, -1))
+	if 0 < count {
+		if isRegexp {
+			content = re.ReplaceAllString(content, replacement)
+		} else {
+			content = re.ReplaceAllLiteralString(content, replacement)
+		}
+	}
+	result.Data = map[string]interface{}{"content": content, "count": count}
+
+	if dryRun || 0 == count {
+		return
+	}
+
+	if err := checkFileLock(path); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	encoded, err := encodeContent(content, encoding)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	delta := int64(len(encoded) - len(data))
+	if err := checkQuota(username, path, delta); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	saveVersion(username, path, string(encoded))
+
+	info, _ := os.Stat(path)
+	if err := ioutil.WriteFile(path, encoded, info.Mode()); nil != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	addUsage(username, path, delta)
+	removeDraft(username, path)
+
+	logger.Debugf("User [%s] replaced [%d] matches of [%s] in [%s]", username, count, find, path)
+}
+
+// compileFindRegexp compiles the specified text to find into a regular expression with the specified options.
+func compileFindRegexp(find string, isRegexp, ignoreCase, wholeWord bool) (*regexp.Regexp, error) {
+	if "" == find {
+		return nil, errEmptyFind
+	}
+
+	expr := find
+	if isRegexp {
+		if wholeWord {
+			expr = `\b(?:` + expr + `)\b`
+		}
+	} else {
+		expr = regexp.QuoteMeta(find)
+		if wholeWord { // word boundaries make sense only beside word characters
+			if isWordChar(find[0]) {
+				expr = `\b` + expr
+			}
+			if isWordChar(find[len(find)-1]) {
+				expr += `\b`
+			}
+		}
+	}
+	if ignoreCase {
+		expr = `(?i)` + expr
+	}
+
+	return regexp.Compile(expr)
+}
+
+// isWordChar checks whether the specified byte is a word character (\w) of regular expressions.
+func isWordChar(b byte) bool {
+	return '_' == b || ('0' <= b && '9' >= b) || ('a' <= b && 'z' >= b) || ('A' <= b && 'Z' >= b)
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+)
+
+const (
+	searchDefaultMaxResults = 500 // max results of a page if conf.Wide.SearchMaxResults is not set
+	searchCountFactor       = 10  // stops counting matches at (offset + limit) * searchCountFactor
+)
+
+// errSearchStop stops walking of a text search.
+var errSearchStop = errors.New("search stopped")
+
+// textSearch represents a paginated text search.
+type textSearch struct {
+	ctx       context.Context
+	text      string     // keyword
+	extension string     // file name suffix filter
+	includes  []string   // file name globs to include, empty means all
+	excludes  []string   // file or directory name globs to exclude
+	offset    int        // count of matches to skip
+	limit     int        // max count of matches of the page
+	total     int        // count of matches found
+	stopped   bool       // whether counting stopped before walking all files
+	snippets  []*Snippet // matches of the page
+}
+
+// newTextSearch creates a text search with the specified request arguments, walking stops if the specified context is
+// done (the request timeout for example).
+func newTextSearch(ctx context.Context, args map[string]interface{}) *textSearch {
+	ret := &textSearch{ctx: ctx, snippets: []*Snippet{}}
+
+	ret.text, _ = args["text"].(string)
+	ret.extension, _ = args["extension"].(string)
+	ret.includes = getGlobs(args["include"])
+	ret.excludes = getGlobs(args["exclude"])
+
+	if offset, ok := args["offset"].(float64); ok && 0 < offset {
+		ret.offset = int(offset)
+	}
+
+	maxResults := conf.Wide.SearchMaxResults
+	if 1 > maxResults {
+		maxResults = searchDefaultMaxResults
+	}
+
+	ret.limit = maxResults
+	if limit, ok := args["limit"].(float64); ok && 0 < limit && int(limit) < maxResults {
+		ret.limit = int(limit)
+	}
+
+	return ret
+}
+
+// search finds files under the specified dir and its sub-directories with the text, likes the command 'grep' or
+// 'findstr'.
+func (ts *textSearch) search(dir string) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if nil != err {
+			logger.Warn(err)
+
+			return nil
+		}
+
+		name := info.Name()
+		if info.IsDir() {
+			if path != dir && (trashDirName == name || draftDirName == name || versionDirName == name ||
+				ts.isExcluded(name)) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !strings.HasSuffix(name, ts.extension) || ts.isExcluded(name) || !ts.isIncluded(name) {
+			return nil
+		}
+
+		if nil != ts.ctx.Err() {
+			ts.stopped = true
+
+			return errSearchStop
+		}
+
+		ts.collect(searchInFile(path, ts.text))
+		if ts.stopped {
+			return errSearchStop
+		}
+
+		return nil
+	})
+}
+
+// collect collects the specified snippets into the current page.
+func (ts *textSearch) collect(snippets []*Snippet) {
+	countCap := (ts.offset + ts.limit) * searchCountFactor
+
+	for _, snippet := range snippets {
+		if countCap <= ts.total {
+			ts.stopped = true
+
+			return
+		}
+
+		ts.total++
+		if ts.offset < ts.total && len(ts.snippets) < ts.limit {
+			ts.snippets = append(ts.snippets, snippet)
+		}
+	}
+}
+
+// truncated checks whether there are more matches after the current page.
+func (ts *textSearch) truncated() bool {
+	return ts.offset+len(ts.snippets) < ts.total
+}
+
+// isIncluded checks whether the specified file name matches the include globs.
+func (ts *textSearch) isIncluded(name string) bool {
+	if 1 > len(ts.includes) {
+		return true
+	}
+
+	return matchGlobs(name, ts.includes)
+}
+
+// isExcluded checks whether the specified file or directory name matches the exclude globs.
+func (ts *textSearch) isExcluded(name string) bool {
+	return matchGlobs(name, ts.excludes)
+}
+
+// matchGlobs checks whether the specified name matches any of the specified globs.
+func matchGlobs(name string, globs []string) bool {
+	for _, glob := range globs {
+		if matched, _ := filepath.Match(glob, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getGlobs gets globs from the specified request argument, an array or a comma separated string.
+func getGlobs(arg interface{}) []string {
+	ret := []string{}
+
+	switch v := arg.(type) {
+	case string:
+		for _, glob := range strings.Split(v, ",") {
+			if glob = strings.TrimSpace(glob); "" != glob {
+				ret = append(ret, glob)
+			}
+		}
+	case []interface{}:
+		for _, glob := range v {
+			if g, ok := glob.(string); ok && "" != strings.TrimSpace(g) {
+				ret = append(ret, strings.TrimSpace(g))
+			}
+		}
+	}
+
+	return ret
+}
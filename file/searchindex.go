@@ -0,0 +1,506 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// searchIndexTTL is the max age of a search index, an older one is rebuilt in background to pick up files changed
+// outside of Wide (by go get or git in a terminal for example).
+const searchIndexTTL = 10 * time.Minute
+
+// indexedFile represents a file cached by a search index.
+type indexedFile struct {
+	modTime time.Time
+	size    int64
+	content string // empty for binary files
+}
+
+// searchIndex represents an in-memory cache of the files (with their contents and modification times) of a workspace
+// for text searches.
+type searchIndex struct {
+	mutex    sync.RWMutex
+	root     string                  // workspace directory
+	files    map[string]*indexedFile // <path, file>
+	keys     []string                // sort keys of the files in walking order, nil if unsorted
+	size     int64                   // total size of the cached contents
+	built    time.Time               // time of the last (re)build
+	building bool                    // whether it's being (re)built
+	disabled bool                    // whether the workspace is too large to index, retried after searchIndexTTL
+}
+
+var (
+	searchIndexes      = map[string]*searchIndex{} // <workspace, search index>
+	searchIndexesMutex sync.Mutex
+)
+
+// Load subscribes the lifecycle events updating search indexes incrementally.
+func Load() {
+	for _, code := range []int{event.EvtCodeFileSaved, event.EvtCodeFileCreated, event.EvtCodeFileRemoved,
+		event.EvtCodeFileRenamed} {
+		event.Subscribe(code, event.HandleFunc(updateSearchIndex))
+	}
+}
+
+// RebuildSearchIndexHandler handles request of rebuilding search indexes of the user's workspaces.
+//
+// Indexes are rebuilt in background, text searches walk the workspaces directly until done.
+func RebuildSearchIndexHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	if 1 > conf.Wide.SearchIndexMaxSize {
+		result.Succ = false
+		result.Msg = "search index is disabled"
+
+		return
+	}
+
+	workspaces := filepath.SplitList(conf.GetUserWorkspace(username))
+	for _, workspace := range workspaces {
+		rebuildSearchIndex(workspace)
+	}
+
+	result.Data = workspaces
+}
+
+// searchIndexed finds files under the specified dir with the text by the search index of the workspace containing
+// the dir. Returns false if the index is disabled, cold (being built in background) or the dir isn't in a workspace,
+// the caller should walk the dir directly then.
+func (ts *textSearch) searchIndexed(dir string) bool {
+	index := getSearchIndex(dir)
+	if nil == index {
+		return false
+	}
+
+	index.mutex.RLock()
+	if nil == index.keys {
+		index.mutex.RUnlock()
+		index.sort()
+		index.mutex.RLock()
+	}
+	keys := index.keys
+	index.mutex.RUnlock()
+
+	prefix := searchIndexKey(dir) + "\x00"
+	start := sort.SearchStrings(keys, prefix)
+	for _, key := range keys[start:] {
+		if !strings.HasPrefix(key, prefix) {
+			break
+		}
+
+		// directories under the dir are filtered as walking does
+		names := strings.Split(key[len(prefix):], "\x00")
+		name := names[len(names)-1]
+		excluded := false
+		for _, dirName := range names[:len(names)-1] {
+			if ts.isExcluded(dirName) {
+				excluded = true
+
+				break
+			}
+		}
+
+		if excluded || !strings.HasSuffix(name, ts.extension) || ts.isExcluded(name) || !ts.isIncluded(name) {
+			continue
+		}
+
+		if nil != ts.ctx.Err() {
+			ts.stopped = true
+
+			break
+		}
+
+		path := strings.Replace(key, "\x00", string(filepath.Separator), -1)
+		content, ok := index.content(path)
+		if !ok {
+			continue
+		}
+
+		ts.collect(searchInContent(path, content, ts.text))
+		if ts.stopped {
+			break
+		}
+	}
+
+	return true
+}
+
+// getSearchIndex gets the warm search index of the workspace containing the specified dir, starts building it in
+// background if it's cold. Returns nil if the index is disabled, cold or the dir isn't in a workspace.
+func getSearchIndex(dir string) *searchIndex {
+	if 1 > conf.Wide.SearchIndexMaxSize {
+		return nil
+	}
+
+	workspace := getIndexedWorkspace(dir)
+	if "" == workspace {
+		return nil
+	}
+
+	searchIndexesMutex.Lock()
+	index := searchIndexes[workspace]
+	if nil == index {
+		index = &searchIndex{root: workspace}
+		searchIndexes[workspace] = index
+	}
+	searchIndexesMutex.Unlock()
+
+	index.mutex.RLock()
+	building, disabled, warm := index.building, index.disabled, nil != index.files
+	expired := time.Since(index.built) > searchIndexTTL
+	index.mutex.RUnlock()
+
+	if !building && ((!warm && !disabled) || expired) {
+		rebuildSearchIndex(workspace)
+	}
+
+	if disabled || !warm {
+		return nil
+	}
+
+	return index
+}
+
+// getIndexedWorkspace gets the workspace containing the specified dir, returns "" if not found.
+func getIndexedWorkspace(dir string) string {
+	owner := conf.GetOwner(dir)
+	if "" == owner {
+		return ""
+	}
+
+	for _, workspace := range filepath.SplitList(conf.GetUserWorkspace(owner)) {
+		if _, err := util.File.SafeJoin(workspace, dir); nil == err {
+			return filepath.Clean(workspace)
+		}
+	}
+
+	return ""
+}
+
+// rebuildSearchIndex rebuilds the search index of the specified workspace in background, the old one (if any) is
+// used until done.
+func rebuildSearchIndex(workspace string) {
+	workspace = filepath.Clean(workspace)
+
+	searchIndexesMutex.Lock()
+	index := searchIndexes[workspace]
+	if nil == index {
+		index = &searchIndex{root: workspace}
+		searchIndexes[workspace] = index
+	}
+	searchIndexesMutex.Unlock()
+
+	index.mutex.Lock()
+	if index.building {
+		index.mutex.Unlock()
+
+		return
+	}
+	index.building = true
+	index.mutex.Unlock()
+
+	go func() {
+		defer util.Recover()
+
+		files, size, ok := scanSearchIndex(workspace, searchIndexBudget(index))
+
+		index.mutex.Lock()
+		defer index.mutex.Unlock()
+
+		index.building = false
+		index.built = time.Now()
+		index.keys = nil
+		if !ok {
+			index.files, index.size, index.disabled = nil, 0, true
+			logger.Warnf("Workspace [%s] is too large to index for searching", workspace)
+
+			return
+		}
+
+		index.files, index.size, index.disabled = files, size, false
+		logger.Debugf("Indexed workspace [%s] for searching, [%d] files, [%d] bytes", workspace, len(files), size)
+	}()
+}
+
+// searchIndexBudget gets the max size of contents the specified index could cache, conf.Wide.SearchIndexMaxSize is
+// shared by all indexes.
+func searchIndexBudget(index *searchIndex) int64 {
+	searchIndexesMutex.Lock()
+	defer searchIndexesMutex.Unlock()
+
+	ret := conf.Wide.SearchIndexMaxSize
+	for _, other := range searchIndexes {
+		if other == index {
+			continue
+		}
+
+		other.mutex.RLock()
+		ret -= other.size
+		other.mutex.RUnlock()
+	}
+
+	return ret
+}
+
+// scanSearchIndex walks the specified workspace and reads its files, skipping the trash, drafts and versions
+// directories. Returns false if the contents exceed the specified budget.
+func scanSearchIndex(workspace string, budget int64) (files map[string]*indexedFile, size int64, ok bool) {
+	files = map[string]*indexedFile{}
+
+	err := filepath.Walk(workspace, func(path string, info os.FileInfo, err error) error {
+		if nil != err {
+			logger.Warn(err)
+
+			return nil
+		}
+
+		if info.IsDir() {
+			name := info.Name()
+			if path != workspace && (trashDirName == name || draftDirName == name || versionDirName == name) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file := readIndexedFile(path, info)
+		size += int64(len(file.content))
+		if size > budget {
+			return errSearchStop
+		}
+
+		files[path] = file
+
+		return nil
+	})
+
+	return files, size, nil == err
+}
+
+// readIndexedFile reads the file specified by path and info for indexing, the content of a binary or unreadable file
+// is empty.
+func readIndexedFile(path string, info os.FileInfo) *indexedFile {
+	ret := &indexedFile{modTime: info.ModTime(), size: info.Size()}
+
+	bytes, err := ioutil.ReadFile(path)
+	if nil != err {
+		logger.Warnf("Read file [%s] failed: [%s]", path, err.Error())
+
+		return ret
+	}
+
+	if content := string(bytes); !util.File.IsBinary(content) {
+		ret.content = content
+	}
+
+	return ret
+}
+
+// content gets the content of the file specified by path, the file is re-read if its modification time or size has
+// changed since indexed. Returns false if the file doesn't exist anymore.
+func (index *searchIndex) content(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if nil != err {
+		index.remove(path)
+
+		return "", false
+	}
+
+	index.mutex.RLock()
+	file := index.files[path]
+	index.mutex.RUnlock()
+
+	if nil != file && file.modTime.Equal(info.ModTime()) && file.size == info.Size() {
+		return file.content, true
+	}
+
+	file = readIndexedFile(path, info)
+	index.put(path, file)
+
+	return file.content, true
+}
+
+// put caches the specified file, the index is disabled if it exceeds its budget.
+func (index *searchIndex) put(path string, file *indexedFile) {
+	budget := searchIndexBudget(index)
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	if nil == index.files {
+		return
+	}
+
+	if old := index.files[path]; nil != old {
+		index.size -= int64(len(old.content))
+	} else {
+		index.keys = nil
+	}
+
+	index.files[path] = file
+	index.size += int64(len(file.content))
+
+	if index.size > budget {
+		index.files, index.keys, index.size, index.disabled = nil, nil, 0, true
+		logger.Warnf("Workspace [%s] is too large to index for searching", index.root)
+	}
+}
+
+// remove removes the file or directory specified by path from the index.
+func (index *searchIndex) remove(path string) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	prefix := path + string(filepath.Separator)
+	for p, file := range index.files {
+		if p == path || strings.HasPrefix(p, prefix) {
+			delete(index.files, p)
+			index.size -= int64(len(file.content))
+			index.keys = nil
+		}
+	}
+}
+
+// add indexes the file or directory specified by path.
+func (index *searchIndex) add(path string) {
+	filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if nil != err {
+			return nil
+		}
+
+		if info.IsDir() {
+			name := info.Name()
+			if trashDirName == name || draftDirName == name || versionDirName == name {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if info.Mode().IsRegular() {
+			index.put(p, readIndexedFile(p, info))
+		}
+
+		return nil
+	})
+}
+
+// sort sorts the keys of the indexed files in walking order.
+func (index *searchIndex) sort() {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	keys := make([]string, 0, len(index.files))
+	for path := range index.files {
+		keys = append(keys, searchIndexKey(path))
+	}
+	sort.Strings(keys)
+
+	index.keys = keys
+}
+
+// searchIndexKey gets the sort key of the specified path, separators are replaced with "\x00" so the keys are sorted
+// as filepath.Walk visits the files.
+func searchIndexKey(path string) string {
+	return strings.Replace(path, string(filepath.Separator), "\x00", -1)
+}
+
+// updateSearchIndex updates the warm search index of the workspace containing the file or directory of the specified
+// lifecycle event.
+func updateSearchIndex(e *event.Event) {
+	if 1 > conf.Wide.SearchIndexMaxSize {
+		return
+	}
+
+	lifecycle := e.Data.(*event.Lifecycle)
+
+	for _, path := range []string{lifecycle.Path, lifecycle.NewPath} {
+		if "" == path {
+			continue
+		}
+		path = filepath.Clean(path)
+
+		searchIndexesMutex.Lock()
+		index := searchIndexes[getIndexedWorkspace(path)]
+		searchIndexesMutex.Unlock()
+
+		if nil == index {
+			continue
+		}
+
+		index.mutex.RLock()
+		warm := nil != index.files
+		index.mutex.RUnlock()
+		if !warm || isInSkippedDir(index.root, path) {
+			continue
+		}
+
+		switch e.Code {
+		case event.EvtCodeFileRemoved:
+			index.remove(path)
+		case event.EvtCodeFileRenamed:
+			if path == filepath.Clean(lifecycle.Path) {
+				index.remove(path)
+			} else {
+				index.add(path)
+			}
+		default: // saved or created
+			index.add(path)
+		}
+	}
+}
+
+// isInSkippedDir checks whether the specified path is in a trash, drafts or versions directory under the specified
+// workspace.
+func isInSkippedDir(workspace, path string) bool {
+	rel, err := filepath.Rel(workspace, path)
+	if nil != err {
+		return true
+	}
+
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		if trashDirName == name || draftDirName == name || versionDirName == name {
+			return true
+		}
+	}
+
+	return false
+}
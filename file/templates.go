@@ -0,0 +1,155 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/b3log/wide/util"
+)
+
+// templatesDir is the directory of new Go file templates, each template is a {name}.tmpl file.
+const templatesDir = "conf/templates"
+
+// defaultTemplate is the name of the template used if no template specified.
+const defaultTemplate = "default"
+
+// Valid template name.
+var templateNameRegexp = regexp.MustCompile("^[A-Za-z0-9_-]+$")
+
+// templateData represents variables could be used in a template.
+type templateData struct {
+	Package  string // package name inferred from the directory
+	Name     string // file name without extension and _test suffix, for example user_list
+	Title    string // camel case of Name, for example UserList
+	Username string // creator
+	Date     string // creation date, for example 2018-01-02
+}
+
+// FileTemplatesHandler handles request of listing new Go file templates.
+func FileTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	result.Data = listFileTemplates()
+}
+
+// listFileTemplates lists names of new Go file templates.
+func listFileTemplates() []string {
+	ret := []string{}
+
+	files, err := ioutil.ReadDir(templatesDir)
+	if nil != err {
+		logger.Error(err)
+
+		return ret
+	}
+
+	for _, f := range files {
+		if ".tmpl" == filepath.Ext(f.Name()) {
+			ret = append(ret, strings.TrimSuffix(f.Name(), ".tmpl"))
+		}
+	}
+
+	return ret
+}
+
+// applyFileTemplate fills the new Go file specified by path with the template specified by name.
+//
+// Templates are read on every call, so they can be edited without restarting the server.
+func applyFileTemplate(username, path, name string) error {
+	if "" == name {
+		name = defaultTemplate
+	}
+
+	if !templateNameRegexp.MatchString(name) {
+		return os.ErrNotExist
+	}
+
+	tpl, err := template.ParseFiles(filepath.Join(templatesDir, name+".tmpl"))
+	if nil != err {
+		return err
+	}
+
+	fileName := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".go"), "_test")
+	data := &templateData{
+		Package:  getPackageName(filepath.Dir(path)),
+		Name:     fileName,
+		Title:    toCamelCase(fileName),
+		Username: username,
+		Date:     time.Now().Format("2006-01-02"),
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, data); nil != err {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// getPackageName gets the package name of the specified directory.
+//
+// Uses the package clause of an existing non-test Go file in the directory, or the directory name if there is no Go
+// file.
+func getPackageName(dir string) string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+
+		astFile, err := parser.ParseFile(token.NewFileSet(), f, nil, parser.PackageClauseOnly)
+		if nil == err && "" != astFile.Name.Name {
+			return astFile.Name.Name
+		}
+	}
+
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+
+		return '_'
+	}, filepath.Base(dir))
+
+	if "" == name || unicode.IsDigit([]rune(name)[0]) {
+		name = "_" + name
+	}
+
+	return name
+}
+
+// toCamelCase converts the specified name to camel case, for example user_list to UserList.
+func toCamelCase(name string) string {
+	ret := ""
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		runes := []rune(part)
+		ret += string(unicode.ToUpper(runes[0])) + string(runes[1:])
+	}
+
+	return ret
+}
@@ -0,0 +1,386 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+const (
+	trashDirName   = ".wide-trash"      // trash directory name under user workspace
+	trashIndexName = ".index.json"      // trash index file name
+	trashMaxAge    = 7 * 24 * time.Hour // max age of trashed items
+	trashMaxSize   = 1024 * 1024 * 1024 // max total size of trashed items of a user (1G)
+)
+
+// trashItem represents a removed file or directory in trash.
+type trashItem struct {
+	Name    string    `json:"name"`    // name in trash, the base name with a timestamp suffix
+	Path    string    `json:"path"`    // original path
+	Deleted time.Time `json:"deleted"` // removed time
+	Size    int64     `json:"size"`    // size in bytes
+	Dir     string    `json:"dir"`     // trash directory holding it, empty means the one of the first workspace
+}
+
+// Exclusive lock of trash.
+var trashMutex sync.Mutex
+
+// TrashHandler handles request of listing items in trash.
+func TrashHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+
+	result.Data = loadTrash(username)
+}
+
+// RestoreTrashHandler handles request of restoring an item from trash to its original path.
+//
+// If the original path exists, the item will be restored with a new name, for example main_1.go.
+func RestoreTrashHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	path, err := restoreTrash(username, args["name"].(string))
+	if nil != err {
+		logger.Errorf("Restores [%s] from trash for user [%s] failed: %s", args["name"], username, err)
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	logger.Debugf("Restored [%s] from trash by user [%s]", path, username)
+
+	result.Data = filepath.ToSlash(path)
+}
+
+// EmptyTrashHandler handles request of emptying trash.
+func EmptyTrashHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+
+	for _, workspace := range filepath.SplitList(conf.GetUserWorkspace(username)) {
+		if err := os.RemoveAll(filepath.Join(workspace, trashDirName)); nil != err {
+			logger.Error(err)
+			result.Succ = false
+
+			return
+		}
+	}
+
+	logger.Debugf("Emptied trash of user [%s]", username)
+}
+
+// FixedTimePurgeTrash purges trashed items exceeding the retention (age or size) periodically (1 hour).
+func FixedTimePurgeTrash() {
+	go func() {
+		defer util.Recover()
+
+		for _ = range time.Tick(time.Hour) {
+			for _, user := range conf.GetUsers() {
+				purgeTrash(user.Name)
+			}
+		}
+	}()
+}
+
+// moveToTrash moves the file or directory specified by path into the trash of the user specified by username.
+//
+// It's moved into the trash directory of the workspace containing it (see getTrashDirFor), so it's renamed on the same
+// file system.
+func moveToTrash(username, path string) bool {
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+
+	trashDir := getTrashDirFor(username, path)
+	if err := os.MkdirAll(trashDir, 0755); nil != err {
+		logger.Error(err)
+
+		return false
+	}
+
+	item := &trashItem{
+		Name:    filepath.Base(path) + "." + time.Now().Format("20060102150405.000000000"),
+		Path:    filepath.Clean(path),
+		Deleted: time.Now(),
+		Size:    getSize(path),
+		Dir:     trashDir,
+	}
+
+	if err := moveAcross(path, filepath.Join(trashDir, item.Name)); nil != err {
+		logger.Errorf("Moves [%s] to trash failed: [%s]", path, err.Error())
+
+		return false
+	}
+
+	items := append(loadTrash(username), item)
+	saveTrash(username, items)
+
+	logger.Tracef("Moved [%s] to trash [%s]", path, item.Name)
+
+	return true
+}
+
+// restoreTrash restores the trashed item specified by name of the user specified by username, returns the restored
+// path.
+func restoreTrash(username, name string) (string, error) {
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+
+	items := loadTrash(username)
+	for i, item := range items {
+		if name != item.Name {
+			continue
+		}
+
+		path := item.Path
+		if !session.CanAccess(username, path) {
+			return "", errors.New("Can't restore to [" + path + "]")
+		}
+
+		if util.File.IsExist(path) {
+			path = getNonConflictPath(path)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); nil != err {
+			return "", err
+		}
+
+		if err := moveAcross(filepath.Join(getItemTrashDir(username, item), item.Name), path); nil != err {
+			return "", err
+		}
+
+		saveTrash(username, append(items[:i], items[i+1:]...))
+
+		return path, nil
+	}
+
+	return "", errors.New("Can't find [" + name + "] in trash")
+}
+
+// purgeTrash removes trashed items older than trashMaxAge, then removes the oldest ones until the total size is
+// under trashMaxSize.
+func purgeTrash(username string) {
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+
+	items := loadTrash(username)
+	if 0 == len(items) {
+		return
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Deleted.After(items[j].Deleted) }) // newest first
+
+	var size int64
+	threshold := time.Now().Add(-trashMaxAge)
+	kept := []*trashItem{}
+	for _, item := range items {
+		size += item.Size
+		if item.Deleted.Before(threshold) || size > trashMaxSize {
+			logger.Debugf("Purges [%s] from trash of user [%s]", item.Path, username)
+
+			os.RemoveAll(filepath.Join(getItemTrashDir(username, item), item.Name))
+
+			continue
+		}
+
+		kept = append(kept, item)
+	}
+
+	saveTrash(username, kept)
+}
+
+// getTrashDir gets the trash directory of the first workspace of the user specified by username, it holds the trash
+// index.
+func getTrashDir(username string) string {
+	workspaces := filepath.SplitList(conf.GetUserWorkspace(username))
+
+	return filepath.Join(workspaces[0], trashDirName)
+}
+
+// getTrashDirFor gets the trash directory of the workspace (of the user specified by username) containing the file
+// specified by path, or the one of the first workspace if not found (a shared file for example).
+func getTrashDirFor(username, path string) string {
+	for _, workspace := range filepath.SplitList(conf.GetUserWorkspace(username)) {
+		workspace = filepath.Clean(workspace)
+		if strings.HasPrefix(path, workspace+string(filepath.Separator)) {
+			return filepath.Join(workspace, trashDirName)
+		}
+	}
+
+	return getTrashDir(username)
+}
+
+// getItemTrashDir gets the trash directory holding the specified trashed item of the user specified by username.
+func getItemTrashDir(username string, item *trashItem) string {
+	if "" == item.Dir {
+		return getTrashDir(username)
+	}
+
+	return item.Dir
+}
+
+// moveAcross moves the file or directory specified by src to dst, it's copied and removed if renaming fails (src and
+// dst are on different file systems for example).
+func moveAcross(src, dst string) error {
+	if err := os.Rename(src, dst); nil == err {
+		return nil
+	}
+
+	if err := copyAcross(src, dst); nil != err {
+		os.RemoveAll(dst)
+
+		return err
+	}
+
+	return os.RemoveAll(src)
+}
+
+// copyAcross copies the file or directory specified by src to dst recursively as it is, symbolic links are kept as
+// links and modes are kept.
+func copyAcross(src, dst string) error {
+	info, err := os.Lstat(src)
+	if nil != err {
+		return err
+	}
+
+	if os.ModeSymlink == info.Mode()&os.ModeSymlink {
+		target, err := os.Readlink(src)
+		if nil != err {
+			return err
+		}
+
+		return os.Symlink(target, dst)
+	}
+
+	if !info.IsDir() {
+		return copyRegularFile(src, dst, info.Mode().Perm())
+	}
+
+	if err := os.Mkdir(dst, info.Mode().Perm()|0700); nil != err { // writable until copied
+		return err
+	}
+
+	for _, name := range readDirNames(src) {
+		if err := copyAcross(filepath.Join(src, name), filepath.Join(dst, name)); nil != err {
+			return err
+		}
+	}
+
+	return os.Chmod(dst, info.Mode().Perm())
+}
+
+// loadTrash loads trashed items of the user specified by username.
+func loadTrash(username string) []*trashItem {
+	ret := []*trashItem{}
+
+	bytes, err := ioutil.ReadFile(filepath.Join(getTrashDir(username), trashIndexName))
+	if nil != err {
+		return ret
+	}
+
+	if err := json.Unmarshal(bytes, &ret); nil != err {
+		logger.Error(err)
+	}
+
+	return ret
+}
+
+// saveTrash saves the specified trashed items of the user specified by username.
+func saveTrash(username string, items []*trashItem) {
+	bytes, err := json.MarshalIndent(items, "", "    ")
+	if nil != err {
+		logger.Error(err)
+
+		return
+	}
+
+	trashDir := getTrashDir(username) // items may be in the trash directories of other workspaces
+	if err := os.MkdirAll(trashDir, 0755); nil != err {
+		logger.Error(err)
+
+		return
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(trashDir, trashIndexName), bytes, 0644); nil != err {
+		logger.Error(err)
+	}
+}
+
+// getSize gets the total size of the file or directory specified by path.
+func getSize(path string) int64 {
+	var ret int64
+
+	filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if nil == err && !info.IsDir() {
+			ret += info.Size()
+		}
+
+		return nil
+	})
+
+	return ret
+}
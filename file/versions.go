@@ -0,0 +1,472 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+const (
+	versionDirName   = ".wide-versions"    // version directory name under user workspace
+	versionIndexName = ".index.json"       // version index file name of a file
+	versionMaxCount  = 32                  // max count of versions of a file
+	versionMaxAge    = 30 * 24 * time.Hour // max age of versions
+	versionMaxSize   = 5 * 1024 * 1024     // max size of a file to keep versions (5M)
+	versionMaxTotal  = 256 * 1024 * 1024   // max total size of versions of a user (256M)
+)
+
+// version represents a previous content of a file kept on saving.
+type version struct {
+	ID    string    `json:"id"`    // unix nano of the saved time
+	Saved time.Time `json:"saved"` // time the content was replaced
+	Size  int64     `json:"size"`  // size in bytes
+	Hash  string    `json:"hash"`  // SHA-1 of the content in hex, dedupes identical consecutive versions
+}
+
+// versionIndex represents the versions of a file, the newest first.
+type versionIndex struct {
+	Path     string     `json:"path"`
+	Versions []*version `json:"versions"`
+}
+
+// Exclusive lock of versions.
+var versionMutex sync.Mutex
+
+// VersionsHandler handles request of listing versions of the file specified by argument "file".
+func VersionsHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	username, path := getVersionFile(w, r, nil)
+	if "" == path {
+		return
+	}
+
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+
+	result.Data = loadVersions(username, path).Versions
+}
+
+// RestoreVersionHandler handles request of restoring the file specified by argument "file" to the version specified
+// by argument "id", it's saved the same way as SaveFileHandler (see saveFile), so the current content is kept as a
+// version as well. The data contains the restored content.
+func RestoreVersionHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	args := map[string]interface{}{}
+	username, path := getVersionFile(w, r, args)
+	if "" == path {
+		return
+	}
+
+	if err := checkFileLock(path); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	id, _ := args["id"].(string)
+	content, err := readVersion(username, path, id)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	// versions keep the raw content, decodes it for saving the same way as the editor saves
+	versionEncoding := detectEncoding([]byte(content))
+	if "" == versionEncoding {
+		versionEncoding = encodingUTF8
+	}
+	code, err := decodeContent([]byte(content), versionEncoding)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	if code, _, err = saveFile(username, path, code, getSaveEncoding(path)); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	logger.Debugf("User [%s] restored [%s] to version [%s]", username, path, id)
+
+	sid, _ := args["sid"].(string)
+	event.Publish(&event.Event{Code: event.EvtCodeFileSaved, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: path, Succ: true}})
+
+	result.Data = map[string]interface{}{"code": code}
+}
+
+// DiffVersionHandler handles request of diffing the version specified by argument "id" of the file specified by
+// argument "file" against the file, or argument "code" (an editor buffer for example) if specified.
+//
+// The data is the unified diff ("diff") from the version to the current content.
+func DiffVersionHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	args := map[string]interface{}{}
+	username, path := getVersionFile(w, r, args)
+	if "" == path {
+		return
+	}
+
+	id, _ := args["id"].(string)
+	old, err := readVersion(username, path, id)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	current, ok := args["code"].(string)
+	if !ok {
+		if current, err = readDiffSide(path); nil != err {
+			result.Succ = false
+			result.Msg = err.Error()
+
+			return
+		}
+	}
+
+	lines, exact := util.Diff.Lines(util.Diff.SplitLines(old), util.Diff.SplitLines(current), diffMaxEdits)
+	name := filepath.ToSlash(path)
+	result.Data = map[string]interface{}{"exact": exact,
+		"diff": util.Diff.Unified(name+"@"+id, name, util.Diff.Hunks(lines, diffContext))}
+}
+
+// getVersionFile gets the user and the file (argument "file") of the specified versions request, the arguments are
+// decoded into the specified args if it's not nil. Responds an error and returns an empty path if forbidden.
+func getVersionFile(w http.ResponseWriter, r *http.Request, args map[string]interface{}) (string, string) {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return "", ""
+	}
+	username := httpSession.Values["username"].(string)
+
+	if nil == args {
+		args = map[string]interface{}{}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+
+		return "", ""
+	}
+
+	file, _ := args["file"].(string)
+	path, err := session.SafePath(username, file)
+	if util.Go.IsAPI(path) || nil != err {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return "", ""
+	}
+
+	return username, path
+}
+
+// FixedTimePurgeVersions removes versions older than versionMaxAge of all users periodically (1 hour).
+func FixedTimePurgeVersions() {
+	go func() {
+		defer util.Recover()
+
+		for _ = range time.Tick(time.Hour) {
+			for _, user := range conf.GetUsers() {
+				purgeVersions(user.Name)
+			}
+		}
+	}()
+}
+
+// purgeVersions removes versions older than versionMaxAge of the user specified by username.
+func purgeVersions(username string) {
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+
+	root := getVersionsDir(username)
+	dirs, err := ioutil.ReadDir(root)
+	if nil != err {
+		return
+	}
+
+	now := time.Now()
+	for _, d := range dirs {
+		dir := filepath.Join(root, d.Name())
+		index := readVersionIndex(dir)
+		if nil == index {
+			continue
+		}
+
+		for i, v := range index.Versions {
+			if versionMaxAge < now.Sub(v.Saved) {
+				removeVersions(username, dir, index.Versions[i:])
+				index.Versions = index.Versions[:i]
+				storeVersions(username, index)
+
+				break
+			}
+		}
+	}
+}
+
+// saveVersion keeps the current content of the file specified by path as a version before it's replaced by the
+// specified content, for the user specified by username.
+//
+// Nothing is kept if the file doesn't exist, is too large, is unchanged, is identical to the latest version or the
+// quota would be exceeded (versions are under the user workspace so they are counted toward the quota). Versions
+// exceeding the count or the age of the file, and the total size of the user are removed.
+func saveVersion(username, path, content string) {
+	info, err := os.Stat(path)
+	if nil != err || info.IsDir() || versionMaxSize < info.Size() {
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if nil != err || string(data) == content || util.File.IsBinary(string(data)) {
+		return
+	}
+
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+
+	sum := sha1.Sum(data)
+	hash := hex.EncodeToString(sum[:])
+
+	index := loadVersions(username, path)
+	if 0 < len(index.Versions) && hash == index.Versions[0].Hash {
+		return
+	}
+
+	dir := getVersionDir(username, path)
+	if err := os.MkdirAll(dir, 0755); nil != err {
+		logger.Error(err)
+
+		return
+	}
+
+	now := time.Now()
+	v := &version{ID: strconv.FormatInt(now.UnixNano(), 10), Saved: now, Size: int64(len(data)), Hash: hash}
+	versionPath := filepath.Join(dir, v.ID)
+	if err := checkQuota(username, versionPath, v.Size); nil != err {
+		logger.Debugf("Skips keeping version of [%s] for user [%s]: %s", path, username, err)
+
+		return
+	}
+
+	if err := ioutil.WriteFile(versionPath, data, 0644); nil != err {
+		logger.Error(err)
+
+		return
+	}
+	addUsage(username, versionPath, v.Size)
+
+	index.Versions = append([]*version{v}, index.Versions...)
+	for i, v := range index.Versions {
+		if versionMaxCount <= i || versionMaxAge < now.Sub(v.Saved) {
+			removeVersions(username, dir, index.Versions[i:])
+			index.Versions = index.Versions[:i]
+
+			break
+		}
+	}
+
+	storeVersions(username, index)
+	limitVersions(username)
+}
+
+// readVersion reads the content of the version specified by id of the file specified by path.
+func readVersion(username, path, id string) (string, error) {
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+
+	for _, v := range loadVersions(username, path).Versions {
+		if v.ID == id {
+			data, err := ioutil.ReadFile(filepath.Join(getVersionDir(username, path), v.ID))
+			if nil != err {
+				return "", err
+			}
+
+			return string(data), nil
+		}
+	}
+
+	return "", errors.New("version [" + id + "] not found")
+}
+
+// limitVersions removes the oldest versions of the user specified by username until the total size is not more than
+// versionMaxTotal.
+func limitVersions(username string) {
+	root := getVersionsDir(username)
+	dirs, err := ioutil.ReadDir(root)
+	if nil != err {
+		return
+	}
+
+	type fileVersion struct {
+		index *versionIndex
+		v     *version
+	}
+
+	all := []*fileVersion{}
+	var total int64
+	for _, d := range dirs {
+		index := readVersionIndex(filepath.Join(root, d.Name()))
+		if nil == index {
+			continue
+		}
+
+		for _, v := range index.Versions {
+			all = append(all, &fileVersion{index: index, v: v})
+			total += v.Size
+		}
+	}
+
+	if total <= versionMaxTotal {
+		return
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].v.Saved.Before(all[j].v.Saved) })
+
+	changed := map[*versionIndex]bool{}
+	for _, fv := range all {
+		if total <= versionMaxTotal {
+			break
+		}
+
+		removeVersions(username, getVersionDir(username, fv.index.Path), []*version{fv.v})
+		for i, v := range fv.index.Versions {
+			if v == fv.v {
+				fv.index.Versions = append(fv.index.Versions[:i], fv.index.Versions[i+1:]...)
+
+				break
+			}
+		}
+
+		total -= fv.v.Size
+		changed[fv.index] = true
+	}
+
+	for index := range changed {
+		storeVersions(username, index)
+	}
+}
+
+// removeVersions removes content files of the specified versions in the specified directory of the user specified
+// by username.
+func removeVersions(username, dir string, versions []*version) {
+	for _, v := range versions {
+		if err := os.Remove(filepath.Join(dir, v.ID)); nil != err {
+			if !os.IsNotExist(err) {
+				logger.Warn(err)
+			}
+
+			continue
+		}
+
+		addUsage(username, dir, -v.Size)
+	}
+}
+
+// loadVersions loads the version index of the file specified by path for the user specified by username.
+func loadVersions(username, path string) *versionIndex {
+	index := readVersionIndex(getVersionDir(username, path))
+	if nil == index || index.Path != path { // not found or hash collision
+		return &versionIndex{Path: path, Versions: []*version{}}
+	}
+
+	return index
+}
+
+// readVersionIndex reads the version index in the specified directory, returns nil if not found.
+func readVersionIndex(dir string) *versionIndex {
+	data, err := ioutil.ReadFile(filepath.Join(dir, versionIndexName))
+	if nil != err {
+		return nil
+	}
+
+	ret := &versionIndex{}
+	if err := json.Unmarshal(data, ret); nil != err {
+		logger.Warn(err)
+
+		return nil
+	}
+
+	return ret
+}
+
+// storeVersions stores the specified version index for the user specified by username, the directory is removed if
+// there is no version.
+func storeVersions(username string, index *versionIndex) {
+	dir := getVersionDir(username, index.Path)
+	if 1 > len(index.Versions) {
+		if err := os.RemoveAll(dir); nil != err {
+			logger.Warn(err)
+		}
+
+		return
+	}
+
+	data, err := json.Marshal(index)
+	if nil != err {
+		logger.Error(err)
+
+		return
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, versionIndexName), data, 0644); nil != err {
+		logger.Error(err)
+	}
+}
+
+// getVersionsDir gets the version directory of the user specified by username.
+func getVersionsDir(username string) string {
+	workspaces := filepath.SplitList(conf.GetUserWorkspace(username))
+
+	return filepath.Join(workspaces[0], versionDirName)
+}
+
+// getVersionDir gets the directory of versions of the file specified by path for the user specified by username.
+func getVersionDir(username, path string) string {
+	hash := sha1.Sum([]byte(path))
+
+	return filepath.Join(getVersionsDir(username), hex.EncodeToString(hash[:]))
+}
@@ -24,13 +24,22 @@
 // 	logger.Error("error message")
 //
 //	logger.Errorf("formatted %s message", "error")
+//
+// 	log.SetFormat("json") // one JSON object per line
+// 	logger.Log(log.Info, "request", log.Fields{"requestId": id, "latency": 12})
 package log
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	stdlog "log"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Logging level.
@@ -43,9 +52,24 @@ const (
 	Error
 )
 
+// Logging formats.
+const (
+	FormatText = "text" // "{L} {date} {time} {file}:{line}: {message} {key}={value}..."
+	FormatJSON = "json" // {"time": ..., "level": ..., "file": ..., "msg": ..., {key}: {value}...}
+)
+
+// Fields represents structured context of a log line.
+type Fields map[string]interface{}
+
 // all loggers.
 var loggers []*Logger
 
+// the global logging format.
+var logFormat = FormatText
+
+// Exclusive lock of writing JSON lines.
+var jsonMutex sync.Mutex
+
 // the global default logging level, it will be used for creating logger.
 var logLevel = Debug
 
@@ -53,12 +77,13 @@ var logLevel = Debug
 // The underlying logger is the standard Go logging "log".
 type Logger struct {
 	level  int
+	out    io.Writer
 	logger *stdlog.Logger
 }
 
 // NewLogger creates a logger.
 func NewLogger(out io.Writer) *Logger {
-	ret := &Logger{level: logLevel, logger: stdlog.New(out, "", stdlog.Ldate|stdlog.Ltime|stdlog.Lshortfile)}
+	ret := &Logger{level: logLevel, out: out, logger: stdlog.New(out, "", stdlog.Ldate|stdlog.Ltime|stdlog.Lshortfile)}
 
 	loggers = append(loggers, ret)
 
@@ -74,6 +99,32 @@ func SetLevel(level string) {
 	}
 }
 
+// GetLevel gets the global logging level name.
+func GetLevel() string {
+	return levelNames[logLevel]
+}
+
+// IsLevel checks whether the specified level name (off/trace/debug/info/warn/error) is valid.
+func IsLevel(level string) bool {
+	level = strings.ToLower(level)
+	for _, name := range levelNames {
+		if name == level {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetFormat sets the logging format (text/json) of all loggers, defaults to text.
+func SetFormat(format string) {
+	if FormatJSON == strings.ToLower(format) {
+		logFormat = FormatJSON
+	} else {
+		logFormat = FormatText
+	}
+}
+
 // getLevel gets logging level int value corresponding to the specified level.
 func getLevel(level string) int {
 	level = strings.ToLower(level)
@@ -122,8 +173,7 @@ func (l *Logger) Trace(v ...interface{}) {
 		return
 	}
 
-	l.logger.SetPrefix("T ")
-	l.logger.Output(2, fmt.Sprint(v...))
+	l.output(Trace, fmt.Sprint(v...), nil)
 }
 
 // Tracef prints trace level message with format.
@@ -132,8 +182,7 @@ func (l *Logger) Tracef(format string, v ...interface{}) {
 		return
 	}
 
-	l.logger.SetPrefix("T ")
-	l.logger.Output(2, fmt.Sprintf(format, v...))
+	l.output(Trace, fmt.Sprintf(format, v...), nil)
 }
 
 // Debug prints debug level message.
@@ -142,8 +191,7 @@ func (l *Logger) Debug(v ...interface{}) {
 		return
 	}
 
-	l.logger.SetPrefix("D ")
-	l.logger.Output(2, fmt.Sprint(v...))
+	l.output(Debug, fmt.Sprint(v...), nil)
 }
 
 // Debugf prints debug level message with format.
@@ -152,8 +200,7 @@ func (l *Logger) Debugf(format string, v ...interface{}) {
 		return
 	}
 
-	l.logger.SetPrefix("D ")
-	l.logger.Output(2, fmt.Sprintf(format, v...))
+	l.output(Debug, fmt.Sprintf(format, v...), nil)
 }
 
 // Info prints info level message.
@@ -162,8 +209,7 @@ func (l *Logger) Info(v ...interface{}) {
 		return
 	}
 
-	l.logger.SetPrefix("I ")
-	l.logger.Output(2, fmt.Sprint(v...))
+	l.output(Info, fmt.Sprint(v...), nil)
 }
 
 // Infof prints info level message with format.
@@ -172,8 +218,7 @@ func (l *Logger) Infof(format string, v ...interface{}) {
 		return
 	}
 
-	l.logger.SetPrefix("I ")
-	l.logger.Output(2, fmt.Sprintf(format, v...))
+	l.output(Info, fmt.Sprintf(format, v...), nil)
 }
 
 // Warn prints warning level message.
@@ -182,8 +227,7 @@ func (l *Logger) Warn(v ...interface{}) {
 		return
 	}
 
-	l.logger.SetPrefix("W ")
-	l.logger.Output(2, fmt.Sprint(v...))
+	l.output(Warn, fmt.Sprint(v...), nil)
 }
 
 // Warn prints warning level message with format.
@@ -192,8 +236,7 @@ func (l *Logger) Warnf(format string, v ...interface{}) {
 		return
 	}
 
-	l.logger.SetPrefix("W ")
-	l.logger.Output(2, fmt.Sprintf(format, v...))
+	l.output(Warn, fmt.Sprintf(format, v...), nil)
 }
 
 // Error prints error level message.
@@ -202,8 +245,7 @@ func (l *Logger) Error(v ...interface{}) {
 		return
 	}
 
-	l.logger.SetPrefix("E ")
-	l.logger.Output(2, fmt.Sprint(v...))
+	l.output(Error, fmt.Sprint(v...), nil)
 }
 
 // Errorf prints error level message with format.
@@ -212,6 +254,64 @@ func (l *Logger) Errorf(format string, v ...interface{}) {
 		return
 	}
 
-	l.logger.SetPrefix("E ")
-	l.logger.Output(2, fmt.Sprintf(format, v...))
+	l.output(Error, fmt.Sprintf(format, v...), nil)
+}
+
+// Log prints message with the specified level and structured fields.
+func (l *Logger) Log(level int, msg string, fields Fields) {
+	if Off == level || level < l.level {
+		return
+	}
+
+	l.output(level, msg, fields)
+}
+
+// level names, indexed by level.
+var levelNames = []string{"off", "trace", "debug", "info", "warn", "error"}
+
+// output prints the message in the current format, called by the logging methods directly.
+func (l *Logger) output(level int, msg string, fields Fields) {
+	if FormatJSON == logFormat {
+		line := map[string]interface{}{}
+		for k, v := range fields {
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+
+			line[k] = v
+		}
+
+		line["time"] = time.Now().Format(time.RFC3339Nano)
+		line["level"] = levelNames[level]
+		line["msg"] = msg
+		if _, file, lineNo, ok := runtime.Caller(2); ok {
+			line["file"] = fmt.Sprintf("%s:%d", filepath.Base(file), lineNo)
+		}
+
+		data, err := json.Marshal(line)
+		if nil != err {
+			data, _ = json.Marshal(map[string]interface{}{"time": line["time"], "level": line["level"], "msg": msg})
+		}
+
+		jsonMutex.Lock()
+		l.out.Write(append(data, '\n'))
+		jsonMutex.Unlock()
+
+		return
+	}
+
+	if 0 < len(fields) {
+		keys := []string{}
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			msg += fmt.Sprintf(" %s=%v", k, fields[k])
+		}
+	}
+
+	l.logger.SetPrefix(strings.ToUpper(levelNames[level][:1]) + " ")
+	l.logger.Output(3, msg)
 }
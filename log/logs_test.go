@@ -15,6 +15,8 @@
 package log
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
 	"testing"
 )
@@ -128,6 +130,31 @@ func TestGetLevel(t *testing.T) {
 	}
 }
 
+func TestGetLevelName(t *testing.T) {
+	SetLevel("warn")
+	defer SetLevel("trace")
+
+	if "warn" != GetLevel() {
+		t.FailNow()
+
+		return
+	}
+}
+
+func TestIsLevel(t *testing.T) {
+	if !IsLevel("Debug") || !IsLevel("off") {
+		t.FailNow()
+
+		return
+	}
+
+	if IsLevel("verbose") || IsLevel("") {
+		t.FailNow()
+
+		return
+	}
+}
+
 func TestLoggerSetLevel(t *testing.T) {
 	logger.SetLevel("trace")
 
@@ -167,3 +194,39 @@ func TestIsWarnEnabled(t *testing.T) {
 		return
 	}
 }
+
+func TestLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewLogger(buf)
+	l.SetLevel("info")
+
+	SetFormat(FormatJSON)
+	defer SetFormat(FormatText)
+
+	l.Log(Debug, "debug", nil)
+	if 0 != buf.Len() {
+		t.Error("Debug message should not be logged at info level")
+
+		return
+	}
+
+	l.Log(Info, "request", Fields{"requestId": "abc", "latency": 12})
+
+	line := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &line); nil != err {
+		t.Error(err)
+
+		return
+	}
+
+	if "info" != line["level"] || "request" != line["msg"] || "abc" != line["requestId"] || nil == line["time"] {
+		t.Errorf("Unexpected JSON log line [%s]", buf.String())
+	}
+
+	SetFormat(FormatText)
+	buf.Reset()
+	l.Log(Info, "request", Fields{"requestId": "abc"})
+	if !bytes.Contains(buf.Bytes(), []byte("request requestId=abc")) {
+		t.Errorf("Unexpected text log line [%s]", buf.String())
+	}
+}
@@ -15,17 +15,28 @@
 package main
 
 import (
+	"bufio"
 	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
-	"html/template"
+	"fmt"
 	"io"
 	"mime"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/exec"
 	"os/signal"
+	"path"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -35,17 +46,25 @@ import (
 	"github.com/b3log/wide/file"
 	"github.com/b3log/wide/i18n"
 	"github.com/b3log/wide/log"
+	"github.com/b3log/wide/metrics"
 	"github.com/b3log/wide/notification"
 	"github.com/b3log/wide/output"
 	"github.com/b3log/wide/playground"
 	"github.com/b3log/wide/scm/git"
 	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/shell"
 	"github.com/b3log/wide/util"
 )
 
 // Logger
 var logger *log.Logger
 
+// Max duration of waiting active requests while shutting down.
+const shutdownTimeout = 10 * time.Second
+
+// Server start time.
+var startTime = time.Now()
+
 // The only one init function in Wide.
 func init() {
 	confPath := flag.String("conf", "conf/wide.json", "path of wide.json")
@@ -75,12 +94,33 @@ func init() {
 
 	i18n.Load()
 	event.Load()
+	output.Load()
+	file.Load()
+	shell.Load()
+	editor.Load()
+	metrics.Load()
+	notification.Load()
 	conf.Load(*confPath, *confIP, *confPort, *confServer, *confLogLevel, *confStaticServer, *confContext, *confChannel,
 		*confPlayground, *confDocker, *confUsersWorkspaces)
 
+	session.InitCookieOptions()
+
+	// templates of pages are parsed once, and again once modified in dev mode
+	if err := util.Template.Load("views", "dev" == conf.Wide.RuntimeMode); nil != err {
+		logger.Error(err)
+
+		os.Exit(-1)
+	}
+
 	conf.FixedTimeCheckEnv()
 	session.FixedTimeSave()
 	session.FixedTimeRelease()
+	file.FixedTimeCleanUploads()
+	file.FixedTimePurgeTrash()
+	file.FixedTimePurgeVersions()
+	conf.FixedTimeLoadEditorThemes()
+	conf.FixedTimeLoadSnippets()
+	editor.FixedTimeStopIdleGopls()
 
 	if *confStat {
 		session.FixedTimeReport()
@@ -95,36 +135,75 @@ func main() {
 	runtime.GOMAXPROCS(conf.Wide.MaxProcs)
 
 	initMime()
-	handleSignal()
+
+	server := &http.Server{Addr: conf.Wide.Server}
+	shutdown := handleSignal(server)
+
+	// health probes and metrics, no session required
+	http.HandleFunc(conf.Wide.Context+"/healthz", healthzHandler)
+	http.HandleFunc(conf.Wide.Context+"/readyz", readyzHandler)
+	http.HandleFunc(conf.Wide.Context+"/metrics", metrics.Handler)
 
 	// IDE
-	http.HandleFunc(conf.Wide.Context+"/", handlerGzWrapper(indexHandler))
-	http.HandleFunc(conf.Wide.Context+"/start", handlerWrapper(startHandler))
-	http.HandleFunc(conf.Wide.Context+"/about", handlerWrapper(aboutHandler))
-	http.HandleFunc(conf.Wide.Context+"/keyboard_shortcuts", handlerWrapper(keyboardShortcutsHandler))
+	http.HandleFunc(conf.Wide.Context+"/", handlerGzWrapper(requireUser(indexHandler)))
+	http.HandleFunc(conf.Wide.Context+"/start", handlerWrapper(requireUser(startHandler)))
+	http.HandleFunc(conf.Wide.Context+"/about", handlerWrapper(requireUser(aboutHandler)))
+	http.HandleFunc(conf.Wide.Context+"/keyboard_shortcuts", handlerWrapper(requireUser(keyboardShortcutsHandler)))
 
 	// static resources
-	http.Handle(conf.Wide.Context+"/static/", http.StripPrefix(conf.Wide.Context+"/static/", http.FileServer(http.Dir("static"))))
+	http.Handle(conf.Wide.Context+"/static/", http.StripPrefix(conf.Wide.Context+"/static/",
+		staticCache("static", http.FileServer(http.Dir("static")))))
 	serveSingle("/favicon.ico", "./static/favicon.ico")
+	http.HandleFunc(conf.Wide.Context+"/editor-themes/", editorThemeHandler)
 
 	// workspaces
-	for _, user := range conf.Users {
-		http.Handle(conf.Wide.Context+"/workspace/"+user.Name+"/",
-			http.StripPrefix(conf.Wide.Context+"/workspace/"+user.Name+"/", http.FileServer(http.Dir(user.WorkspacePath()))))
+	for _, user := range conf.GetUsers() {
+		session.ServeWorkspace(user)
 	}
 
 	// session
 	http.HandleFunc(conf.Wide.Context+"/session/ws", handlerWrapper(session.WSHandler))
+	http.HandleFunc(conf.Wide.Context+"/shares", handlerWrapper(session.SharesHandler))
+	http.HandleFunc(conf.Wide.Context+"/share/new", handlerWrapper(editorRequired(session.NewShareHandler)))
+	http.HandleFunc(conf.Wide.Context+"/share/join", handlerWrapper(session.JoinShareHandler))
+	http.HandleFunc(conf.Wide.Context+"/share/close", handlerWrapper(session.CloseShareHandler))
+	http.HandleFunc(conf.Wide.Context+"/user/delete", handlerWrapper(session.DeleteUserHandler))
+	http.HandleFunc(conf.Wide.Context+"/admin/sessions", handlerWrapper(adminRequired(session.AdminSessionsHandler)))
+	http.HandleFunc(conf.Wide.Context+"/admin/log/level", handlerWrapper(adminRequired(session.AdminLogLevelHandler)))
+	http.HandleFunc(conf.Wide.Context+"/admin/conf/reload",
+		handlerWrapper(adminRequired(session.AdminReloadConfHandler)))
+	http.HandleFunc(conf.Wide.Context+"/admin/sessions/terminate",
+		handlerWrapper(adminRequired(session.AdminTerminateSessionHandler)))
 	http.HandleFunc(conf.Wide.Context+"/session/save", handlerWrapper(session.SaveContentHandler))
 
 	// run
 	http.HandleFunc(conf.Wide.Context+"/build", handlerWrapper(output.BuildHandler))
+	http.HandleFunc(conf.Wide.Context+"/build/all", handlerWrapper(output.BuildAllHandler))
+	http.HandleFunc(conf.Wide.Context+"/build/targets", handlerWrapper(output.BuildTargetsHandler))
+	http.HandleFunc(conf.Wide.Context+"/build/profiles", handlerWrapper(output.BuildProfileHandler))
 	http.HandleFunc(conf.Wide.Context+"/run", handlerWrapper(output.RunHandler))
+	http.HandleFunc(conf.Wide.Context+"/go/run", handlerWrapper(output.GoRunHandler))
+	http.HandleFunc(conf.Wide.Context+"/go/eval", handlerWrapper(output.EvalHandler))
+	http.HandleFunc(conf.Wide.Context+"/processes", handlerWrapper(output.ProcessesHandler))
+	http.HandleFunc(conf.Wide.Context+"/run/conf", handlerWrapper(output.RunConfHandler))
+	http.HandleFunc(conf.Wide.Context+"/run/log", handlerWrapper(output.RunLogHandler))
 	http.HandleFunc(conf.Wide.Context+"/stop", handlerWrapper(output.StopHandler))
+	http.HandleFunc(conf.Wide.Context+"/go", handlerWrapper(output.GoCmdHandler))
+	http.HandleFunc(conf.Wide.Context+"/go/env", handlerWrapper(output.GoEnvHandler))
+	http.HandleFunc(conf.Wide.Context+"/go/env/set", handlerWrapper(editorRequired(output.GoEnvSetHandler)))
 	http.HandleFunc(conf.Wide.Context+"/go/test", handlerWrapper(output.GoTestHandler))
 	http.HandleFunc(conf.Wide.Context+"/go/vet", handlerWrapper(output.GoVetHandler))
-	http.HandleFunc(conf.Wide.Context+"/go/get", handlerWrapper(output.GoGetHandler))
-	http.HandleFunc(conf.Wide.Context+"/go/install", handlerWrapper(output.GoInstallHandler))
+	http.HandleFunc(conf.Wide.Context+"/go/lint", handlerWrapper(output.GoLintHandler))
+	http.HandleFunc(conf.Wide.Context+"/go/get", handlerWrapper(editorRequired(output.GoGetHandler)))
+	http.HandleFunc(conf.Wide.Context+"/go/install", handlerWrapper(editorRequired(output.GoInstallHandler)))
+	http.HandleFunc(conf.Wide.Context+"/devtools", handlerWrapper(output.DevToolsHandler))
+	http.HandleFunc(conf.Wide.Context+"/admin/devtools/install",
+		handlerWrapper(adminRequired(output.AdminDevToolsInstallHandler)))
+	http.HandleFunc(conf.Wide.Context+"/go/toolchains", handlerWrapper(output.GoToolchainsHandler))
+	http.HandleFunc(conf.Wide.Context+"/problems", handlerWrapper(output.ProblemsHandler))
+	http.HandleFunc(conf.Wide.Context+"/problems/cancel", handlerWrapper(output.ProblemsCancelHandler))
+	http.HandleFunc(conf.Wide.Context+"/go/clean", handlerWrapper(editorRequired(output.GoCleanHandler)))
+	http.HandleFunc(conf.Wide.Context+"/go/generate", handlerWrapper(editorRequired(output.GoGenerateHandler)))
 	http.HandleFunc(conf.Wide.Context+"/output/ws", handlerWrapper(output.WSHandler))
 
 	// cross-compilation
@@ -133,43 +212,79 @@ func main() {
 	// file tree
 	http.HandleFunc(conf.Wide.Context+"/files", handlerWrapper(file.GetFilesHandler))
 	http.HandleFunc(conf.Wide.Context+"/file/refresh", handlerWrapper(file.RefreshDirectoryHandler))
-	http.HandleFunc(conf.Wide.Context+"/file", handlerWrapper(file.GetFileHandler))
-	http.HandleFunc(conf.Wide.Context+"/file/save", handlerWrapper(file.SaveFileHandler))
-	http.HandleFunc(conf.Wide.Context+"/file/new", handlerWrapper(file.NewFileHandler))
-	http.HandleFunc(conf.Wide.Context+"/file/remove", handlerWrapper(file.RemoveFileHandler))
-	http.HandleFunc(conf.Wide.Context+"/file/rename", handlerWrapper(file.RenameFileHandler))
+	http.HandleFunc(conf.Wide.Context+"/file", handlerGzWrapper(file.GetFileHandler))
+	http.HandleFunc(conf.Wide.Context+"/file/recent", handlerWrapper(file.RecentFilesHandler))
+	http.HandleFunc(conf.Wide.Context+"/file/save", handlerWrapper(editorRequired(file.SaveFileHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/draft/save", handlerWrapper(editorRequired(file.SaveDraftHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/draft/discard", handlerWrapper(editorRequired(file.DiscardDraftHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/new", handlerWrapper(editorRequired(file.NewFileHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/templates", handlerWrapper(file.FileTemplatesHandler))
+	http.HandleFunc(conf.Wide.Context+"/file/dir/new", handlerWrapper(editorRequired(file.NewDirHandler)))
+	http.HandleFunc(conf.Wide.Context+"/project/templates", handlerWrapper(file.ProjectTemplatesHandler))
+	http.HandleFunc(conf.Wide.Context+"/project/new", handlerWrapper(editorRequired(file.NewProjectHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/remove", handlerWrapper(editorRequired(file.RemoveFileHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/trash", handlerWrapper(file.TrashHandler))
+	http.HandleFunc(conf.Wide.Context+"/file/trash/restore", handlerWrapper(editorRequired(file.RestoreTrashHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/trash/empty", handlerWrapper(editorRequired(file.EmptyTrashHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/copy", handlerWrapper(editorRequired(file.CopyFileHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/rename", handlerWrapper(editorRequired(file.RenameFileHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/package/rename", handlerWrapper(editorRequired(file.RenamePackageHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/lock", handlerWrapper(editorRequired(file.LockFileHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/batch/remove", handlerWrapper(editorRequired(file.BatchRemoveFileHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/batch/move", handlerWrapper(editorRequired(file.BatchMoveFileHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/encoding", handlerWrapper(editorRequired(file.EncodingHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/diff", handlerWrapper(file.DiffHandler))
+	http.HandleFunc(conf.Wide.Context+"/file/versions", handlerWrapper(file.VersionsHandler))
+	http.HandleFunc(conf.Wide.Context+"/file/version/diff", handlerWrapper(file.DiffVersionHandler))
+	http.HandleFunc(conf.Wide.Context+"/file/version/restore", handlerWrapper(editorRequired(file.RestoreVersionHandler)))
 	http.HandleFunc(conf.Wide.Context+"/file/search/text", handlerWrapper(file.SearchTextHandler))
+	http.HandleFunc(conf.Wide.Context+"/file/search/index/rebuild", handlerWrapper(file.RebuildSearchIndexHandler))
+	http.HandleFunc(conf.Wide.Context+"/file/replace", handlerWrapper(editorRequired(file.ReplaceInFileHandler)))
 	http.HandleFunc(conf.Wide.Context+"/file/find/name", handlerWrapper(file.FindHandler))
 
 	// outline
 	http.HandleFunc(conf.Wide.Context+"/outline", handlerWrapper(file.GetOutlineHandler))
 
 	// file export/import
-	http.HandleFunc(conf.Wide.Context+"/file/zip/new", handlerWrapper(file.CreateZipHandler))
-	http.HandleFunc(conf.Wide.Context+"/file/zip", handlerWrapper(file.GetZipHandler))
-	http.HandleFunc(conf.Wide.Context+"/file/upload", handlerWrapper(file.UploadHandler))
-	http.HandleFunc(conf.Wide.Context+"/file/decompress", handlerWrapper(file.DecompressHandler))
+	http.HandleFunc(conf.Wide.Context+"/file/zip/new", handlerWrapper(editorRequired(file.CreateZipHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/zip", handlerGzWrapper(file.GetZipHandler))
+	http.HandleFunc(conf.Wide.Context+"/file/upload", handlerWrapper(editorRequired(file.UploadHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/upload/chunk", handlerWrapper(editorRequired(file.UploadChunkHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/zip/import", handlerWrapper(editorRequired(file.ImportZipHandler)))
+	http.HandleFunc(conf.Wide.Context+"/file/decompress", handlerWrapper(editorRequired(file.DecompressHandler)))
 
 	// editor
 	http.HandleFunc(conf.Wide.Context+"/editor/ws", handlerWrapper(editor.WSHandler))
 	http.HandleFunc(conf.Wide.Context+"/go/fmt", handlerWrapper(editor.GoFmtHandler))
 	http.HandleFunc(conf.Wide.Context+"/autocomplete", handlerWrapper(editor.AutocompleteHandler))
 	http.HandleFunc(conf.Wide.Context+"/exprinfo", handlerWrapper(editor.GetExprInfoHandler))
+	http.HandleFunc(conf.Wide.Context+"/hoverdoc", handlerWrapper(editor.HoverDocHandler))
+	http.HandleFunc(conf.Wide.Context+"/go/doc", handlerWrapper(editor.GoDocHandler))
+	http.HandleFunc(conf.Wide.Context+"/diagnostics", handlerWrapper(editor.DiagnosticsHandler))
+	http.HandleFunc(conf.Wide.Context+"/go/tokens", handlerWrapper(editor.TokensHandler))
+	http.HandleFunc(conf.Wide.Context+"/go/structure", handlerWrapper(editor.StructureHandler))
+	http.HandleFunc(conf.Wide.Context+"/go/imports", handlerWrapper(editor.OrganizeImportsHandler))
 	http.HandleFunc(conf.Wide.Context+"/find/decl", handlerWrapper(editor.FindDeclarationHandler))
 	http.HandleFunc(conf.Wide.Context+"/find/usages", handlerWrapper(editor.FindUsagesHandler))
 
 	// shell
-	// http.HandleFunc(conf.Wide.Context+"/shell/ws", handlerWrapper(shell.WSHandler))
-	// http.HandleFunc(conf.Wide.Context+"/shell", handlerWrapper(shell.IndexHandler))
+	// http.HandleFunc(conf.Wide.Context+"/shell/ws", handlerWrapper(editorRequired(shell.WSHandler)))
+	// http.HandleFunc(conf.Wide.Context+"/shell", handlerWrapper(editorRequired(shell.IndexHandler)))
 
 	// notification
 	http.HandleFunc(conf.Wide.Context+"/notification/ws", handlerWrapper(notification.WSHandler))
+	http.HandleFunc(conf.Wide.Context+"/notification/unread", handlerWrapper(notification.GetUnreadHandler))
+	http.HandleFunc(conf.Wide.Context+"/admin/motd", handlerWrapper(adminRequired(notification.AdminMOTDHandler)))
 
 	// user
 	http.HandleFunc(conf.Wide.Context+"/login", handlerWrapper(session.LoginHandler))
 	http.HandleFunc(conf.Wide.Context+"/logout", handlerWrapper(session.LogoutHandler))
 	http.HandleFunc(conf.Wide.Context+"/signup", handlerWrapper(session.SignUpUserHandler))
 	http.HandleFunc(conf.Wide.Context+"/preference", handlerWrapper(session.PreferenceHandler))
+	http.HandleFunc(conf.Wide.Context+"/preference/keybindings", handlerWrapper(session.KeybindingsHandler))
+	http.HandleFunc(conf.Wide.Context+"/tokens", handlerWrapper(session.TokensHandler))
+	http.HandleFunc(conf.Wide.Context+"/token/new", handlerWrapper(session.NewTokenHandler))
+	http.HandleFunc(conf.Wide.Context+"/token/revoke", handlerWrapper(session.RevokeTokenHandler))
 
 	// playground
 	http.HandleFunc(conf.Wide.Context+"/playground", handlerWrapper(playground.IndexHandler))
@@ -183,51 +298,45 @@ func main() {
 	http.HandleFunc(conf.Wide.Context+"/playground/autocomplete", handlerWrapper(playground.AutocompleteHandler))
 
 	// git
-	http.HandleFunc(conf.Wide.Context+"/git/clone", handlerWrapper(git.CloneHandler))
-
-	logger.Infof("Wide is running [%s]", conf.Wide.Server+conf.Wide.Context)
-
-	err := http.ListenAndServe(conf.Wide.Server, nil)
-	if err != nil {
+	http.HandleFunc(conf.Wide.Context+"/git/clone", handlerWrapper(editorRequired(git.CloneHandler)))
+	http.HandleFunc(conf.Wide.Context+"/git/status", handlerWrapper(git.StatusHandler))
+	http.HandleFunc(conf.Wide.Context+"/git/diff", handlerWrapper(git.DiffHandler))
+	http.HandleFunc(conf.Wide.Context+"/git/stage", handlerWrapper(editorRequired(git.StageHandler)))
+	http.HandleFunc(conf.Wide.Context+"/git/unstage", handlerWrapper(editorRequired(git.UnstageHandler)))
+	http.HandleFunc(conf.Wide.Context+"/git/commit", handlerWrapper(editorRequired(git.CommitHandler)))
+
+	var err error
+	if conf.Wide.IsTLS() {
+		logger.Infof("Wide is running [https://%s]", conf.Wide.Server+conf.Wide.Context)
+
+		err = server.ListenAndServeTLS(conf.Wide.TLSCert, conf.Wide.TLSKey)
+	} else {
+		logger.Infof("Wide is running [%s]", conf.Wide.Server+conf.Wide.Context)
+
+		err = server.ListenAndServe()
+	}
+	if http.ErrServerClosed != err {
 		logger.Error(err)
+
+		return
 	}
+
+	<-shutdown // waits for the graceful shutdown
 }
 
 // indexHandler handles request of Wide index.
-func indexHandler(w http.ResponseWriter, r *http.Request) {
+func indexHandler(w http.ResponseWriter, r *http.Request, user *conf.User) {
 	if conf.Wide.Context+"/" != r.RequestURI {
 		http.Redirect(w, r, conf.Wide.Context+"/", http.StatusFound)
 
 		return
 	}
 
-	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
-	if httpSession.IsNew {
-		http.Redirect(w, r, conf.Wide.Context+"/login", http.StatusFound)
+	username := user.Name
+	if !conf.IsEditorTheme(user.Editor.Theme) { // the custom theme has been removed
+		logger.Warnf("Editor theme [%s] of user [%s] is unavailable, uses the default one", user.Editor.Theme, username)
 
-		return
-	}
-
-	username := httpSession.Values["username"].(string)
-	if "playground" == username { // reserved user for Playground
-		http.Redirect(w, r, conf.Wide.Context+"/login", http.StatusFound)
-
-		return
-	}
-
-	httpSession.Options.MaxAge = conf.Wide.HTTPSessionMaxAge
-	if "" != conf.Wide.Context {
-		httpSession.Options.Path = conf.Wide.Context
-	}
-	httpSession.Save(r, w)
-
-	user := conf.GetUser(username)
-	if nil == user {
-		logger.Warnf("Not found user [%s]", username)
-
-		http.Redirect(w, r, conf.Wide.Context+"/login", http.StatusFound)
-
-		return
+		user.Editor.Theme = "wide"
 	}
 
 	locale := user.Locale
@@ -237,11 +346,13 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 	model := map[string]interface{}{"conf": conf.Wide, "i18n": i18n.GetAll(locale), "locale": locale,
 		"username": username, "sid": session.WideSessions.GenId(), "latestSessionContent": user.LatestSessionContent,
 		"pathSeparator": conf.PathSeparator, "codeMirrorVer": conf.CodeMirrorVer,
-		"user": user, "editorThemes": conf.GetEditorThemes(), "crossPlatforms": util.Go.GetCrossPlatforms()}
+		"user": user, "editorThemes": conf.GetEditorThemes(), "customEditorThemes": conf.GetCustomEditorThemes(),
+		"crossPlatforms": util.Go.GetCrossPlatforms(), "editorModes": conf.Wide.EditorModes,
+		"keybindings": user.GetKeybindings()}
 
 	logger.Debugf("User [%s] has [%d] sessions", username, len(wideSessions))
 
-	t, err := template.ParseFiles("views/index.html")
+	t, err := util.Template.Get("index.html")
 	if nil != err {
 		logger.Error(err)
 		http.Error(w, err.Error(), 500)
@@ -253,45 +364,145 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleSignal handles system signal for graceful shutdown.
-func handleSignal() {
+//
+// On SIGINT, SIGQUIT or SIGTERM, notifies all online sessions, stops accepting new connections and waits active
+// requests (at most shutdownTimeout), then kills processes spawned for users and saves online users. The returned
+// channel is closed after the shutdown completed. A second signal exits immediately.
+func handleSignal(server *http.Server) <-chan struct{} {
+	done := make(chan struct{})
+
 	go func() {
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 
 		signal.Notify(c, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
 		s := <-c
-		logger.Tracef("Got signal [%s]", s)
+		logger.Infof("Got signal [%s], shutting down", s)
+
+		go func() {
+			s := <-c
+			logger.Warnf("Got signal [%s] again, exit immediately", s)
+
+			os.Exit(1)
+		}()
+
+		notification.NotifyAll(event.EvtCodeServerShutdown)
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(ctx); nil != err {
+			logger.Warnf("Shutdown server failed: %s", err)
+		}
+
+		output.Processes.KillAll()
+		editor.StopGopls()
+		logger.Trace("Killed all processes")
 
 		session.SaveOnlineUsers()
 		logger.Tracef("Saved all online user, exit")
 
-		os.Exit(0)
+		close(done)
 	}()
+
+	return done
 }
 
-// serveSingle registers the handler function for the given pattern and filename.
-func serveSingle(pattern string, filename string) {
-	http.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filename)
-	})
+// healthzHandler handles liveness probe, responds server status without requiring a session.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, nil)
 }
 
-// startHandler handles request of start page.
-func startHandler(w http.ResponseWriter, r *http.Request) {
-	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
-	if httpSession.IsNew {
-		http.Redirect(w, r, conf.Wide.Context+"/login", http.StatusFound)
+// readyzHandler handles readiness probe, responds 503 if the Go toolchain is not reachable.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "go", "version").CombinedOutput()
+	if nil != err {
+		logger.Warnf("Readiness check failed: %s", err)
+
+		writeHealth(w, http.StatusServiceUnavailable, map[string]interface{}{"go": err.Error()})
 
 		return
 	}
 
-	httpSession.Options.MaxAge = conf.Wide.HTTPSessionMaxAge
-	if "" != conf.Wide.Context {
-		httpSession.Options.Path = conf.Wide.Context
+	writeHealth(w, http.StatusOK, map[string]interface{}{"go": strings.TrimSpace(string(output))})
+}
+
+// writeHealth writes server status (version, uptime and active sessions) with the specified extra fields as JSON.
+func writeHealth(w http.ResponseWriter, statusCode int, extra map[string]interface{}) {
+	data := map[string]interface{}{
+		"status":   http.StatusText(statusCode),
+		"version":  conf.WideVersion,
+		"uptime":   int64(time.Since(startTime).Seconds()),
+		"sessions": len(session.WideSessions),
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+// editorThemeHandler handles request of custom editor theme CSS ({context}/editor-themes/{name}.css).
+func editorThemeHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(path.Base(r.URL.Path), ".css")
+
+	themePath, ok := conf.GetCustomEditorThemePath(name)
+	if !ok {
+		http.NotFound(w, r)
+
+		return
 	}
-	httpSession.Save(r, w)
 
-	username := httpSession.Values["username"].(string)
-	locale := conf.GetUser(username).Locale
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	http.ServeFile(w, r, themePath)
+}
+
+// staticCache wraps the specified handler serving static files in the specified root directory with cache headers.
+//
+// Versioned resources (the CodeMirror library, or requested with the query string conf.Wide.StaticResourceVersion)
+// are cached for conf.Wide.StaticMaxAge as immutable, the others are cached for conf.Wide.StaticShortMaxAge. Files
+// are tagged with ETag (size and modification time), http.FileServer responds 304 for a matched "If-None-Match".
+func staticCache(root string, handler http.Handler) http.Handler {
+	versionedPrefix := "/js/lib/codemirror-" + conf.CodeMirrorVer + "/"
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(name)))
+		if nil != err || info.IsDir() {
+			handler.ServeHTTP(w, r)
+
+			return
+		}
+
+		w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+
+		if strings.HasPrefix(name, versionedPrefix) ||
+			("" != r.URL.RawQuery && r.URL.RawQuery == conf.Wide.StaticResourceVersion) {
+			w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(conf.Wide.StaticMaxAge)+", immutable")
+		} else {
+			w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(conf.Wide.StaticShortMaxAge))
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// serveSingle registers the handler function for the given pattern and filename.
+func serveSingle(pattern string, filename string) {
+	http.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filename)
+	})
+}
+
+// startHandler handles request of start page.
+func startHandler(w http.ResponseWriter, r *http.Request, user *conf.User) {
+	username := user.Name
+	locale := user.Locale
 	userWorkspace := conf.GetUserWorkspace(username)
 
 	sid := r.URL.Query()["sid"][0]
@@ -303,7 +514,7 @@ func startHandler(w http.ResponseWriter, r *http.Request) {
 	model := map[string]interface{}{"conf": conf.Wide, "i18n": i18n.GetAll(locale), "locale": locale,
 		"username": username, "workspace": userWorkspace, "ver": conf.WideVersion, "sid": sid}
 
-	t, err := template.ParseFiles("views/start.html")
+	t, err := util.Template.Get("start.html")
 
 	if nil != err {
 		logger.Error(err)
@@ -316,26 +527,13 @@ func startHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // keyboardShortcutsHandler handles request of keyboard shortcuts page.
-func keyboardShortcutsHandler(w http.ResponseWriter, r *http.Request) {
-	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
-	if httpSession.IsNew {
-		http.Redirect(w, r, conf.Wide.Context+"/login", http.StatusFound)
-
-		return
-	}
-
-	httpSession.Options.MaxAge = conf.Wide.HTTPSessionMaxAge
-	if "" != conf.Wide.Context {
-		httpSession.Options.Path = conf.Wide.Context
-	}
-	httpSession.Save(r, w)
-
-	username := httpSession.Values["username"].(string)
-	locale := conf.GetUser(username).Locale
+func keyboardShortcutsHandler(w http.ResponseWriter, r *http.Request, user *conf.User) {
+	locale := user.Locale
 
-	model := map[string]interface{}{"conf": conf.Wide, "i18n": i18n.GetAll(locale), "locale": locale}
+	model := map[string]interface{}{"conf": conf.Wide, "i18n": i18n.GetAll(locale), "locale": locale,
+		"keybindings": user.GetKeybindings()}
 
-	t, err := template.ParseFiles("views/keyboard_shortcuts.html")
+	t, err := util.Template.Get("keyboard_shortcuts.html")
 
 	if nil != err {
 		logger.Error(err)
@@ -348,27 +546,13 @@ func keyboardShortcutsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // aboutHandle handles request of about page.
-func aboutHandler(w http.ResponseWriter, r *http.Request) {
-	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
-	if httpSession.IsNew {
-		http.Redirect(w, r, conf.Wide.Context+"/login", http.StatusFound)
-
-		return
-	}
-
-	httpSession.Options.MaxAge = conf.Wide.HTTPSessionMaxAge
-	if "" != conf.Wide.Context {
-		httpSession.Options.Path = conf.Wide.Context
-	}
-	httpSession.Save(r, w)
-
-	username := httpSession.Values["username"].(string)
-	locale := conf.GetUser(username).Locale
+func aboutHandler(w http.ResponseWriter, r *http.Request, user *conf.User) {
+	locale := user.Locale
 
 	model := map[string]interface{}{"conf": conf.Wide, "i18n": i18n.GetAll(locale), "locale": locale,
 		"ver": conf.WideVersion, "goos": runtime.GOOS, "goarch": runtime.GOARCH, "gover": runtime.Version()}
 
-	t, err := template.ParseFiles("views/about.html")
+	t, err := util.Template.Get("about.html")
 
 	if nil != err {
 		logger.Error(err)
@@ -383,12 +567,20 @@ func aboutHandler(w http.ResponseWriter, r *http.Request) {
 // handlerWrapper wraps the HTTP Handler for some common processes.
 //
 //  1. panic recover
-//  2. request stopwatch
-//  3. i18n
+//  2. session user check
+//  3. API token authentication
+//  4. request timeout
+//  5. request stopwatch
+//  6. i18n
+//  7. request id
 func handlerWrapper(f func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	handler := panicRecover(f)
+	handler = userCheck(handler)
+	handler = tokenAuth(handler)
+	handler = timeout(handler)
 	handler = stopwatch(handler)
 	handler = i18nLoad(handler)
+	handler = requestID(handler)
 
 	return handler
 }
@@ -396,36 +588,176 @@ func handlerWrapper(f func(w http.ResponseWriter, r *http.Request)) func(w http.
 // handlerGzWrapper wraps the HTTP Handler for some common processes.
 //
 //  1. panic recover
-//  2. gzip response
-//  3. request stopwatch
-//  4. i18n
+//  2. session user check
+//  3. API token authentication
+//  4. request timeout
+//  5. gzip response
+//  6. request stopwatch
+//  7. i18n
+//  8. request id
 func handlerGzWrapper(f func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	handler := panicRecover(f)
+	handler = userCheck(handler)
+	handler = tokenAuth(handler)
+	handler = timeout(handler)
 	handler = gzipWrapper(handler)
 	handler = stopwatch(handler)
 	handler = i18nLoad(handler)
+	handler = requestID(handler)
 
 	return handler
 }
 
-// gzipWrapper wraps the process with response gzip.
+// requireUser wraps the process of a page with the session user, redirects to the login page if there is no session or
+// the session user doesn't exist (the reserved user of Playground for example). The session cookie is renewed.
+func requireUser(f func(http.ResponseWriter, *http.Request, *conf.User)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+		if httpSession.IsNew {
+			http.Redirect(w, r, conf.Wide.Context+"/login", http.StatusFound)
+
+			return
+		}
+
+		username := httpSession.Values["username"].(string)
+		if "playground" == username { // reserved user for Playground
+			http.Redirect(w, r, conf.Wide.Context+"/login", http.StatusFound)
+
+			return
+		}
+
+		user := conf.GetUser(username)
+		if nil == user {
+			logger.Warnf("Not found user [%s]", username)
+
+			http.Redirect(w, r, conf.Wide.Context+"/login", http.StatusFound)
+
+			return
+		}
+
+		session.SaveHTTPSession(w, r, httpSession)
+
+		f(w, r, user)
+	}
+}
+
+// editorRequired wraps the process with role checking, responds 403 if the request can't modify files (see
+// session.CanWrite), that is the session user is a viewer (or not found) or the request is authenticated by a
+// read-only API token.
+//
+// Wraps handlers modifying files or installing packages, so viewers can only browse, build and run code. Handlers
+// deciding by arguments (such as output.GoCmdHandler) check session.CanWrite themselves.
+func editorRequired(f func(http.ResponseWriter, *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+		if !httpSession.IsNew && !session.CanWrite(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
+
+		f(w, r)
+	}
+}
+
+// adminRequired wraps the process with role checking, responds 403 if the session user isn't an admin.
+func adminRequired(f func(http.ResponseWriter, *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+		if httpSession.IsNew {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
+
+		user := conf.GetUser(httpSession.Values["username"].(string))
+		if nil == user || !user.IsAdmin() {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
+
+		f(w, r)
+	}
+}
+
+// userCheck wraps the process with checking the user of the session cookie, a cookie of a deleted user (or of a
+// user deleted and signed up again) is cleared, the request is served as not logged in then.
+func userCheck(f func(http.ResponseWriter, *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session.CheckHTTPSession(w, r)
+
+		f(w, r)
+	}
+}
+
+// tokenAuth wraps the process with API token authentication, a request with header "Authorization: Bearer {token}"
+// is served as the token's user instead of the session cookie, responds 401 if the token is invalid.
+func tokenAuth(f func(http.ResponseWriter, *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if strings.HasPrefix(authorization, "Bearer ") {
+			if !session.UseAPIToken(w, r, strings.TrimSpace(authorization[len("Bearer "):])) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+				return
+			}
+		}
+
+		f(w, r)
+	}
+}
+
+// gzipWrapper wraps the process with response compression (gzip or deflate) negotiated by "Accept-Encoding".
+//
+// Only textual content is compressed, responses which are already encoded (such as util.RetGzResult), compressed
+// (such as zip archives) or partial are sent as is.
 func gzipWrapper(f func(http.ResponseWriter, *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if "" == encoding || "" != r.Header.Get("Range") || http.MethodHead == r.Method {
 			f(w, r)
 
 			return
 		}
 
-		w.Header().Set("Content-Encoding", "gzip")
-		gz := gzip.NewWriter(w)
-		defer gz.Close()
-		gzr := gzipResponseWriter{Writer: gz, ResponseWriter: w}
+		gzr := &gzipResponseWriter{ResponseWriter: w, encoding: encoding}
+		defer gzr.Close()
 
 		f(gzr, r)
 	}
 }
 
+// negotiateEncoding returns the preferred content encoding ("gzip" or "deflate") accepted by the specified
+// "Accept-Encoding" header value, returns "" if none of them is acceptable.
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, _ = strconv.ParseFloat(strings.TrimSpace(param[2:]), 64)
+			}
+		}
+
+		accepted[coding] = 0 < q
+	}
+
+	for _, coding := range []string{"gzip", "deflate"} {
+		if ok, found := accepted[coding]; ok || (!found && accepted["*"]) {
+			return coding
+		}
+	}
+
+	return ""
+}
+
 // i18nLoad wraps the i18n process.
 func i18nLoad(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -435,13 +767,35 @@ func i18nLoad(handler func(w http.ResponseWriter, r *http.Request)) func(w http.
 	}
 }
 
+// requestID wraps the process with a request id, which is carried in the request context (see util.Request.GetID)
+// and the X-Request-Id response header to correlate log lines of the request.
+func requestID(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := util.Request.NewID()
+		w.Header().Set("X-Request-Id", id)
+
+		handler(w, util.Request.WithID(r, id))
+	}
+}
+
 // stopwatch wraps the request stopwatch process.
 func stopwatch(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
 		defer func() {
-			logger.Tracef("[%s, %s, %s]", r.Method, r.RequestURI, time.Since(start))
+			elapsed := time.Since(start)
+			metrics.ObserveRequest(elapsed)
+
+			fields := log.Fields{"requestId": util.Request.GetID(r), "method": r.Method, "uri": r.RequestURI,
+				"latency": float64(elapsed) / float64(time.Millisecond)}
+			if httpSession, _ := session.HTTPSession.Get(r, "wide-session"); nil != httpSession {
+				if username, ok := httpSession.Values["username"].(string); ok {
+					fields["username"] = username
+				}
+			}
+
+			logger.Log(log.Trace, "request", fields)
 		}()
 
 		handler(w, r)
@@ -449,11 +803,173 @@ func stopwatch(handler func(w http.ResponseWriter, r *http.Request)) func(w http
 }
 
 // panicRecover wraps the panic recover process.
+//
+// The panic is logged with the request id, and responds 500 with the request id if the response hasn't been written.
 func panicRecover(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		defer util.Recover()
+		rw := &recoverResponseWriter{ResponseWriter: w}
+		defer util.RecoverRequest(rw, r)
 
-		handler(w, r)
+		handler(rw, r)
+	}
+}
+
+// recoverResponseWriter represents a response writer recording whether the response has been written.
+type recoverResponseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+// Written checks whether the response has been written (or the connection has been hijacked).
+func (w *recoverResponseWriter) Written() bool {
+	return w.written
+}
+
+// WriteHeader sends an HTTP response header with the specified status code.
+func (w *recoverResponseWriter) WriteHeader(code int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the data to the connection as part of an HTTP reply.
+func (w *recoverResponseWriter) Write(b []byte) (int, error) {
+	w.written = true
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client.
+func (w *recoverResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection, used by WebSocket upgrading.
+func (w *recoverResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer doesn't support hijacking")
+	}
+
+	w.written = true
+
+	return hijacker.Hijack()
+}
+
+// timeoutExemptRoutes holds the routes (without context) exempt from the request timeout, they download, upload or
+// build as long as the size of the workspace requires.
+var timeoutExemptRoutes = map[string]bool{"/file/zip": true, "/file/zip/new": true, "/file/upload": true,
+	"/file/zip/import": true, "/build/all": true}
+
+// timeout wraps the process with a deadline (conf.Wide.RequestTimeout), the request context is canceled and 503 is
+// responded if the handler doesn't return in time. The handler keeps running in background, it should respect the
+// request context (exec.CommandContext for example) to stop its work, its writes after the deadline are discarded.
+//
+// WebSocket requests are exempt since they're long-lived by design, so are the routes of timeoutExemptRoutes which
+// stream responses or run for long, they still stop on the client gone.
+func timeout(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if 1 > conf.Wide.RequestTimeout || strings.EqualFold("websocket", r.Header.Get("Upgrade")) ||
+			timeoutExemptRoutes[strings.TrimPrefix(r.URL.Path, conf.Wide.Context)] {
+			handler(w, r)
+
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(conf.Wide.RequestTimeout)*time.Second)
+		defer cancel()
+
+		tw := &timeoutResponseWriter{w: w, header: http.Header{}}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			handler(tw, r.WithContext(ctx))
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			tw.mutex.Lock()
+			defer tw.mutex.Unlock()
+
+			tw.timedOut = true
+			if context.DeadlineExceeded != ctx.Err() { // client gone
+				return
+			}
+
+			logger.Warnf("Request [id=%s, uri=%s] timeout [%ds]", util.Request.GetID(r), r.RequestURI,
+				conf.Wide.RequestTimeout)
+
+			if !tw.wroteHeader {
+				http.Error(w, "Request timeout", http.StatusServiceUnavailable)
+			}
+		}
+	}
+}
+
+// timeoutResponseWriter represents a response writer of a handler with a deadline, writes are discarded after the
+// deadline.
+type timeoutResponseWriter struct {
+	w           http.ResponseWriter
+	header      http.Header // headers of the handler, copied to w on writing the header
+	mutex       sync.Mutex
+	timedOut    bool
+	wroteHeader bool
+}
+
+// Header returns the header map that will be sent by WriteHeader.
+func (w *timeoutResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader sends an HTTP response header with the specified status code.
+func (w *timeoutResponseWriter) WriteHeader(code int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.writeHeader(code)
+}
+
+func (w *timeoutResponseWriter) writeHeader(code int) {
+	if w.timedOut || w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	for k, v := range w.header {
+		w.w.Header()[k] = v
+	}
+	w.w.WriteHeader(code)
+}
+
+// Write writes the data to the connection as part of an HTTP reply, returns http.ErrHandlerTimeout after the deadline.
+func (w *timeoutResponseWriter) Write(b []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	w.writeHeader(http.StatusOK)
+
+	return w.w.Write(b)
+}
+
+// Flush sends any buffered data to the client.
+func (w *timeoutResponseWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.timedOut {
+		return
+	}
+
+	w.writeHeader(http.StatusOK)
+	if flusher, ok := w.w.(http.Flusher); ok {
+		flusher.Flush()
 	}
 }
 
@@ -466,18 +982,88 @@ func initMime() {
 	mime.AddExtensionType(".json", "application/json")
 }
 
-// gzipResponseWriter represents a gzip response writer.
+// gzipResponseWriter represents a compressing response writer, whether to compress is decided on the response header
+// at the first write.
 type gzipResponseWriter struct {
-	io.Writer
 	http.ResponseWriter
+	encoding string         // negotiated content encoding, "gzip" or "deflate"
+	writer   io.WriteCloser // compressor, nil if the response is not compressed
+	decided  bool           // whether compression has been decided
+}
+
+// WriteHeader decides whether to compress the response and sends the header with the specified status code.
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	w.decide(code, nil)
+
+	w.ResponseWriter.WriteHeader(code)
 }
 
 // Write writes response with appropriate 'Content-Type'.
-func (w gzipResponseWriter) Write(b []byte) (int, error) {
-	if "" == w.Header().Get("Content-Type") {
-		// If no content type, apply sniffing algorithm to un-gzipped body.
-		w.Header().Set("Content-Type", http.DetectContentType(b))
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.decide(http.StatusOK, b)
+	}
+
+	if nil == w.writer {
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.writer.Write(b)
+}
+
+// Close flushes the compressor if the response is compressed.
+func (w *gzipResponseWriter) Close() error {
+	if nil == w.writer {
+		return nil
+	}
+
+	return w.writer.Close()
+}
+
+// decide decides whether to compress the response with the specified status code and the first chunk of body.
+func (w *gzipResponseWriter) decide(code int, b []byte) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	header := w.Header()
+	if "" == header.Get("Content-Type") && nil != b {
+		// If no content type, apply sniffing algorithm to un-compressed body.
+		header.Set("Content-Type", http.DetectContentType(b))
+	}
+
+	if http.StatusOK != code || "" != header.Get("Content-Encoding") || !isCompressible(header.Get("Content-Type")) {
+		return
+	}
+
+	header.Del("Content-Length")
+	header.Del("Accept-Ranges")
+	header.Set("Content-Encoding", w.encoding)
+	if "deflate" == w.encoding {
+		w.writer = zlib.NewWriter(w.ResponseWriter)
+	} else {
+		w.writer = gzip.NewWriter(w.ResponseWriter)
+	}
+}
+
+// isCompressible determines whether the content of the specified content type is worth compressing, that is textual
+// and not compressed already.
+func isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if nil != err {
+		return false
+	}
+
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/javascript", "application/x-javascript", "application/xml",
+		"image/svg+xml":
+		return true
 	}
 
-	return w.Writer.Write(b)
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
 }
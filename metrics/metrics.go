@@ -0,0 +1,183 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics includes server metrics exposed in Prometheus text format.
+package metrics
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/session"
+)
+
+// Upper bounds (in seconds) of request latency histogram buckets.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Lifecycle counters.
+var (
+	buildsStarted uint64
+	buildsFailed  uint64
+	runsStarted   uint64
+	runsFailed    uint64
+	testsStarted  uint64
+	testsFailed   uint64
+)
+
+// histogram represents a cumulative histogram.
+type histogram struct {
+	mutex  sync.Mutex
+	counts []uint64 // count of each bucket
+	sum    float64  // sum of observed values
+	count  uint64   // count of observed values
+}
+
+// Request latency histogram.
+var latency = &histogram{counts: make([]uint64, len(latencyBuckets))}
+
+// Load subscribes lifecycle events to count builds, runs and tests.
+func Load() {
+	count := func(code int, counter *uint64, failedCounter *uint64) {
+		event.Subscribe(code, event.HandleFunc(func(e *event.Event) {
+			if nil == failedCounter {
+				atomic.AddUint64(counter, 1)
+
+				return
+			}
+
+			if lifecycle, ok := e.Data.(*event.Lifecycle); ok && !lifecycle.Succ {
+				atomic.AddUint64(failedCounter, 1)
+			}
+		}))
+	}
+
+	count(event.EvtCodeBuildStarted, &buildsStarted, nil)
+	count(event.EvtCodeBuildDone, nil, &buildsFailed)
+	count(event.EvtCodeRunStarted, &runsStarted, nil)
+	count(event.EvtCodeRunExited, nil, &runsFailed)
+	count(event.EvtCodeTestStarted, &testsStarted, nil)
+	count(event.EvtCodeTestDone, nil, &testsFailed)
+}
+
+// ObserveRequest records the specified request latency.
+func ObserveRequest(d time.Duration) {
+	latency.observe(d.Seconds())
+}
+
+// observe records the specified value.
+func (h *histogram) observe(v float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.sum += v
+	h.count++
+}
+
+// Handler handles request of metrics in Prometheus text format.
+//
+// If conf.Wide.MetricsToken is set, the request must carry it in header "Authorization: Bearer {token}" or query
+// parameter "token".
+func Handler(w http.ResponseWriter, r *http.Request) {
+	if token := conf.Wide.MetricsToken; "" != token {
+		reqToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if "" == reqToken {
+			reqToken = r.URL.Query().Get("token")
+		}
+
+		if 1 != subtle.ConstantTimeCompare([]byte(token), []byte(reqToken)) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	writeMetric(w, "wide_sessions", "gauge", "Number of active Wide sessions.",
+		map[string]float64{"": float64(len(session.WideSessions))})
+
+	writeMetric(w, "wide_websockets", "gauge", "Number of active WebSocket connections.",
+		map[string]float64{
+			`channel="session"`:      float64(len(session.SessionWS)),
+			`channel="editor"`:       float64(len(session.EditorWS)),
+			`channel="output"`:       float64(len(session.OutputWS)),
+			`channel="notification"`: float64(session.CountNotificationWS()),
+			`channel="playground"`:   float64(len(session.PlaygroundWS)),
+		})
+
+	writeMetric(w, "wide_builds_total", "counter", "Number of builds started.",
+		map[string]float64{"": float64(atomic.LoadUint64(&buildsStarted))})
+	writeMetric(w, "wide_builds_failed_total", "counter", "Number of builds failed.",
+		map[string]float64{"": float64(atomic.LoadUint64(&buildsFailed))})
+	writeMetric(w, "wide_runs_total", "counter", "Number of runs started.",
+		map[string]float64{"": float64(atomic.LoadUint64(&runsStarted))})
+	writeMetric(w, "wide_runs_failed_total", "counter", "Number of runs exited abnormally.",
+		map[string]float64{"": float64(atomic.LoadUint64(&runsFailed))})
+	writeMetric(w, "wide_tests_total", "counter", "Number of go test started.",
+		map[string]float64{"": float64(atomic.LoadUint64(&testsStarted))})
+	writeMetric(w, "wide_tests_failed_total", "counter", "Number of go test failed.",
+		map[string]float64{"": float64(atomic.LoadUint64(&testsFailed))})
+
+	latency.write(w, "wide_http_request_duration_seconds", "HTTP request latency in seconds.")
+}
+
+// writeMetric writes a metric with the specified name, type, help and samples.
+//
+// samples: <labels, value>, an empty labels means no label.
+func writeMetric(w io.Writer, name, typ, help string, samples map[string]float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+
+	for labels, value := range samples {
+		if "" == labels {
+			fmt.Fprintf(w, "%s %s\n", name, formatFloat(value))
+		} else {
+			fmt.Fprintf(w, "%s{%s} %s\n", name, labels, formatFloat(value))
+		}
+	}
+}
+
+// write writes the histogram with the specified name and help.
+func (h *histogram) write(w io.Writer, name, help string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+
+	for i, bound := range latencyBuckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// formatFloat formats the specified value in the shortest representation.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"path/filepath"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/util"
+)
+
+// Load subscribes lifecycle events of builds, runs and tests done to send email notifications, see mailOnDone.
+func Load() {
+	event.Subscribe(event.EvtCodeBuildDone, event.HandleFunc(mailOnDone))
+	event.Subscribe(event.EvtCodeRunExited, event.HandleFunc(mailOnDone))
+	event.Subscribe(event.EvtCodeTestDone, event.HandleFunc(mailOnDone))
+}
+
+// mailOnDone sends an email summary of the specified build/run/test done event to the user if the SMTP server is
+// configured (conf.Wide.SMTPServer), the user opts in (conf.User.MailNotify) and the elapsed time exceeds
+// conf.Wide.MailThreshold.
+func mailOnDone(e *event.Event) {
+	if "" == conf.Wide.SMTPServer {
+		return
+	}
+
+	lifecycle, ok := e.Data.(*event.Lifecycle)
+	if !ok || lifecycle.Elapsed < time.Duration(conf.Wide.MailThreshold)*time.Second {
+		return
+	}
+
+	user := conf.GetUser(lifecycle.Username)
+	if nil == user || !user.MailNotify || "" == user.Email {
+		return
+	}
+
+	kind := "build"
+	switch e.Code {
+	case event.EvtCodeRunExited:
+		kind = "run"
+	case event.EvtCodeTestDone:
+		kind = "test"
+	}
+
+	result := "mail_succ"
+	if !lifecycle.Succ {
+		result = "mail_failed"
+	}
+
+	locale := user.Locale
+	subject := fmt.Sprintf(i18n.Get(locale, "mail_subject").(string), i18n.Get(locale, kind),
+		i18n.Get(locale, result), filepath.Base(lifecycle.Path))
+
+	body := &bytes.Buffer{}
+	body.WriteString(lifecycle.Path + "\r\n\r\n")
+	body.WriteString(fmt.Sprintf(i18n.Get(locale, "mail_elapsed").(string), lifecycle.Elapsed.Round(time.Second)))
+	body.WriteString("\r\n")
+	if 0 < len(lifecycle.Errors) {
+		body.WriteString("\r\n" + i18n.Get(locale, "mail_errors").(string) + "\r\n")
+		for _, line := range lifecycle.Errors {
+			body.WriteString("  " + line + "\r\n")
+		}
+	}
+
+	go sendMail(user.Email, subject, body.String()) // handlers should not block
+}
+
+// sendMail sends a plain text email with the specified subject and body to the specified address, failures are
+// logged only.
+func sendMail(to, subject, body string) {
+	defer util.Recover()
+
+	if addr, err := mail.ParseAddress(to); nil != err || addr.Address != to { // no header injection
+		logger.Warnf("Invalid email address [%s]", to)
+
+		return
+	}
+
+	server := conf.Wide.SMTPServer
+	host, _, err := net.SplitHostPort(server)
+	if nil != err {
+		logger.Errorf("Invalid SMTP server [%s]: %v", server, err)
+
+		return
+	}
+
+	var auth smtp.Auth
+	if "" != conf.Wide.SMTPUsername {
+		auth = smtp.PlainAuth("", conf.Wide.SMTPUsername, conf.Wide.SMTPPassword, host)
+	}
+
+	from := conf.Wide.SMTPFrom
+	if "" == from {
+		from = conf.Wide.SMTPUsername
+	}
+
+	msg := &bytes.Buffer{}
+	msg.WriteString("From: " + from + "\r\n")
+	msg.WriteString("To: " + to + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(body)
+
+	if err := smtp.SendMail(server, auth, from, []string{to}, msg.Bytes()); nil != err {
+		logger.Errorf("Sends email to [%s] failed: %v", to, err)
+
+		return
+	}
+
+	logger.Debugf("Sent email [%s] to [%s]", subject, to)
+}
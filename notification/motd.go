@@ -0,0 +1,175 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notification
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// motd is the notification.type of the message of the day.
+const motd = "MOTD"
+
+// Max length of the message of the day.
+const motdMaxLength = 1024
+
+var (
+	// The current message of the day, nil if not set.
+	currentMOTD *Notification
+
+	// Exclusive lock for the message of the day.
+	motdMutex sync.Mutex
+)
+
+// AdminMOTDHandler handles request of getting, setting or clearing the message of the day (banner) announcing
+// maintenance windows for example.
+//
+// Argument "message" is the message, empty means clearing. Argument "severity" is INFO (default), WARN or ERROR.
+// Argument "expires" is the seconds the message lasts, 0 (default) means until cleared. A set message is broadcast
+// to all connected sessions and pushed to newly connecting ones until it expires or is cleared, the data is the
+// current message (null if not set). Only the current message is returned if argument "message" is absent.
+//
+// Requires the admin role.
+func AdminMOTDHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil && io.EOF != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	message, ok := args["message"].(string)
+	if !ok {
+		result.Data = getMOTD()
+
+		return
+	}
+
+	if session.IsReadOnlyRequest(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	admin := httpSession.Values["username"]
+
+	message = strings.TrimSpace(message)
+	if "" == message {
+		clearMOTD()
+
+		logger.Infof("Admin [%v] cleared the message of the day", admin)
+
+		return
+	}
+
+	if motdMaxLength < len(message) {
+		result.Succ = false
+		result.Msg = "message is too long"
+
+		return
+	}
+
+	severity, _ := args["severity"].(string)
+	severity = strings.ToUpper(strings.TrimSpace(severity))
+	if "" == severity {
+		severity = info
+	}
+	if info != severity && warn != severity && error != severity {
+		result.Succ = false
+		result.Msg = "severity [" + severity + "] is invalid"
+
+		return
+	}
+
+	now := time.Now()
+	notification := &Notification{Type: motd, Severity: severity, Message: message, Created: now.UnixNano()}
+	if expires, _ := args["expires"].(float64); 0 < expires {
+		notification.Expires = now.Add(time.Duration(expires) * time.Second).UnixNano()
+	}
+
+	setMOTD(notification)
+	result.Data = notification
+
+	logger.Infof("Admin [%v] set the message of the day [%s]", admin, message)
+}
+
+// getMOTD gets the current message of the day, returns nil if not set or expired.
+func getMOTD() *Notification {
+	motdMutex.Lock()
+	defer motdMutex.Unlock()
+
+	if nil != currentMOTD && 0 < currentMOTD.Expires && time.Now().UnixNano() > currentMOTD.Expires {
+		currentMOTD = nil
+	}
+
+	return currentMOTD
+}
+
+// setMOTD sets the specified notification as the message of the day and broadcasts it to all connected sessions.
+func setMOTD(notification *Notification) {
+	motdMutex.Lock()
+	currentMOTD = notification
+	motdMutex.Unlock()
+
+	broadcast(notification)
+}
+
+// clearMOTD clears the message of the day and notifies all connected sessions to remove the banner.
+func clearMOTD() {
+	motdMutex.Lock()
+	currentMOTD = nil
+	motdMutex.Unlock()
+
+	broadcast(map[string]interface{}{"cmd": "clear-motd"})
+}
+
+// broadcast pushes the specified message to all connected notification channels.
+func broadcast(message interface{}) {
+	for _, wsChannel := range session.NotificationWS {
+		if err := wsChannel.WriteJSON(message); nil != err {
+			logger.Tracef("Broadcasts to session [%s] failed: %s", wsChannel.Sid, err)
+
+			continue
+		}
+
+		wsChannel.Refresh()
+	}
+}
+
+// pushMOTD pushes the current message of the day (if any) to the specified newly connected channel.
+func pushMOTD(wsChannel *util.WSChannel) {
+	notification := getMOTD()
+	if nil == notification {
+		return
+	}
+
+	if err := wsChannel.WriteJSON(notification); nil != err {
+		return
+	}
+
+	wsChannel.Refresh()
+}
@@ -19,6 +19,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/b3log/wide/conf"
@@ -39,6 +40,9 @@ const (
 	server = "Server" // notification.type: server
 )
 
+// Max length of undelivered notifications of a user.
+const maxUndeliveredLength = 64
+
 // Logger.
 var logger = log.NewLogger(os.Stdout)
 
@@ -48,23 +52,98 @@ type Notification struct {
 	Type     string `json:"type"`
 	Severity string `json:"severity"`
 	Message  string `json:"message"`
+	Created  int64  `json:"created"`           // create time in unix nano
+	Expires  int64  `json:"expires,omitempty"` // expire time in unix nano, message of the day only
+}
+
+// Undelivered notifications of all users.
+//
+// <username, []*Notification>
+var undelivered = map[string][]*Notification{}
+
+// Exclusive lock for undelivered notifications.
+var undeliveredMutex sync.Mutex
+
+// queue appends the specified notification to the undelivered notifications of the user specified by the given
+// username. The oldest one will be dropped if the queue is full.
+func queue(username string, notification *Notification) {
+	undeliveredMutex.Lock()
+	defer undeliveredMutex.Unlock()
+
+	notifications := append(undelivered[username], notification)
+	if len(notifications) > maxUndeliveredLength {
+		notifications = notifications[len(notifications)-maxUndeliveredLength:]
+	}
+
+	undelivered[username] = notifications
+
+	logger.Tracef("User [%s] has [%d] undelivered notifications", username, len(notifications))
+}
+
+// requeue prepends the specified notifications (taken but failed to push) to the undelivered notifications of the
+// user specified by the given username, so they are still pushed before the ones queued meanwhile. The oldest ones will
+// be dropped if the queue is full.
+func requeue(username string, notifications []*Notification) {
+	undeliveredMutex.Lock()
+	defer undeliveredMutex.Unlock()
+
+	notifications = append(append([]*Notification{}, notifications...), undelivered[username]...)
+	if len(notifications) > maxUndeliveredLength {
+		notifications = notifications[len(notifications)-maxUndeliveredLength:]
+	}
+
+	undelivered[username] = notifications
+}
+
+// takeUndelivered gets and clears the undelivered notifications of the user specified by the given username.
+func takeUndelivered(username string) []*Notification {
+	undeliveredMutex.Lock()
+	defer undeliveredMutex.Unlock()
+
+	ret := undelivered[username]
+	delete(undelivered, username)
+
+	if nil == ret {
+		ret = []*Notification{}
+	}
+
+	return ret
+}
+
+// flush pushes the undelivered notifications of the user specified by the given username to the specified channel
+// in order. Notifications failed to push will be queued again.
+func flush(username string, wsChannel *util.WSChannel) {
+	notifications := takeUndelivered(username)
+
+	for i, notification := range notifications {
+		if err := wsChannel.WriteJSON(notification); nil != err {
+			requeue(username, notifications[i:])
+
+			return
+		}
+	}
+
+	wsChannel.Refresh()
 }
 
 // event2Notification processes user event by converting the specified event to a notification, and then push it to front
 // browser with notification channel.
+//
+// If the notification channel is not available at present, the notification will be queued and pushed after the
+// channel (re)connected.
 func event2Notification(e *event.Event) {
-	if nil == session.NotificationWS[e.Sid] {
+	wSession := session.WideSessions.Get(e.Sid)
+	if nil == wSession {
 		return
 	}
 
-	wsChannel := session.NotificationWS[e.Sid]
-	if nil == wsChannel {
+	username := wSession.Username
+	user := conf.GetUser(username)
+	if nil == user {
 		return
 	}
 
-	httpSession, _ := session.HTTPSession.Get(wsChannel.Request, "wide-session")
-	username := httpSession.Values["username"].(string)
-	locale := conf.GetUser(username).Locale
+	locale := user.Locale
 
 	var notification *Notification
 
@@ -77,17 +156,66 @@ func event2Notification(e *event.Event) {
 	case event.EvtCodeServerInternalError:
 		notification = &Notification{event: e, Type: server, Severity: error,
 			Message: i18n.Get(locale, "notification_"+strconv.Itoa(e.Code)).(string) + " [" + e.Data.(string) + "]"}
+	case event.EvtCodeServerShutdown:
+		notification = &Notification{event: e, Type: server, Severity: warn,
+			Message: i18n.Get(locale, "notification_"+strconv.Itoa(e.Code)).(string)}
+	case event.EvtCodeStartupSucceeded:
+		notification = &Notification{event: e, Type: setup, Severity: info,
+			Message: i18n.Get(locale, "notification_"+strconv.Itoa(e.Code)).(string)}
+	case event.EvtCodeStartupFailed:
+		notification = &Notification{event: e, Type: setup, Severity: error,
+			Message: i18n.Get(locale, "notification_"+strconv.Itoa(e.Code)).(string) + " [" + e.Data.(string) + "]"}
 	default:
 		logger.Warnf("Can't handle event[code=%d]", e.Code)
 
 		return
 	}
 
-	wsChannel.WriteJSON(notification)
+	notification.Created = time.Now().UnixNano()
+
+	wsChannel := session.GetNotificationWS(e.Sid)
+	if nil == wsChannel {
+		queue(username, notification)
+
+		return
+	}
+
+	if err := wsChannel.WriteJSON(notification); nil != err {
+		queue(username, notification)
+
+		return
+	}
 
 	wsChannel.Refresh()
 }
 
+// NotifyAll notifies all online sessions of the event with the specified code synchronously.
+//
+// It's used while shutting down the server, the asynchronous event queues may not be consumed in time.
+func NotifyAll(code int) {
+	for _, s := range session.WideSessions {
+		event2Notification(&event.Event{Code: code, Sid: s.ID})
+	}
+}
+
+// GetUnreadHandler handles request of getting unread notifications.
+//
+// The returned notifications will be marked as read (cleared from the undelivered queue).
+func GetUnreadHandler(w http.ResponseWriter, r *http.Request) {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	result.Data = takeUndelivered(username)
+}
+
 // WSHandler handles request of creating notification channel.
 func WSHandler(w http.ResponseWriter, r *http.Request) {
 	sid := r.URL.Query()["sid"][0]
@@ -107,17 +235,26 @@ func WSHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session.NotificationWS[sid] = &wsChan
+	session.SetNotificationWS(sid, &wsChan)
+
+	logger.Tracef("Open a new [Notification] with session [%s], %d", sid, session.CountNotificationWS())
 
-	logger.Tracef("Open a new [Notification] with session [%s], %d", sid, len(session.NotificationWS))
+	// push the backlog before going live
+	flush(wSession.Username, &wsChan)
+	pushMOTD(&wsChan)
 
-	// add user event handler
-	wSession.EventQueue.AddHandler(event.HandleFunc(event2Notification))
+	// add user event handler, once since the channel may reconnect
+	wSession.NotificationOnce.Do(func() {
+		wSession.EventQueue.AddHandler(event.HandleFunc(event2Notification))
+	})
 
 	input := map[string]interface{}{}
 
 	for {
 		if err := wsChan.ReadJSON(&input); err != nil {
+			// notifications will be queued until the channel reconnected
+			session.RemoveNotificationWS(sid, &wsChan)
+
 			return
 		}
 	}
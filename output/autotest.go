@@ -0,0 +1,291 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bufio"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// Debounce delay of auto test, saves within this delay will trigger only one test run.
+const autoTestDelay = 800 * time.Millisecond
+
+// autoTest represents the auto test state of a package in a wide session.
+type autoTest struct {
+	timer *time.Timer // debounce timer
+	cmd   *exec.Cmd   // in-flight go test command
+	gen   uint64      // generation, increased on each save, a run of an older generation is stale
+}
+
+// Auto test states.
+//
+// <sid:dir, *autoTest>
+var autoTests = map[string]*autoTest{}
+
+// Exclusive lock for auto test states.
+var autoTestMutex sync.Mutex
+
+// Load initializes the output handling, such as subscribing lifecycle events.
+func Load() {
+	event.Subscribe(event.EvtCodeFileSaved, event.HandleFunc(autoTestOnSave))
+	event.Subscribe(event.EvtCodeSessionRemoved, event.HandleFunc(removeAutoTests))
+
+	for _, code := range []int{event.EvtCodeFileSaved, event.EvtCodeFileRemoved, event.EvtCodeFileRenamed} {
+		event.Subscribe(code, event.HandleFunc(invalidateBuildCache))
+	}
+}
+
+// autoTestOnSave schedules a go test run of the package the saved file belongs to if the user enabled auto test.
+//
+// An in-flight run of the package (with the test binary, see killProcess) will be cancelled at once, and the new run
+// will be started after autoTestDelay if there is no subsequent save. A run which has not started yet when the save
+// arrives becomes stale (see autoTest.gen) and won't start.
+func autoTestOnSave(e *event.Event) {
+	lifecycle := e.Data.(*event.Lifecycle)
+
+	user := conf.GetUser(lifecycle.Username)
+	if nil == user || !user.AutoTest {
+		return
+	}
+
+	if ".go" != filepath.Ext(lifecycle.Path) {
+		return
+	}
+
+	sid := e.Sid
+	username := lifecycle.Username
+	dir := filepath.Dir(lifecycle.Path)
+	key := sid + ":" + dir
+
+	autoTestMutex.Lock()
+	defer autoTestMutex.Unlock()
+
+	test := autoTests[key]
+	if nil == test {
+		test = &autoTest{}
+		autoTests[key] = test
+	}
+
+	test.gen++
+	gen := test.gen
+
+	if nil != test.cmd && nil != test.cmd.Process {
+		killProcess(test.cmd.Process)
+		test.cmd = nil
+
+		logger.Debugf("Cancelled an in-flight auto test [dir=%s] of user [%s, %s]", dir, username, sid)
+	}
+
+	if nil != test.timer {
+		test.timer.Stop()
+	}
+
+	test.timer = time.AfterFunc(autoTestDelay, func() {
+		runAutoTest(key, sid, username, dir, gen)
+	})
+}
+
+// removeAutoTests cancels and removes the auto test states of the removed wide session.
+func removeAutoTests(e *event.Event) {
+	autoTestMutex.Lock()
+	defer autoTestMutex.Unlock()
+
+	for key, test := range autoTests {
+		if !strings.HasPrefix(key, e.Sid+":") {
+			continue
+		}
+
+		if nil != test.timer {
+			test.timer.Stop()
+		}
+
+		if nil != test.cmd && nil != test.cmd.Process {
+			killProcess(test.cmd.Process)
+		}
+
+		delete(autoTests, key)
+	}
+}
+
+// isStaleAutoTest checks whether the auto test run of the specified generation is superseded by a newer save or its
+// state has been removed.
+//
+// The caller should hold autoTestMutex.
+func isStaleAutoTest(key string, gen uint64) bool {
+	test := autoTests[key]
+
+	return nil == test || test.gen != gen
+}
+
+// runAutoTest runs go test in the specified dir for the save of the specified generation and streams the output to
+// the output channel of the specified session.
+func runAutoTest(key, sid, username, dir string, gen uint64) {
+	defer util.Recover()
+
+	user := conf.GetUser(username)
+	if nil == session.WideSessions.Get(sid) || nil == user {
+		autoTestMutex.Lock()
+		if !isStaleAutoTest(key, gen) {
+			delete(autoTests, key)
+		}
+		autoTestMutex.Unlock()
+
+		return
+	}
+
+	locale := user.Locale
+
+	release, err := AcquireProcSlot(username)
+	if nil != err { // skips this time, the next save triggers it again
+		logger.Debugf("Skipped an auto test [dir=%s] of user [%s, %s]: %s", dir, username, sid, err)
+
+		return
+	}
+	defer release()
+
+	cmd := exec.Command("go", "test", "-v")
+	cmd.Dir = dir
+
+	setCmdEnv(cmd, username)
+	setProcessGroup(cmd) // kills the test binary as well on cancelling
+
+	stdout, err := cmd.StdoutPipe()
+	if nil != err {
+		logger.Error(err)
+
+		return
+	}
+
+	cmd.Stderr = cmd.Stdout
+
+	autoTestMutex.Lock()
+	if isStaleAutoTest(key, gen) { // a newer save arrived after the timer fired
+		autoTestMutex.Unlock()
+
+		logger.Debugf("Cancelled a stale auto test [dir=%s] of user [%s, %s]", dir, username, sid)
+
+		return
+	}
+	test := autoTests[key]
+
+	if err := cmd.Start(); nil != err {
+		autoTestMutex.Unlock()
+		logger.Error(err)
+
+		return
+	}
+
+	test.cmd = cmd
+	autoTestMutex.Unlock()
+	untrack := Processes.Track(sid, cmd, ProcKindTest)
+
+	logger.Debugf("User [%s, %s] is running auto test [dir=%s]", username, sid, dir)
+
+	event.Publish(&event.Event{Code: event.EvtCodeTestStarted, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: dir}})
+
+	channelRet := map[string]interface{}{}
+
+	if wsChannel := session.OutputWS[sid]; nil != wsChannel {
+		// display "START [go test]" in front-end browser
+
+		channelRet["output"] = "<span class='start-test'>" + i18n.Get(locale, "start-test").(string) + "</span>\n"
+		channelRet["cmd"] = "start-test"
+
+		if err := wsChannel.WriteJSON(&channelRet); nil == err {
+			wsChannel.Refresh()
+		}
+	}
+
+	channelRet["cmd"] = "go test"
+
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadString('\n')
+
+		autoTestMutex.Lock()
+		stale := isStaleAutoTest(key, gen)
+		autoTestMutex.Unlock()
+		if stale { // cancelled, drains the output without pushing
+			line = ""
+		}
+
+		if "" != line {
+			if wsChannel := session.OutputWS[sid]; nil != wsChannel {
+				line = strings.Replace(line, "<", "&lt;", -1)
+				line = strings.Replace(line, ">", "&gt;", -1)
+				channelRet["output"] = line
+
+				if err := wsChannel.WriteJSON(&channelRet); nil == err {
+					wsChannel.Refresh()
+				}
+			}
+		}
+
+		if io.EOF == err {
+			break
+		}
+
+		if nil != err {
+			logger.Warn(err)
+
+			break
+		}
+	}
+
+	succ := nil == cmd.Wait()
+	untrack()
+
+	autoTestMutex.Lock()
+	cancelled := isStaleAutoTest(key, gen)
+	if !cancelled {
+		test.cmd = nil
+	}
+	autoTestMutex.Unlock()
+
+	if cancelled { // superseded by a newer save
+		return
+	}
+
+	event.Publish(&event.Event{Code: event.EvtCodeTestDone, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: dir, Succ: succ}})
+
+	if succ {
+		channelRet["output"] = "<span class='test-succ'>" + i18n.Get(locale, "test-succ").(string) + "</span>\n"
+	} else {
+		channelRet["output"] = "<span class='test-error'>" + i18n.Get(locale, "test-error").(string) + "</span>\n"
+	}
+
+	if wsChannel := session.OutputWS[sid]; nil != wsChannel {
+		if err := wsChannel.WriteJSON(&channelRet); nil != err {
+			logger.Warn(err)
+
+			return
+		}
+
+		wsChannel.Refresh()
+	}
+}
@@ -24,16 +24,23 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
 	"github.com/b3log/wide/i18n"
 	"github.com/b3log/wide/session"
 	"github.com/b3log/wide/util"
 )
 
+// lifecycleMaxErrors is the max count of errors carried by a done lifecycle event.
+const lifecycleMaxErrors = 10
+
 // BuildHandler handles request of building.
+//
+// If the package is unchanged since the latest successful build of the session (see getPackageHash), the build is
+// skipped and the output message contains "fromCache".
 func BuildHandler(w http.ResponseWriter, r *http.Request) {
 	result := util.NewResult()
 	defer util.RetResult(w, r, result)
@@ -69,6 +76,34 @@ func BuildHandler(w http.ResponseWriter, r *http.Request) {
 
 	curDir := filepath.Dir(filePath)
 
+	// builds the selected main package instead of the package of the file if argument "target" is present
+	if target, ok := args["target"].(string); ok {
+		if "" != target {
+			target = filepath.Clean(filepath.FromSlash(target))
+			if !session.CanAccess(username, target) || !util.File.IsDir(target) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+
+				return
+			}
+
+			curDir = target
+		}
+
+		if wSession := session.WideSessions.Get(sid); nil != wSession {
+			wSession.BuildTarget = target
+		}
+	}
+
+	profile, err := selectBuildProfile(user, curDir, args)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+	profileArgs, profileEnv := buildProfileArgs(profile)
+	buildArgs := append(user.BuildArgs(runtime.GOOS), profileArgs...)
+
 	fout, err := os.Create(filePath)
 
 	if nil != err {
@@ -94,17 +129,49 @@ func BuildHandler(w http.ResponseWriter, r *http.Request) {
 		suffix = ".exe"
 	}
 
+	executable := filepath.Base(curDir) + suffix
+	executable = filepath.Join(curDir, executable)
+
+	// skips building an unchanged package built successfully by the session
+	hash := getPackageHash(curDir, append(buildArgs, profileEnv...), conf.GetGoRoot(username))
+	if isBuildCached(sid, curDir, hash) && util.File.IsExist(executable) {
+		event.Publish(&event.Event{Code: event.EvtCodeBuildDone, Sid: sid,
+			Data: &event.Lifecycle{Username: username, Path: filePath, Succ: true}})
+
+		result.Data = map[string]interface{}{"fromCache": true}
+
+		if wsChannel := session.OutputWS[sid]; nil != wsChannel {
+			channelRet := map[string]interface{}{"cmd": "build", "executable": executable, "nextCmd": args["nextCmd"],
+				"fromCache": true, "lints": []*Lint{},
+				"output": "<span class='build-succ'>" + i18n.Get(locale, "build-succ-cached").(string) + "</span>\n"}
+			if err := wsChannel.WriteJSON(&channelRet); nil != err {
+				logger.Warn(err)
+			}
+
+			wsChannel.Refresh()
+		}
+
+		return
+	}
+
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(locale, "too_many_procs").(string)
+
+		return
+	}
+	defer release()
+
 	goBuildArgs := []string{}
 	goBuildArgs = append(goBuildArgs, "build")
-	goBuildArgs = append(goBuildArgs, user.BuildArgs(runtime.GOOS)...)
+	goBuildArgs = append(goBuildArgs, buildArgs...)
 
-	cmd := exec.Command("go", goBuildArgs...)
+	cmd := exec.CommandContext(r.Context(), "go", goBuildArgs...)
 	cmd.Dir = curDir
 
 	setCmdEnv(cmd, username)
-
-	executable := filepath.Base(curDir) + suffix
-	executable = filepath.Join(curDir, executable)
+	cmd.Env = append(cmd.Env, profileEnv...)
 
 	stdout, err := cmd.StdoutPipe()
 	if nil != err {
@@ -132,7 +199,7 @@ func BuildHandler(w http.ResponseWriter, r *http.Request) {
 		// display "START [go build]" in front-end browser
 
 		msg := i18n.Get(locale, "start-build").(string)
-		msg = strings.Replace(msg, "build]", "build "+fmt.Sprint(user.BuildArgs(runtime.GOOS))+"]", 1)
+		msg = strings.Replace(msg, "build]", "build "+fmt.Sprint(buildArgs)+"]", 1)
 
 		channelRet["output"] = "<span class='start-build'>" + msg + "</span>\n"
 		channelRet["cmd"] = "start-build"
@@ -154,9 +221,14 @@ func BuildHandler(w http.ResponseWriter, r *http.Request) {
 
 		return
 	}
+	untrack := Processes.Track(sid, cmd, ProcKindBuild)
 
 	// logger.Debugf("User [%s, %s] is building [id=%d, dir=%s]", username, sid, runningId, curDir)
 
+	started := time.Now()
+	event.Publish(&event.Event{Code: event.EvtCodeBuildStarted, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: filePath}})
+
 	channelRet["cmd"] = "build"
 	channelRet["executable"] = executable
 
@@ -229,13 +301,30 @@ func BuildHandler(w http.ResponseWriter, r *http.Request) {
 		wsChannel.Refresh()
 	}
 
-	if nil == cmd.Wait() {
+	buildSucc := nil == cmd.Wait()
+	untrack()
+
+	lifecycle := &event.Lifecycle{Username: username, Path: filePath, Succ: buildSucc, Elapsed: time.Since(started)}
+	if !buildSucc {
+		lifecycle.Errors = headLines(lines, lifecycleMaxErrors)
+	}
+	event.Publish(&event.Event{Code: event.EvtCodeBuildDone, Sid: sid, Data: lifecycle})
+
+	if buildSucc {
+		cacheBuild(sid, curDir, hash)
+
 		channelRet["nextCmd"] = args["nextCmd"]
 		channelRet["output"] = "<span class='build-succ'>" + i18n.Get(locale, "build-succ").(string) + "</span>\n"
 
 		go func() { // go install, for subsequent gocode lib-path
 			defer util.Recover()
 
+			release, err := AcquireProcSlot(username)
+			if nil != err { // it's optional
+				return
+			}
+			defer release()
+
 			cmd := exec.Command("go", "install")
 			cmd.Dir = curDir
 
@@ -249,55 +338,8 @@ func BuildHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		channelRet["output"] = "<span class='build-error'>" + i18n.Get(locale, "build-error").(string) + "</span>\n"
 
-		// lint process
-		if lines[0][0] == '#' {
-			lines = lines[1:] // skip the first line
-		}
-
-		lints := []*Lint{}
-
-		for _, line := range lines {
-			if len(line) < 1 || !strings.Contains(line, ":") {
-				continue
-			}
-
-			if line[0] == '\t' {
-				// append to the last lint
-				last := len(lints)
-				msg := lints[last-1].Msg
-				msg += line
-
-				lints[last-1].Msg = msg
-
-				continue
-			}
-
-			file := line[:strings.Index(line, ":")]
-			left := line[strings.Index(line, ":")+1:]
-			index := strings.Index(left, ":")
-			lineNo := 0
-			msg := left
-			if index >= 0 {
-				lineNo, err = strconv.Atoi(left[:index])
-
-				if nil != err {
-					continue
-				}
-
-				msg = left[index+2:]
-			}
-
-			lint := &Lint{
-				File:     filepath.ToSlash(filepath.Join(curDir, file)),
-				LineNo:   lineNo - 1,
-				Severity: lintSeverityError,
-				Msg:      msg,
-			}
-
-			lints = append(lints, lint)
-		}
-
-		channelRet["lints"] = lints
+		channelRet["lints"] = parseCompilerLints(curDir, lines)
+		channelRet["raw"] = strings.Join(lines, "")
 	}
 
 	wsChannel := session.OutputWS[sid]
@@ -311,3 +353,19 @@ func BuildHandler(w http.ResponseWriter, r *http.Request) {
 
 	wsChannel.Refresh()
 }
+
+// headLines returns the first non-blank lines (trimmed) of the specified lines, at most the specified count.
+func headLines(lines []string, count int) []string {
+	ret := []string{}
+	for _, line := range lines {
+		if count <= len(ret) {
+			break
+		}
+
+		if line = strings.TrimSpace(line); "" != line {
+			ret = append(ret, line)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,344 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+const (
+	pkgCompiling = "compiling" // package build status: compiling
+	pkgDone      = "done"      // package build status: done
+	pkgFailed    = "failed"    // package build status: failed
+)
+
+var (
+	// Import path printed by go build -v, such as "github.com/b3log/wide/conf" and "command-line-arguments".
+	buildVerboseRegexp = regexp.MustCompile(`^[\w.~+-]+(/[\w.~+-]+)*$`)
+
+	// Compile command printed by go build -x, such as ".../compile -o $WORK/b015/_pkg_.a ... -p fmt ...".
+	buildCompileRegexp = regexp.MustCompile(`[/\\]compile(\.exe)? .*-o \$WORK[/\\](b\d+)[/\\]_pkg_\.a .*-p (\S+)`)
+
+	// Action directory creation printed by go build -x, such as "mkdir -p $WORK/b015/".
+	buildMkdirRegexp = regexp.MustCompile(`^mkdir -p \$WORK[/\\](b\d+)[/\\]?$`)
+
+	// Build ID command printed by go build -x after a package compiled, such as "go tool buildid -w $WORK/b015/_pkg_.a".
+	buildIDRegexp = regexp.MustCompile(`\bbuildid(\.exe)? -w \$WORK[/\\](b\d+)[/\\]_pkg_\.a`)
+
+	// Shell commands printed by go build -x, they are not forwarded to the output channel.
+	buildShellRegexp = regexp.MustCompile(`^(WORK=|mkdir |cat |cd |cp |mv |rm |echo |touch |chmod |ln |EOF$|# internal$)`)
+)
+
+// pkgStatus represents the build status of a package.
+type pkgStatus struct {
+	Package string `json:"package"` // import path
+	Status  string `json:"status"`  // compiling/done/failed
+}
+
+// buildProgress parses the verbose output (-v -x) of go build into package build statuses.
+type buildProgress struct {
+	statuses map[string]string // <import path, status>
+	actions  map[string]string // <action directory under $WORK (such as b015), import path>
+	verbose  string            // the latest package printed by -v whose action directory isn't known yet
+	action   string            // the latest action directory created whose package isn't printed by -v yet
+	heredoc  bool              // whether in a here document (such as importcfg) of a shell command
+}
+
+// newBuildProgress creates a build progress.
+func newBuildProgress() *buildProgress {
+	return &buildProgress{statuses: map[string]string{}, actions: map[string]string{}}
+}
+
+// parse parses the specified line of go build output, returns the changed package statuses (deduplicated), and
+// whether the line is a message (compiler errors for example) rather than a command or a package printed by -v.
+func (p *buildProgress) parse(line string) (changes []*pkgStatus, message bool) {
+	line = strings.TrimRight(line, "\r\n")
+
+	if p.heredoc {
+		if "EOF" == line {
+			p.heredoc = false
+		}
+
+		return nil, false
+	}
+
+	if strings.Contains(line, "<< 'EOF'") {
+		p.heredoc = true
+
+		return nil, false
+	}
+
+	// go build prints a package by -v right before (older versions) or after creating its action directory, it's how
+	// a main package (compiled with "-p main") is identified
+	if m := buildMkdirRegexp.FindStringSubmatch(line); nil != m {
+		if "" != p.verbose {
+			p.actions[m[1]], p.verbose = p.verbose, ""
+		} else {
+			p.action = m[1]
+		}
+
+		return nil, false
+	}
+
+	if m := buildCompileRegexp.FindStringSubmatch(line); nil != m {
+		if "main" != m[3] {
+			p.actions[m[2]] = m[3]
+		}
+
+		if pkg, ok := p.actions[m[2]]; ok {
+			return p.set(pkg, pkgCompiling), false
+		}
+
+		return nil, false
+	}
+
+	if m := buildIDRegexp.FindStringSubmatch(line); nil != m {
+		if pkg, ok := p.actions[m[2]]; ok {
+			return p.set(pkg, pkgDone), false
+		}
+
+		return nil, false
+	}
+
+	if strings.HasPrefix(line, "# ") { // header of the errors of a package
+		return p.set(strings.TrimSpace(line[2:]), pkgFailed), true
+	}
+
+	if buildShellRegexp.MatchString(line) || strings.Contains(line, "$WORK") {
+		return nil, false
+	}
+
+	if buildVerboseRegexp.MatchString(line) {
+		if "" != p.action {
+			p.actions[p.action], p.action = line, ""
+		} else {
+			p.verbose = line
+		}
+
+		return p.set(line, pkgCompiling), false
+	}
+
+	return nil, "" != line
+}
+
+// set sets the status of the specified package, returns the change or nil if unchanged. A failed package stays
+// failed, a done package doesn't go back to compiling.
+func (p *buildProgress) set(pkg, status string) []*pkgStatus {
+	old := p.statuses[pkg]
+	if old == status || pkgFailed == old || (pkgDone == old && pkgCompiling == status) {
+		return nil
+	}
+
+	p.statuses[pkg] = status
+
+	return []*pkgStatus{{Package: pkg, Status: status}}
+}
+
+// count counts the packages of the specified status.
+func (p *buildProgress) count(status string) int {
+	ret := 0
+	for _, s := range p.statuses {
+		if s == status {
+			ret++
+		}
+	}
+
+	return ret
+}
+
+// BuildAllHandler handles request of building all packages (go build ./...) under the directory specified by
+// argument "dir" (defaults to the src directory of the user workspace) with progress.
+//
+// The build runs with -v -x, its output is parsed into package statuses pushed to the output channel as
+// {"cmd": "build-progress", "package", "status": "compiling"/"done"/"failed"}, each status change of a package is
+// pushed once. Compiler errors are forwarded as BuildHandler does, and the final message (cmd "build-all") carries
+// the parsed lints and the count of done and failed packages.
+func BuildAllHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+	user := conf.GetUser(username)
+	locale := user.Locale
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	sid := args["sid"].(string)
+
+	dir, _ := args["dir"].(string)
+	if "" == dir {
+		dir = filepath.Join(filepath.SplitList(conf.GetUserWorkspace(username))[0], "src")
+	}
+	dir = filepath.Clean(filepath.FromSlash(dir))
+	if util.Go.IsAPI(dir) || !session.CanAccess(username, dir) || !util.File.IsDir(dir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	profile, err := selectBuildProfile(user, dir, args)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+	profileArgs, profileEnv := buildProfileArgs(profile)
+
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(locale, "too_many_procs").(string)
+
+		return
+	}
+	defer release()
+
+	goArgs := append([]string{"build", "-v", "-x"}, profileArgs...)
+	goArgs = append(goArgs, "./...")
+
+	cmd := exec.CommandContext(r.Context(), "go", goArgs...)
+	cmd.Dir = dir
+	setCmdEnv(cmd, username)
+	cmd.Env = append(cmd.Env, profileEnv...)
+
+	stderr, err := cmd.StderrPipe()
+	if nil != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+	cmd.Stdout = cmd.Stderr
+
+	channelRet := map[string]interface{}{}
+
+	if wsChannel := session.OutputWS[sid]; nil != wsChannel {
+		msg := strings.Replace(i18n.Get(locale, "start-build").(string), "build]", "build ./...]", 1)
+		channelRet["output"] = "<span class='start-build'>" + msg + "</span>\n"
+		channelRet["cmd"] = "start-build"
+
+		if err := wsChannel.WriteJSON(&channelRet); nil != err {
+			logger.Warn(err)
+		}
+
+		wsChannel.Refresh()
+	}
+
+	if err := cmd.Start(); nil != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+	untrack := Processes.Track(sid, cmd, ProcKindBuild)
+
+	started := time.Now()
+	progress := newBuildProgress()
+	lines := []string{}
+
+	reader := bufio.NewReader(stderr)
+	for {
+		line, err := reader.ReadString('\n')
+		if "" != line {
+			changes, message := progress.parse(line)
+			if message {
+				lines = append(lines, line)
+			}
+
+			if wsChannel := session.OutputWS[sid]; nil != wsChannel {
+				for _, change := range changes {
+					if err := wsChannel.WriteJSON(map[string]interface{}{"cmd": "build-progress",
+						"package": change.Package, "status": change.Status}); nil != err {
+						logger.Warn(err)
+					}
+				}
+
+				if message {
+					msg := map[string]interface{}{"cmd": "build",
+						"output": "<span class='stderr'>" + parsePath(dir, line) + "</span>"}
+					if err := wsChannel.WriteJSON(msg); nil != err {
+						logger.Warn(err)
+					}
+				}
+
+				wsChannel.Refresh()
+			}
+		}
+
+		if nil != err {
+			if io.EOF != err {
+				logger.Warn(err)
+			}
+
+			break
+		}
+	}
+
+	buildSucc := nil == cmd.Wait()
+	untrack()
+
+	logger.Debugf("User [%s] built [%s] in [%s], [%d] packages done, [%d] failed", username, dir, time.Since(started),
+		progress.count(pkgDone), progress.count(pkgFailed))
+
+	channelRet["cmd"] = "build-all"
+	channelRet["done"] = progress.count(pkgDone)
+	channelRet["failed"] = progress.count(pkgFailed)
+	if buildSucc {
+		channelRet["output"] = "<span class='build-succ'>" + i18n.Get(locale, "build-succ").(string) + "</span>\n"
+	} else {
+		channelRet["output"] = "<span class='build-error'>" + i18n.Get(locale, "build-error").(string) + "</span>\n"
+		channelRet["lints"] = parseCompilerLints(dir, lines)
+		channelRet["raw"] = strings.Join(lines, "")
+	}
+
+	result.Data = map[string]interface{}{"succ": buildSucc, "done": channelRet["done"], "failed": channelRet["failed"]}
+
+	wsChannel := session.OutputWS[sid]
+	if nil == wsChannel {
+		return
+	}
+
+	if err := wsChannel.WriteJSON(&channelRet); nil != err {
+		logger.Warn(err)
+	}
+
+	wsChannel.Refresh()
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/b3log/wide/event"
+)
+
+// Hashes of the latest successful builds, <sid:dir, hash>.
+var buildCache = map[string]string{}
+
+// Exclusive lock of buildCache.
+var buildCacheMutex sync.Mutex
+
+// getPackageHash hashes the Go files (and go.mod, go.sum) of the package in the specified directory with the
+// specified build arguments and GOROOT, returns empty if any file can't be read.
+func getPackageHash(dir string, buildArgs []string, goRoot string) string {
+	f, err := os.Open(dir)
+	if nil != err {
+		return ""
+	}
+	names, _ := f.Readdirnames(-1)
+	f.Close()
+
+	sort.Strings(names)
+
+	hash := sha1.New()
+	io.WriteString(hash, goRoot+"\x00"+strings.Join(buildArgs, "\x00")+"\x00")
+	for _, name := range names {
+		if ".go" != filepath.Ext(name) && "go.mod" != name && "go.sum" != name {
+			continue
+		}
+
+		file, err := os.Open(filepath.Join(dir, name))
+		if nil != err {
+			return ""
+		}
+
+		io.WriteString(hash, name+"\x00")
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if nil != err {
+			return ""
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// isBuildCached checks whether the package in the specified directory has been built successfully by the session
+// specified by sid with the specified hash.
+func isBuildCached(sid, dir, hash string) bool {
+	buildCacheMutex.Lock()
+	defer buildCacheMutex.Unlock()
+
+	return "" != hash && buildCache[sid+":"+dir] == hash
+}
+
+// cacheBuild records a successful build of the package in the specified directory by the session specified by sid.
+func cacheBuild(sid, dir, hash string) {
+	if "" == hash {
+		return
+	}
+
+	buildCacheMutex.Lock()
+	defer buildCacheMutex.Unlock()
+
+	buildCache[sid+":"+dir] = hash
+}
+
+// invalidateBuildCache invalidates cached builds of packages affected by the file lifecycle event (saved, removed or
+// renamed), the package containing the file and the packages under it if it's a directory.
+func invalidateBuildCache(e *event.Event) {
+	lifecycle := e.Data.(*event.Lifecycle)
+
+	paths := []string{lifecycle.Path}
+	if "" != lifecycle.NewPath {
+		paths = append(paths, lifecycle.NewPath)
+	}
+
+	buildCacheMutex.Lock()
+	defer buildCacheMutex.Unlock()
+
+	for key := range buildCache {
+		dir := key[strings.Index(key, ":")+1:]
+		for _, path := range paths {
+			path = filepath.Clean(path)
+			if dir == filepath.Dir(path) || dir == path || strings.HasPrefix(dir, path+string(os.PathSeparator)) {
+				delete(buildCache, key)
+
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// Scopes of go clean.
+const (
+	cleanOutput    = "output"    // build output (executable and object files) of the package, `go clean`
+	cleanCache     = "cache"     // build cache, `go clean -cache`
+	cleanTestCache = "testcache" // test results cache, `go clean -testcache`
+	cleanModCache  = "modcache"  // module download cache shared by all users, `go clean -modcache`
+)
+
+// cleanResult represents the result of cleaning a scope.
+type cleanResult struct {
+	Scope  string `json:"scope"`
+	Cmd    string `json:"cmd"`
+	Succ   bool   `json:"succ"`
+	Output string `json:"output"`
+}
+
+// GoCleanHandler handles request of go clean.
+//
+// Argument "file" specifies the package, "scopes" is an array of output/cache/testcache/modcache (defaults to
+// output). The module cache is shared by all users, so cleaning it requires argument "confirmModCache" to be true.
+func GoCleanHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	filePath := args["file"].(string)
+	if util.Go.IsAPI(filePath) || !session.CanAccess(username, filePath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	curDir := filepath.Dir(filePath)
+
+	scopes := []string{}
+	if argScopes, ok := args["scopes"].([]interface{}); ok {
+		for _, scope := range argScopes {
+			if s, ok := scope.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	if 0 == len(scopes) {
+		scopes = append(scopes, cleanOutput)
+	}
+
+	confirmModCache, _ := args["confirmModCache"].(bool)
+
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(conf.GetUser(username).Locale, "too_many_procs").(string)
+
+		return
+	}
+	defer release()
+
+	results := []*cleanResult{}
+	for _, scope := range scopes {
+		goCleanArgs := []string{"clean", "-x"} // -x prints the remove commands, reports what was cleaned
+		switch scope {
+		case cleanOutput:
+		case cleanCache, cleanTestCache:
+			goCleanArgs = append(goCleanArgs, "-"+scope)
+		case cleanModCache:
+			if !confirmModCache {
+				results = append(results, &cleanResult{Scope: scope,
+					Output: "the module cache is shared by all users, confirmation required"})
+
+				continue
+			}
+
+			goCleanArgs = append(goCleanArgs, "-"+scope)
+		default:
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+
+			return
+		}
+
+		cmd := exec.Command("go", goCleanArgs...)
+		cmd.Dir = curDir
+		setCmdEnv(cmd, username)
+
+		out, err := cmd.CombinedOutput()
+		ret := &cleanResult{Scope: scope, Cmd: "go " + strings.Join(goCleanArgs, " "), Succ: nil == err,
+			Output: strings.TrimSpace(string(out))}
+		if nil != err && "" == ret.Output {
+			ret.Output = err.Error()
+		}
+
+		results = append(results, ret)
+
+		logger.Debugf("User [%s] ran [%s] in [%s]: %v", username, ret.Cmd, curDir, ret.Succ)
+	}
+
+	result.Data = results
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Compiler error line, file:line[:column]: message.
+//
+// The file may start with a Windows drive letter (C:\work\main.go), so the first colon can't be treated as the
+// separator naively.
+var compilerErrRegexp = regexp.MustCompile(`^((?:[A-Za-z]:)?[^:\t]+):(\d+)(?::(\d+))?:\s?(.*)$`)
+
+// parseCompilerLints parses the specified lines of go build (compile) output in the specified directory into lints.
+//
+// Lines starting with a tab are context of the previous error and appended to its message. An error marked with
+// "warning:" (cgo for example) is a warning.
+func parseCompilerLints(curDir string, lines []string) []*Lint {
+	ret := []*Lint{}
+
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r\n")
+		if "" == line || '#' == line[0] { // "# package" header
+			continue
+		}
+
+		if '\t' == line[0] || strings.HasPrefix(line, "    ") {
+			if 0 < len(ret) {
+				ret[len(ret)-1].Msg += "\n" + line
+			}
+
+			continue
+		}
+
+		lint := parseLint(curDir, line)
+		if nil == lint {
+			continue
+		}
+
+		ret = append(ret, lint)
+	}
+
+	return ret
+}
+
+// parseLint parses the specified line of go build (compile) output in the specified directory into a lint, returns
+// nil if the line is not an error with file position.
+func parseLint(curDir, line string) *Lint {
+	matches := compilerErrRegexp.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+	if nil == matches {
+		return nil
+	}
+
+	file := matches[1]
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(curDir, file)
+	}
+
+	lineNo, _ := strconv.Atoi(matches[2])
+	column := 0
+	if "" != matches[3] {
+		column, _ = strconv.Atoi(matches[3])
+	}
+
+	msg := matches[4]
+	severity := lintSeverityError
+	if strings.HasPrefix(msg, "warning:") {
+		severity = lintSeverityWarn
+		msg = strings.TrimSpace(strings.TrimPrefix(msg, "warning:"))
+	} else {
+		msg = strings.TrimSpace(strings.TrimPrefix(msg, "error:"))
+	}
+
+	ret := &Lint{
+		File:     filepath.ToSlash(file),
+		LineNo:   lineNo - 1,
+		Severity: severity,
+		Msg:      msg,
+	}
+	if 0 < column {
+		ret.Column = column - 1
+	}
+
+	return ret
+}
@@ -23,7 +23,6 @@ import (
 	"net/http"
 	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
 
 	"github.com/b3log/wide/conf"
@@ -144,7 +143,16 @@ func CrossCompilationHandler(w http.ResponseWriter, r *http.Request) {
 
 	reader := bufio.NewReader(io.MultiReader(stdout, stderr))
 
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(locale, "too_many_procs").(string)
+
+		return
+	}
+
 	if err := cmd.Start(); nil != err {
+		release()
 		logger.Error(err)
 		result.Succ = false
 
@@ -153,6 +161,7 @@ func CrossCompilationHandler(w http.ResponseWriter, r *http.Request) {
 
 	go func(runningId int) {
 		defer util.Recover()
+		defer release()
 		defer cmd.Wait()
 
 		// logger.Debugf("User [%s, %s] is building [id=%d, dir=%s]", username, sid, runningId, curDir)
@@ -183,55 +192,8 @@ func CrossCompilationHandler(w http.ResponseWriter, r *http.Request) {
 				"<span class='stderr'>" + errOutWithPath + "</span>"
 
 			// lint process
-
-			if lines[0][0] == '#' {
-				lines = lines[1:] // skip the first line
-			}
-
-			lints := []*Lint{}
-
-			for _, line := range lines {
-				if len(line) < 1 {
-					continue
-				}
-
-				if line[0] == '\t' {
-					// append to the last lint
-					last := len(lints)
-					msg := lints[last-1].Msg
-					msg += line
-
-					lints[last-1].Msg = msg
-
-					continue
-				}
-
-				file := line[:strings.Index(line, ":")]
-				left := line[strings.Index(line, ":")+1:]
-				index := strings.Index(left, ":")
-				lineNo := 0
-				msg := left
-				if index >= 0 {
-					lineNo, err = strconv.Atoi(left[:index])
-
-					if nil != err {
-						continue
-					}
-
-					msg = left[index+2:]
-				}
-
-				lint := &Lint{
-					File:     filepath.Join(curDir, file),
-					LineNo:   lineNo - 1,
-					Severity: lintSeverityError,
-					Msg:      msg,
-				}
-
-				lints = append(lints, lint)
-			}
-
-			channelRet["lints"] = lints
+			channelRet["lints"] = parseCompilerLints(curDir, lines)
+			channelRet["raw"] = errOut
 		}
 
 		if nil != session.OutputWS[sid] {
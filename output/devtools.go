@@ -0,0 +1,256 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// devToolStatus represents the installation status of a helper tool.
+type devToolStatus struct {
+	*conf.DevTool
+	Installed bool   `json:"installed"`
+	Path      string `json:"path"`    // path of the executable, empty if not installed
+	Version   string `json:"version"` // module version, empty if unknown
+	Hint      string `json:"hint"`    // hint of installing, empty if installed
+}
+
+var (
+	// Whether the helper tools are being installed.
+	devToolsInstalling bool
+
+	// Exclusive lock for devToolsInstalling.
+	devToolsMutex sync.Mutex
+)
+
+// DevToolsHandler handles request of checking the helper tools (see conf.DevTools), the data is the status of each
+// tool (see devToolStatus).
+func DevToolsHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+	locale := conf.GetUser(username).Locale
+
+	result.Data = getDevToolStatuses(locale)
+}
+
+// AdminDevToolsInstallHandler handles request of installing the missing helper tools by go install, the output is
+// streamed to the output channel of the session specified by argument "sid".
+//
+// Argument "tools" specifies the names of the tools to install, defaults to all the missing ones. The tools are
+// installed one by one into the GOPATH of Wide in background, data "tools" is the names of them. Only one
+// installation is allowed at a time.
+//
+// Requires the admin role.
+func AdminDevToolsInstallHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	if session.IsReadOnlyRequest(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	username := httpSession.Values["username"].(string)
+	locale := conf.GetUser(username).Locale
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	sid, _ := args["sid"].(string)
+
+	tools := []*conf.DevTool{}
+	if names, ok := args["tools"].([]interface{}); ok {
+		for _, name := range names {
+			n, _ := name.(string)
+			tool := conf.GetDevTool(n)
+			if nil == tool {
+				result.Succ = false
+				result.Msg = "unknown tool [" + n + "]"
+
+				return
+			}
+
+			tools = append(tools, tool)
+		}
+	} else {
+		for _, tool := range conf.DevTools {
+			if "" == tool.Path() {
+				tools = append(tools, tool)
+			}
+		}
+	}
+
+	names := []string{}
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	result.Data = map[string]interface{}{"tools": names}
+
+	if 0 == len(tools) {
+		return
+	}
+
+	devToolsMutex.Lock()
+	if devToolsInstalling {
+		devToolsMutex.Unlock()
+		result.Succ = false
+		result.Msg = i18n.Get(locale, "devtools_installing").(string)
+
+		return
+	}
+	devToolsInstalling = true
+	devToolsMutex.Unlock()
+
+	logger.Infof("Admin [%s] is installing dev tools %v", username, names)
+
+	writeOutput(sid, map[string]interface{}{"cmd": "start-install",
+		"output": "<span class='start-install'>" + i18n.Get(locale, "start-install").(string) + "</span>\n"})
+
+	go func() {
+		defer util.Recover()
+		defer func() {
+			devToolsMutex.Lock()
+			devToolsInstalling = false
+			devToolsMutex.Unlock()
+		}()
+
+		for _, tool := range tools {
+			succ := installDevTool(sid, tool)
+
+			output := "<span class='install-succ'>" + i18n.Get(locale, "install-succ").(string) + " " + tool.Name
+			if succ {
+				if version := tool.Version(tool.Path()); "" != version {
+					output += " " + version
+				}
+			} else {
+				output = "<span class='install-error'>" + i18n.Get(locale, "install-error").(string) + " " + tool.Name
+			}
+
+			writeOutput(sid, map[string]interface{}{"cmd": "go install", "output": output + "</span>\n"})
+		}
+
+		logger.Infof("Installed dev tools %v", names)
+	}()
+}
+
+// installDevTool installs the specified helper tool by go install, the output is streamed to the output channel of
+// the session specified by sid. Returns whether the tool is installed successfully.
+func installDevTool(sid string, tool *conf.DevTool) bool {
+	writeOutput(sid, map[string]interface{}{"cmd": "go install", "output": tool.InstallCmd() + "\n"})
+
+	// installs into the GOPATH of Wide where the tools are looked up, see util.Go.GetExecutableInGOBIN
+	cmd := exec.Command("go", "install", tool.Package)
+	cmd.Env = append(os.Environ(), "GO111MODULE=on")
+
+	stdout, err := cmd.StdoutPipe()
+	if nil != err {
+		logger.Error(err)
+
+		return false
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); nil != err {
+		logger.Error(err)
+
+		return false
+	}
+
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadString('\n')
+		if "" != line {
+			writeOutput(sid, map[string]interface{}{"cmd": "go install",
+				"output": "<span class='stderr'>" + html.EscapeString(line) + "</span>"})
+		}
+
+		if nil != err {
+			if io.EOF != err {
+				logger.Warn(err)
+			}
+
+			break
+		}
+	}
+
+	if err := cmd.Wait(); nil != err {
+		logger.Warnf("Installing dev tool [%s] failed: %s", tool.Name, err)
+
+		return false
+	}
+
+	return true
+}
+
+// getDevToolStatuses gets the status of each helper tool, hints are in the specified locale.
+func getDevToolStatuses(locale string) []*devToolStatus {
+	ret := []*devToolStatus{}
+	for _, tool := range conf.DevTools {
+		status := &devToolStatus{DevTool: tool, Path: tool.Path()}
+		if "" != status.Path {
+			status.Installed = true
+			status.Version = tool.Version(status.Path)
+		} else {
+			status.Hint = conf.GetDevToolHint(locale, tool.Name)
+		}
+
+		ret = append(ret, status)
+	}
+
+	return ret
+}
+
+// writeOutput writes the specified message to the output channel of the session specified by sid, if it's connected.
+func writeOutput(sid string, message map[string]interface{}) {
+	wsChannel := session.OutputWS[sid]
+	if nil == wsChannel {
+		return
+	}
+
+	if err := wsChannel.WriteJSON(message); nil != err {
+		logger.Warn(err)
+
+		return
+	}
+
+	wsChannel.Refresh()
+}
@@ -0,0 +1,318 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+const (
+	evalCodeMax   = 64 * 1024         // max size of a snippet
+	evalStdinMax  = 64 * 1024         // max size of the standard input of a snippet
+	evalOutputMax = 64 * 1024         // max size of stdout and stderr of a snippet (each)
+	evalTimeout   = 10 * time.Second  // max duration of compiling and running a snippet
+	evalMemoryMax = 256 * 1024 * 1024 // max size of the data segment (heap) of a snippet
+	evalProcsMax  = 64                // max number of processes (threads included) of a snippet
+	evalFileMax   = 1024 * 1024       // max size of a file written by a snippet
+)
+
+// evalLimits is the source of a package imported by each snippet (see buildSnippet), its initialization sets the
+// resource limits of the snippet process before any code of the snippet runs. The hard limits are lowered as well, the
+// snippet can't raise them back since it has no capabilities (see isolate).
+var evalLimits = fmt.Sprintf(`package limits
+
+import "syscall"
+
+const rlimitNPROC = 0x6
+
+func init() {
+	for resource, max := range map[int]uint64{syscall.RLIMIT_DATA: %d, rlimitNPROC: %d, syscall.RLIMIT_FSIZE: %d} {
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: max, Max: max}); nil != err {
+			panic(err)
+		}
+	}
+}
+`, evalMemoryMax, evalProcsMax, evalFileMax)
+
+// errEvalTimeout indicates the snippet is killed since it took too long.
+var errEvalTimeout = errors.New("timeout")
+
+// evalCacheDir is the build cache directory shared by all snippets, so the standard library is not rebuilt each time.
+var evalCacheDir = filepath.Join(os.TempDir(), "wide-eval-cache")
+
+// EvalHandler handles request of evaluating a standalone Go program (argument "code"), playground-style.
+//
+// The program is compiled and run in a temporary module which is removed after, the user workspace is not involved
+// and dependencies can't be downloaded. Optional argument "stdin" is fed to the standard input of the program. The
+// program runs sandboxed (see isolate): it only sees the temporary module directory, runs as an unprivileged user and
+// has no network unless conf.Wide.EvalNetwork is true. Its memory, processes and written files are limited by
+// evalMemoryMax, evalProcsMax and evalFileMax. The program is refused if sandboxing is unavailable.
+//
+// Compiling and running take evalTimeout at most, stdout and stderr are truncated at evalOutputMax. Compile errors
+// are returned as lints (data "lints") the same as BuildHandler, the file of them is "main.go".
+func EvalHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+	user := conf.GetUser(username)
+	if nil == user {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	code, _ := args["code"].(string)
+	stdin, _ := args["stdin"].(string)
+	if "" == strings.TrimSpace(code) || evalCodeMax < len(code) || evalStdinMax < len(stdin) {
+		result.Succ = false
+		result.Msg = "the snippet or its stdin is empty or too large"
+
+		return
+	}
+
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(user.Locale, "too_many_procs").(string)
+
+		return
+	}
+	defer release()
+
+	tmpDir, err := ioutil.TempDir("", "wide-eval")
+	if nil != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// the executable runs chrooted into tmpDir
+	cmd := exec.Command("/main")
+	cmd.Dir = "/"
+	cmd.Env = []string{"HOME=/", "TMPDIR=/"}
+	cmd.Stdin = strings.NewReader(stdin)
+	if err := isolate(cmd, tmpDir, conf.Wide.EvalNetwork); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	deadline := time.Now().Add(evalTimeout)
+	data := map[string]interface{}{"phase": "build", "stdout": "", "stderr": "", "exitCode": -1, "timeout": false,
+		"truncated": false, "lints": []*Lint{}}
+	result.Data = data
+
+	out, err := buildSnippet(username, tmpDir, code, deadline)
+	if nil != err {
+		output := out.String()
+		data["stderr"] = strings.Replace(output, tmpDir+string(os.PathSeparator), "", -1)
+		data["timeout"] = errEvalTimeout == err
+		data["truncated"] = out.truncated
+		if lints := parseCompilerLints(tmpDir, strings.Split(output, "\n")); 0 < len(lints) {
+			for _, lint := range lints {
+				lint.File = filepath.Base(lint.File)
+			}
+			data["lints"] = lints
+		} else if _, ok := err.(*exec.ExitError); !ok && errEvalTimeout != err {
+			logger.Error(err)
+			result.Succ = false
+		}
+
+		return
+	}
+
+	data["phase"] = "run"
+	stdout, stderr := newCappedBuffer(evalOutputMax), newCappedBuffer(evalOutputMax)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	err = runWithDeadline(cmd, deadline, stdout, stderr)
+	data["stdout"] = stdout.String()
+	data["stderr"] = stderr.String()
+	data["truncated"] = stdout.truncated || stderr.truncated
+	data["timeout"] = errEvalTimeout == err
+	if nil == err {
+		data["exitCode"] = 0
+	} else if exitErr, ok := err.(*exec.ExitError); ok {
+		data["exitCode"] = exitErr.ExitCode()
+	} else if errEvalTimeout != err {
+		logger.Warnf("User [%s] evaluates a snippet failed: %s", username, err)
+		result.Succ = false
+		result.Msg = err.Error()
+	}
+}
+
+// buildSnippet compiles the specified code as a module in the specified directory into the executable "main" of the
+// directory with the toolchain of the user specified by username, returns the output of the compiler.
+func buildSnippet(username, dir, code string, deadline time.Time) (*cappedBuffer, error) {
+	out := newCappedBuffer(evalOutputMax)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(code), 0644); nil != err {
+		return out, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module snippet\n"), 0644); nil != err {
+		return out, err
+	}
+	// the file name sorts after main.go so compile errors of the snippet come first
+	if err := ioutil.WriteFile(filepath.Join(dir, "zz_limits.go"),
+		[]byte("package main\n\nimport _ \"snippet/internal/limits\"\n"), 0644); nil != err {
+		return out, err
+	}
+	limitsDir := filepath.Join(dir, "internal", "limits")
+	if err := os.MkdirAll(limitsDir, 0755); nil != err {
+		return out, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(limitsDir, "limits.go"), []byte(evalLimits), 0644); nil != err {
+		return out, err
+	}
+	if uid, gid := sandboxUser(); uid != os.Getuid() { // the unprivileged user runs the executable
+		if err := os.Chown(dir, uid, gid); nil != err {
+			return out, err
+		}
+	}
+
+	goRoot := conf.GetGoRoot(username)
+	cmd := exec.Command(conf.GetGoExecutable(goRoot), "build", "-o", "main", ".")
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"GOROOT=" + goRoot,
+		"GOPATH=" + filepath.Join(dir, "gopath"),
+		"GOCACHE=" + evalCacheDir,
+		"GOTMPDIR=" + dir,
+		"GO111MODULE=on",
+		"CGO_ENABLED=0", // statically linked to run chrooted
+		"GOFLAGS=-mod=mod",
+		"GOPROXY=off",
+		"GOTOOLCHAIN=local",
+		"HOME=" + dir,
+		"PATH=" + filepath.Join(goRoot, "bin") + string(os.PathListSeparator) + os.Getenv("PATH"),
+	}
+	cmd.Stdout, cmd.Stderr = out, out
+
+	return out, runWithDeadline(cmd, deadline, out)
+}
+
+// runWithDeadline runs the specified command, kills it (with its children) if it is still running at the specified
+// deadline or any of the specified buffers is full, returns errEvalTimeout if killed for the deadline.
+func runWithDeadline(cmd *exec.Cmd, deadline time.Time, bufs ...*cappedBuffer) error {
+	setProcessGroup(cmd)
+
+	full := make(chan struct{})
+	var once sync.Once
+	for _, buf := range bufs {
+		buf.onFull = func() { once.Do(func() { close(full) }) }
+	}
+
+	if err := cmd.Start(); nil != err {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-full:
+		killProcess(cmd.Process)
+
+		return <-done
+	case <-timer.C:
+		killProcess(cmd.Process)
+		<-done
+
+		return errEvalTimeout
+	}
+}
+
+// cappedBuffer is a buffer holding the first max bytes written, the rest is dropped.
+type cappedBuffer struct {
+	mutex     sync.Mutex
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+	onFull    func() // called once the buffer is full
+}
+
+// newCappedBuffer creates a buffer holding the specified max bytes.
+func newCappedBuffer(max int) *cappedBuffer {
+	return &cappedBuffer{max: max}
+}
+
+// Write writes the specified bytes as much as the buffer could hold, it never fails so the writer keeps going till
+// killed.
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	n := len(p)
+	if left := b.max - b.buf.Len(); left < len(p) {
+		p = p[:left]
+		if !b.truncated {
+			b.truncated = true
+			if nil != b.onFull {
+				b.onFull()
+			}
+		}
+	}
+	b.buf.Write(p)
+
+	return n, nil
+}
+
+// String returns the content of the buffer.
+func (b *cappedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buf.String()
+}
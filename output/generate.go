@@ -0,0 +1,173 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"html"
+	"io"
+	"math/rand"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// GoGenerateHandler handles request of go generate.
+//
+// Runs `go generate` for the package of argument "file", or `go generate ./...` for the package and its
+// sub-packages if argument "recursive" is true. The output is pushed line by line, and the last message contains
+// "refresh", the directory to refresh in file tree since generators often create new files.
+func GoGenerateHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+	locale := conf.GetUser(username).Locale
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	sid := args["sid"].(string)
+
+	filePath := args["file"].(string)
+	if util.Go.IsAPI(filePath) || !session.CanAccess(username, filePath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	curDir := filepath.Dir(filePath)
+
+	goGenerateArgs := []string{"generate", "-x"}
+	if recursive, _ := args["recursive"].(bool); recursive {
+		goGenerateArgs = append(goGenerateArgs, "./...")
+	}
+
+	cmd := exec.Command("go", goGenerateArgs...)
+	cmd.Dir = curDir
+
+	setCmdEnv(cmd, username)
+
+	stdout, err := cmd.StdoutPipe()
+	if nil != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+	cmd.Stderr = cmd.Stdout
+
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(locale, "too_many_procs").(string)
+
+		return
+	}
+
+	if err := cmd.Start(); nil != err {
+		release()
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	channelRet := map[string]interface{}{}
+
+	if nil != session.OutputWS[sid] {
+		// display "START [go generate]" in front-end browser
+
+		channelRet["output"] = "<span class='start-get'>" + i18n.Get(locale, "start-generate").(string) + "</span>\n"
+		channelRet["cmd"] = "start-generate"
+
+		wsChannel := session.OutputWS[sid]
+
+		err := wsChannel.WriteJSON(&channelRet)
+		if nil != err {
+			logger.Warn(err)
+		}
+
+		wsChannel.Refresh()
+	}
+
+	go func(runningId int) {
+		defer util.Recover()
+		defer release()
+
+		logger.Debugf("User [%s, %s] is running [go generate] [runningId=%d]", username, sid, runningId)
+
+		channelRet := map[string]interface{}{}
+		channelRet["cmd"] = "go generate"
+
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadString('\n')
+			if "" != line {
+				if wsChannel := session.OutputWS[sid]; nil != wsChannel {
+					channelRet["output"] = html.EscapeString(line)
+					if err := wsChannel.WriteJSON(&channelRet); nil != err {
+						logger.Warn(err)
+					}
+
+					wsChannel.Refresh()
+				}
+			}
+
+			if nil != err {
+				if io.EOF != err {
+					logger.Warn(err)
+				}
+
+				break
+			}
+		}
+
+		if err := cmd.Wait(); nil != err {
+			logger.Debugf("User [%s, %s] 's [go generate] [runningId=%d] has done (with error)", username, sid, runningId)
+
+			channelRet["output"] = "<span class='get-error'>" + i18n.Get(locale, "generate-error").(string) + "</span>\n"
+		} else {
+			logger.Debugf("User [%s, %s] 's running [go generate] [runningId=%d] has done", username, sid, runningId)
+
+			channelRet["output"] = "<span class='get-succ'>" + i18n.Get(locale, "generate-succ").(string) + "</span>\n"
+		}
+		channelRet["refresh"] = filepath.ToSlash(curDir)
+
+		if wsChannel := session.OutputWS[sid]; nil != wsChannel {
+			if err := wsChannel.WriteJSON(&channelRet); nil != err {
+				logger.Warn(err)
+			}
+
+			wsChannel.Refresh()
+		}
+	}(rand.Int())
+}
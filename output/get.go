@@ -103,7 +103,16 @@ func GoGetHandler(w http.ResponseWriter, r *http.Request) {
 
 	reader := bufio.NewReader(io.MultiReader(stdout, stderr))
 
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(locale, "too_many_procs").(string)
+
+		return
+	}
+
 	if err := cmd.Start(); nil != err {
+		release()
 		logger.Error(err)
 		result.Succ = false
 
@@ -112,6 +121,7 @@ func GoGetHandler(w http.ResponseWriter, r *http.Request) {
 
 	go func(runningId int) {
 		defer util.Recover()
+		defer release()
 		defer cmd.Wait()
 
 		logger.Debugf("User [%s, %s] is running [go get] [runningId=%d]", username, sid, runningId)
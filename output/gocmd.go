@@ -0,0 +1,355 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"math/rand"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// goSubcmd represents a whitelisted go subcommand.
+type goSubcmd struct {
+	flags    map[string]bool // allowed flags, <name, whether takes a value>
+	modifies bool            // whether modifies the workspace, viewers can't run it
+	args     func(dir, username string, args []string) error
+}
+
+// Whitelisted go subcommands.
+//
+// Flags running arbitrary programs or reading files outside the workspace (-exec, -toolexec, -overlay, -modfile, -C,
+// etc.) aren't allowed.
+var goSubcmds = map[string]*goSubcmd{
+	"build": {flags: map[string]bool{"-v": false, "-x": false, "-n": false, "-a": false, "-race": false,
+		"-trimpath": false, "-tags": true, "-ldflags": true, "-gcflags": true, "-mod": true, "-o": true},
+		args: checkGoPackages},
+	"test": {flags: map[string]bool{"-v": false, "-short": false, "-race": false, "-cover": false, "-failfast": false,
+		"-benchmem": false, "-json": false, "-run": true, "-bench": true, "-count": true, "-cpu": true,
+		"-parallel": true, "-timeout": true, "-tags": true, "-mod": true}, args: checkGoPackages},
+	"vet": {flags: map[string]bool{"-v": false, "-json": false, "-tags": true, "-mod": true},
+		args: checkGoPackages},
+	"get": {flags: map[string]bool{"-u": false, "-d": false, "-t": false, "-v": false, "-x": false},
+		modifies: true, args: checkGoPackages},
+	"install": {flags: map[string]bool{"-v": false, "-x": false, "-a": false, "-race": false, "-trimpath": false,
+		"-tags": true, "-ldflags": true, "-gcflags": true, "-mod": true}, modifies: true, args: checkGoPackages},
+	"generate": {flags: map[string]bool{"-v": false, "-x": false, "-n": false, "-run": true}, modifies: true,
+		args: checkGoPackages},
+	"mod": {flags: map[string]bool{"-v": false, "-x": false, "-json": false, "-e": false, "-m": false},
+		modifies: true, args: checkGoModArgs},
+	"list": {flags: map[string]bool{"-m": false, "-u": false, "-e": false, "-json": false, "-deps": false,
+		"-test": false, "-versions": false, "-f": true, "-tags": true, "-mod": true}, args: checkGoPackages},
+	"env": {flags: map[string]bool{"-json": false}, args: checkGoEnvNames},
+}
+
+// Read-only go mod subcommands, viewers could run them.
+var goModReadOnlyCmds = []string{"graph", "verify", "why"}
+
+// All go mod subcommands allowed.
+var goModCmds = []string{"download", "graph", "init", "tidy", "vendor", "verify", "why"}
+
+var (
+	// valid import path or pattern, a module path with version query (@latest for example) is also valid
+	goPackageRegexp = regexp.MustCompile(`^[A-Za-z0-9._~+\-/]+(@[A-Za-z0-9._+\-]+)?$`)
+	// valid environment variable name
+	goEnvNameRegexp = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+)
+
+// GoCmdHandler handles request of running a whitelisted go subcommand.
+//
+// Argument "cmd" is the subcommand (build, test, vet, get, install, generate, mod, list or env), argument "args" is
+// the arguments array, flags and arguments are validated per subcommand (see goSubcmds), argument "dir" is the
+// working directory. The command isn't run by a shell, and runs with the user's environment (see setCmdEnv).
+//
+// The output is pushed to the output channel line by line, the last message contains "exitCode".
+func GoCmdHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	sid := args["sid"].(string)
+
+	name, _ := args["cmd"].(string)
+	subcmd := goSubcmds[name]
+	if nil == subcmd {
+		result.Succ = false
+		result.Msg = "go subcommand [" + name + "] is not allowed"
+
+		return
+	}
+
+	dir, _ := args["dir"].(string)
+	dir = filepath.Clean(filepath.FromSlash(dir))
+	if "" == dir || util.Go.IsAPI(dir) || !session.CanAccess(username, dir) || !util.File.IsDir(dir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	cmdArgs := []string{}
+	if arr, ok := args["args"].([]interface{}); ok {
+		for _, arg := range arr {
+			a, ok := arg.(string)
+			if !ok {
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+
+				return
+			}
+
+			cmdArgs = append(cmdArgs, a)
+		}
+	}
+
+	user := conf.GetUser(username)
+	if nil == user {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if subcmd.modifies && !session.CanWrite(r) &&
+		!("mod" == name && 0 < len(cmdArgs) && util.Str.Contains(cmdArgs[0], goModReadOnlyCmds)) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if err := checkGoCmdArgs(subcmd, dir, username, cmdArgs); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(user.Locale, "too_many_procs").(string)
+
+		return
+	}
+
+	cmd := exec.Command("go", append([]string{name}, cmdArgs...)...)
+	cmd.Dir = dir
+	setCmdEnv(cmd, username)
+
+	stdout, err := cmd.StdoutPipe()
+	if nil != err {
+		release()
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); nil != err {
+		release()
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	command := "go " + strings.Join(append([]string{name}, cmdArgs...), " ")
+	result.Data = map[string]interface{}{"cmd": command}
+
+	go func(runningId int) {
+		defer util.Recover()
+		defer release()
+
+		logger.Debugf("User [%s, %s] is running [%s] [runningId=%d]", username, sid, command, runningId)
+
+		channelRet := map[string]interface{}{"cmd": "go", "subcmd": name}
+
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadString('\n')
+			if "" != line {
+				if wsChannel := session.OutputWS[sid]; nil != wsChannel {
+					channelRet["output"] = html.EscapeString(line)
+					if err := wsChannel.WriteJSON(&channelRet); nil != err {
+						logger.Warn(err)
+					}
+
+					wsChannel.Refresh()
+				}
+			}
+
+			if nil != err {
+				if io.EOF != err {
+					logger.Warn(err)
+				}
+
+				break
+			}
+		}
+
+		exitCode := 0
+		if err := cmd.Wait(); nil != err {
+			exitCode = -1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				exitCode = exitErr.ExitCode()
+			}
+		}
+
+		logger.Debugf("User [%s, %s] 's [%s] [runningId=%d] has done [exitCode=%d]", username, sid, command,
+			runningId, exitCode)
+
+		delete(channelRet, "output")
+		channelRet["exitCode"] = exitCode
+		if wsChannel := session.OutputWS[sid]; nil != wsChannel {
+			if err := wsChannel.WriteJSON(&channelRet); nil != err {
+				logger.Warn(err)
+			}
+
+			wsChannel.Refresh()
+		}
+	}(rand.Int())
+}
+
+// checkGoCmdArgs validates the specified arguments of the specified go subcommand, flags come first and are checked
+// against the whitelist, the rest are checked by the subcommand.
+func checkGoCmdArgs(subcmd *goSubcmd, dir, username string, args []string) error {
+	i := 0
+	if 0 < len(args) && subcmd == goSubcmds["mod"] { // go mod <command> [flags] [arguments]
+		if !util.Str.Contains(args[0], goModCmds) {
+			return errors.New("go mod subcommand [" + args[0] + "] is not allowed")
+		}
+
+		i = 1
+	}
+
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if "--" == arg {
+			i++
+
+			break
+		}
+
+		if !strings.HasPrefix(arg, "-") {
+			break
+		}
+
+		flag, value := arg, ""
+		hasValue := false
+		if eq := strings.Index(arg, "="); 0 < eq {
+			flag, value = arg[:eq], arg[eq+1:]
+			hasValue = true
+		}
+		flag = "-" + strings.TrimLeft(flag, "-")
+
+		takesValue, ok := subcmd.flags[flag]
+		if !ok {
+			return errors.New("flag [" + flag + "] is not allowed")
+		}
+
+		if takesValue && !hasValue {
+			if i+1 >= len(args) {
+				return errors.New("flag [" + flag + "] needs a value")
+			}
+
+			i++
+			value = args[i]
+		} else if !takesValue && hasValue && "true" != value && "false" != value {
+			return errors.New("flag [" + flag + "] doesn't take a value")
+		}
+
+		if "-o" == flag && !canAccessGoPath(dir, username, value) {
+			return errors.New("output [" + value + "] is not allowed")
+		}
+	}
+
+	rest := args[i:]
+	if subcmd == goSubcmds["mod"] && 0 < len(args) {
+		rest = append([]string{args[0]}, rest...)
+	}
+
+	return subcmd.args(dir, username, rest)
+}
+
+// checkGoPackages validates the specified package arguments, a path (starts with "." or "/") must be accessible.
+func checkGoPackages(dir, username string, args []string) error {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") || !goPackageRegexp.MatchString(arg) {
+			return errors.New("argument [" + arg + "] is not allowed")
+		}
+
+		if (strings.HasPrefix(arg, ".") || filepath.IsAbs(arg)) && !canAccessGoPath(dir, username, arg) {
+			return errors.New("argument [" + arg + "] is not allowed")
+		}
+	}
+
+	return nil
+}
+
+// checkGoModArgs validates the specified go mod arguments, the first one is the go mod subcommand.
+func checkGoModArgs(dir, username string, args []string) error {
+	if 1 > len(args) {
+		return errors.New("go mod subcommand is required")
+	}
+
+	return checkGoPackages(dir, username, args[1:])
+}
+
+// checkGoEnvNames validates the specified go env arguments, they are variable names.
+func checkGoEnvNames(dir, username string, args []string) error {
+	for _, arg := range args {
+		if !goEnvNameRegexp.MatchString(arg) {
+			return errors.New("argument [" + arg + "] is not allowed")
+		}
+	}
+
+	return nil
+}
+
+// canAccessGoPath checks whether the specified path (relative to dir if not absolute) is accessible by the user
+// specified by username, a pattern suffix "/..." is ignored.
+func canAccessGoPath(dir, username, path string) bool {
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "..."), "/")
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	return session.CanAccess(username, filepath.Clean(path))
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/b3log/wide/conf"
+)
+
+func TestCheckGoCmdArgs(t *testing.T) {
+	workspace, err := ioutil.TempDir("", "wide-gocmd")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workspace)
+
+	dir := filepath.Join(workspace, "src", "hello")
+	if err := os.MkdirAll(dir, 0755); nil != err {
+		t.Fatal(err)
+	}
+
+	if nil == conf.Wide {
+		json.Unmarshal([]byte("{}"), &conf.Wide)
+	}
+	conf.SetUsers([]*conf.User{{Name: "gocmd", Workspace: workspace}})
+	defer conf.SetUsers(nil)
+
+	outside := filepath.Join(filepath.Dir(workspace), "outside")
+
+	cases := []struct {
+		cmd  string
+		args []string
+		ok   bool
+	}{
+		{"build", []string{}, true},
+		{"build", []string{"-v", "./..."}, true},
+		{"build", []string{"-o", "hello", "."}, true},
+		{"build", []string{"-o", filepath.Join(workspace, "bin", "hello")}, true},
+		{"build", []string{"-o", outside}, false},
+		{"build", []string{"-o=" + outside}, false},
+		{"build", []string{"--o=" + outside}, false},
+		{"build", []string{"-o", "../../../outside"}, false},
+		{"build", []string{"-o"}, false},
+		{"build", []string{"-v=true"}, true},
+		{"build", []string{"-v=1"}, false},
+		{"build", []string{"-toolexec", "sh"}, false},
+		{"build", []string{"-exec=sh"}, false},
+		{"test", []string{"-overlay", "overlay.json"}, false},
+		{"test", []string{"-run", "TestHello", "-count=1", "./..."}, true},
+		{"build", []string{"--", "./..."}, true},
+		{"build", []string{"--", "-o"}, false},
+		{"build", []string{"../../.."}, false},
+		{"build", []string{"./../../../outside"}, false},
+		{"build", []string{outside}, false},
+		{"vet", []string{"../hello"}, true},
+		{"get", []string{"github.com/b3log/wide@latest"}, true},
+		{"get", []string{"github.com/b3log/wide@v1.5.0"}, true},
+		{"get", []string{"github.com/b3log/wide@v1;rm"}, false},
+		{"get", []string{"github.com/b3log/wide@"}, false},
+		{"mod", []string{"tidy"}, true},
+		{"mod", []string{"why", "-m", "github.com/b3log/wide"}, true},
+		{"mod", []string{"edit", "-replace=a=../../.."}, false},
+		{"mod", []string{"edit"}, false},
+		{"mod", []string{}, false},
+		{"env", []string{"GOPATH", "GOROOT"}, true},
+		{"env", []string{"-w", "GOFLAGS=-toolexec=sh"}, false},
+		{"env", []string{"goflags"}, false},
+	}
+
+	for _, c := range cases {
+		err := checkGoCmdArgs(goSubcmds[c.cmd], dir, "gocmd", c.args)
+		if c.ok && nil != err {
+			t.Errorf("go %s %v should be allowed: %s", c.cmd, c.args, err)
+		} else if !c.ok && nil == err {
+			t.Errorf("go %s %v should not be allowed", c.cmd, c.args)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// Go environment variables a user could set by `go env -w`.
+var goEnvUserVars = []string{"CGO_ENABLED", "GO111MODULE", "GONOPROXY", "GONOSUMDB", "GOPRIVATE", "GOPROXY"}
+
+// Go environment variables only admins could set, they affect security or resources shared by users.
+//
+// GOPATH, GOROOT, GOOS and GOARCH are set by Wide (see setCmdEnv) thus can't be set at all.
+var goEnvAdminVars = []string{"GOCACHE", "GOFLAGS", "GOINSECURE", "GOMODCACHE", "GOSUMDB", "GOTMPDIR", "GOTOOLCHAIN"}
+
+// GoEnvHandler handles request of `go env`, the data contains "env", the parsed variables, and "settable", names of
+// variables the user could set by GoEnvSetHandler.
+func GoEnvHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(conf.GetUser(username).Locale, "too_many_procs").(string)
+
+		return
+	}
+	defer release()
+
+	cmd := exec.Command("go", "env", "-json")
+	cmd.Dir = filepath.SplitList(conf.GetUserWorkspace(username))[0]
+	setCmdEnv(cmd, username)
+
+	output, err := cmd.Output()
+	if nil != err {
+		logger.Error(err)
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	env := map[string]string{}
+	if err := json.Unmarshal(output, &env); nil != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	result.Data = map[string]interface{}{"env": env, "settable": getSettableGoEnvVars(username)}
+}
+
+// GoEnvSetHandler handles request of setting go environment variables by `go env -w`.
+//
+// Argument "vars" is a map of variable names to values, an empty value unsets the variable (`go env -u`). The
+// variables are written to the user's GOENV file (see conf.User.GoEnvPath), so they take effect for subsequent go
+// commands of the user only.
+func GoEnvSetHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	vars, _ := args["vars"].(map[string]interface{})
+	if 1 > len(vars) {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+
+		return
+	}
+
+	settable := getSettableGoEnvVars(username)
+	names := []string{}
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sets := []string{"env", "-w"}
+	unsets := []string{"env", "-u"}
+	for _, name := range names {
+		if !util.Str.Contains(name, settable) {
+			result.Succ = false
+			result.Msg = "go environment variable [" + name + "] can't be set"
+
+			return
+		}
+
+		value, ok := vars[name].(string)
+		if !ok || strings.ContainsAny(value, "\r\n") {
+			result.Succ = false
+			result.Msg = "invalid value of go environment variable [" + name + "]"
+
+			return
+		}
+
+		if "" == value {
+			unsets = append(unsets, name)
+		} else {
+			sets = append(sets, name+"="+value)
+		}
+	}
+
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(conf.GetUser(username).Locale, "too_many_procs").(string)
+
+		return
+	}
+	defer release()
+
+	for _, goArgs := range [][]string{sets, unsets} {
+		if 3 > len(goArgs) {
+			continue
+		}
+
+		cmd := exec.Command("go", goArgs...)
+		cmd.Dir = filepath.SplitList(conf.GetUserWorkspace(username))[0]
+		setCmdEnv(cmd, username)
+
+		if output, err := cmd.CombinedOutput(); nil != err {
+			logger.Warnf("User [%s] failed to run [go %s]: %s", username, strings.Join(goArgs, " "), output)
+			result.Succ = false
+			result.Msg = strings.TrimSpace(string(output))
+
+			return
+		}
+	}
+
+	logger.Infof("User [%s] set go environment variables %v", username, names)
+}
+
+// getSettableGoEnvVars gets names of go environment variables the user specified by username could set.
+func getSettableGoEnvVars(username string) []string {
+	ret := append([]string{}, goEnvUserVars...)
+
+	if user := conf.GetUser(username); nil != user && user.IsAdmin() {
+		ret = append(ret, goEnvAdminVars...)
+		sort.Strings(ret)
+	}
+
+	return ret
+}
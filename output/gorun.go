@@ -0,0 +1,237 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"html"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// GoRunHandler handles request of compiling and running a main package in one step by `go run`.
+//
+// Argument "file" is a file of the main package, or argument "target" specifies the main package directory. Arguments
+// "args" and "env" update the run configuration of the package the same as RunHandler, the saved run configuration is
+// applied. Argument "profile" selects the build profile (see BuildProfileHandler), the last-used one of the package
+// is applied if absent. Optional argument "stdin" is fed to the standard input of the program.
+//
+// The combined output is pushed to the output channel as "run" and "run-done" messages like RunHandler. The process
+// could be stopped by StopHandler, the program started by `go run` is killed as well. The build artifacts are created
+// in a temporary directory (GOTMPDIR) which is removed after the process exited.
+func GoRunHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+	user := conf.GetUser(username)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	sid, _ := args["sid"].(string)
+	wSession := session.WideSessions.Get(sid)
+	if nil == wSession || wSession.Username != username {
+		result.Succ = false
+
+		return
+	}
+
+	filePath, _ := args["file"].(string)
+	curDir := filepath.Dir(filepath.Clean(filepath.FromSlash(filePath)))
+	if target, _ := args["target"].(string); "" != target {
+		curDir = filepath.Clean(filepath.FromSlash(target))
+	}
+	if util.Go.IsAPI(curDir) || !session.CanAccess(username, curDir) || !util.File.IsDir(curDir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	_, hasArgs := args["args"]
+	_, hasEnv := args["env"]
+	if hasArgs || hasEnv {
+		runConf, err := parseRunConf(args)
+		if nil != err {
+			result.Succ = false
+			result.Msg = err.Error()
+
+			return
+		}
+
+		saveRunConf(user, curDir, runConf)
+	}
+	runConf := getRunConf(user, curDir)
+
+	profile, err := selectBuildProfile(user, curDir, args)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+	profileArgs, profileEnv := buildProfileArgs(profile)
+
+	tmpDir, err := ioutil.TempDir("", "wide-go-run")
+	if nil != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	goArgs := append(append([]string{"run"}, profileArgs...), ".")
+	cmd := exec.Command("go", append(goArgs, runConf.Args...)...)
+	setCmdEnv(cmd, username)
+	cmd.Dir = curDir
+	cmd.Env = append(cmd.Env, profileEnv...)
+	cmd.Env = append(cmd.Env, "GOTMPDIR="+tmpDir)
+	cmd.Env = append(cmd.Env, toEnviron(runConf.Env)...)
+	if conf.Docker {
+		SetNamespace(cmd)
+	}
+	setProcessGroup(cmd)
+
+	if stdin, _ := args["stdin"].(string); "" != stdin {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if nil != err {
+		os.RemoveAll(tmpDir)
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+	cmd.Stderr = cmd.Stdout
+
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		os.RemoveAll(tmpDir)
+		result.Succ = false
+		result.Msg = i18n.Get(user.Locale, "too_many_procs").(string)
+
+		return
+	}
+
+	if err := cmd.Start(); nil != err {
+		release()
+		os.RemoveAll(tmpDir)
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	pid := cmd.Process.Pid
+	runLog := newRunLog(sid, pid, 1)
+	Processes.Add(wSession, cmd, ProcKindRun)
+
+	started := time.Now()
+	event.Publish(&event.Event{Code: event.EvtCodeRunStarted, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: curDir, Pid: pid}})
+
+	result.Data = map[string]interface{}{"pid": pid}
+
+	go func(runningId int) {
+		defer util.Recover()
+
+		logger.Debugf("User [%s, %s] is running [go run] [id=%d, dir=%s]", username, sid, runningId, curDir)
+
+		channelRet := map[string]interface{}{"cmd": "run", "pid": pid, "output": ""}
+		writeGoRunOutput(sid, channelRet) // for front-end to get the 'run' state and pid
+
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadString('\n')
+			if "" != line {
+				runLog.write(line)
+
+				channelRet["output"] = html.EscapeString(line)
+				writeGoRunOutput(sid, channelRet)
+			}
+
+			if nil != err {
+				if io.EOF != err {
+					logger.Warn(err)
+				}
+
+				break
+			}
+		}
+		runLog.done()
+
+		err := cmd.Wait()
+		release()
+		Processes.Remove(wSession, cmd.Process)
+		if err := os.RemoveAll(tmpDir); nil != err {
+			logger.Warn(err)
+		}
+
+		logger.Debugf("User [%s, %s] 's running [go run] [id=%d, dir=%s] has done [%v]", username, sid, runningId,
+			curDir, err)
+
+		event.Publish(&event.Event{Code: event.EvtCodeRunExited, Sid: sid,
+			Data: &event.Lifecycle{Username: username, Path: curDir, Pid: pid, Succ: nil == err,
+				Elapsed: time.Since(started)}})
+
+		channelRet["cmd"] = "run-done"
+		channelRet["output"] = ""
+		if nil != err {
+			channelRet["output"] = "<span class='stderr'>" + html.EscapeString(err.Error()) + "</span>\n"
+		}
+		writeGoRunOutput(sid, channelRet)
+	}(rand.Int())
+}
+
+// writeGoRunOutput pushes the specified message to the output channel of the session specified by sid.
+func writeGoRunOutput(sid string, channelRet map[string]interface{}) {
+	wsChannel := session.OutputWS[sid]
+	if nil == wsChannel {
+		return
+	}
+
+	if err := wsChannel.WriteJSON(&channelRet); nil != err {
+		logger.Warn(err)
+	}
+
+	wsChannel.Refresh()
+}
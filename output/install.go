@@ -23,7 +23,6 @@ import (
 	"net/http"
 	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
 
 	"github.com/b3log/wide/conf"
@@ -108,7 +107,16 @@ func GoInstallHandler(w http.ResponseWriter, r *http.Request) {
 
 	reader := bufio.NewReader(io.MultiReader(stdout, stderr))
 
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(locale, "too_many_procs").(string)
+
+		return
+	}
+
 	if err := cmd.Start(); nil != err {
+		release()
 		logger.Error(err)
 		result.Succ = false
 
@@ -117,6 +125,7 @@ func GoInstallHandler(w http.ResponseWriter, r *http.Request) {
 
 	go func(runningId int) {
 		defer util.Recover()
+		defer release()
 		defer cmd.Wait()
 
 		logger.Debugf("User [%s, %s] is running [go install] [id=%d, dir=%s]", username, sid, runningId, curDir)
@@ -133,49 +142,8 @@ func GoInstallHandler(w http.ResponseWriter, r *http.Request) {
 			errOut := string(buf)
 			lines := strings.Split(errOut, "\n")
 
-			if lines[0][0] == '#' {
-				lines = lines[1:] // skip the first line
-			}
-
-			lints := []*Lint{}
-
-			for _, line := range lines {
-				if len(line) < 1 {
-					continue
-				}
-
-				if line[0] == '\t' {
-					// append to the last lint
-					last := len(lints)
-					msg := lints[last-1].Msg
-					msg += line
-
-					lints[last-1].Msg = msg
-
-					continue
-				}
-
-				file := line[:strings.Index(line, ":")]
-				left := line[strings.Index(line, ":")+1:]
-				index := strings.Index(left, ":")
-				lineNo := 0
-				msg := left
-				if index >= 0 {
-					lineNo, _ = strconv.Atoi(left[:index])
-					msg = left[index+2:]
-				}
-
-				lint := &Lint{
-					File:     file,
-					LineNo:   lineNo - 1,
-					Severity: lintSeverityError,
-					Msg:      msg,
-				}
-
-				lints = append(lints, lint)
-			}
-
-			channelRet["lints"] = lints
+			channelRet["lints"] = parseCompilerLints(curDir, lines)
+			channelRet["raw"] = errOut
 
 			channelRet["output"] = "<span class='install-error'>" + i18n.Get(locale, "install-error").(string) + "</span>\n" + errOut
 		} else {
@@ -0,0 +1,68 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/b3log/wide/conf"
+)
+
+// errTooManyProcs indicates the concurrent process limit is reached.
+var errTooManyProcs = errors.New("too many concurrent operations")
+
+var (
+	runningProcs      = map[string]int{} // <username, count of running go processes>
+	runningProcsTotal int                // count of running go processes of all users
+	runningProcsMutex sync.Mutex
+)
+
+// AcquireProcSlot acquires a slot of running a go (or linter) process for the user specified by username, returns
+// errTooManyProcs if the limit of the user (conf.Wide.MaxUserProcs) or of all users (conf.Wide.MaxProcsTotal) is
+// reached.
+//
+// The returned function releases the slot, it must be called once the process exited or failed to start, calling it
+// more than once is safe.
+func AcquireProcSlot(username string) (func(), error) {
+	runningProcsMutex.Lock()
+	defer runningProcsMutex.Unlock()
+
+	if 0 < conf.Wide.MaxUserProcs && conf.Wide.MaxUserProcs <= runningProcs[username] {
+		return nil, errTooManyProcs
+	}
+
+	if 0 < conf.Wide.MaxProcsTotal && conf.Wide.MaxProcsTotal <= runningProcsTotal {
+		return nil, errTooManyProcs
+	}
+
+	runningProcs[username]++
+	runningProcsTotal++
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			runningProcsMutex.Lock()
+			defer runningProcsMutex.Unlock()
+
+			runningProcs[username]--
+			if 1 > runningProcs[username] {
+				delete(runningProcs, username)
+			}
+			runningProcsTotal--
+		})
+	}, nil
+}
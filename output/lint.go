@@ -0,0 +1,178 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// Matches a linter output line, such as "main.go:12:5: exported function Foo should have comment (golint)".
+var lintLineExp = regexp.MustCompile(`^(.+\.go):(\d+)(?::(\d+))?:\s*(.*)$`)
+
+// GoLintHandler handles request of running linter on the current package.
+//
+// golangci-lint will be used if it's installed, otherwise golint. The lints will be returned as JSON for gutter
+// display.
+func GoLintHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+	user := conf.GetUser(username)
+	locale := user.Locale
+
+	var args map[string]interface{}
+
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	filePath := args["file"].(string)
+	if util.Go.IsAPI(filePath) || !session.CanAccess(username, filePath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	curDir := filepath.Dir(filePath)
+
+	linter, linterPath := getLinter()
+	if "" == linter {
+		result.Succ = false
+		result.Msg = conf.GetDevToolHint(locale, "golangci-lint")
+
+		return
+	}
+
+	argv := []string{"."}
+	if "golangci-lint" == linter {
+		argv = []string{"run"}
+
+		if lintConf := user.LintConf; "" != lintConf {
+			if !filepath.IsAbs(lintConf) {
+				lintConf = filepath.Join(curDir, lintConf)
+			}
+
+			if !session.CanAccess(username, lintConf) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+
+				return
+			}
+
+			argv = append(argv, "--config", lintConf)
+		}
+
+		argv = append(argv, ".")
+	}
+
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(locale, "too_many_procs").(string)
+
+		return
+	}
+	defer release()
+
+	cmd := exec.Command(linterPath, argv...)
+	cmd.Dir = curDir
+
+	setCmdEnv(cmd, username)
+
+	// linters exit with non-zero code if found issues, so just ignore the error here
+	out, _ := cmd.CombinedOutput()
+
+	logger.Debugf("User [%s] ran [%s] in [%s]", username, linter, curDir)
+
+	data := map[string]interface{}{}
+	result.Data = &data
+
+	data["linter"] = linter
+	data["lints"] = parseLints(curDir, string(out))
+}
+
+// getLinter gets the name and path of the available linter, returns "", "" if not found.
+//
+// golangci-lint is preferred to golint.
+func getLinter() (name, path string) {
+	for _, linter := range []string{"golangci-lint", "golint"} {
+		p := util.Go.GetExecutableInGOBIN(linter)
+		if util.File.IsExist(p) {
+			return linter, p
+		}
+
+		if p, err := exec.LookPath(linter); nil == err {
+			return linter, p
+		}
+	}
+
+	return "", ""
+}
+
+// parseLints parses the specified linter output into lints, file paths will be resolved against the specified curDir.
+func parseLints(curDir, output string) []*Lint {
+	ret := []*Lint{}
+
+	for _, line := range strings.Split(output, "\n") {
+		parts := lintLineExp.FindStringSubmatch(strings.TrimSpace(line))
+		if nil == parts {
+			continue
+		}
+
+		file := parts[1]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(curDir, file)
+		}
+
+		lineNo, _ := strconv.Atoi(parts[2])
+		column, _ := strconv.Atoi(parts[3])
+		msg := parts[4]
+
+		severity := lintSeverityWarn
+		if strings.HasSuffix(msg, "(typecheck)") {
+			severity = lintSeverityError
+		}
+
+		ret = append(ret, &Lint{
+			File:     filepath.ToSlash(file),
+			LineNo:   lineNo - 1,
+			Column:   column - 1,
+			Severity: severity,
+			Msg:      msg,
+		})
+	}
+
+	return ret
+}
@@ -17,9 +17,21 @@
 package output
 
 import (
+	"errors"
+	"os"
 	"os/exec"
 )
 
 func SetNamespace(cmd *exec.Cmd) {
 	// do nothing
 }
+
+// isolate returns an error since user namespaces are only supported on Linux.
+func isolate(cmd *exec.Cmd, dir string, network bool) error {
+	return errors.New("sandboxing is not supported on this platform")
+}
+
+// sandboxUser gets the user and group IDs of Wide since isolate is not supported.
+func sandboxUser() (uid, gid int) {
+	return os.Getuid(), os.Getgid()
+}
@@ -15,8 +15,11 @@
 package output
 
 import (
+	"os"
 	"os/exec"
 	"syscall"
+
+	"github.com/b3log/wide/conf"
 )
 
 func SetNamespace(cmd *exec.Cmd) {
@@ -30,3 +33,42 @@ func SetNamespace(cmd *exec.Cmd) {
 	// cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: 1001, Size: 1}}
 	// cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: 1001, Size: 1}}
 }
+
+// isolate runs the process of the specified command in a new user namespace, chrooted into the specified directory
+// (so the executable must be statically linked and its path relative to the directory) as an unprivileged user with
+// no capabilities. The process has no network but loopback (which is down) either unless the specified network is
+// true.
+//
+// The unprivileged user (see sandboxUser) is mapped to "nobody" in the namespace.
+func isolate(cmd *exec.Cmd, dir string, network bool) error {
+	uid, gid := sandboxUser()
+
+	if nil == cmd.SysProcAttr {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER
+	if !network {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	cmd.SysProcAttr.Chroot = dir
+	cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 65534, HostID: uid, Size: 1}}
+	cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 65534, HostID: gid, Size: 1}}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: 65534, Gid: 65534}
+
+	return nil
+}
+
+// sandboxUser gets the user and group IDs processes run by isolate run as: the user of SetNamespace if runs via Docker,
+// "nobody" if Wide runs as root, or the user of Wide otherwise.
+func sandboxUser() (uid, gid int) {
+	if conf.Docker {
+		return 1001, 1001
+	}
+
+	if uid, gid = os.Getuid(), os.Getgid(); 0 == uid {
+		return 65534, 65534
+	}
+
+	return
+}
@@ -21,7 +21,6 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"strconv"
 	"strings"
 	"time"
 
@@ -44,6 +43,7 @@ var logger = log.NewLogger(os.Stdout)
 type Lint struct {
 	File     string `json:"file"`
 	LineNo   int    `json:"lineNo"`
+	Column   int    `json:"column"`
 	Severity string `json:"severity"`
 	Msg      string `json:"msg"`
 }
@@ -68,51 +68,50 @@ func WSHandler(w http.ResponseWriter, r *http.Request) {
 
 // parsePath parses file path in the specified outputLine, and returns new line with front-end friendly.
 func parsePath(curDir, outputLine string) string {
-	index := strings.Index(outputLine, " ")
-	if -1 == index || index >= len(outputLine) {
+	matches := compilerErrRegexp.FindStringSubmatch(strings.TrimRight(outputLine, "\r\n"))
+	if nil == matches {
 		return outputLine
 	}
 
-	pathPart := outputLine[:index]
-	msgPart := outputLine[index:]
-
-	parts := strings.Split(pathPart, ":")
-	if len(parts) < 2 { // no file path info (line & column) found
-		return outputLine
-	}
-
-	file := parts[0]
-	line := parts[1]
-	if _, err := strconv.Atoi(line); nil != err {
-		return outputLine
+	file, line, column := matches[1], matches[2], matches[3]
+	text := file + ":" + line
+	if "" == column {
+		column = "0"
+	} else {
+		text += ":" + column
 	}
 
-	column := "0"
-	hasColumn := 4 == len(parts)
-	if hasColumn {
-		column = parts[2]
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(curDir, path)
 	}
 
-	tagStart := `<span class="path" data-path="` + filepath.ToSlash(filepath.Join(curDir, file)) + `" data-line="` + line +
+	tagStart := `<span class="path" data-path="` + filepath.ToSlash(path) + `" data-line="` + line +
 		`" data-column="` + column + `">`
-	text := file + ":" + line
-	if hasColumn {
-		text += ":" + column
-	}
 	tagEnd := "</span>:"
 
-	return tagStart + text + tagEnd + msgPart
+	return tagStart + text + tagEnd + outputLine[len(text)+1:]
 }
 
 func setCmdEnv(cmd *exec.Cmd, username string) {
 	userWorkspace := conf.GetUserWorkspace(username)
+	goRoot := conf.GetGoRoot(username)
+
+	// uses the go command of the toolchain selected by the user
+	if "go" == cmd.Args[0] {
+		cmd.Path = conf.GetGoExecutable(goRoot)
+	}
 
 	cmd.Env = append(cmd.Env,
 		"GOPATH="+userWorkspace,
 		"GOOS="+runtime.GOOS,
 		"GOARCH="+runtime.GOARCH,
-		"GOROOT="+runtime.GOROOT(),
-		"PATH="+os.Getenv("PATH"))
+		"GOROOT="+goRoot,
+		"PATH="+filepath.Join(goRoot, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	if user := conf.GetUser(username); nil != user {
+		cmd.Env = append(cmd.Env, "GOENV="+user.GoEnvPath())
+	}
 
 	if util.OS.IsWindows() {
 		// FIXME: for some weird issues on Windows, such as: The requested service provider could not be loaded or initialized.
@@ -0,0 +1,38 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package output
+
+import "os"
+
+// listeningPorts returns the TCP ports listened by the process group led by the specified pid, port detection is
+// only supported on Linux.
+func listeningPorts(pid int) []int {
+	return nil
+}
+
+// heldPorts returns the ports of the specified ports which are still listened by any process, port detection is only
+// supported on Linux.
+func heldPorts(ports []int) []int {
+	return nil
+}
+
+// isGroupAlive checks whether the process group led by the specified process is still running, only the leader is
+// checked on platforms other than Linux.
+func isGroupAlive(p *os.Process) bool {
+	return isProcessAlive(p)
+}
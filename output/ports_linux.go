@@ -0,0 +1,155 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the state of a listening socket in /proc/net/tcp.
+const tcpListen = "0A"
+
+// listeningPorts returns the TCP ports listened by the process group led by the specified pid (the program started
+// by `go run` is a child of the go command, so the whole group is inspected).
+func listeningPorts(pid int) []int {
+	inodes := map[string]bool{}
+	for _, member := range groupMembers(pid) {
+		fds, err := ioutil.ReadDir("/proc/" + strconv.Itoa(member) + "/fd")
+		if nil != err {
+			continue
+		}
+
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(member), "fd", fd.Name()))
+			if nil != err || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+
+			inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] = true
+		}
+	}
+
+	if 0 == len(inodes) {
+		return nil
+	}
+
+	return tcpListenPorts(func(inode string, port int) bool { return inodes[inode] })
+}
+
+// heldPorts returns the ports of the specified ports which are still listened by any process.
+func heldPorts(ports []int) []int {
+	if 0 == len(ports) {
+		return nil
+	}
+
+	wanted := map[int]bool{}
+	for _, port := range ports {
+		wanted[port] = true
+	}
+
+	return tcpListenPorts(func(inode string, port int) bool { return wanted[port] })
+}
+
+// isGroupAlive checks whether any process (zombies excluded) of the process group led by the specified process is
+// still running.
+func isGroupAlive(p *os.Process) bool {
+	return 0 < len(groupMembers(p.Pid))
+}
+
+// groupMembers returns pids of the living (not zombie) processes in the process group specified by pgid.
+func groupMembers(pgid int) (ret []int) {
+	dirs, err := ioutil.ReadDir("/proc")
+	if nil != err {
+		return
+	}
+
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(dir.Name())
+		if nil != err {
+			continue
+		}
+
+		stat, err := ioutil.ReadFile("/proc/" + dir.Name() + "/stat")
+		if nil != err {
+			continue
+		}
+
+		// pid (comm) state ppid pgrp ..., comm may contain spaces and parentheses
+		s := string(stat)
+		i := strings.LastIndex(s, ")")
+		if 0 > i {
+			continue
+		}
+
+		fields := strings.Fields(s[i+1:])
+		if 3 > len(fields) || "Z" == fields[0] {
+			continue
+		}
+
+		if strconv.Itoa(pgid) == fields[2] {
+			ret = append(ret, pid)
+		}
+	}
+
+	return
+}
+
+// tcpListenPorts returns the sorted distinct listening ports in /proc/net/tcp and /proc/net/tcp6 accepted by the
+// specified filter.
+func tcpListenPorts(accept func(inode string, port int) bool) []int {
+	found := map[int]bool{}
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(table)
+		if nil != err {
+			continue
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // header
+		for scanner.Scan() {
+			// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+			fields := strings.Fields(scanner.Text())
+			if 10 > len(fields) || tcpListen != fields[3] {
+				continue
+			}
+
+			local := fields[1]
+			port, err := strconv.ParseInt(local[strings.LastIndex(local, ":")+1:], 16, 32)
+			if nil != err {
+				continue
+			}
+
+			if accept(fields[9], int(port)) {
+				found[int(port)] = true
+			}
+		}
+
+		f.Close()
+	}
+
+	var ret []int
+	for port := range found {
+		ret = append(ret, port)
+	}
+	sort.Ints(ret)
+
+	return ret
+}
@@ -0,0 +1,284 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// fileProblems represents problems of a file.
+type fileProblems struct {
+	File     string  `json:"file"`
+	Problems []*Lint `json:"problems"`
+}
+
+// problemsCheck represents a running problems check.
+type problemsCheck struct {
+	cancel context.CancelFunc
+}
+
+// Running problems checks. <sid, *problemsCheck>
+var problemsChecks = map[string]*problemsCheck{}
+
+// Exclusive lock of problemsChecks.
+var problemsMutex sync.Mutex
+
+// ProblemsHandler handles request of checking problems of the whole workspace.
+//
+// Runs `go build ./...` and `go vet ./...` (if argument "vet" is true) in the directory specified by argument "dir"
+// (defaults to the src directory of the user workspace), returns problems grouped by file. Compile errors are parsed
+// the same way as BuildHandler.
+//
+// The progress (the package being checked) is pushed to the output channel, and the check could be cancelled by
+// ProblemsCancelHandler. A new check of the same session cancels the running one.
+func ProblemsHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	sid := args["sid"].(string)
+	if wSession := session.WideSessions.Get(sid); nil == wSession || wSession.Username != username {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	dir, _ := args["dir"].(string)
+	if "" == dir {
+		dir = filepath.Join(filepath.SplitList(conf.GetUserWorkspace(username))[0], "src")
+	}
+	dir = filepath.Clean(filepath.FromSlash(dir))
+	if !session.CanAccess(username, dir) || !util.File.IsDir(dir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(conf.GetUser(username).Locale, "too_many_procs").(string)
+
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	check := &problemsCheck{cancel: cancel}
+	problemsMutex.Lock()
+	if running, ok := problemsChecks[sid]; ok {
+		running.cancel()
+	}
+	problemsChecks[sid] = check
+	problemsMutex.Unlock()
+
+	defer func() {
+		problemsMutex.Lock()
+		if check == problemsChecks[sid] { // keeps a newer check
+			delete(problemsChecks, sid)
+		}
+		problemsMutex.Unlock()
+	}()
+
+	commands := [][]string{{"build", "./..."}}
+	if vet, _ := args["vet"].(bool); vet {
+		commands = append(commands, []string{"vet", "./..."})
+	}
+
+	lints := []*Lint{}
+	for _, goArgs := range commands {
+		found, err := checkProblems(ctx, sid, username, dir, goArgs)
+		if nil != err {
+			logger.Error(err)
+			result.Succ = false
+
+			return
+		}
+
+		if "vet" == goArgs[0] {
+			for _, lint := range found {
+				lint.Severity = lintSeverityWarn
+			}
+		}
+
+		lints = append(lints, found...)
+	}
+
+	result.Data = map[string]interface{}{"files": groupProblems(lints), "total": len(lints),
+		"cancelled": nil != ctx.Err()}
+}
+
+// ProblemsCancelHandler handles request of cancelling the running problems check of the session specified by
+// argument "sid", the session must belong to the session user.
+func ProblemsCancelHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	sid, _ := args["sid"].(string)
+	if wSession := session.WideSessions.Get(sid); nil == wSession || wSession.Username != username {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	problemsMutex.Lock()
+	defer problemsMutex.Unlock()
+
+	if check, ok := problemsChecks[sid]; ok {
+		check.cancel()
+		delete(problemsChecks, sid)
+	}
+}
+
+// checkProblems runs go command with the specified arguments in the specified directory, returns the problems found.
+//
+// Package headers ("# package") of the output are pushed to the output channel of the session specified by sid as
+// the progress.
+func checkProblems(ctx context.Context, sid, username, dir string, goArgs []string) ([]*Lint, error) {
+	cmd := exec.CommandContext(ctx, "go", goArgs...)
+	cmd.Dir = dir
+	setCmdEnv(cmd, username)
+
+	stdout, err := cmd.StdoutPipe()
+	if nil != err {
+		return nil, err
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); nil != err {
+		return nil, err
+	}
+
+	channelRet := map[string]interface{}{"cmd": "problems", "phase": goArgs[0]}
+
+	lines := []string{}
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadString('\n')
+		if "" != line {
+			lines = append(lines, line)
+
+			if strings.HasPrefix(line, "# ") {
+				if wsChannel := session.OutputWS[sid]; nil != wsChannel {
+					channelRet["package"] = strings.TrimSpace(line[2:])
+					if err := wsChannel.WriteJSON(&channelRet); nil != err {
+						logger.Warn(err)
+					}
+
+					wsChannel.Refresh()
+				}
+			}
+		}
+
+		if nil != err {
+			if io.EOF != err {
+				logger.Warn(err)
+			}
+
+			break
+		}
+	}
+
+	cmd.Wait() // exits with non-zero if there are problems
+
+	return parseCompilerLints(dir, lines), nil
+}
+
+// groupProblems deduplicates the specified problems and groups them by file, files and problems are sorted.
+func groupProblems(lints []*Lint) []*fileProblems {
+	seen := map[string]bool{}
+	files := map[string]*fileProblems{}
+	for _, lint := range lints {
+		key := lint.File + ":" + strconv.Itoa(lint.LineNo) + ":" + strconv.Itoa(lint.Column) + ":" + lint.Msg
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		fp, ok := files[lint.File]
+		if !ok {
+			fp = &fileProblems{File: lint.File, Problems: []*Lint{}}
+			files[lint.File] = fp
+		}
+
+		fp.Problems = append(fp.Problems, lint)
+	}
+
+	ret := []*fileProblems{}
+	for _, fp := range files {
+		sort.SliceStable(fp.Problems, func(i, j int) bool {
+			a, b := fp.Problems[i], fp.Problems[j]
+			if a.LineNo != b.LineNo {
+				return a.LineNo < b.LineNo
+			}
+
+			return a.Column < b.Column
+		})
+
+		ret = append(ret, fp)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].File < ret[j].File })
+
+	return ret
+}
@@ -15,12 +15,37 @@
 package output
 
 import (
+	"encoding/json"
+	"net/http"
 	"os"
+	"os/exec"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/b3log/wide/conf"
 	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
 )
 
+// Kinds of processes.
+const (
+	ProcKindBuild = "build"
+	ProcKindRun   = "run"
+	ProcKindTest  = "test"
+	ProcKindShell = "shell"
+)
+
+// ProcessInfo represents a process of a session.
+type ProcessInfo struct {
+	Pid     int       `json:"pid"`
+	Kind    string    `json:"kind"`    // ProcKindBuild/ProcKindRun/ProcKindTest/ProcKindShell
+	Command string    `json:"command"` // command line
+	Started time.Time `json:"started"`
+	Status  string    `json:"status"` // "running", or "exited" if the process is gone but not reaped yet
+}
+
 // Type of process set.
 type procs map[string][]*os.Process
 
@@ -29,15 +54,23 @@ type procs map[string][]*os.Process
 // <sid, []*os.Process>
 var Processes = procs{}
 
+// Information of processes.
+//
+// <pid, *ProcessInfo>
+var processInfos = map[int]*ProcessInfo{}
+
 // Exclusive lock.
 var mutex sync.Mutex
 
-// Add adds the specified process to the user process set.
-func (procs *procs) Add(wSession *session.WideSession, proc *os.Process) {
+// Add adds the process of the specified started command of the specified kind to the user process set.
+func (procs *procs) Add(wSession *session.WideSession, cmd *exec.Cmd, kind string) {
 	mutex.Lock()
 	defer mutex.Unlock()
 
 	sid := wSession.ID
+	proc := cmd.Process
+	processInfos[proc.Pid] = &ProcessInfo{Pid: proc.Pid, Kind: kind, Command: strings.Join(cmd.Args, " "),
+		Started: time.Now()}
 	userProcesses := (*procs)[sid]
 
 	userProcesses = append(userProcesses, proc)
@@ -49,12 +82,26 @@ func (procs *procs) Add(wSession *session.WideSession, proc *os.Process) {
 	logger.Tracef("Session [%s] has [%d] processes", sid, len((*procs)[sid]))
 }
 
+// Track adds the process of the specified started command of the specified kind to the process set of the session
+// specified by sid if the session exists, returns the function removing it after the process exited.
+func (procs *procs) Track(sid string, cmd *exec.Cmd, kind string) func() {
+	wSession := session.WideSessions.Get(sid)
+	if nil == wSession {
+		return func() {}
+	}
+
+	procs.Add(wSession, cmd, kind)
+
+	return func() { procs.Remove(wSession, cmd.Process) }
+}
+
 // Remove removes the specified process from the user process set.
 func (procs *procs) Remove(wSession *session.WideSession, proc *os.Process) {
 	mutex.Lock()
 	defer mutex.Unlock()
 
 	sid := wSession.ID
+	delete(processInfos, proc.Pid)
 
 	userProcesses := (*procs)[sid]
 
@@ -85,13 +132,14 @@ func (procs *procs) Kill(wSession *session.WideSession, pid int) {
 
 	for i, p := range userProcesses {
 		if p.Pid == pid {
-			if err := p.Kill(); nil != err {
+			if err := killProcess(p); nil != err {
 				logger.Errorf("Kill a process [pid=%d] of user [%s, %s] failed [error=%v]", pid, wSession.Username, sid, err)
 			} else {
 				var newProcesses []*os.Process
 
 				newProcesses = append(userProcesses[:i], userProcesses[i+1:]...)
 				(*procs)[sid] = newProcesses
+				delete(processInfos, pid)
 
 				// bind process with wide session
 				wSession.SetProcesses(newProcesses)
@@ -103,3 +151,165 @@ func (procs *procs) Kill(wSession *session.WideSession, pid int) {
 		}
 	}
 }
+
+// stopConfirmTimeout is the max duration to wait for the process group to exit after it has been killed.
+const stopConfirmTimeout = 3 * time.Second
+
+// StopResult represents the result of stopping a process.
+type StopResult struct {
+	Killed bool  // whether the process is killed (not exited on interrupt in the grace period)
+	Exited bool  // whether the process and its children have exited actually
+	Ports  []int // TCP ports listened by the process and its children before stopping
+	Held   []int // ports of Ports still listened by any process after stopping
+}
+
+// Stop stops a process specified by the given pid gracefully: interrupts it (Ctrl-C) first, kills it if it's still
+// running after the specified grace period, then confirms the process and its children have exited. Returns nil if
+// the process is not found.
+func (procs *procs) Stop(wSession *session.WideSession, pid int, grace time.Duration) *StopResult {
+	p := procs.get(wSession.ID, pid)
+	if nil == p {
+		return nil
+	}
+
+	ret := &StopResult{Ports: listeningPorts(pid)}
+
+	if err := interruptProcess(p); nil != err {
+		logger.Debugf("Interrupt a process [pid=%d] of user [%s, %s] failed [error=%v]", pid, wSession.Username,
+			wSession.ID, err)
+	} else if waitGroupExit(p, grace) {
+		logger.Debugf("Interrupted a process [pid=%d] of user [%s, %s]", pid, wSession.Username, wSession.ID)
+
+		ret.Exited = true
+	}
+
+	if !ret.Exited {
+		ret.Killed = true
+		procs.Kill(wSession, pid)
+		if isGroupAlive(p) { // the process may have been removed from the session while its children are running
+			killProcess(p)
+		}
+
+		ret.Exited = waitGroupExit(p, stopConfirmTimeout)
+		if !ret.Exited {
+			logger.Warnf("Process [pid=%d] of user [%s, %s] is still running after killed", pid, wSession.Username,
+				wSession.ID)
+		}
+	}
+
+	ret.Held = heldPorts(ret.Ports)
+
+	return ret
+}
+
+// waitGroupExit waits the process group led by the specified process to exit in the specified timeout, returns
+// whether it has exited.
+func waitGroupExit(p *os.Process, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if !isGroupAlive(p) {
+			return true
+		}
+
+		if !time.Now().Before(deadline) {
+			return false
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// get gets the process specified by the given pid of the session specified by sid, returns nil if not found.
+func (procs *procs) get(sid string, pid int) *os.Process {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, p := range (*procs)[sid] {
+		if p.Pid == pid {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// KillAll kills all processes of all users, it's used while shutting down the server.
+func (procs *procs) KillAll() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for sid, userProcesses := range *procs {
+		for _, p := range userProcesses {
+			if err := killProcess(p); nil != err {
+				logger.Warnf("Kill a process [pid=%d] of session [%s] failed [error=%v]", p.Pid, sid, err)
+			}
+		}
+
+		delete(*procs, sid)
+	}
+
+	processInfos = map[int]*ProcessInfo{}
+}
+
+// List lists the processes of the session specified by sid, the earliest started first.
+func (procs *procs) List(sid string) []*ProcessInfo {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	ret := []*ProcessInfo{}
+	for _, p := range (*procs)[sid] {
+		info := ProcessInfo{Pid: p.Pid, Status: "running"}
+		if i := processInfos[p.Pid]; nil != i {
+			info = *i
+		}
+		if !isProcessAlive(p) {
+			info.Status = "exited"
+		}
+
+		ret = append(ret, &info)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Started.Before(ret[j].Started) })
+
+	return ret
+}
+
+// ProcessesHandler handles request of listing the processes (build, run, test and shell) of the session specified
+// by argument "sid", the session must be of the user unless the user is an admin.
+func ProcessesHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	sid, _ := args["sid"].(string)
+	wSession := session.WideSessions.Get(sid)
+	if nil == wSession {
+		result.Succ = false
+		result.Msg = "session [" + sid + "] not found"
+
+		return
+	}
+
+	if user := conf.GetUser(username); wSession.Username != username && (nil == user || !user.IsAdmin()) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	result.Data = Processes.List(sid)
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package output
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup makes the process of the specified command lead a new process group, so its children (the program
+// started by `go run` for example) could be killed together.
+func setProcessGroup(cmd *exec.Cmd) {
+	if nil == cmd.SysProcAttr {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// killProcess kills the process group led by the specified process, or the process only if it doesn't lead a group.
+func killProcess(p *os.Process) error {
+	if err := syscall.Kill(-p.Pid, syscall.SIGKILL); nil == err {
+		return nil
+	}
+
+	return p.Kill()
+}
+
+// isProcessAlive checks whether the specified process is still running (or not reaped yet).
+func isProcessAlive(p *os.Process) bool {
+	return nil == p.Signal(syscall.Signal(0))
+}
+
+// interruptProcess sends SIGINT to the process group led by the specified process, or to the process only if it
+// doesn't lead a group.
+func interruptProcess(p *os.Process) error {
+	if err := syscall.Kill(-p.Pid, syscall.SIGINT); nil == err {
+		return nil
+	}
+
+	return p.Signal(os.Interrupt)
+}
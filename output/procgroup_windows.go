@@ -0,0 +1,63 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setProcessGroup makes the process of the specified command lead a new console process group, so it could receive
+// CTRL_BREAK_EVENT alone (see interruptProcess), killProcess kills the process tree.
+func setProcessGroup(cmd *exec.Cmd) {
+	if nil == cmd.SysProcAttr {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// procGenerateConsoleCtrlEvent is GenerateConsoleCtrlEvent of kernel32.dll.
+var procGenerateConsoleCtrlEvent = syscall.NewLazyDLL("kernel32.dll").NewProc("GenerateConsoleCtrlEvent")
+
+// interruptProcess sends CTRL_BREAK_EVENT (Ctrl-C is disabled in a new process group) to the process group led by
+// the specified process, or asks the process tree to close (taskkill without /F) if the event can't be sent (the
+// server has no console for example).
+func interruptProcess(p *os.Process) error {
+	if ok, _, _ := procGenerateConsoleCtrlEvent.Call(syscall.CTRL_BREAK_EVENT, uintptr(p.Pid)); 0 != ok {
+		return nil
+	}
+
+	return exec.Command("taskkill", "/T", "/PID", strconv.Itoa(p.Pid)).Run()
+}
+
+// killProcess kills the specified process and its children.
+func killProcess(p *os.Process) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(p.Pid)).Run(); nil == err {
+		return nil
+	}
+
+	return p.Kill()
+}
+
+// isProcessAlive checks whether the specified process is still running.
+func isProcessAlive(p *os.Process) bool {
+	out, err := exec.Command("tasklist", "/NH", "/FI", "PID eq "+strconv.Itoa(p.Pid)).Output()
+
+	return nil == err && strings.Contains(string(out), strconv.Itoa(p.Pid))
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+const (
+	profileMaxCount  = 32   // max count of custom build profiles of a user
+	profileMaxTags   = 32   // max count of build tags of a build profile
+	profileMaxLength = 1024 // max length of a flags value of a build profile
+)
+
+var (
+	// Valid build profile name.
+	profileNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,31}$`)
+
+	// Valid flags value, such as "-s -w -X 'main.version=1.0'", shell metacharacters are rejected.
+	profileFlagsRegexp = regexp.MustCompile(`^[A-Za-z0-9_ .,=/:+*@%'"-]*$`)
+
+	// Valid build tag.
+	profileTagRegexp = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+)
+
+// Environment variables a build profile can't set, they are set by Wide or could smuggle flags into the go command.
+var profileReservedEnv = []string{"GOFLAGS", "GOROOT", "GOPATH", "GOENV", "GOTMPDIR", "GOOS", "GOARCH", "PATH"}
+
+// BuildProfileHandler handles request of getting or updating the build profiles of the user and the one selected for
+// a package.
+//
+// Argument "path" is the package directory or a file of it. If argument "profiles" is present, the custom build
+// profiles are replaced, it's an array of {"name", "ldflags", "gcflags", "tags", "env"}. If argument "profile" is
+// present, it's selected for the package, empty means none.
+//
+// Data "profiles" is the built-in and custom build profiles, data "profile" is the name of the one selected for the
+// package.
+func BuildProfileHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	path, _ := args["path"].(string)
+	dir := filepath.Clean(filepath.FromSlash(path))
+	if !session.CanAccess(username, dir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	if !util.File.IsDir(dir) {
+		dir = filepath.Dir(dir)
+	}
+
+	user := conf.GetUser(username)
+
+	if _, ok := args["profiles"]; ok {
+		if session.IsReadOnlyRequest(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
+
+		profiles, err := parseBuildProfiles(args["profiles"])
+		if nil != err {
+			result.Succ = false
+			result.Msg = err.Error()
+
+			return
+		}
+
+		user.BuildProfiles = profiles
+		if !user.Save() {
+			logger.Errorf("Saves build profiles for user [%s] failed", username)
+		}
+	}
+
+	profile, err := selectBuildProfile(user, dir, args)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	name := ""
+	if nil != profile {
+		name = profile.Name
+	}
+
+	result.Data = map[string]interface{}{"profiles": user.GetBuildProfiles(), "profile": name}
+}
+
+// selectBuildProfile gets the build profile of the package specified by dir for the specified user.
+//
+// If argument "profile" is present in the specified request arguments, it's selected and remembered as the last-used
+// one of the package (empty means none), otherwise the last-used one is returned. Returns nil if none is selected.
+func selectBuildProfile(user *conf.User, dir string, args map[string]interface{}) (*conf.BuildProfile, error) {
+	name, ok := args["profile"].(string)
+	if !ok {
+		return user.GetBuildProfile(user.GetBuildProfileUse(dir)), nil
+	}
+
+	var ret *conf.BuildProfile
+	if "" != name {
+		if ret = user.GetBuildProfile(name); nil == ret {
+			return nil, errors.New("build profile [" + name + "] not found")
+		}
+	}
+
+	if user.SetBuildProfileUse(dir, name) && !user.Save() {
+		logger.Errorf("Saves build profile of [%s] for user [%s] failed", dir, user.Name)
+	}
+
+	return ret, nil
+}
+
+// buildProfileArgs gets the go command arguments and environment variables of the specified build profile, returns
+// empty ones if the profile is nil.
+func buildProfileArgs(profile *conf.BuildProfile) (args []string, env []string) {
+	if nil == profile {
+		return []string{}, []string{}
+	}
+
+	return profile.Args(), toEnviron(profile.Env)
+}
+
+// parseBuildProfiles parses and validates the specified custom build profiles.
+//
+// Flags values are passed to the go command as single arguments (never through a shell), characters out of the
+// common flag syntax are rejected anyway, so are environment variables set by Wide or smuggling flags (GOFLAGS).
+func parseBuildProfiles(v interface{}) ([]*conf.BuildProfile, error) {
+	ret := []*conf.BuildProfile{}
+	if nil == v {
+		return ret, nil
+	}
+
+	profiles, ok := v.([]interface{})
+	if !ok || len(profiles) > profileMaxCount {
+		return nil, errors.New("invalid build profiles")
+	}
+
+	names := map[string]bool{}
+	for _, p := range profiles {
+		m, ok := p.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("invalid build profile")
+		}
+
+		profile := &conf.BuildProfile{Tags: []string{}, Env: map[string]string{}}
+		profile.Name, _ = m["name"].(string)
+		if !profileNameRegexp.MatchString(profile.Name) || names[profile.Name] {
+			return nil, errors.New("invalid build profile name [" + profile.Name + "]")
+		}
+		names[profile.Name] = true
+
+		profile.LDFlags, _ = m["ldflags"].(string)
+		profile.GCFlags, _ = m["gcflags"].(string)
+		for _, flags := range []string{profile.LDFlags, profile.GCFlags} {
+			if len(flags) > profileMaxLength || !profileFlagsRegexp.MatchString(flags) {
+				return nil, errors.New("invalid flags [" + flags + "] of build profile [" + profile.Name + "]")
+			}
+		}
+
+		if nil != m["tags"] {
+			tags, ok := m["tags"].([]interface{})
+			if !ok || len(tags) > profileMaxTags {
+				return nil, errors.New("invalid tags of build profile [" + profile.Name + "]")
+			}
+
+			for _, t := range tags {
+				tag, _ := t.(string)
+				if !profileTagRegexp.MatchString(tag) {
+					return nil, errors.New("invalid tag [" + tag + "] of build profile [" + profile.Name + "]")
+				}
+
+				profile.Tags = append(profile.Tags, tag)
+			}
+		}
+
+		if nil != m["env"] {
+			env, ok := m["env"].(map[string]interface{})
+			if !ok || len(env) > runMaxEnv {
+				return nil, errors.New("invalid environment variables of build profile [" + profile.Name + "]")
+			}
+
+			for name, value := range env {
+				s, ok := value.(string)
+				reserved := util.Str.Contains(strings.ToUpper(name), profileReservedEnv)
+				if !ok || !envNameRegexp.MatchString(name) || reserved || len(s) > runMaxLength ||
+					strings.ContainsAny(s, "\x00\n") {
+					return nil, errors.New("invalid environment variable [" + name + "] of build profile [" +
+						profile.Name + "]")
+				}
+
+				profile.Env[name] = s
+			}
+		}
+
+		ret = append(ret, profile)
+	}
+
+	return ret, nil
+}
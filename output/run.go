@@ -17,14 +17,19 @@ package output
 import (
 	"bufio"
 	"encoding/json"
+	"html"
 	"math/rand"
 	"net/http"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/i18n"
 	"github.com/b3log/wide/session"
 	"github.com/b3log/wide/util"
 )
@@ -41,6 +46,9 @@ type outputBuf struct {
 }
 
 // RunHandler handles request of executing a binary file.
+//
+// The executable runs with the arguments and environment variables of the run configuration of its package (see
+// RunConfHandler), the run configuration is updated first if argument "args" or "env" is present.
 func RunHandler(w http.ResponseWriter, r *http.Request) {
 	result := util.NewResult()
 	defer util.RetResult(w, r, result)
@@ -64,6 +72,28 @@ func RunHandler(w http.ResponseWriter, r *http.Request) {
 	cmd := exec.Command(filePath)
 	cmd.Dir = curDir
 
+	if nil != wSession {
+		user := conf.GetUser(wSession.Username)
+
+		_, hasArgs := args["args"]
+		_, hasEnv := args["env"]
+		if hasArgs || hasEnv {
+			if runConf, err := parseRunConf(args); nil != err {
+				logger.Warn(err)
+				result.Succ = false
+				result.Msg = err.Error()
+			} else {
+				saveRunConf(user, curDir, runConf)
+			}
+		}
+
+		runConf := getRunConf(user, curDir)
+		cmd.Args = append(cmd.Args, runConf.Args...)
+		if 0 < len(runConf.Env) {
+			cmd.Env = append(os.Environ(), toEnviron(runConf.Env)...)
+		}
+	}
+
 	if conf.Docker {
 		SetNamespace(cmd)
 	}
@@ -83,9 +113,16 @@ func RunHandler(w http.ResponseWriter, r *http.Request) {
 	outReader := bufio.NewReader(stdout)
 	errReader := bufio.NewReader(stderr)
 
-	if err := cmd.Start(); nil != err {
-		logger.Error(err)
-		result.Succ = false
+	release := func() {}
+	if result.Succ {
+		if release, err = AcquireProcSlot(wSession.Username); nil != err {
+			result.Succ = false
+			result.Msg = i18n.Get(conf.GetUser(wSession.Username).Locale, "too_many_procs").(string)
+		} else if err := cmd.Start(); nil != err {
+			release()
+			logger.Error(err)
+			result.Succ = false
+		}
 	}
 
 	wsChannel := session.OutputWS[sid]
@@ -111,12 +148,25 @@ func RunHandler(w http.ResponseWriter, r *http.Request) {
 
 	channelRet["pid"] = cmd.Process.Pid
 
+	runLog := newRunLog(sid, cmd.Process.Pid, 2) // written by the stdout and stderr readers
+
 	// add the process to user's process set
-	Processes.Add(wSession, cmd.Process)
+	Processes.Add(wSession, cmd, ProcKindRun)
+
+	started := time.Now()
+	event.Publish(&event.Event{Code: event.EvtCodeRunStarted, Sid: sid,
+		Data: &event.Lifecycle{Username: wSession.Username, Path: filePath, Pid: cmd.Process.Pid}})
 
 	go func(runningId int) {
 		defer util.Recover()
-		defer cmd.Wait()
+		defer func() {
+			err := cmd.Wait()
+			release()
+
+			event.Publish(&event.Event{Code: event.EvtCodeRunExited, Sid: sid,
+				Data: &event.Lifecycle{Username: wSession.Username, Path: filePath, Pid: cmd.Process.Pid, Succ: nil == err,
+					Elapsed: time.Since(started)}})
+		}()
 
 		logger.Debugf("User [%s, %s] is running [id=%d, file=%s]", wSession.Username, sid, runningId, filePath)
 
@@ -135,6 +185,7 @@ func RunHandler(w http.ResponseWriter, r *http.Request) {
 
 		go func() {
 			defer util.Recover()
+			defer runLog.done()
 
 			buf := outputBuf{}
 			count := 0
@@ -147,6 +198,9 @@ func RunHandler(w http.ResponseWriter, r *http.Request) {
 
 				r, _, err := outReader.ReadRune()
 				count++
+				if nil == err {
+					runLog.write(string(r))
+				}
 
 				if nil != err {
 					// remove the exited process from user's process set
@@ -223,6 +277,9 @@ func RunHandler(w http.ResponseWriter, r *http.Request) {
 		buf := outputBuf{}
 		for {
 			r, _, err := errReader.ReadRune()
+			if nil == err {
+				runLog.write(string(r))
+			}
 
 			wsChannel := session.OutputWS[sid]
 			if nil != err || nil == wsChannel {
@@ -256,10 +313,14 @@ func RunHandler(w http.ResponseWriter, r *http.Request) {
 				wsChannel.Refresh()
 			}
 		}
+		runLog.done()
 	}(rand.Int())
 }
 
 // StopHandler handles request of stoping a running process.
+//
+// The process is interrupted (Ctrl-C) and given conf.Wide.StopGracePeriod to exit, then killed (see procs.Stop). How
+// it is stopped is reported over the output channel.
 func StopHandler(w http.ResponseWriter, r *http.Request) {
 	result := util.NewResult()
 	defer util.RetResult(w, r, result)
@@ -282,5 +343,51 @@ func StopHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	Processes.Kill(wSession, pid)
+	locale := conf.Wide.Locale
+	if user := conf.GetUser(wSession.Username); nil != user {
+		locale = user.Locale
+	}
+
+	go func() {
+		defer util.Recover()
+
+		stopped := Processes.Stop(wSession, pid, time.Duration(conf.Wide.StopGracePeriod)*time.Second)
+		if nil == stopped {
+			return
+		}
+
+		msgs := []string{i18n.Get(locale, "stop_interrupted").(string)}
+		if stopped.Killed {
+			msgs[0] = strings.Replace(i18n.Get(locale, "stop_killed").(string), "{grace}",
+				strconv.Itoa(conf.Wide.StopGracePeriod), 1)
+		}
+		if !stopped.Exited {
+			msgs = append(msgs, i18n.Get(locale, "stop_not_exited").(string))
+		}
+		if 0 < len(stopped.Held) {
+			msgs = append(msgs, strings.Replace(i18n.Get(locale, "stop_ports_held").(string), "{ports}",
+				joinPorts(stopped.Held), 1))
+		} else if 0 < len(stopped.Ports) {
+			msgs = append(msgs, strings.Replace(i18n.Get(locale, "stop_ports_freed").(string), "{ports}",
+				joinPorts(stopped.Ports), 1))
+		}
+
+		output := ""
+		for _, msg := range msgs {
+			output += "<span class='stderr'>" + html.EscapeString(msg) + "</span>\n"
+		}
+
+		writeGoRunOutput(sid, map[string]interface{}{"cmd": "run", "pid": pid, "output": output,
+			"exited": stopped.Exited, "ports": stopped.Ports, "heldPorts": stopped.Held})
+	}()
+}
+
+// joinPorts joins the specified ports with comma.
+func joinPorts(ports []int) string {
+	var ret []string
+	for _, port := range ports {
+		ret = append(ret, strconv.Itoa(port))
+	}
+
+	return strings.Join(ret, ", ")
 }
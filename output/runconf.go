@@ -0,0 +1,172 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+const (
+	runMaxArgs   = 256  // max count of run arguments
+	runMaxEnv    = 64   // max count of run environment variables
+	runMaxLength = 4096 // max length of an argument or an environment variable value
+)
+
+// Valid environment variable name.
+var envNameRegexp = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+// RunConfHandler handles request of getting or updating the run configuration (arguments and environment variables)
+// of a main package.
+//
+// Argument "path" is the package directory or a file of it. If argument "args" or "env" is present, the run
+// configuration is updated, "args" is an array passed to the executable as is (one element per argument, no shell
+// quoting or splitting, so spaces and quotes are kept literally), "env" is a map of environment variables.
+func RunConfHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	dir := filepath.Clean(filepath.FromSlash(args["path"].(string)))
+	if !session.CanAccess(username, dir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	if !util.File.IsDir(dir) {
+		dir = filepath.Dir(dir)
+	}
+
+	user := conf.GetUser(username)
+
+	_, hasArgs := args["args"]
+	_, hasEnv := args["env"]
+	if hasArgs || hasEnv {
+		if session.IsReadOnlyRequest(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
+
+		runConf, err := parseRunConf(args)
+		if nil != err {
+			result.Succ = false
+			result.Msg = err.Error()
+
+			return
+		}
+
+		saveRunConf(user, dir, runConf)
+	}
+
+	result.Data = getRunConf(user, dir)
+}
+
+// getRunConf gets the run configuration of the package specified by dir of the specified user.
+func getRunConf(user *conf.User, dir string) *conf.RunConf {
+	if ret := user.GetRunConf(dir); nil != ret {
+		return ret
+	}
+
+	return &conf.RunConf{Args: []string{}, Env: map[string]string{}}
+}
+
+// saveRunConf saves the specified run configuration of the package specified by dir for the specified user.
+func saveRunConf(user *conf.User, dir string, runConf *conf.RunConf) {
+	if 0 == len(runConf.Args) && 0 == len(runConf.Env) {
+		user.SetRunConf(dir, nil)
+	} else {
+		user.SetRunConf(dir, runConf)
+	}
+
+	if !user.Save() {
+		logger.Errorf("Saves run configuration of [%s] for user [%s] failed", dir, user.Name)
+	}
+}
+
+// parseRunConf parses and validates arguments "args" and "env" of the specified request arguments.
+func parseRunConf(args map[string]interface{}) (*conf.RunConf, error) {
+	ret := &conf.RunConf{Args: []string{}, Env: map[string]string{}}
+
+	if nil != args["args"] {
+		runArgs, ok := args["args"].([]interface{})
+		if !ok || len(runArgs) > runMaxArgs {
+			return nil, errors.New("invalid arguments")
+		}
+
+		for _, arg := range runArgs {
+			s, ok := arg.(string)
+			if !ok || len(s) > runMaxLength || strings.ContainsRune(s, 0) {
+				return nil, errors.New("invalid argument")
+			}
+
+			ret.Args = append(ret.Args, s)
+		}
+	}
+
+	if nil != args["env"] {
+		env, ok := args["env"].(map[string]interface{})
+		if !ok || len(env) > runMaxEnv {
+			return nil, errors.New("invalid environment variables")
+		}
+
+		for name, value := range env {
+			s, ok := value.(string)
+			if !ok || !envNameRegexp.MatchString(name) || len(s) > runMaxLength || strings.ContainsRune(s, 0) {
+				return nil, errors.New("invalid environment variable [" + name + "]")
+			}
+
+			ret.Env[name] = s
+		}
+	}
+
+	return ret, nil
+}
+
+// toEnviron converts the specified environment variables to the "key=value" form, sorted by key.
+func toEnviron(env map[string]string) []string {
+	ret := []string{}
+	for name, value := range env {
+		ret = append(ret, name+"="+value)
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/b3log/wide/session"
+)
+
+// runLogMaxSize is the max size of a run log file, the log is rotated to {file}.1 when exceeding, so at most
+// 2 * runLogMaxSize of the latest output is kept.
+const runLogMaxSize = 8 * 1024 * 1024
+
+// runLogDir is the directory of run logs.
+var runLogDir = filepath.Join(os.TempDir(), "wide-run-logs")
+
+// runLog represents the captured output (stdout and stderr) of a run.
+type runLog struct {
+	sid   string
+	pid   int
+	path  string
+	file  *os.File
+	buf   *bufio.Writer // buffers writes of the file
+	size  int64
+	refs  int // count of readers writing to the log, the file is closed when all of them are done
+	mutex sync.Mutex
+}
+
+// Run logs of the current or the last run of sessions, <sid, log>.
+var runLogs = map[string]*runLog{}
+
+// Exclusive lock of runLogs.
+var runLogsMutex sync.Mutex
+
+// newRunLog creates the run log of the process specified by pid for the session specified by sid, written by the
+// specified count of readers, the log of the last run of the session is removed.
+//
+// Logs of removed sessions are cleaned up as well.
+func newRunLog(sid string, pid, readers int) *runLog {
+	runLogsMutex.Lock()
+	defer runLogsMutex.Unlock()
+
+	for s, l := range runLogs {
+		if s != sid && nil == session.WideSessions.Get(s) {
+			l.remove()
+			delete(runLogs, s)
+		}
+	}
+
+	if last := runLogs[sid]; nil != last {
+		last.remove()
+		delete(runLogs, sid)
+	}
+
+	if err := os.MkdirAll(runLogDir, 0755); nil != err {
+		logger.Error(err)
+
+		return nil
+	}
+
+	path := filepath.Join(runLogDir, sid+".log")
+	f, err := os.Create(path)
+	if nil != err {
+		logger.Error(err)
+
+		return nil
+	}
+
+	ret := &runLog{sid: sid, pid: pid, path: path, file: f, buf: bufio.NewWriter(f), refs: readers}
+	runLogs[sid] = ret
+
+	return ret
+}
+
+// write writes the specified output to the log, rotates the log file if it exceeds runLogMaxSize.
+func (l *runLog) write(output string) {
+	if nil == l {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if nil == l.file {
+		return
+	}
+
+	if runLogMaxSize < l.size+int64(len(output)) {
+		l.closeFile()
+		os.Rename(l.path, l.path+".1")
+
+		f, err := os.Create(l.path)
+		if nil != err {
+			logger.Error(err)
+
+			return
+		}
+
+		l.file = f
+		l.buf = bufio.NewWriter(f)
+		l.size = 0
+	}
+
+	n, _ := l.buf.WriteString(output)
+	l.size += int64(n)
+}
+
+// done marks a reader done, closes the file if all readers are done.
+func (l *runLog) done() {
+	if nil == l {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.refs--
+	if 1 > l.refs {
+		l.closeFile()
+	}
+}
+
+// flush flushes buffered output to the log file.
+func (l *runLog) flush() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if nil != l.file {
+		l.buf.Flush()
+	}
+}
+
+// closeFile flushes and closes the log file, the caller must hold the lock.
+func (l *runLog) closeFile() {
+	if nil != l.file {
+		l.buf.Flush()
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// remove closes and removes the log files.
+func (l *runLog) remove() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.closeFile()
+	os.Remove(l.path)
+	os.Remove(l.path + ".1")
+}
+
+// RunLogHandler handles request of downloading the complete output log of the current or the last run of the session
+// specified by argument "sid".
+func RunLogHandler(w http.ResponseWriter, r *http.Request) {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	sid := r.URL.Query().Get("sid")
+	if wSession := session.WideSessions.Get(sid); nil == wSession || wSession.Username != username {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	runLogsMutex.Lock()
+	l := runLogs[sid]
+	runLogsMutex.Unlock()
+	if nil == l {
+		http.Error(w, "Not Found", http.StatusNotFound)
+
+		return
+	}
+
+	l.flush()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=run-"+strconv.Itoa(l.pid)+".log")
+
+	for _, path := range []string{l.path + ".1", l.path} { // the rotated one first
+		f, err := os.Open(path)
+		if nil != err {
+			continue
+		}
+
+		io.Copy(w, f)
+		f.Close()
+	}
+}
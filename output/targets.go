@@ -0,0 +1,120 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// BuildTargetsHandler handles request of listing main packages (directories containing a package main with a main
+// func) in the user workspace, which could be selected as the target of building and running.
+//
+// The data contains "targets" and "selected", the last selected target of the session.
+func BuildTargetsHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	targets := []string{}
+	for _, workspace := range filepath.SplitList(conf.GetUserWorkspace(username)) {
+		targets = append(targets, findMainPackages(filepath.Join(workspace, "src"))...)
+	}
+
+	selected := ""
+	if wSession := session.WideSessions.Get(r.URL.Query().Get("sid")); nil != wSession {
+		selected = filepath.ToSlash(wSession.BuildTarget)
+	}
+
+	result.Data = map[string]interface{}{"targets": targets, "selected": selected}
+}
+
+// findMainPackages finds directories of main packages under the specified root directory.
+//
+// Hidden, vendor and testdata directories are skipped.
+func findMainPackages(root string) []string {
+	ret := []string{}
+
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if nil != err {
+			return nil
+		}
+
+		name := info.Name()
+		if info.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || "vendor" == name ||
+				"testdata" == name) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if ".go" != filepath.Ext(name) || strings.HasSuffix(name, "_test.go") {
+			return nil
+		}
+
+		dir := filepath.ToSlash(filepath.Dir(path))
+		if 0 < len(ret) && dir == ret[len(ret)-1] { // found in this directory already
+			return nil
+		}
+
+		if hasMainFunc(path) {
+			ret = append(ret, dir)
+		}
+
+		return nil
+	})
+
+	return ret
+}
+
+// hasMainFunc checks whether the Go file specified by path is in package main and declares func main.
+func hasMainFunc(path string) bool {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.PackageClauseOnly)
+	if nil != err || "main" != f.Name.Name {
+		return false
+	}
+
+	f, err = parser.ParseFile(fset, path, nil, 0)
+	if nil != err {
+		return false
+	}
+
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && nil == fn.Recv && "main" == fn.Name.Name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -17,20 +17,33 @@ package output
 import (
 	"bufio"
 	"encoding/json"
+	"html"
 	"io"
-	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
 	"github.com/b3log/wide/i18n"
 	"github.com/b3log/wide/session"
 	"github.com/b3log/wide/util"
 )
 
+// testMaxRetries is the max count of retrying failed tests.
+const testMaxRetries = 5
+
 // GoTestHandler handles request of go test.
+//
+// Tests are run with -json, the human-readable output is streamed to the output channel and the last message contains
+// the structured results ("results", see testSummary) which distinguish a build failure from test failures.
+//
+// If argument "retries" is specified, failed tests are re-run (only them) up to the count of times, a test passed by
+// retrying is marked as flaky.
 func GoTestHandler(w http.ResponseWriter, r *http.Request) {
 	result := util.NewResult()
 	defer util.RetResult(w, r, result)
@@ -58,7 +71,15 @@ func GoTestHandler(w http.ResponseWriter, r *http.Request) {
 	filePath := args["file"].(string)
 	curDir := filepath.Dir(filePath)
 
-	cmd := exec.Command("go", "test", "-v")
+	retries := 0
+	if r, ok := args["retries"].(float64); ok && 0 < r {
+		retries = int(r)
+		if testMaxRetries < retries {
+			retries = testMaxRetries
+		}
+	}
+
+	cmd := exec.Command("go", "test", "-v", "-json")
 	cmd.Dir = curDir
 
 	setCmdEnv(cmd, username)
@@ -104,35 +125,80 @@ func GoTestHandler(w http.ResponseWriter, r *http.Request) {
 
 	reader := bufio.NewReader(io.MultiReader(stdout, stderr))
 
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(locale, "too_many_procs").(string)
+
+		return
+	}
+
 	if err := cmd.Start(); nil != err {
+		release()
 		logger.Error(err)
 		result.Succ = false
 
 		return
 	}
+	untrack := Processes.Track(sid, cmd, ProcKindTest)
+
+	started := time.Now()
+	event.Publish(&event.Event{Code: event.EvtCodeTestStarted, Sid: sid,
+		Data: &event.Lifecycle{Username: username, Path: filePath}})
 
 	go func(runningId int) {
 		defer util.Recover()
+		defer release()
 
 		logger.Debugf("User [%s, %s] is running [go test] [runningId=%d]", username, sid, runningId)
 
 		channelRet := map[string]interface{}{}
 		channelRet["cmd"] = "go test"
 
-		// read all
-		buf, _ := ioutil.ReadAll(reader)
+		parser := newTestParser()
+		streamTestOutput(sid, reader, parser)
 
 		// waiting for go test finished
 		cmd.Wait()
+		untrack()
+		succ := cmd.ProcessState.Success()
 
-		if !cmd.ProcessState.Success() {
-			logger.Debugf("User [%s, %s] 's running [go test] [runningId=%d] has done (with error)", username, sid, runningId)
+		for attempt := 1; attempt <= retries && !succ; attempt++ {
+			failed := parser.failedTests()
+			if 1 > len(failed) { // a build failure or a failure out of tests
+				break
+			}
+
+			logger.Debugf("User [%s, %s] is retrying failed tests %v [runningId=%d, attempt=%d]", username, sid,
+				failed, runningId, attempt)
+
+			succ = retryTests(sid, username, curDir, attempt, failed, parser)
+		}
 
-			channelRet["output"] = "<span class='test-error'>" + i18n.Get(locale, "test-error").(string) + "</span>\n" + string(buf)
+		summary := parser.summary(succ)
+		channelRet["results"] = summary
+
+		lifecycle := &event.Lifecycle{Username: username, Path: filePath, Succ: succ, Elapsed: time.Since(started)}
+		if testStatusBuildFail == summary.Status {
+			lifecycle.Errors = headLines(strings.Split(summary.BuildOutput, "\n"), lifecycleMaxErrors)
 		} else {
+			lifecycle.Errors = headLines(parser.failedTests(), lifecycleMaxErrors)
+		}
+		event.Publish(&event.Event{Code: event.EvtCodeTestDone, Sid: sid, Data: lifecycle})
+
+		switch summary.Status {
+		case testStatusBuildFail:
+			logger.Debugf("User [%s, %s] 's running [go test] [runningId=%d] has done (build failed)", username, sid, runningId)
+
+			channelRet["output"] = "<span class='test-error'>" + i18n.Get(locale, "test-build-error").(string) + "</span>\n"
+		case testStatusFail:
+			logger.Debugf("User [%s, %s] 's running [go test] [runningId=%d] has done (with error)", username, sid, runningId)
+
+			channelRet["output"] = "<span class='test-error'>" + i18n.Get(locale, "test-error").(string) + "</span>\n"
+		default:
 			logger.Debugf("User [%s, %s] 's running [go test] [runningId=%d] has done", username, sid, runningId)
 
-			channelRet["output"] = "<span class='test-succ'>" + i18n.Get(locale, "test-succ").(string) + "</span>\n" + string(buf)
+			channelRet["output"] = "<span class='test-succ'>" + i18n.Get(locale, "test-succ").(string) + "</span>\n"
 		}
 
 		if nil != session.OutputWS[sid] {
@@ -147,3 +213,71 @@ func GoTestHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}(rand.Int())
 }
+
+// streamTestOutput streams the human-readable output of `go test -json` read from the specified reader to the output
+// channel of the session specified by sid, the test events are parsed by the specified parser.
+func streamTestOutput(sid string, reader *bufio.Reader, parser *testParser) {
+	channelRet := map[string]interface{}{"cmd": "go test"}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if "" != line {
+			if text := parser.parseLine(line); "" != text {
+				channelRet["output"] = html.EscapeString(text)
+				if wsChannel := session.OutputWS[sid]; nil != wsChannel {
+					if err := wsChannel.WriteJSON(&channelRet); nil != err {
+						logger.Warn(err)
+					}
+
+					wsChannel.Refresh()
+				}
+			}
+		}
+
+		if nil != err {
+			if io.EOF != err {
+				logger.Warn(err)
+			}
+
+			break
+		}
+	}
+}
+
+// retryTests re-runs the specified failed top-level tests in the specified directory, merges the results into the
+// specified parser, returns whether the retry succeeded.
+func retryTests(sid, username, dir string, attempt int, failed []string, parser *testParser) bool {
+	names := []string{}
+	for _, name := range failed {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+
+	cmd := exec.Command("go", "test", "-v", "-json", "-count=1", "-run", "^("+strings.Join(names, "|")+")$")
+	cmd.Dir = dir
+	setCmdEnv(cmd, username)
+
+	stdout, err := cmd.StdoutPipe()
+	if nil != err {
+		logger.Error(err)
+
+		return false
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); nil != err {
+		logger.Error(err)
+
+		return false
+	}
+
+	untrack := Processes.Track(sid, cmd, ProcKindTest)
+
+	retry := newTestParser()
+	streamTestOutput(sid, bufio.NewReader(stdout), retry)
+	succ := nil == cmd.Wait()
+	untrack()
+
+	parser.mergeRetry(retry, attempt)
+
+	return succ
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Statuses of test results.
+const (
+	testStatusRun       = "run"
+	testStatusPass      = "pass"
+	testStatusFail      = "fail"
+	testStatusSkip      = "skip"
+	testStatusBuildFail = "build-fail" // the package failed to build, no tests ran
+)
+
+// testEvent represents an event of `go test -json` (see `go doc test2json`).
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64 // seconds
+	Output  string
+}
+
+// testResult represents the result of a test, subtests are the children.
+type testResult struct {
+	Name     string        `json:"name"`
+	Package  string        `json:"package"`
+	Status   string        `json:"status"`
+	Elapsed  float64       `json:"elapsed"` // seconds
+	Output   string        `json:"output"`
+	Flaky    bool          `json:"flaky"`    // whether the test failed but passed by retrying
+	Attempts int           `json:"attempts"` // count of retries
+	Children []*testResult `json:"children"`
+}
+
+// testSummary represents the structured results of a `go test -json` run.
+type testSummary struct {
+	Status      string        `json:"status"` // pass/fail/build-fail
+	Passed      int           `json:"passed"`
+	Failed      int           `json:"failed"`
+	Skipped     int           `json:"skipped"`
+	Flaky       int           `json:"flaky"`
+	Elapsed     float64       `json:"elapsed"`     // seconds
+	BuildOutput string        `json:"buildOutput"` // compiler output of a build failure
+	Tests       []*testResult `json:"tests"`       // top-level tests
+}
+
+// testParser parses the event stream of `go test -json`.
+type testParser struct {
+	tests       map[string]*testResult // <package/test, result>
+	roots       []*testResult
+	elapsed     float64
+	buildOutput []string
+	buildFailed bool
+}
+
+// newTestParser creates a parser of `go test -json` output.
+func newTestParser() *testParser {
+	return &testParser{tests: map[string]*testResult{}, roots: []*testResult{}}
+}
+
+// parseLine parses a line of the output, returns the human-readable text of the line.
+//
+// Lines which aren't test events (compiler errors printed by old go commands for example) are taken as build output.
+func (p *testParser) parseLine(line string) string {
+	e := &testEvent{}
+	if !strings.HasPrefix(line, "{") || nil != json.Unmarshal([]byte(line), e) {
+		p.buildOutput = append(p.buildOutput, line)
+
+		return line
+	}
+
+	switch e.Action {
+	case "build-output":
+		p.buildOutput = append(p.buildOutput, e.Output)
+	case "build-fail":
+		p.buildFailed = true
+	}
+
+	if "" == e.Test {
+		switch e.Action {
+		case testStatusPass, testStatusFail, testStatusSkip:
+			p.elapsed += e.Elapsed
+		case "output":
+			if strings.HasPrefix(e.Output, "FAIL") && strings.Contains(e.Output, "[build failed]") {
+				p.buildFailed = true
+			}
+		}
+
+		return e.Output
+	}
+
+	result := p.getTest(e.Package, e.Test)
+	switch e.Action {
+	case "output":
+		result.Output += e.Output
+	case testStatusPass, testStatusFail, testStatusSkip:
+		result.Status = e.Action
+		result.Elapsed = e.Elapsed
+	}
+
+	return e.Output
+}
+
+// getTest gets the result of the specified test, creates it (and its parents) if not found.
+func (p *testParser) getTest(pkg, name string) *testResult {
+	key := pkg + "/" + name
+	if ret, ok := p.tests[key]; ok {
+		return ret
+	}
+
+	ret := &testResult{Name: name, Package: pkg, Status: testStatusRun, Children: []*testResult{}}
+	p.tests[key] = ret
+
+	if i := strings.LastIndex(name, "/"); 0 < i {
+		parent := p.getTest(pkg, name[:i])
+		ret.Name = name[i+1:]
+		parent.Children = append(parent.Children, ret)
+	} else {
+		p.roots = append(p.roots, ret)
+	}
+
+	return ret
+}
+
+// summary gets the summary of the parsed results, succ is whether the go test command succeeded.
+func (p *testParser) summary(succ bool) *testSummary {
+	ret := &testSummary{Status: testStatusPass, Elapsed: p.elapsed, Tests: p.roots}
+	ret.count(p.roots)
+
+	if p.buildFailed || (!succ && 1 > len(p.tests) && 0 < len(p.buildOutput)) {
+		ret.Status = testStatusBuildFail
+		ret.BuildOutput = strings.Join(p.buildOutput, "")
+	} else if !succ || 0 < ret.Failed {
+		ret.Status = testStatusFail
+	}
+
+	return ret
+}
+
+// count counts the specified test results and their subtests by status.
+func (s *testSummary) count(results []*testResult) {
+	for _, result := range results {
+		switch result.Status {
+		case testStatusPass:
+			s.Passed++
+		case testStatusFail:
+			s.Failed++
+		case testStatusSkip:
+			s.Skipped++
+		}
+
+		if result.Flaky {
+			s.Flaky++
+		}
+
+		s.count(result.Children)
+	}
+}
+
+// failedTests gets the names of the failed top-level tests.
+func (p *testParser) failedTests() []string {
+	ret := []string{}
+	for _, result := range p.roots {
+		if testStatusFail == result.Status {
+			ret = append(ret, result.Name)
+		}
+	}
+
+	return ret
+}
+
+// mergeRetry merges the results of the specified retry of failed tests.
+//
+// A failed test passed by the retry is marked as flaky, its output and subtests are replaced by the retry's.
+func (p *testParser) mergeRetry(retry *testParser, attempt int) {
+	for _, retried := range retry.roots {
+		result, ok := p.tests[retried.Package+"/"+retried.Name]
+		if !ok || testStatusFail != result.Status {
+			continue
+		}
+
+		result.Attempts = attempt
+		result.Output += "--- retry " + strconv.Itoa(attempt) + " ---\n" + retried.Output
+		if testStatusPass == retried.Status {
+			result.Status = testStatusPass
+			result.Flaky = true
+			result.Elapsed = retried.Elapsed
+			result.Children = retried.Children
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// goVersionTimeout is the timeout of running `go version` of a toolchain.
+const goVersionTimeout = 5 * time.Second
+
+// toolchain represents a Go toolchain.
+type toolchain struct {
+	Root     string `json:"root"`            // GOROOT
+	Version  string `json:"version"`         // output of `go version`
+	Error    string `json:"error,omitempty"` // error of running `go version`
+	Selected bool   `json:"selected"`        // whether selected by the current user
+}
+
+// GoToolchainsHandler handles request of listing and switching Go toolchains.
+//
+// A GET request lists the toolchain running Wide and the ones configured in conf.Wide.GoToolchains. A POST request
+// with argument "root" selects the toolchain for the current user, the selected toolchain (GOROOT and PATH) is used
+// by build, run, test, get and other go commands. An empty root selects the toolchain running Wide.
+func GoToolchainsHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+	user := conf.GetUser(username)
+
+	if http.MethodPost == r.Method {
+		var args map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+			logger.Error(err)
+			result.Succ = false
+
+			return
+		}
+
+		root, _ := args["root"].(string)
+		if err := selectToolchain(user, root); nil != err {
+			result.Succ = false
+			result.Msg = err.Error()
+
+			return
+		}
+	}
+
+	selected := conf.GetGoRoot(username)
+	roots := append([]string{runtime.GOROOT()}, conf.Wide.GoToolchains...)
+
+	toolchains := []*toolchain{}
+	seen := map[string]bool{}
+	for _, root := range roots {
+		if seen[root] {
+			continue
+		}
+		seen[root] = true
+
+		t := &toolchain{Root: root, Selected: root == selected}
+		if version, err := getGoVersion(root); nil != err {
+			t.Error = err.Error()
+		} else {
+			t.Version = version
+		}
+
+		toolchains = append(toolchains, t)
+	}
+
+	result.Data = toolchains
+}
+
+// selectToolchain selects the toolchain specified by root for the specified user after validating it.
+func selectToolchain(user *conf.User, root string) error {
+	if "" != root && runtime.GOROOT() != root {
+		configured := false
+		for _, goRoot := range conf.Wide.GoToolchains {
+			if goRoot == root {
+				configured = true
+
+				break
+			}
+		}
+
+		if !configured {
+			return errors.New("toolchain [" + root + "] is not configured")
+		}
+
+		if _, err := getGoVersion(root); nil != err {
+			return errors.New("toolchain [" + root + "] is unavailable: " + err.Error())
+		}
+	}
+
+	if runtime.GOROOT() == root {
+		root = ""
+	}
+
+	user.GoRoot = root
+	if !user.Save() {
+		return errors.New("can't save the selection")
+	}
+
+	logger.Debugf("User [%s] selected Go toolchain [%s]", user.Name, root)
+
+	return nil
+}
+
+// getGoVersion checks the go command of the toolchain specified by root exists and is executable, returns the output
+// of `go version`.
+func getGoVersion(root string) (string, error) {
+	goExecutable := conf.GetGoExecutable(root)
+
+	info, err := os.Stat(goExecutable)
+	if nil != err {
+		return "", err
+	}
+
+	if info.IsDir() || (!util.OS.IsWindows() && 0 == info.Mode()&0111) {
+		return "", errors.New(goExecutable + " is not executable")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), goVersionTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, goExecutable, "version")
+	cmd.Env = append(os.Environ(), "GOROOT="+root)
+	cmd.Dir = filepath.Dir(goExecutable)
+
+	out, err := cmd.CombinedOutput()
+	if nil != err {
+		return "", errors.New(strings.TrimSpace(string(out)) + " " + err.Error())
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
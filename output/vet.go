@@ -104,7 +104,16 @@ func GoVetHandler(w http.ResponseWriter, r *http.Request) {
 
 	reader := bufio.NewReader(io.MultiReader(stdout, stderr))
 
+	release, err := AcquireProcSlot(username)
+	if nil != err {
+		result.Succ = false
+		result.Msg = i18n.Get(locale, "too_many_procs").(string)
+
+		return
+	}
+
 	if err := cmd.Start(); nil != err {
+		release()
 		logger.Error(err)
 		result.Succ = false
 
@@ -113,6 +122,7 @@ func GoVetHandler(w http.ResponseWriter, r *http.Request) {
 
 	go func(runningId int) {
 		defer util.Recover()
+		defer release()
 
 		logger.Debugf("User [%s, %s] is running [go vet] [runningId=%d]", username, sid, runningId)
 
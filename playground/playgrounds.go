@@ -46,11 +46,7 @@ func IndexHandler(w http.ResponseWriter, r *http.Request) {
 		httpSession.Values["username"] = "playground"
 	}
 
-	httpSession.Options.MaxAge = conf.Wide.HTTPSessionMaxAge
-	if "" != conf.Wide.Context {
-		httpSession.Options.Path = conf.Wide.Context
-	}
-	httpSession.Save(r, w)
+	session.SaveHTTPSession(w, r, httpSession)
 
 	username := httpSession.Values["username"].(string)
 
@@ -96,7 +92,7 @@ func IndexHandler(w http.ResponseWriter, r *http.Request) {
 
 	logger.Debugf("User [%s] has [%d] sessions", username, len(wideSessions))
 
-	t, err := template.ParseFiles("views/playground/index.html")
+	t, err := util.Template.Get("playground/index.html")
 
 	if nil != err {
 		logger.Error(err)
@@ -109,7 +109,7 @@ func RunHandler(w http.ResponseWriter, r *http.Request) {
 	channelRet["pid"] = cmd.Process.Pid
 
 	// add the process to user's process set
-	output.Processes.Add(wSession, cmd.Process)
+	output.Processes.Add(wSession, cmd, output.ProcKindRun)
 
 	go func(runningId int) {
 		defer util.Recover()
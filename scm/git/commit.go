@@ -0,0 +1,262 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"bufio"
+	"encoding/json"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// pathsArgs represents arguments of staging and unstaging.
+type pathsArgs struct {
+	Paths []string `json:"paths"`
+}
+
+// StageHandler handles request of staging files (git add).
+func StageHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	root, paths, ok := getRepoPaths(w, r, result)
+	if !ok {
+		return
+	}
+
+	if _, err := git(root, append([]string{"add", "-A", "--"}, paths...)...); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+	}
+}
+
+// UnstageHandler handles request of unstaging files (git reset).
+func UnstageHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	root, paths, ok := getRepoPaths(w, r, result)
+	if !ok {
+		return
+	}
+
+	if _, err := git(root, "rev-parse", "--verify", "-q", "HEAD"); nil != err {
+		// no commit yet, removes them from the index
+		_, err = git(root, append([]string{"rm", "-r", "-q", "--cached", "--"}, paths...)...)
+		if nil != err {
+			result.Succ = false
+			result.Msg = err.Error()
+		}
+
+		return
+	}
+
+	if _, err := git(root, append([]string{"reset", "-q", "HEAD", "--"}, paths...)...); nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+	}
+}
+
+// CommitHandler handles request of committing the staged changes of the repository containing the specified path.
+//
+// The output of git commit (hooks may run for a while) is pushed to the output channel of the specified session.
+func CommitHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+	user := conf.GetUser(username)
+	locale := user.Locale
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	sid := args["sid"].(string)
+	path := filepath.Clean(filepath.FromSlash(args["path"].(string)))
+	message := strings.TrimSpace(args["message"].(string))
+	if !session.CanAccess(username, path) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if "" == message {
+		result.Succ = false
+		result.Msg = "commit message is empty"
+
+		return
+	}
+
+	dir := path
+	if !util.File.IsDir(dir) {
+		dir = filepath.Dir(dir)
+	}
+
+	root, err := git(dir, "rev-parse", "--show-toplevel")
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	cmd := exec.Command("git", "commit", "-F", "-")
+	cmd.Dir = strings.TrimSpace(root)
+	cmd.Stdin = strings.NewReader(message)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME="+user.Name, "GIT_AUTHOR_EMAIL="+user.Email,
+		"GIT_COMMITTER_NAME="+user.Name, "GIT_COMMITTER_EMAIL="+user.Email)
+
+	stdout, err := cmd.StdoutPipe()
+	if nil != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); nil != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	pushOutput(sid, "start-git_commit",
+		"<span class='start-get'>"+i18n.Get(locale, "start-git_commit").(string)+"</span>\n")
+
+	go func() {
+		defer util.Recover()
+
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadString('\n')
+			if "" != line {
+				pushOutput(sid, "git commit", html.EscapeString(line))
+			}
+
+			if nil != err {
+				if io.EOF != err {
+					logger.Warn(err)
+				}
+
+				break
+			}
+		}
+
+		if err := cmd.Wait(); nil != err {
+			logger.Debugf("User [%s, %s] 's [git commit] failed: %s", username, sid, err)
+			pushOutput(sid, "git commit",
+				"<span class='get-error'>"+i18n.Get(locale, "git_commit-error").(string)+"</span>\n")
+
+			return
+		}
+
+		logger.Debugf("User [%s, %s] committed in [%s]", username, sid, cmd.Dir)
+		pushOutput(sid, "git commit", "<span class='get-succ'>"+i18n.Get(locale, "git_commit-done").(string)+"</span>\n")
+	}()
+}
+
+// getRepoPaths gets the repository root and the paths relative to it from the specified request, writes the
+// response and returns false if the request is invalid.
+func getRepoPaths(w http.ResponseWriter, r *http.Request, result *util.Result) (root string, paths []string, ok bool) {
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	args := &pathsArgs{}
+	if err := json.NewDecoder(r.Body).Decode(args); err != nil || 0 == len(args.Paths) {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+
+		return
+	}
+
+	for _, path := range args.Paths {
+		path = filepath.Clean(filepath.FromSlash(path))
+		if !session.CanAccess(username, path) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
+
+		if "" == root {
+			dir := path
+			if !util.File.IsDir(dir) {
+				dir = filepath.Dir(dir)
+			}
+
+			out, err := git(dir, "rev-parse", "--show-toplevel")
+			if nil != err {
+				result.Succ = false
+				result.Msg = err.Error()
+
+				return
+			}
+			root = filepath.Clean(strings.TrimSpace(out))
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if nil != err || strings.HasPrefix(rel, "..") {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+
+			return
+		}
+
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+
+	return root, paths, true
+}
+
+// pushOutput pushes the specified output with the specified cmd to the output channel of the specified session.
+func pushOutput(sid, cmd, output string) {
+	wsChannel := session.OutputWS[sid]
+	if nil == wsChannel {
+		return
+	}
+
+	if err := wsChannel.WriteJSON(map[string]interface{}{"cmd": cmd, "output": output}); nil != err {
+		logger.Warn(err)
+
+		return
+	}
+
+	wsChannel.Refresh()
+}
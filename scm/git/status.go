@@ -0,0 +1,221 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+// FileStatus represents the git status of a file.
+type FileStatus struct {
+	Path     string `json:"path"`               // absolute path
+	OrigPath string `json:"origPath,omitempty"` // absolute path before renaming or copying
+	Code     string `json:"code"`               // status code of porcelain format, for example M, A, D, R, ?
+}
+
+// Status represents the git status of a repository.
+type Status struct {
+	Root      string        `json:"root"`      // top-level directory of the repository
+	Staged    []*FileStatus `json:"staged"`    // changes to be committed
+	Unstaged  []*FileStatus `json:"unstaged"`  // changes not staged for commit
+	Untracked []*FileStatus `json:"untracked"` // untracked files
+}
+
+// StatusHandler handles request of git status of the repository containing the specified path.
+func StatusHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	path := filepath.Clean(filepath.FromSlash(args["path"].(string)))
+	if !session.CanAccess(username, path) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	status, err := GetStatus(path)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	result.Data = status
+}
+
+// DiffHandler handles request of git diff of a file.
+//
+// Argument "staged" specifies whether diffs the staged changes (against HEAD) or the unstaged changes (against the
+// index).
+func DiffHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := session.HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	path := filepath.Clean(filepath.FromSlash(args["path"].(string)))
+	if !session.CanAccess(username, path) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	staged, _ := args["staged"].(bool)
+
+	gitArgs := []string{"diff", "--no-color"}
+	if staged {
+		gitArgs = append(gitArgs, "--cached")
+	}
+	gitArgs = append(gitArgs, "--", filepath.Base(path))
+
+	out, err := git(filepath.Dir(path), gitArgs...)
+	if nil != err {
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	result.Data = out
+}
+
+// GetStatus gets the git status of the repository containing the specified path by one `git status` call.
+func GetStatus(path string) (*Status, error) {
+	dir := path
+	if !util.File.IsDir(dir) {
+		dir = filepath.Dir(dir)
+	}
+
+	root, err := git(dir, "rev-parse", "--show-toplevel")
+	if nil != err {
+		return nil, err
+	}
+	root = filepath.Clean(strings.TrimSpace(root))
+
+	out, err := git(root, "status", "--porcelain", "-z", "--untracked-files=all")
+	if nil != err {
+		return nil, err
+	}
+
+	return parseStatus(root, out), nil
+}
+
+// parseStatus parses the specified output of `git status --porcelain -z` of the repository specified by root.
+//
+// Each entry is "XY path", X is the status of the index and Y is the status of the work tree, a renamed or copied
+// entry is followed by its original path.
+func parseStatus(root, out string) *Status {
+	ret := &Status{Root: filepath.ToSlash(root), Staged: []*FileStatus{}, Unstaged: []*FileStatus{},
+		Untracked: []*FileStatus{}}
+
+	abs := func(p string) string {
+		return filepath.ToSlash(filepath.Join(root, filepath.FromSlash(p)))
+	}
+
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if 4 > len(entry) {
+			continue
+		}
+
+		x, y, path := entry[0:1], entry[1:2], abs(entry[3:])
+
+		origPath := ""
+		if "R" == x || "C" == x {
+			if i+1 < len(entries) {
+				i++
+				origPath = abs(entries[i])
+			}
+		}
+
+		if "?" == x {
+			ret.Untracked = append(ret.Untracked, &FileStatus{Path: path, Code: "?"})
+
+			continue
+		}
+
+		if " " != x && "!" != x {
+			ret.Staged = append(ret.Staged, &FileStatus{Path: path, OrigPath: origPath, Code: x})
+		}
+
+		if " " != y && "!" != y {
+			ret.Unstaged = append(ret.Unstaged, &FileStatus{Path: path, Code: y})
+		}
+	}
+
+	return ret
+}
+
+// git runs git with the specified arguments in the specified directory, returns the stdout.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); nil != err {
+		msg := strings.TrimSpace(stderr.String())
+		if "" == msg {
+			msg = err.Error()
+		}
+
+		return "", errors.New(msg)
+	}
+
+	return stdout.String(), nil
+}
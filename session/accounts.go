@@ -0,0 +1,230 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/util"
+)
+
+// Directory name of archived workspaces of deleted users under conf.Wide.UsersWorkspaces.
+const deletedDirName = ".deleted"
+
+// Served workspaces, username -> true, a pattern can't be registered twice with http.Handle.
+var servedWorkspaces = map[string]bool{}
+var servedMutex sync.Mutex
+
+// ServeWorkspace serves files of the workspace of the specified user under /workspace/{username}/, does nothing if
+// it has been served (such as a user signed up again after deleted).
+func ServeWorkspace(user *conf.User) {
+	servedMutex.Lock()
+	defer servedMutex.Unlock()
+
+	if servedWorkspaces[user.Name] {
+		return
+	}
+	servedWorkspaces[user.Name] = true
+
+	prefix := conf.Wide.Context + "/workspace/" + user.Name + "/"
+	http.Handle(prefix, http.StripPrefix(prefix, http.FileServer(http.Dir(user.WorkspacePath()))))
+}
+
+// DeleteUserHandler handles request of deleting the user specified by argument "username" (defaults to the session
+// user), argument "confirm" must be the username. A user can delete itself with argument "password", an admin can
+// delete any non-admin user.
+//
+// The user's sessions are released (its processes are killed), its configurations are removed, and its workspace is
+// archived under {UsersWorkspaces}/.deleted/, or removed if argument "purge" is true.
+func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	operator := getTokenOwner(w, r) // API tokens can't delete accounts
+	if nil == operator {
+		return
+	}
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	username, _ := args["username"].(string)
+	if "" == username {
+		username = operator.Name
+	}
+
+	user := conf.GetUser(username)
+	if nil == user {
+		result.Succ = false
+		result.Msg = "user [" + username + "] not found"
+
+		return
+	}
+
+	if confirm, _ := args["confirm"].(string); confirm != user.Name {
+		result.Succ = false
+		result.Msg = "confirmation mismatched"
+
+		return
+	}
+
+	if user.Name == operator.Name {
+		if password, _ := args["password"].(string); user.Password != conf.Salt(password, user.Salt) {
+			result.Succ = false
+			result.Msg = "wrong password"
+
+			return
+		}
+	} else if !operator.IsAdmin() {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if user.IsAdmin() { // the workspace of an admin may be the GOPATH
+		result.Succ = false
+		result.Msg = "can't delete an admin"
+
+		return
+	}
+
+	purge, _ := args["purge"].(bool)
+	if err := deleteUser(user, purge); nil != err {
+		logger.Error(err)
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	logger.Infof("User [%s] deleted user [%s], workspace purged [%v]", operator.Name, user.Name, purge)
+
+	if user.Name == operator.Name {
+		httpSession, _ := HTTPSession.Get(r, "wide-session")
+		httpSession.Options.MaxAge = -1
+		SaveHTTPSession(w, r, httpSession)
+	}
+}
+
+// deleteUser deletes the specified user, its workspace is removed if the specified purge is true, archived otherwise.
+//
+// The workspace is kept as it is if it's not under conf.Wide.UsersWorkspaces. The workspace is archived (or purged)
+// before the user is dropped, so a failure of archiving leaves the user as it is.
+func deleteUser(user *conf.User, purge bool) error {
+	addUserMutex.Lock()
+	defer addUserMutex.Unlock()
+
+	usersWorkspaces := filepath.Clean(conf.Wide.UsersWorkspaces)
+	workspaces := []string{}
+	for _, workspace := range filepath.SplitList(user.WorkspacePath()) {
+		workspace = filepath.Clean(workspace)
+		if !strings.HasPrefix(workspace, usersWorkspaces+string(filepath.Separator)) {
+			logger.Warnf("Workspace [%s] of the deleted user [%s] is kept", workspace, user.Name)
+
+			continue
+		}
+
+		workspaces = append(workspaces, workspace)
+	}
+
+	if purge {
+		for _, workspace := range workspaces {
+			if err := os.RemoveAll(workspace); nil != err {
+				return err
+			}
+		}
+	} else if err := archiveWorkspaces(user.Name, usersWorkspaces, workspaces); nil != err {
+		return err
+	}
+
+	if err := os.Remove(filepath.Join("conf", "users", user.Name+".json")); nil != err && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, path := range []string{user.GoEnvPath(), filepath.Join("static", "user", user.Name)} {
+		if err := os.RemoveAll(path); nil != err {
+			logger.Errorf("Removes [%s] of the deleted user [%s] failed: %s", path, user.Name, err)
+		}
+	}
+
+	users := conf.GetUsers()
+	for i, u := range users {
+		if u.Name == user.Name { // the user may be reloaded (see conf.Reload) meanwhile
+			conf.SetUsers(append(users[:i:i], users[i+1:]...))
+
+			break
+		}
+	}
+
+	for _, s := range WideSessions.GetByUsername(user.Name) {
+		WideSessions.Remove(s.ID)
+	}
+
+	event.Publish(&event.Event{Code: event.EvtCodeUserDeleted, Data: user.WorkspacePath()})
+
+	return nil
+}
+
+// archiveWorkspaces moves the specified workspaces of the user specified by username into the directory of deleted
+// workspaces under the specified usersWorkspaces, each one with a unique name. The moved ones are moved back if one
+// fails.
+func archiveWorkspaces(username, usersWorkspaces string, workspaces []string) error {
+	deletedDir := filepath.Join(usersWorkspaces, deletedDirName)
+	if err := os.MkdirAll(deletedDir, 0755); nil != err {
+		return err
+	}
+
+	prefix := username + "-" + time.Now().Format("20060102150405")
+	archived := map[string]string{} // <workspace, archive>
+	for i, workspace := range workspaces {
+		name := prefix
+		if 1 < len(workspaces) {
+			name += "-" + strconv.Itoa(i)
+		}
+
+		archive := filepath.Join(deletedDir, name)
+		for seq := 1; util.File.IsExist(archive); seq++ {
+			archive = filepath.Join(deletedDir, name+"_"+strconv.Itoa(seq))
+		}
+
+		if err := os.Rename(workspace, archive); nil != err {
+			for workspace, archive := range archived {
+				if err := os.Rename(archive, workspace); nil != err {
+					logger.Errorf("Moves [%s] back to [%s] failed: %s", archive, workspace, err)
+				}
+			}
+
+			return err
+		}
+
+		archived[workspace] = archive
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/log"
+	"github.com/b3log/wide/util"
+)
+
+// sessionInfo represents a wide session listed for administrators.
+type sessionInfo struct {
+	Sid       string    `json:"sid"`
+	Username  string    `json:"username"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`   // the latest active time
+	Processes []int     `json:"processes"` // pids of running processes
+}
+
+// AdminSessionsHandler handles request of listing active wide sessions of all users, the latest active first.
+//
+// Requires the admin role.
+func AdminSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	mutex.Lock()
+	infos := []*sessionInfo{}
+	for _, s := range WideSessions {
+		info := &sessionInfo{Sid: s.ID, Username: s.Username, Created: s.Created, Updated: s.Updated,
+			Processes: []int{}}
+		for _, p := range s.Processes {
+			info.Processes = append(info.Processes, p.Pid)
+		}
+
+		infos = append(infos, info)
+	}
+	mutex.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Updated.After(infos[j].Updated) })
+
+	result.Data = infos
+}
+
+// AdminTerminateSessionHandler handles request of terminating the wide session specified by argument "sid", its
+// WebSocket channels are closed and its running processes are killed.
+//
+// Requires the admin role.
+func AdminTerminateSessionHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	sid, _ := args["sid"].(string)
+	wSession := WideSessions.Get(sid)
+	if nil == wSession {
+		result.Succ = false
+		result.Msg = "session [" + sid + "] not found"
+
+		return
+	}
+
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	logger.Infof("Admin [%v] terminated session [%s] of user [%s]", httpSession.Values["username"], sid,
+		wSession.Username)
+
+	WideSessions.Remove(sid)
+}
+
+// AdminLogLevelHandler handles request of getting or setting (argument "level", off/trace/debug/info/warn/error) the
+// logging level at runtime, the data is the current level. The change is logged for audit.
+//
+// Requires the admin role.
+func AdminLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil && io.EOF != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	level, _ := args["level"].(string)
+	level = strings.ToLower(strings.TrimSpace(level))
+	if "" == level {
+		result.Data = log.GetLevel()
+
+		return
+	}
+
+	if IsReadOnlyRequest(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	if !log.IsLevel(level) {
+		result.Succ = false
+		result.Msg = "logging level [" + level + "] is invalid"
+		result.Data = log.GetLevel()
+
+		return
+	}
+
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	// logs before changing for the new level may hide it
+	logger.Warnf("Admin [%v] changed logging level from [%s] to [%s]", httpSession.Values["username"], log.GetLevel(),
+		level)
+
+	conf.SetLogLevel(level)
+
+	result.Data = level
+}
+
+// AdminReloadConfHandler handles request of reloading wide.json and users' configurations from disk without
+// restarting Wide. Workspaces of the added users are created and served, sessions of the removed users are released
+// (their processes are killed). The data is {restart: [], added: [], removed: []}, "restart" holds names of the
+// changed fields requiring a restart to take effect.
+//
+// Requires the admin role.
+func AdminReloadConfHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	if IsReadOnlyRequest(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	addUserMutex.Lock()
+	reloaded, err := conf.Reload()
+	addUserMutex.Unlock()
+	if nil != err {
+		logger.Error(err)
+		result.Succ = false
+		result.Msg = err.Error()
+
+		return
+	}
+
+	InitCookieOptions()
+
+	added := []string{}
+	for _, user := range reloaded.Added {
+		ServeWorkspace(user)
+		added = append(added, user.Name)
+	}
+
+	removed := []string{}
+	for _, user := range reloaded.Removed {
+		for _, s := range WideSessions.GetByUsername(user.Name) {
+			WideSessions.Remove(s.ID)
+		}
+		removed = append(removed, user.Name)
+	}
+
+	restart := reloaded.Restart
+	if nil == restart {
+		restart = []string{}
+	}
+
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	logger.Infof("Admin [%v] reloaded configurations, added users %v, removed users %v, fields requiring restart %v",
+		httpSession.Values["username"], added, removed, restart)
+
+	result.Data = map[string]interface{}{"restart": restart, "added": added, "removed": removed}
+}
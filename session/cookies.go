@@ -0,0 +1,109 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/b3log/wide/conf"
+	"github.com/gorilla/sessions"
+)
+
+// SameSite attributes of the session cookie.
+var cookieSameSites = map[string]string{"lax": "Lax", "strict": "Strict", "none": "None"}
+
+// InitCookieOptions applies the cookie configurations to the HTTP session store, so every session inherits them. It
+// must be called after the configurations are loaded.
+//
+// The cookie is Secure if conf.Wide.CookieSecure is true or the server serves HTTPS.
+func InitCookieOptions() {
+	path := "/"
+	if "" != conf.Wide.Context {
+		path = conf.Wide.Context
+	}
+
+	HTTPSession.Options = &sessions.Options{
+		Path:     path,
+		MaxAge:   conf.Wide.HTTPSessionMaxAge,
+		HttpOnly: conf.Wide.CookieHTTPOnly,
+		Secure:   conf.Wide.CookieSecure || conf.Wide.IsTLS(),
+	}
+}
+
+// SaveHTTPSession saves the specified HTTP session, the session cookie is renewed (or deleted if the max age of the
+// session is negative) with the SameSite attribute of conf.Wide.CookieSameSite (defaults to Lax).
+func SaveHTTPSession(w http.ResponseWriter, r *http.Request, httpSession *sessions.Session) error {
+	if err := httpSession.Save(r, w); nil != err {
+		logger.Error(err)
+
+		return err
+	}
+
+	// the session store doesn't support SameSite, appends it to the cookie set
+	sameSite, ok := cookieSameSites[strings.ToLower(conf.Wide.CookieSameSite)]
+	if !ok {
+		sameSite = "Lax"
+	}
+	if "None" == sameSite && !httpSession.Options.Secure { // browsers reject SameSite=None without Secure
+		sameSite = "Lax"
+	}
+
+	cookies := w.Header()["Set-Cookie"]
+	for i, cookie := range cookies {
+		if strings.HasPrefix(cookie, httpSession.Name()+"=") && !strings.Contains(cookie, "; SameSite=") {
+			cookies[i] = cookie + "; SameSite=" + sameSite
+		}
+	}
+
+	return nil
+}
+
+// SetHTTPSessionUser sets the specified user as the user of the specified HTTP session. The creation time of the user
+// is kept in the session value "userCreated" as well, so the session is invalidated if the user is deleted and signed
+// up again with the same name (see CheckHTTPSession).
+func SetHTTPSessionUser(httpSession *sessions.Session, user *conf.User) {
+	httpSession.Values["username"] = user.Name
+	httpSession.Values["userCreated"] = user.Created
+}
+
+// CheckHTTPSession checks whether the user of the HTTP session of the specified request still exists and is the one
+// the session is created for, returns false and clears the session (and its cookie) if not. Sessions of API tokens
+// and playground are not checked.
+func CheckHTTPSession(w http.ResponseWriter, r *http.Request) bool {
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew || IsTokenRequest(r) {
+		return true
+	}
+
+	username, _ := httpSession.Values["username"].(string)
+	if "playground" == username {
+		return true
+	}
+
+	created, ok := httpSession.Values["userCreated"].(int64)
+	if user := conf.GetUser(username); nil != user && ok && created == user.Created {
+		return true
+	}
+
+	logger.Debugf("Clears the HTTP session of user [%s] which is deleted or signed up again", username)
+
+	// the session is cached by the request, the following gets see it as a new one
+	httpSession.Values = map[interface{}]interface{}{}
+	httpSession.IsNew = true
+	SaveHTTPSession(w, r, httpSession)
+
+	return false
+}
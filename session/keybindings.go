@@ -0,0 +1,102 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/util"
+)
+
+// KeybindingsHandler handles request of getting or updating the keyboard shortcuts of the user.
+//
+// If argument "keybindings" ({action: key combo}) is present, the custom keyboard shortcuts are replaced, they are
+// validated for well-formed key combos and conflicts (see conf.ParseKeybindings). If argument "reset" is true, the
+// custom ones are cleared so the defaults take effect.
+//
+// Data "keybindings" is the effective keyboard shortcuts, data "defaults" is the default ones.
+func KeybindingsHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+	user := conf.GetUser(username)
+	if nil == user {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil && io.EOF != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	reset, _ := args["reset"].(bool)
+	_, update := args["keybindings"]
+	if reset || update {
+		if IsReadOnlyRequest(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+			return
+		}
+
+		keybindings := map[string]string{}
+		if !reset {
+			bindings, ok := args["keybindings"].(map[string]interface{})
+			if !ok {
+				result.Succ = false
+				result.Msg = "invalid keybindings"
+
+				return
+			}
+
+			for action, combo := range bindings {
+				keybindings[action], _ = combo.(string)
+			}
+
+			var err error
+			if keybindings, err = conf.ParseKeybindings(keybindings); nil != err {
+				result.Succ = false
+				result.Msg = err.Error()
+
+				return
+			}
+		}
+
+		user.Keybindings = keybindings
+		user.Updated = time.Now().UnixNano()
+		if !user.Save() {
+			result.Succ = false
+
+			return
+		}
+	}
+
+	result.Data = map[string]interface{}{"keybindings": user.GetKeybindings(), "defaults": conf.DefaultKeybindings}
+}
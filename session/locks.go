@@ -0,0 +1,102 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileLock represents a read-only lock of a file, a locked file can't be modified by anyone (including the holder)
+// until it's unlocked or the holding session is removed.
+type FileLock struct {
+	Path     string    `json:"path"`
+	Username string    `json:"username"` // holder
+	Sid      string    `json:"-"`        // holding session
+	Locked   time.Time `json:"locked"`
+}
+
+// File locks, <path, lock>.
+var fileLocks = map[string]*FileLock{}
+
+// Exclusive lock of fileLocks.
+var fileLocksMutex sync.RWMutex
+
+// LockFile locks the file specified by path by the wide session specified by sid, returns the existing lock if the
+// file has been locked.
+func LockFile(sid, username, path string) *FileLock {
+	path = filepath.Clean(path)
+
+	fileLocksMutex.Lock()
+	defer fileLocksMutex.Unlock()
+
+	if lock, ok := fileLocks[path]; ok {
+		return lock
+	}
+
+	lock := &FileLock{Path: path, Username: username, Sid: sid, Locked: time.Now()}
+	fileLocks[path] = lock
+
+	return lock
+}
+
+// UnlockFile unlocks the file specified by path.
+func UnlockFile(path string) {
+	fileLocksMutex.Lock()
+	defer fileLocksMutex.Unlock()
+
+	delete(fileLocks, filepath.Clean(path))
+}
+
+// GetFileLock gets the lock of the file specified by path, returns nil if not locked.
+func GetFileLock(path string) *FileLock {
+	fileLocksMutex.RLock()
+	defer fileLocksMutex.RUnlock()
+
+	return fileLocks[filepath.Clean(path)]
+}
+
+// GetFileLockUnder gets a lock of the file specified by path or any file under it (if it's a directory), returns nil
+// if there is no lock.
+func GetFileLockUnder(path string) *FileLock {
+	path = filepath.Clean(path)
+	prefix := path + string(os.PathSeparator)
+
+	fileLocksMutex.RLock()
+	defer fileLocksMutex.RUnlock()
+
+	for p, lock := range fileLocks {
+		if p == path || strings.HasPrefix(p, prefix) {
+			return lock
+		}
+	}
+
+	return nil
+}
+
+// releaseFileLocks releases locks held by the wide session specified by sid.
+func releaseFileLocks(sid string) {
+	fileLocksMutex.Lock()
+	defer fileLocksMutex.Unlock()
+
+	for path, lock := range fileLocks {
+		if lock.Sid == sid {
+			delete(fileLocks, path)
+		}
+	}
+}
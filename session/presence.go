@@ -0,0 +1,105 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// presenceInterval is the min interval of broadcasting presence of a wide session, updates in the interval are
+// merged (the latest wins).
+const presenceInterval = 500 * time.Millisecond
+
+// Presence represents what a wide session (a browser tab) is editing.
+type Presence struct {
+	Sid      string   `json:"sid"`
+	Username string   `json:"username"`
+	Files    []string `json:"files"` // paths of open files
+	Path     string   `json:"path"`  // path of the current file
+	Line     int      `json:"line"`  // cursor line of the current file
+	Ch       int      `json:"ch"`    // cursor column of the current file
+}
+
+var (
+	presences      = map[string]*Presence{}   // latest presence, <sid, presence>
+	presenceTimers = map[string]*time.Timer{} // pending broadcasts, <sid, timer>
+	presenceMutex  sync.Mutex
+)
+
+// UpdatePresence updates presence of the wide session specified by presence.Sid, broadcasts it over the notification
+// channels of the other sessions of the same user and sessions joined the same shares (see Share) later.
+func UpdatePresence(presence *Presence) {
+	presenceMutex.Lock()
+	defer presenceMutex.Unlock()
+
+	presences[presence.Sid] = presence
+
+	if _, ok := presenceTimers[presence.Sid]; ok {
+		return
+	}
+
+	sid := presence.Sid
+	presenceTimers[sid] = time.AfterFunc(presenceInterval, func() {
+		presenceMutex.Lock()
+		p := presences[sid]
+		delete(presenceTimers, sid)
+		presenceMutex.Unlock()
+
+		if nil != p {
+			broadcastPresence(p)
+		}
+	})
+}
+
+// releasePresence removes presence of the wide session specified by sid, broadcasts it with no file open.
+func releasePresence(sid, username string) {
+	presenceMutex.Lock()
+	_, ok := presences[sid]
+	delete(presences, sid)
+	if timer, pending := presenceTimers[sid]; pending {
+		timer.Stop()
+		delete(presenceTimers, sid)
+	}
+	presenceMutex.Unlock()
+
+	if ok {
+		broadcastPresence(&Presence{Sid: sid, Username: username, Files: []string{}})
+	}
+}
+
+// broadcastPresence pushes the specified presence to the notification channels of the other sessions of the same
+// user and sessions joined the same shares.
+func broadcastPresence(presence *Presence) {
+	sids := map[string]bool{}
+	for _, s := range WideSessions.GetByUsername(presence.Username) {
+		sids[s.ID] = true
+	}
+	for _, path := range presence.Files {
+		for _, sid := range GetShareSids(presence.Sid, path) {
+			sids[sid] = true
+		}
+	}
+	delete(sids, presence.Sid)
+
+	msg := map[string]interface{}{"cmd": "presence", "presence": presence}
+	for sid := range sids {
+		if wsChannel := GetNotificationWS(sid); nil != wsChannel {
+			if err := wsChannel.WriteJSON(&msg); nil != err {
+				logger.Warn(err)
+			}
+		}
+	}
+}
@@ -61,28 +61,73 @@ var (
 	// OutputWS holds all output channels. <sid, *util.WSChannel>
 	OutputWS = map[string]*util.WSChannel{}
 
-	// NotificationWS holds all notification channels. <sid, *util.WSChannel>
+	// NotificationWS holds all notification channels, guarded by notificationWSMutex (see GetNotificationWS).
+	// <sid, *util.WSChannel>
 	NotificationWS = map[string]*util.WSChannel{}
 
 	// PlaygroundWS holds all playground channels. <sid, *util.WSChannel>
 	PlaygroundWS = map[string]*util.WSChannel{}
 )
 
+// Exclusive lock for NotificationWS.
+var notificationWSMutex sync.RWMutex
+
+// GetNotificationWS gets the notification channel of the session specified by sid, returns nil if not connected.
+func GetNotificationWS(sid string) *util.WSChannel {
+	notificationWSMutex.RLock()
+	defer notificationWSMutex.RUnlock()
+
+	return NotificationWS[sid]
+}
+
+// SetNotificationWS sets the specified channel as the notification channel of the session specified by sid.
+func SetNotificationWS(sid string, wsChannel *util.WSChannel) {
+	notificationWSMutex.Lock()
+	defer notificationWSMutex.Unlock()
+
+	NotificationWS[sid] = wsChannel
+}
+
+// RemoveNotificationWS removes the notification channel of the session specified by sid if it is the specified
+// channel, so a channel reconnected meanwhile is kept. Returns whether removed.
+func RemoveNotificationWS(sid string, wsChannel *util.WSChannel) bool {
+	notificationWSMutex.Lock()
+	defer notificationWSMutex.Unlock()
+
+	if wsChannel != NotificationWS[sid] {
+		return false
+	}
+
+	delete(NotificationWS, sid)
+
+	return true
+}
+
+// CountNotificationWS gets the number of notification channels.
+func CountNotificationWS() int {
+	notificationWSMutex.RLock()
+	defer notificationWSMutex.RUnlock()
+
+	return len(NotificationWS)
+}
+
 // HTTP session store.
 var HTTPSession = sessions.NewCookieStore([]byte("BEYOND"))
 
 // WideSession represents a session associated with a browser tab.
 type WideSession struct {
-	ID          string                     // id
-	Username    string                     // username
-	HTTPSession *sessions.Session          // HTTP session related
-	Processes   []*os.Process              // process set
-	EventQueue  *event.UserEventQueue      // event queue
-	State       int                        // state
-	Content     *conf.LatestSessionContent // the latest session content
-	FileWatcher *fsnotify.Watcher          // files change watcher
-	Created     time.Time                  // create time
-	Updated     time.Time                  // the latest use time
+	ID               string                     // id
+	Username         string                     // username
+	HTTPSession      *sessions.Session          // HTTP session related
+	Processes        []*os.Process              // process set
+	EventQueue       *event.UserEventQueue      // event queue
+	State            int                        // state
+	Content          *conf.LatestSessionContent // the latest session content
+	FileWatcher      *fsnotify.Watcher          // files change watcher
+	BuildTarget      string                     // directory of the selected main package to build and run, empty means the current file's
+	NotificationOnce sync.Once                  // adds the notification event handler to EventQueue once
+	Created          time.Time                  // create time
+	Updated          time.Time                  // the latest use time
 }
 
 // Type of wide sessions.
@@ -225,8 +270,7 @@ func WSHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		httpSession.Options.MaxAge = conf.Wide.HTTPSessionMaxAge
-		httpSession.Save(r, w)
+		SaveHTTPSession(w, r, httpSession)
 
 		wSession = WideSessions.new(httpSession, sid)
 
@@ -248,7 +292,7 @@ func WSHandler(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	// send websocket ping message.
-	go func(t *time.Ticker, channel util.WSChannel) {
+	go func(t *time.Ticker, channel *util.WSChannel) {
 		for {
 			select {
 			case <-t.C:
@@ -258,7 +302,7 @@ func WSHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-	}(ticker, wsChan)
+	}(ticker, &wsChan)
 
 	for {
 		if err := wsChan.ReadJSON(&input); err != nil {
@@ -296,8 +340,15 @@ func SaveContentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew || IsReadOnlyRequest(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
 	wSession := WideSessions.Get(args.Sid)
-	if nil == wSession {
+	if nil == wSession || wSession.Username != httpSession.Values["username"] {
 		result.Succ = false
 
 		return
@@ -305,7 +356,7 @@ func SaveContentHandler(w http.ResponseWriter, r *http.Request) {
 
 	wSession.Content = args.LatestSessionContent
 
-	for _, user := range conf.Users {
+	for _, user := range conf.GetUsers() {
 		if user.Name == wSession.Username {
 			// update the variable in-memory, session.FixedTimeSave() function will persist it periodically
 			user.LatestSessionContent = wSession.Content
@@ -360,6 +411,9 @@ func (sessions *wSessions) Get(sid string) *WideSession {
 //  2. process set
 //  3. websocket channels
 //  4. file watcher
+//  5. file locks
+//  6. shares
+//  7. presence
 func (sessions *wSessions) Remove(sid string) {
 	mutex.Lock()
 	defer mutex.Unlock()
@@ -387,9 +441,8 @@ func (sessions *wSessions) Remove(sid string) {
 				delete(OutputWS, sid)
 			}
 
-			if ws, ok := NotificationWS[sid]; ok {
+			if ws := GetNotificationWS(sid); nil != ws && RemoveNotificationWS(sid, ws) {
 				ws.Close()
-				delete(NotificationWS, sid)
 			}
 
 			if ws, ok := SessionWS[sid]; ok {
@@ -397,6 +450,11 @@ func (sessions *wSessions) Remove(sid string) {
 				delete(SessionWS, sid)
 			}
 
+			if ws, ok := EditorWS[sid]; ok {
+				ws.Close()
+				delete(EditorWS, sid)
+			}
+
 			if ws, ok := PlaygroundWS[sid]; ok {
 				ws.Close()
 				delete(PlaygroundWS, sid)
@@ -407,6 +465,12 @@ func (sessions *wSessions) Remove(sid string) {
 				s.FileWatcher.Close()
 			}
 
+			releaseFileLocks(sid)
+			releaseShares(sid)
+			go releasePresence(sid, s.Username) // the session lock is held
+
+			event.Publish(&event.Event{Code: event.EvtCodeSessionRemoved, Sid: sid, Data: s.Username})
+
 			cnt := 0 // count wide sessions associated with HTTP session
 			for _, ses := range *sessions {
 				if ses.Username == s.Username {
@@ -437,6 +501,33 @@ func (sessions *wSessions) GetByUsername(username string) []*WideSession {
 	return ret
 }
 
+// newLightweight creates a non-interactive wide session (of an API token for example) with the specified HTTP session
+// and session id, it has an event queue but no file watcher.
+func (sessions *wSessions) newLightweight(httpSession *sessions.Session, sid string) *WideSession {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	username := httpSession.Values["username"].(string)
+	now := time.Now()
+
+	ret := &WideSession{
+		ID:          sid,
+		Username:    username,
+		HTTPSession: httpSession,
+		EventQueue:  event.UserEventQueues.New(sid),
+		State:       sessionStateActive,
+		Content:     &conf.LatestSessionContent{},
+		Created:     now,
+		Updated:     now,
+	}
+
+	*sessions = append(*sessions, ret)
+
+	logger.Debugf("Created a lightweight session [%s] of user [%s]", sid, username)
+
+	return ret
+}
+
 // new creates a wide session.
 func (sessions *wSessions) new(httpSession *sessions.Session, sid string) *WideSession {
 	mutex.Lock()
@@ -465,6 +556,8 @@ func (sessions *wSessions) new(httpSession *sessions.Session, sid string) *WideS
 	// create user event queue
 	ret.EventQueue = event.UserEventQueues.New(sid)
 
+	event.Publish(&event.Event{Code: event.EvtCodeSessionCreated, Sid: sid, Data: username})
+
 	// add a filesystem watcher to notify front-end after the files changed
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
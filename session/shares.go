@@ -0,0 +1,321 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/util"
+)
+
+// Share represents a shared session, a directory of the owner's workspace edited by the invited users together.
+//
+// Invited users could access the directory, wide sessions joined the share receive edits and cursors of each other
+// over the editor channel. Concurrent edits aren't merged, the last writer wins.
+type Share struct {
+	ID      string          `json:"id"`
+	Owner   string          `json:"owner"`
+	Dir     string          `json:"dir"`
+	Users   []string        `json:"users"` // invited users
+	Created time.Time       `json:"created"`
+	sid     string          // wide session of the owner created the share, the share is closed with it
+	sids    map[string]bool // joined wide sessions
+}
+
+// Shares, <id, share>.
+var shares = map[string]*Share{}
+
+// Exclusive lock of shares.
+var sharesMutex sync.RWMutex
+
+// NewShareHandler handles request of sharing a directory of the user's workspace.
+//
+// Argument "dir" is the directory to share, argument "users" is the usernames to invite. The share is closed when the
+// session specified by argument "sid" is removed.
+func NewShareHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	sid := args["sid"].(string)
+	if s := WideSessions.Get(sid); nil == s || s.Username != username {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	dir := filepath.Clean(filepath.FromSlash(args["dir"].(string)))
+	if !canAccessOwn(username, dir) || !util.File.IsDir(dir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	users := []string{}
+	if arr, ok := args["users"].([]interface{}); ok {
+		for _, u := range arr {
+			name, _ := u.(string)
+			if nil == conf.GetUser(name) || name == username {
+				result.Succ = false
+				result.Msg = "user [" + name + "] can't be invited"
+
+				return
+			}
+
+			users = append(users, name)
+		}
+	}
+	if 1 > len(users) {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+
+		return
+	}
+
+	share := &Share{ID: WideSessions.GenId(), Owner: username, Dir: filepath.ToSlash(dir), Users: users,
+		Created: time.Now(), sid: sid, sids: map[string]bool{sid: true}}
+
+	sharesMutex.Lock()
+	shares[share.ID] = share
+	sharesMutex.Unlock()
+
+	logger.Infof("User [%s] shared [%s] with %v", username, dir, users)
+
+	result.Data = share
+}
+
+// JoinShareHandler handles request of joining the share specified by argument "id" with the session specified by
+// argument "sid".
+func JoinShareHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	if IsReadOnlyRequest(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	sid := args["sid"].(string)
+	if s := WideSessions.Get(sid); nil == s || s.Username != username {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	id, _ := args["id"].(string)
+
+	sharesMutex.Lock()
+	defer sharesMutex.Unlock()
+
+	share := shares[id]
+	if nil == share || (share.Owner != username && !util.Str.Contains(username, share.Users)) {
+		result.Succ = false
+		result.Msg = "share not found"
+
+		return
+	}
+
+	share.sids[sid] = true
+
+	result.Data = share
+}
+
+// CloseShareHandler handles request of closing the share specified by argument "id", only the owner could close it.
+func CloseShareHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	if IsReadOnlyRequest(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	id, _ := args["id"].(string)
+
+	sharesMutex.Lock()
+	defer sharesMutex.Unlock()
+
+	if share := shares[id]; nil != share && share.Owner == username {
+		delete(shares, id)
+	}
+}
+
+// SharesHandler handles request of listing shares owned by or inviting the user.
+func SharesHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+	username := httpSession.Values["username"].(string)
+
+	result.Data = GetShares(username)
+}
+
+// GetShares gets shares owned by or inviting the user specified by username.
+func GetShares(username string) []*Share {
+	ret := []*Share{}
+
+	sharesMutex.RLock()
+	defer sharesMutex.RUnlock()
+
+	for _, share := range shares {
+		if share.Owner == username || util.Str.Contains(username, share.Users) {
+			ret = append(ret, share)
+		}
+	}
+
+	return ret
+}
+
+// GetShareSids gets ids of wide sessions joined the shares containing the file specified by path, the session
+// specified by sid excluded. Returns nil if the session hasn't joined any of the shares.
+func GetShareSids(sid, path string) []string {
+	path = filepath.Clean(filepath.FromSlash(path))
+
+	sharesMutex.RLock()
+	defer sharesMutex.RUnlock()
+
+	joined := false
+	sids := map[string]bool{}
+	for _, share := range shares {
+		if !isUnder(path, filepath.FromSlash(share.Dir)) || !share.sids[sid] {
+			continue
+		}
+
+		joined = true
+		for s := range share.sids {
+			if s != sid {
+				sids[s] = true
+			}
+		}
+	}
+
+	if !joined {
+		return nil
+	}
+
+	ret := []string{}
+	for s := range sids {
+		ret = append(ret, s)
+	}
+
+	return ret
+}
+
+// sharedPath validates the specified absolute path against the shares the user specified by username is invited to,
+// returns the cleaned path.
+func sharedPath(username, path string) (string, error) {
+	path = filepath.FromSlash(path)
+	if !filepath.IsAbs(path) {
+		return "", errors.New("path [" + path + "] is not absolute")
+	}
+
+	sharesMutex.RLock()
+	defer sharesMutex.RUnlock()
+
+	for _, share := range shares {
+		if !util.Str.Contains(username, share.Users) {
+			continue
+		}
+
+		if ret, err := util.File.SafeJoin(filepath.FromSlash(share.Dir), path); nil == err {
+			return ret, nil
+		}
+	}
+
+	return "", util.ErrPathEscaped
+}
+
+// releaseShares closes shares created by the wide session specified by sid and leaves the shares it joined.
+func releaseShares(sid string) {
+	sharesMutex.Lock()
+	defer sharesMutex.Unlock()
+
+	for id, share := range shares {
+		if share.sid == sid {
+			delete(shares, id)
+
+			continue
+		}
+
+		delete(share.sids, sid)
+	}
+}
+
+// isUnder checks whether the specified path is the specified directory or under it.
+func isUnder(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/util"
+)
+
+// copyStarterKit copies contents of the starter kit directory of the specified role (conf.Wide.StarterKits, falls
+// back to conf.Wide.StarterKit) into the specified workspace of a new user, the directory layout of the starter kit
+// is the same as a workspace (src/hello/main.go for example).
+//
+// Returns false if the starter kit isn't configured, doesn't exist or exceeds the user quota (conf.Wide.UserQuota),
+// nothing is copied in these cases.
+func copyStarterKit(workspace, role string) bool {
+	kit := conf.Wide.GetStarterKit(role)
+	if "" == kit {
+		return false
+	}
+
+	if !util.File.IsDir(kit) {
+		logger.Warnf("Starter kit [%s] is not a directory", kit)
+
+		return false
+	}
+
+	var size int64
+	filepath.Walk(kit, func(path string, info os.FileInfo, err error) error {
+		if nil == err && !info.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	if 0 < conf.Wide.UserQuota && conf.Wide.UserQuota < size {
+		logger.Warnf("Starter kit [%s] (%d bytes) exceeds the user quota [%d bytes]", kit, size,
+			conf.Wide.UserQuota)
+
+		return false
+	}
+
+	if err := util.File.CopyDir(kit, workspace); nil != err {
+		logger.Error(err)
+
+		return false
+	}
+
+	logger.Debugf("Copied starter kit [%s] of role [%s] into workspace [%s]", kit, role, workspace)
+
+	return true
+}
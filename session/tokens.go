@@ -0,0 +1,255 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/util"
+)
+
+// tokenMaxCount is the max count of API tokens of a user.
+const tokenMaxCount = 16
+
+// tokenUsedSaveInterval is the min interval of persisting the latest use time of an API token.
+const tokenUsedSaveInterval = time.Minute
+
+// TokenSessionHeader is the response header carrying the id of the wide session of an API token request.
+const TokenSessionHeader = "X-Wide-Session"
+
+// Exclusive lock of API tokens of users.
+var tokensMutex sync.Mutex
+
+// TokensHandler handles request of listing API tokens of the session user, the token hashes are not returned.
+func TokensHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	user := getTokenOwner(w, r)
+	if nil == user {
+		return
+	}
+
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+
+	tokens := []map[string]interface{}{}
+	for _, token := range user.APITokens {
+		tokens = append(tokens, map[string]interface{}{"id": token.ID, "name": token.Name, "scope": token.Scope,
+			"created": token.Created, "used": token.Used})
+	}
+
+	result.Data = tokens
+}
+
+// NewTokenHandler handles request of generating an API token with arguments "name" and "scope" (read/full) for the
+// session user.
+//
+// The token is returned only once by this request, only its hash is stored.
+func NewTokenHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	user := getTokenOwner(w, r)
+	if nil == user {
+		return
+	}
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	name, _ := args["name"].(string)
+	name = strings.TrimSpace(name)
+	scope, _ := args["scope"].(string)
+	if "" == scope {
+		scope = conf.TokenScopeRead
+	}
+	if !conf.IsTokenScope(scope) {
+		result.Succ = false
+		result.Msg = "token scope [" + scope + "] is unsupported"
+
+		return
+	}
+
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+
+	if tokenMaxCount <= len(user.APITokens) {
+		result.Succ = false
+		result.Msg = "too many tokens, please revoke unused ones"
+
+		return
+	}
+
+	token, apiToken, err := user.NewAPIToken(name, scope)
+	if nil != err {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	if !user.Save() {
+		user.RevokeAPIToken(apiToken.ID)
+		result.Succ = false
+
+		return
+	}
+
+	logger.Infof("User [%s] generated an API token [%s, scope=%s]", user.Name, apiToken.ID, scope)
+
+	result.Data = map[string]interface{}{"token": token, "id": apiToken.ID, "name": name, "scope": scope,
+		"created": apiToken.Created}
+}
+
+// RevokeTokenHandler handles request of revoking the API token specified by argument "id" of the session user.
+func RevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	result := util.NewResult()
+	defer util.RetResult(w, r, result)
+
+	user := getTokenOwner(w, r)
+	if nil == user {
+		return
+	}
+
+	var args map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		logger.Error(err)
+		result.Succ = false
+
+		return
+	}
+
+	id, _ := args["id"].(string)
+
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+
+	if !user.RevokeAPIToken(id) {
+		result.Succ = false
+		result.Msg = "token [" + id + "] not found"
+
+		return
+	}
+
+	user.Save()
+
+	logger.Infof("User [%s] revoked an API token [%s]", user.Name, id)
+}
+
+// UseAPIToken authenticates the specified request with the specified API token, the HTTP session of the request is
+// resolved to the token's user without a cookie, returns false if the token is invalid.
+//
+// The scope of the token is kept in the session value "tokenScope", see IsReadOnlyRequest. The token has a
+// lightweight non-interactive wide session (without file watcher) for the endpoints requiring argument "sid", its id
+// is responded by header TokenSessionHeader.
+func UseAPIToken(w http.ResponseWriter, r *http.Request, token string) bool {
+	user, apiToken := conf.GetUserByAPIToken(token)
+	if nil == user {
+		return false
+	}
+
+	httpSession, _ := HTTPSession.Get(r, "wide-session") // cached by the request, the following gets see the values
+	httpSession.Values["username"] = user.Name
+	httpSession.Values["tokenScope"] = apiToken.Scope
+	httpSession.IsNew = false
+
+	sid := "token-" + user.Name + "-" + apiToken.ID
+	wSession := WideSessions.Get(sid)
+	if nil == wSession {
+		wSession = WideSessions.newLightweight(httpSession, sid)
+	}
+	wSession.Refresh()
+	w.Header().Set(TokenSessionHeader, sid)
+
+	now := time.Now().UnixNano()
+
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+
+	save := int64(tokenUsedSaveInterval) <= now-apiToken.Used
+	apiToken.Used = now
+	if save && !user.Save() {
+		logger.Warnf("Saves the latest use time of API token [%s] of user [%s] failed", apiToken.ID, user.Name)
+	}
+
+	return true
+}
+
+// IsTokenRequest checks whether the specified request is authenticated by an API token (of any scope).
+func IsTokenRequest(r *http.Request) bool {
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	_, ok := httpSession.Values["tokenScope"]
+
+	return ok
+}
+
+// IsReadOnlyRequest checks whether the specified request is authenticated by a read-only API token.
+func IsReadOnlyRequest(r *http.Request) bool {
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	scope, _ := httpSession.Values["tokenScope"].(string)
+
+	return conf.TokenScopeRead == scope
+}
+
+// CanWrite checks whether the specified request could modify files: the session user exists and isn't a viewer, and
+// the request isn't authenticated by a read-only API token.
+func CanWrite(r *http.Request) bool {
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew || IsReadOnlyRequest(r) {
+		return false
+	}
+
+	username, _ := httpSession.Values["username"].(string)
+	user := conf.GetUser(username)
+
+	return nil != user && !user.IsViewer()
+}
+
+// getTokenOwner gets the user of the specified request for managing API tokens, responds 403 and returns nil if the
+// request isn't authenticated by the session cookie (tokens can't manage tokens).
+func getTokenOwner(w http.ResponseWriter, r *http.Request) *conf.User {
+	httpSession, _ := HTTPSession.Get(r, "wide-session")
+	if httpSession.IsNew {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return nil
+	}
+
+	if IsTokenRequest(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return nil
+	}
+
+	user := conf.GetUser(httpSession.Values["username"].(string))
+	if nil == user {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return nil
+	}
+
+	return user
+}
@@ -18,6 +18,7 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"math/rand"
 	"net/http"
 	"os"
@@ -26,11 +27,11 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"text/template"
 	"time"
 
 	"github.com/b3log/wide/conf"
 	"github.com/b3log/wide/i18n"
+	"github.com/b3log/wide/log"
 	"github.com/b3log/wide/util"
 )
 
@@ -57,11 +58,7 @@ func PreferenceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	httpSession.Options.MaxAge = conf.Wide.HTTPSessionMaxAge
-	if "" != conf.Wide.Context {
-		httpSession.Options.Path = conf.Wide.Context
-	}
-	httpSession.Save(r, w)
+	SaveHTTPSession(w, r, httpSession)
 
 	username := httpSession.Values["username"].(string)
 	user := conf.GetUser(username)
@@ -80,7 +77,7 @@ func PreferenceHandler(w http.ResponseWriter, r *http.Request) {
 			"locales": i18n.GetLocalesNames(), "gofmts": util.Go.GetGoFormats(),
 			"themes": conf.GetThemes(), "editorThemes": conf.GetEditorThemes()}
 
-		t, err := template.ParseFiles("views/preference.html")
+		t, err := util.Template.Get("preference.html")
 
 		if nil != err {
 			logger.Error(err)
@@ -103,6 +100,12 @@ func PreferenceHandler(w http.ResponseWriter, r *http.Request) {
 
 	// non-GET request as save request
 
+	if IsTokenRequest(r) { // credentials and startup command can't be changed by API tokens
+		http.Error(w, "Forbidden", http.StatusForbidden)
+
+		return
+	}
+
 	result := util.NewResult()
 	defer util.RetResult(w, r, result)
 
@@ -114,6 +117,13 @@ func PreferenceHandler(w http.ResponseWriter, r *http.Request) {
 		GoBuildArgsForWindows string
 		GoBuildArgsForDarwin  string
 		Keymap                string
+		AutoTest              bool
+		LintConf              string
+		LineEnding            string
+		StartupCmd            string
+		MailNotify            bool
+		TreeSort              string
+		TreeMixed             bool
 		Workspace             string
 		Username              string
 		Password              string
@@ -134,6 +144,27 @@ func PreferenceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !conf.IsEditorTheme(args.EditorTheme) {
+		result.Succ = false
+		result.Msg = "editor theme [" + args.EditorTheme + "] is unavailable"
+
+		return
+	}
+
+	if "" != args.LineEnding && "lf" != args.LineEnding && "crlf" != args.LineEnding {
+		result.Succ = false
+		result.Msg = "line ending [" + args.LineEnding + "] is unsupported"
+
+		return
+	}
+
+	if !conf.IsTreeSort(args.TreeSort) {
+		result.Succ = false
+		result.Msg = "file tree sort [" + args.TreeSort + "] is unsupported"
+
+		return
+	}
+
 	user.FontFamily = args.FontFamily
 	user.FontSize = args.FontSize
 	user.GoFormat = args.GoFmt
@@ -141,6 +172,13 @@ func PreferenceHandler(w http.ResponseWriter, r *http.Request) {
 	user.GoBuildArgsForWindows = args.GoBuildArgsForWindows
 	user.GoBuildArgsForDarwin = args.GoBuildArgsForDarwin
 	user.Keymap = args.Keymap
+	user.AutoTest = args.AutoTest
+	user.LintConf = args.LintConf
+	user.LineEnding = args.LineEnding
+	user.StartupCmd = strings.TrimSpace(args.StartupCmd)
+	user.MailNotify = args.MailNotify
+	user.TreeSort = args.TreeSort
+	user.TreeMixed = args.TreeMixed
 	// XXX: disallow change workspace at present
 	// user.Workspace = args.Workspace
 	if user.Password != args.Password {
@@ -177,7 +215,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		model := map[string]interface{}{"conf": conf.Wide, "i18n": i18n.GetAll(conf.Wide.Locale),
 			"locale": conf.Wide.Locale, "ver": conf.WideVersion, "year": time.Now().Year()}
 
-		t, err := template.ParseFiles("views/login.html")
+		t, err := util.Template.Get("login.html")
 
 		if nil != err {
 			logger.Error(err)
@@ -203,30 +241,31 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	args.Username = r.FormValue("username")
 	args.Password = r.FormValue("password")
 
-	result.Succ = false
-	for _, user := range conf.Users {
-		if user.Name == args.Username && user.Password == conf.Salt(args.Password, user.Salt) {
-			result.Succ = true
+	var user *conf.User
+	for _, u := range conf.GetUsers() {
+		if u.Name == args.Username && u.Password == conf.Salt(args.Password, u.Salt) {
+			user = u
 
 			break
 		}
 	}
 
+	result.Succ = nil != user
 	if !result.Succ {
+		logger.Log(log.Info, "login failed", log.Fields{"requestId": util.Request.GetID(r), "username": args.Username,
+			"remoteAddr": r.RemoteAddr})
+
 		return
 	}
 
 	// create a HTTP session
 	httpSession, _ := HTTPSession.Get(r, "wide-session")
-	httpSession.Values["username"] = args.Username
+	SetHTTPSessionUser(httpSession, user)
 	httpSession.Values["id"] = strconv.Itoa(rand.Int())
-	httpSession.Options.MaxAge = conf.Wide.HTTPSessionMaxAge
-	if "" != conf.Wide.Context {
-		httpSession.Options.Path = conf.Wide.Context
-	}
-	httpSession.Save(r, w)
+	SaveHTTPSession(w, r, httpSession)
 
-	logger.Debugf("Created a HTTP session [%s] for user [%s]", httpSession.Values["id"].(string), args.Username)
+	logger.Log(log.Debug, "logged in", log.Fields{"requestId": util.Request.GetID(r), "username": args.Username,
+		"httpSession": httpSession.Values["id"]})
 }
 
 // LogoutHandler handles request of user logout (exit).
@@ -237,7 +276,7 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	httpSession, _ := HTTPSession.Get(r, "wide-session")
 
 	httpSession.Options.MaxAge = -1
-	httpSession.Save(r, w)
+	SaveHTTPSession(w, r, httpSession)
 }
 
 // SignUpUserHandler handles request of registering user.
@@ -249,7 +288,7 @@ func SignUpUserHandler(w http.ResponseWriter, r *http.Request) {
 			"locale": conf.Wide.Locale, "ver": conf.WideVersion, "dir": conf.Wide.UsersWorkspaces,
 			"pathSeparator": conf.PathSeparator, "year": time.Now().Year()}
 
-		t, err := template.ParseFiles("views/sign_up.html")
+		t, err := util.Template.Get("sign_up.html")
 
 		if nil != err {
 			logger.Error(err)
@@ -291,13 +330,9 @@ func SignUpUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	// create a HTTP session
 	httpSession, _ := HTTPSession.Get(r, "wide-session")
-	httpSession.Values["username"] = username
+	SetHTTPSessionUser(httpSession, conf.GetUser(username))
 	httpSession.Values["id"] = strconv.Itoa(rand.Int())
-	httpSession.Options.MaxAge = conf.Wide.HTTPSessionMaxAge
-	if "" != conf.Wide.Context {
-		httpSession.Options.Path = conf.Wide.Context
-	}
-	httpSession.Save(r, w)
+	SaveHTTPSession(w, r, httpSession)
 }
 
 // FixedTimeSave saves online users' configurations periodically (1 minute).
@@ -313,20 +348,51 @@ func FixedTimeSave() {
 	}()
 }
 
-// CanAccess determines whether the user specified by the given username can access the specified path.
+// CanAccess determines whether the user specified by the given username can access the specified path, a path of
+// the user's workspace or of a directory shared with the user (see Share).
 func CanAccess(username, path string) bool {
+	_, err := SafePath(username, path)
+
+	return nil == err
+}
+
+// SafePath validates the specified absolute path against the workspaces of the user specified by username and the
+// directories shared with the user, returns the cleaned path.
+//
+// The path is checked by util.File.SafeJoin, so ".." escapes and symbolic links pointing outside are rejected.
+func SafePath(username, path string) (string, error) {
+	if ret, err := ownPath(username, path); nil == err {
+		return ret, nil
+	}
+
+	return sharedPath(username, path)
+}
+
+// canAccessOwn determines whether the specified path is in the workspace of the user specified by username.
+func canAccessOwn(username, path string) bool {
+	_, err := ownPath(username, path)
+
+	return nil == err
+}
+
+// ownPath validates the specified absolute path against the workspaces of the user specified by username, returns
+// the cleaned path.
+func ownPath(username, path string) (string, error) {
 	path = filepath.FromSlash(path)
+	if !filepath.IsAbs(path) {
+		return "", errors.New("path [" + path + "] is not absolute")
+	}
 
 	userWorkspace := conf.GetUserWorkspace(username)
 	workspaces := filepath.SplitList(userWorkspace)
 
 	for _, workspace := range workspaces {
-		if strings.HasPrefix(path, workspace) {
-			return true
+		if ret, err := util.File.SafeJoin(workspace, path); nil == err {
+			return ret, nil
 		}
 	}
 
-	return false
+	return "", util.ErrPathEscaped
 }
 
 // SaveOnlineUsers saves online users' configurations at once.
@@ -386,7 +452,7 @@ func addUser(username, password, email string) string {
 	addUserMutex.Lock()
 	defer addUserMutex.Unlock()
 
-	for _, user := range conf.Users {
+	for _, user := range conf.GetUsers() {
 		if strings.ToLower(user.Name) == strings.ToLower(username) {
 			return userExists
 		}
@@ -399,18 +465,19 @@ func addUser(username, password, email string) string {
 	workspace := filepath.Join(conf.Wide.UsersWorkspaces, username)
 
 	newUser := conf.NewUser(username, password, email, workspace)
-	conf.Users = append(conf.Users, newUser)
+	conf.SetUsers(append(conf.GetUsers(), newUser))
 
 	if !newUser.Save() {
 		return userCreateError
 	}
 
 	conf.CreateWorkspaceDir(workspace)
-	helloWorld(workspace)
+	if !copyStarterKit(workspace, newUser.Role) {
+		helloWorld(workspace)
+	}
 	conf.UpdateCustomizedConf(username)
 
-	http.Handle("/workspace/"+username+"/",
-		http.StripPrefix("/workspace/"+username+"/", http.FileServer(http.Dir(newUser.WorkspacePath()))))
+	ServeWorkspace(newUser)
 
 	logger.Infof("Created a user [%s]", username)
 
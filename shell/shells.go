@@ -16,10 +16,11 @@
 package shell
 
 import (
-	"html/template"
+	"bytes"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
@@ -27,6 +28,7 @@ import (
 	"github.com/b3log/wide/conf"
 	"github.com/b3log/wide/i18n"
 	"github.com/b3log/wide/log"
+	"github.com/b3log/wide/output"
 	"github.com/b3log/wide/session"
 	"github.com/b3log/wide/util"
 	"github.com/gorilla/websocket"
@@ -49,11 +51,7 @@ func IndexHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	httpSession.Options.MaxAge = conf.Wide.HTTPSessionMaxAge
-	if "" != conf.Wide.Context {
-		httpSession.Options.Path = conf.Wide.Context
-	}
-	httpSession.Save(r, w)
+	session.SaveHTTPSession(w, r, httpSession)
 
 	username := httpSession.Values["username"].(string)
 	locale := conf.GetUser(username).Locale
@@ -65,7 +63,7 @@ func IndexHandler(w http.ResponseWriter, r *http.Request) {
 
 	logger.Tracef("User [%s] has [%d] sessions", username, len(wideSessions))
 
-	t, err := template.ParseFiles("views/shell.html")
+	t, err := util.Template.Get("shell.html")
 
 	if nil != err {
 		logger.Error(err)
@@ -129,7 +127,7 @@ func WSHandler(w http.ResponseWriter, r *http.Request) {
 
 		output := ""
 		if !strings.Contains(inputCmd, "clear") {
-			output = pipeCommands(username, commands...)
+			output = pipeCommands(sid, username, commands...)
 		}
 
 		ret = map[string]interface{}{"output": output, "cmd": "shell-output"}
@@ -143,7 +141,7 @@ func WSHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func pipeCommands(username string, commands ...*exec.Cmd) string {
+func pipeCommands(sid, username string, commands ...*exec.Cmd) string {
 	for i, command := range commands[:len(commands)-1] {
 		setCmdEnv(command, username)
 
@@ -152,7 +150,9 @@ func pipeCommands(username string, commands ...*exec.Cmd) string {
 			return err.Error()
 		}
 
-		command.Start()
+		if err := command.Start(); nil == err {
+			defer output.Processes.Track(sid, command, output.ProcKindShell)()
+		}
 
 		commands[i+1].Stdin = stdout
 	}
@@ -160,7 +160,14 @@ func pipeCommands(username string, commands ...*exec.Cmd) string {
 	last := commands[len(commands)-1]
 	setCmdEnv(last, username)
 
-	out, err := last.CombinedOutput()
+	out := &bytes.Buffer{}
+	last.Stdout, last.Stderr = out, out
+	err := last.Start()
+	if nil == err {
+		untrack := output.Processes.Track(sid, last, output.ProcKindShell)
+		err = last.Wait()
+		untrack()
+	}
 
 	// release resources
 	for _, command := range commands[:len(commands)-1] {
@@ -171,19 +178,20 @@ func pipeCommands(username string, commands ...*exec.Cmd) string {
 		return err.Error()
 	}
 
-	return string(out)
+	return out.String()
 }
 
 func setCmdEnv(cmd *exec.Cmd, username string) {
 	userWorkspace := conf.GetUserWorkspace(username)
+	goRoot := conf.GetGoRoot(username)
 
 	cmd.Env = append(cmd.Env,
 		"TERM="+os.Getenv("TERM"),
 		"GOPATH="+userWorkspace,
 		"GOOS="+runtime.GOOS,
 		"GOARCH="+runtime.GOARCH,
-		"GOROOT="+runtime.GOROOT(),
-		"PATH="+os.Getenv("PATH"))
+		"GOROOT="+goRoot,
+		"PATH="+filepath.Join(goRoot, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"))
 
 	cmd.Dir = userWorkspace
 }
@@ -0,0 +1,155 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"bufio"
+	"context"
+	"html"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/b3log/wide/conf"
+	"github.com/b3log/wide/event"
+	"github.com/b3log/wide/session"
+	"github.com/b3log/wide/util"
+)
+
+const (
+	startupTimeout     = 5 * time.Minute        // max running time of a startup command
+	startupOutputWait  = 10 * time.Second       // max time waiting for the output channel of a new session
+	startupOutputCheck = 200 * time.Millisecond // interval of checking the output channel
+)
+
+// Load subscribes to session creation for running startup commands of users.
+func Load() {
+	event.Subscribe(event.EvtCodeSessionCreated, event.HandleFunc(runStartupCmd))
+}
+
+// runStartupCmd runs the startup command (conf.User.StartupCmd) of the user of the new session specified by the
+// event asynchronously.
+//
+// The command is run by the system shell in the user workspace with the same environment as the shell, its output
+// is pushed to the output channel of the session as the startup console. The result is notified to the session, a
+// failure doesn't affect the session. Startup commands of viewers are ignored.
+func runStartupCmd(e *event.Event) {
+	username, _ := e.Data.(string)
+	user := conf.GetUser(username)
+	if nil == user || "" == user.StartupCmd || user.IsViewer() {
+		return
+	}
+
+	go func() {
+		defer util.Recover()
+
+		sid := e.Sid
+		command := user.StartupCmd
+
+		// the output channel is opened by the front-end after the session channel
+		deadline := time.Now().Add(startupOutputWait)
+		for nil == session.OutputWS[sid] && time.Now().Before(deadline) {
+			time.Sleep(startupOutputCheck)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+		defer cancel()
+
+		var cmd *exec.Cmd
+		if "windows" == runtime.GOOS {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+		} else {
+			cmd = exec.CommandContext(ctx, "sh", "-c", command)
+		}
+		setCmdEnv(cmd, username)
+		cmd.Dir = filepath.SplitList(cmd.Dir)[0]
+
+		logger.Debugf("User [%s, %s] is running startup command [%s]", username, sid, command)
+
+		writeStartupOutput(sid, "start-startup", html.EscapeString("$ "+command+"\n"))
+
+		err := runStartupProcess(cmd, sid)
+		if nil == err && nil != ctx.Err() {
+			err = ctx.Err()
+		}
+
+		wSession := session.WideSessions.Get(sid)
+		if nil != err {
+			logger.Warnf("User [%s, %s] 's startup command [%s] failed: %s", username, sid, command, err)
+
+			writeStartupOutput(sid, "startup", html.EscapeString(err.Error()+"\n"))
+			if nil != wSession && nil != wSession.EventQueue {
+				wSession.EventQueue.Queue <- &event.Event{Code: event.EvtCodeStartupFailed, Sid: sid, Data: err.Error()}
+			}
+
+			return
+		}
+
+		logger.Debugf("User [%s, %s] 's startup command [%s] succeeded", username, sid, command)
+
+		if nil != wSession && nil != wSession.EventQueue {
+			wSession.EventQueue.Queue <- &event.Event{Code: event.EvtCodeStartupSucceeded, Sid: sid}
+		}
+	}()
+}
+
+// runStartupProcess starts the specified command and pushes its output to the output channel of the session
+// specified by sid line by line, returns the error of waiting the command.
+func runStartupProcess(cmd *exec.Cmd, sid string) error {
+	stdout, err := cmd.StdoutPipe()
+	if nil != err {
+		return err
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); nil != err {
+		return err
+	}
+
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadString('\n')
+		if "" != line {
+			writeStartupOutput(sid, "startup", html.EscapeString(line))
+		}
+
+		if nil != err {
+			if io.EOF != err {
+				logger.Warn(err)
+			}
+
+			break
+		}
+	}
+
+	return cmd.Wait()
+}
+
+// writeStartupOutput pushes the specified output to the output channel of the session specified by sid.
+func writeStartupOutput(sid, cmd, output string) {
+	wsChannel := session.OutputWS[sid]
+	if nil == wsChannel {
+		return
+	}
+
+	channelRet := map[string]interface{}{"cmd": cmd, "output": output}
+	if err := wsChannel.WriteJSON(&channelRet); nil != err {
+		logger.Warn(err)
+	}
+
+	wsChannel.Refresh()
+}
@@ -113,6 +113,7 @@ var editors = {
 
                 $("#dialogCloseEditor button.discard").click(function () {
                     var i = $("#dialogCloseEditor").data("index");
+                    editors._discardDraft(editors.data[i].editor.options.path);
                     editors.tabs.del(editors.data[i].id);
                     $("#dialogCloseEditor").dialog("close");
                     editors._removeAllMarker();
@@ -125,6 +126,12 @@ var editors = {
             }
         });
 
+        if (0 < config.autosaveInterval) {
+            setInterval(editors._autosave, config.autosaveInterval * 1000);
+        }
+
+        editors._initCollab();
+
         editors.tabs = new Tabs({
             id: ".edit-panel",
             setAfter: function () {
@@ -176,9 +183,10 @@ var editors = {
                         break;
                     }
                 }
+                editors._reportPresence();
 
                 if (editors.data.length === 0) { // 起始页可能存在，所以用编辑器数据判断
-                    menu.disabled(['save-all', 'build', 'run', 'go-test', 'go-vet', 'go-get', 'go-install',
+                    menu.disabled(['save-all', 'build', 'run', 'go-test', 'go-vet', 'go-get', 'go-install', 'go-generate',
                         'find', 'find-next', 'find-previous', 'replace', 'replace-all',
                         'format', 'autocomplete', 'jump-to-decl', 'expr-info', 'find-usages', 'toggle-comment',
                         'edit']);
@@ -297,6 +305,51 @@ var editors = {
         }
         return currentPath;
     },
+    // 展开代码片段：占位符 ${1:默认值}、${1}、$1 替换为默认值并选中第一个，没有的话光标放到 $0 处
+    _applySnippet: function (cm, data, completion) {
+        var from = CodeMirror.Pos(data.from.line, data.from.ch - completion.replaceLen),
+                indent = cm.getLine(from.line).match(/^\s*/)[0],
+                template = completion.text.replace(/\n/g, '\n' + indent),
+                placeholder = /\$\{(\d+)(?::([^}]*))?\}|\$(\d+)/g,
+                text = '', last = 0, cursor = -1, selected = 0, end = -1, match;
+
+        while ((match = placeholder.exec(template))) {
+            text += template.substring(last, match.index);
+            if ('0' === (match[1] || match[3])) {
+                end = text.length;
+            } else if (-1 === cursor) {
+                cursor = text.length;
+                selected = (match[2] || '').length;
+            }
+            text += match[2] || '';
+            last = placeholder.lastIndex;
+        }
+        text += template.substring(last);
+
+        if (-1 === cursor) {
+            cursor = -1 === end ? text.length : end;
+        }
+
+        cm.replaceRange(text, from, data.to, "complete");
+        var start = cm.indexFromPos(from) + cursor;
+        cm.setSelection(cm.posFromIndex(start), cm.posFromIndex(start + selected));
+    },
+    _extraKeys: function (commands) {
+        // 按用户的快捷键设置（config.keybindings）绑定编辑器命令
+        var keys = {
+            ".": "autocompleteAfterDot",
+            "Ctrl-D": "doNothing" // 取消默认的 deleteLine
+        },
+        bindings = config.keybindings || {};
+
+        for (var action in commands) {
+            if (bindings[action]) {
+                keys[bindings[action]] = commands[action];
+            }
+        }
+
+        return keys;
+    },
     _initCodeMirrorHotKeys: function () {
         CodeMirror.registerHelper("hint", "go", function (editor) {
             editor = wide.curEditor; // 使用当前编辑器覆盖实参，因为异步调用的原因，实参不一定正确
@@ -367,6 +420,12 @@ var editors = {
                                             + autocompleteArray[i].type.substring(4) + '</span>';
                                     text += '()';
                                     break;
+                                case "snippet":
+                                    displayText = '<span class="fn-clear"><span class="ico-func ico"></span>'
+                                            + '<b>' + autocompleteArray[i].name + '</b>    '
+                                            + autocompleteArray[i].type + '</span>';
+                                    text = autocompleteArray[i].snippet;
+                                    break;
                                 default:
                                     console.warn("Can't handle autocomplete [" + autocompleteArray[i].class + "]");
                                     break;
@@ -376,6 +435,11 @@ var editors = {
                                 displayText: displayText,
                                 text: text
                             };
+
+                            if ("snippet" === autocompleteArray[i].class) {
+                                autocompleteHints[i].replaceLen = autocompleteArray[i].replaceLen || 0;
+                                autocompleteHints[i].hint = editors._applySnippet;
+                            }
                         }
                     }
 
@@ -594,8 +658,17 @@ var editors = {
                     var data = result.data;
 
                     var tId = tree.getTIdByPath(data.path);
-                    wide.curNode = tree.fileTree.getNodeByTId(tId);
-                    tree.fileTree.selectNode(wide.curNode);
+                    if (tId) {
+                        wide.curNode = tree.fileTree.getNodeByTId(tId);
+                        tree.fileTree.selectNode(wide.curNode);
+                    } else { // 声明位于工作空间之外（$GOROOT 或模块缓存），只读打开
+                        wide.curNode = {
+                            path: data.path,
+                            name: data.path.substring(data.path.lastIndexOf("/") + 1),
+                            iconSkin: "ico-ztree-go ",
+                            isGOAPI: true
+                        };
+                    }
 
                     tree.openFile(wide.curNode, CodeMirror.Pos(data.cursorLine - 1, data.cursorCh - 1));
                 }
@@ -632,7 +705,7 @@ var editors = {
             cm.extendSelection(word.anchor, word.head);
         };
     },
-    appendSearch: function (data, type, key) {
+    appendSearch: function (data, type, key, more) {
         var searcHTML = '<ul class="list">',
                 key = key.toLowerCase();
 
@@ -662,6 +735,9 @@ var editors = {
         if (data.length === 0) {
             searcHTML += '<li>' + config.label.search_no_match + '</li>';
         }
+        if (more) { // 还有更多结果
+            searcHTML += '<li class="more">' + config.label.search_more + ' (' + data.length + ' / ' + more.total + ')</li>';
+        }
         searcHTML += '</ul>';
 
         var $search = $('.bottom-window-group .search'),
@@ -685,6 +761,10 @@ var editors = {
             });
 
             $search.on("dblclick", "li", function () {
+                if ($(this).hasClass("more")) {
+                    return;
+                }
+
                 var $it = $(this),
                         tId = tree.getTIdByPath($it.attr("title"));
                 tree.openFile(tree.fileTree.getNodeByTId(tId));
@@ -716,11 +796,172 @@ var editors = {
             });
         }
 
+        if (more) {
+            $search.find("li.more:last").click(function () {
+                $(this).remove();
+                more.next();
+            });
+        }
+
         // focus
         bottomGroup.tabs.setCurrent("search");
         windows.flowBottom();
         $(".bottom-window-group .search").focus();
     },
+    // 自动保存未保存编辑器的草稿，草稿和文件分开存放，下次打开文件时提示恢复.
+    _autosave: function () {
+        for (var i = 0, ii = editors.data.length; i < ii; i++) {
+            var editor = editors.data[i].editor;
+            if (!editor.draftChanged || editor.doc.isClean() || editor.getOption("readOnly")) {
+                continue;
+            }
+
+            editor.draftChanged = false;
+
+            var request = newWideRequest();
+            request.file = editor.options.path;
+            request.code = editor.getValue();
+
+            $.ajax({
+                type: 'POST',
+                url: config.context + '/file/draft/save',
+                data: JSON.stringify(request),
+                dataType: "json"
+            });
+        }
+    },
+    _initCollab: function () {
+        // 共享会话：加入同一共享的会话之间互相广播编辑和光标
+        editors.sharedDirs = [];
+        editors.collabWS = new ReconnectingWebSocket(config.channel + '/editor/ws?sid=' + config.wideSessionId);
+        editors.collabWS.onmessage = function (e) {
+            var data = JSON.parse(e.data),
+                    editor = editors.getEditorByPath(data.path);
+            if (!editor) {
+                return;
+            }
+
+            switch (data.cmd) {
+                case 'collab-edit':
+                    editor.operation(function () {
+                        for (var i = 0; i < data.changes.length; i++) {
+                            var change = data.changes[i];
+                            editor.replaceRange(change.text.join("\n"), change.from, change.to, "collab");
+                        }
+                    });
+
+                    break;
+                case 'collab-cursor':
+                    editors._showCollabCursor(editor, data);
+
+                    break;
+            }
+        };
+
+        $.ajax({
+            type: 'GET',
+            url: config.context + '/shares',
+            dataType: "json",
+            success: function (result) {
+                if (!result.succ) {
+                    return;
+                }
+
+                for (var i = 0; i < result.data.length; i++) {
+                    editors.joinShare(result.data[i].id);
+                }
+            }
+        });
+    },
+    share: function (dir, users) {
+        var request = newWideRequest();
+        request.dir = dir;
+        request.users = users;
+
+        $.ajax({
+            type: 'POST',
+            url: config.context + '/share/new',
+            data: JSON.stringify(request),
+            dataType: "json",
+            success: function (result) {
+                if (result.succ) {
+                    editors.sharedDirs.push(result.data.dir);
+                }
+            }
+        });
+    },
+    joinShare: function (id) {
+        var request = newWideRequest();
+        request.id = id;
+
+        $.ajax({
+            type: 'POST',
+            url: config.context + '/share/join',
+            data: JSON.stringify(request),
+            dataType: "json",
+            success: function (result) {
+                if (result.succ && -1 === editors.sharedDirs.indexOf(result.data.dir)) {
+                    editors.sharedDirs.push(result.data.dir);
+                }
+            }
+        });
+    },
+    _reportPresence: function () {
+        // 向同一用户的其他会话报告打开的文件和光标位置，去抖
+        clearTimeout(editors.presenceTimer);
+        editors.presenceTimer = setTimeout(function () {
+            var msg = {cmd: "presence", files: [], path: "", line: 0, ch: 0};
+            for (var i = 0; i < editors.data.length; i++) {
+                msg.files.push(editors.data[i].editor.options.path);
+            }
+
+            if (wide.curEditor) {
+                var cursor = wide.curEditor.getCursor();
+                msg.path = wide.curEditor.options.path;
+                msg.line = cursor.line;
+                msg.ch = cursor.ch;
+            }
+
+            editors._sendCollab(msg);
+        }, 300);
+    },
+    _isShared: function (path) {
+        for (var i = 0; i < editors.sharedDirs.length; i++) {
+            var dir = editors.sharedDirs[i];
+            if (path === dir || 0 === path.indexOf(dir + '/')) {
+                return true;
+            }
+        }
+
+        return false;
+    },
+    _sendCollab: function (msg) {
+        if (editors.collabWS && 1 === editors.collabWS.readyState) {
+            editors.collabWS.send(JSON.stringify(msg));
+        }
+    },
+    _showCollabCursor: function (editor, data) {
+        editor.collabCursors = editor.collabCursors || {};
+        if (editor.collabCursors[data.sid]) {
+            editor.collabCursors[data.sid].clear();
+        }
+
+        var widget = $('<span class="collab-cursor" title="' + data.username + '"><span>'
+                + data.username + '</span></span>')[0];
+        editor.collabCursors[data.sid] = editor.setBookmark({line: data.line, ch: data.ch},
+                {widget: widget, insertLeft: true});
+    },
+    _discardDraft: function (path) {
+        var request = newWideRequest();
+        request.file = path;
+
+        $.ajax({
+            type: 'POST',
+            url: config.context + '/file/draft/discard',
+            data: JSON.stringify(request),
+            dataType: "json"
+        });
+    },
     // 新建一个编辑器 Tab，如果已经存在 Tab 则切换到该 Tab.
     newEditor: function (data, cursor) {
         var id = wide.curNode.id;
@@ -732,7 +973,7 @@ var editors = {
             content: '<textarea id="editor' + id + '"></textarea>'
         });
 
-        menu.undisabled(['save-all', 'close-all', 'build', 'run', 'go-test', 'go-vet', 'go-get', 'go-install',
+        menu.undisabled(['save-all', 'close-all', 'build', 'run', 'go-test', 'go-vet', 'go-get', 'go-install', 'go-generate',
             'find', 'find-next', 'find-previous', 'replace', 'replace-all',
             'format', 'autocomplete', 'jump-to-decl', 'expr-info', 'find-usages', 'toggle-comment',
             'edit']);
@@ -740,8 +981,22 @@ var editors = {
         var textArea = document.getElementById("editor" + id);
         textArea.value = data.content;
 
+        var tabSize = config.editorTabSize,
+            indentUnit = 4,
+            indentWithTabs = true;
+        if (data.editorConfig) { // .editorconfig 缩进设置
+            if (data.editorConfig.indentStyle) {
+                indentWithTabs = "tab" === data.editorConfig.indentStyle;
+            }
+            if (data.editorConfig.indentSize) {
+                indentUnit = data.editorConfig.indentSize;
+                tabSize = data.editorConfig.indentSize;
+            }
+        }
+
         var editor = CodeMirror.fromTextArea(textArea, {
             lineNumbers: true,
+            firstLineNumber: data.startLine || 1,
             autofocus: true,
             autoCloseBrackets: true,
             matchBrackets: true,
@@ -749,50 +1004,48 @@ var editors = {
             rulers: [{color: "#ccc", column: 120, lineStyle: "dashed"}],
             styleActiveLine: true,
             theme: config.editorTheme,
-            tabSize: config.editorTabSize,
-            indentUnit: 4,
-            indentWithTabs: true,
+            tabSize: tabSize,
+            indentUnit: indentUnit,
+            indentWithTabs: indentWithTabs,
             foldGutter: true,
             cursorHeight: 1,
             path: data.path,
-            readOnly: wide.curNode.isGOAPI,
+            readOnly: wide.curNode.isGOAPI || data.readOnly,
             profile: 'xhtml', // define Emmet output profile
-            extraKeys: {
-                "Ctrl-\\": "autocompleteAnyWord",
-                ".": "autocompleteAfterDot",
-                "Ctrl-/": 'toggleComment',
-                "Ctrl-I": "exprInfo",
-                "Ctrl-L": "gotoLine",
-                "Ctrl-E": "deleteLine",
-                "Ctrl-D": "doNothing", // 取消默认的 deleteLine
-                "Ctrl-B": "jumpToDecl",
-                "Ctrl-S": function () {
+            extraKeys: editors._extraKeys({
+                autocomplete: "autocompleteAnyWord",
+                toggleComment: "toggleComment",
+                exprInfo: "exprInfo",
+                gotoLine: "gotoLine",
+                deleteLine: "deleteLine",
+                jumpToDecl: "jumpToDecl",
+                save: function () {
                     wide.saveFile();
                 },
-                "Shift-Ctrl-S": function () {
+                saveAll: function () {
                     menu.saveAllFiles();
                 },
-                "Shift-Alt-F": function () {
+                format: function () {
                     var currentPath = editors.getCurrentPath();
                     if (!currentPath) {
                         return false;
                     }
                     wide.fmt(currentPath, wide.curEditor);
                 },
-                "Alt-F7": "findUsages",
-                "Shift-Alt-Enter": function () {
+                findUsages: "findUsages",
+                fullScreen: function () {
                     if (windows.isMaxEditor) {
                         windows.restoreEditor();
                     } else {
                         windows.maxEditor();
                     }
                 },
-                "Shift-Ctrl-Up": "copyLinesUp",
-                "Shift-Ctrl-Down": "copyLinesDown",
-                "Shift-Alt-Up": "moveLinesUp",
-                "Shift-Alt-Down": "moveLinesDown",
-                "Shift-Alt-J": "selectIdentifier"
-            }
+                copyLinesUp: "copyLinesUp",
+                copyLinesDown: "copyLinesDown",
+                moveLinesUp: "moveLinesUp",
+                moveLinesDown: "moveLinesDown",
+                selectIdent: "selectIdentifier"
+            })
         });
 
         if ("text/html" === data.mode) {
@@ -804,13 +1057,49 @@ var editors = {
             var cursor = cm.getCursor();
 
             $(".footer .cursor").text('|   ' + (cursor.line + 1) + ':' + (cursor.ch + 1) + '   |');
+
+            editors._reportPresence();
         });
 
         editor.on('blur', function (cm) {
             $(".edit-exprinfo").remove();
         });
 
+        editor.on('changes', function (cm, changes) {
+            if (!editors._isShared(cm.options.path)) {
+                return;
+            }
+
+            var ops = [];
+            for (var i = 0; i < changes.length; i++) {
+                if ("collab" === changes[i].origin || "setValue" === changes[i].origin) { // 远端编辑不再广播
+                    continue;
+                }
+
+                ops.push({from: changes[i].from, to: changes[i].to, text: changes[i].text});
+            }
+
+            if (0 < ops.length) {
+                editors._sendCollab({cmd: "collab-edit", path: cm.options.path, changes: ops});
+            }
+        });
+
+        editor.on('cursorActivity', function (cm) {
+            if (!editors._isShared(cm.options.path)) {
+                return;
+            }
+
+            // 光标移动去抖
+            clearTimeout(cm.collabCursorTimer);
+            cm.collabCursorTimer = setTimeout(function () {
+                var cursor = cm.getCursor();
+                editors._sendCollab({cmd: "collab-cursor", path: cm.options.path, line: cursor.line, ch: cursor.ch});
+            }, 200);
+        });
+
         editor.on('changes', function (cm) {
+            cm.draftChanged = true;
+
             if (cm.doc.isClean()) { // no modification
                 $(".edit-panel .tabs > div").each(function () {
                     var $span = $(this).find("span:eq(0)");
@@ -883,6 +1172,7 @@ var editors = {
             "editor": editor,
             "id": id
         });
+        editors._reportPresence();
 
         $(".footer .cursor").text('|   ' + (cursor.line + 1) + ':' + (cursor.ch + 1) + '   |');
 
@@ -892,5 +1182,36 @@ var editors = {
 
         editor.setCursor(cursor);
         editor.focus();
+
+        if (data.mixedLineEndings) { // 混用了换行符
+            var notificationHTML = '<tr><td class="severity">WARN</td><td class="message">'
+                    + data.path + ': ' + config.label.mixed_line_endings + '</td><td class="type">File</td></tr>';
+            $('.bottom-window-group .notification > table').append(notificationHTML);
+            $(".notification-count").show();
+        }
+
+        if (data.encoding && "utf-8" !== data.encoding) { // 非 UTF-8 编码，保存时保持原编码
+            var encodingHTML = '<tr><td class="severity">INFO</td><td class="message">'
+                    + data.path + ': ' + config.label.file_encoding + ' ' + data.encoding
+                    + '</td><td class="type">File</td></tr>';
+            $('.bottom-window-group .notification > table').append(encodingHTML);
+            $(".notification-count").show();
+        }
+
+        if (data.truncated) { // 文件过大，只打开了目标行附近的部分
+            var truncatedHTML = '<tr><td class="severity">WARN</td><td class="message">'
+                    + data.path + ': ' + config.label.file_truncated + '</td><td class="type">File</td></tr>';
+            $('.bottom-window-group .notification > table').append(truncatedHTML);
+            $(".notification-count").show();
+        }
+
+        if (data.draft) { // 存在比文件新的草稿
+            if (confirm(config.label.restore_draft + ' (' + new Date(data.draft.saved).toLocaleString() + ')')) {
+                editor.setValue(data.draft.content);
+                editor.setCursor(cursor);
+            } else {
+                editors._discardDraft(data.path);
+            }
+        }
     }
 };
\ No newline at end of file
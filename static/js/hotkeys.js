@@ -213,8 +213,7 @@ var hotkeys = {
     _bindOutput: function () {
         $(".bottom-window-group .output").keydown(function (event) {
             var hotKeys = hotkeys.defaultKeyMap;
-            if (event.altKey === hotKeys.clearWindow.altKey
-                    && event.which === hotKeys.clearWindow.which) {  // Alt-C clear output
+            if (hotkeys._match(event, hotKeys.clearWindow)) {  // Alt-C clear output
                 bottomGroup.clear('output');
 
                 event.preventDefault();
@@ -228,14 +227,12 @@ var hotkeys = {
             event.preventDefault();
 
             var hotKeys = hotkeys.defaultKeyMap;
-            if (event.ctrlKey === hotKeys.search.ctrlKey
-                    && event.which === hotKeys.search.which) {  // Ctrl-F 搜索
+            if (hotkeys._match(event, hotKeys.search)) {  // Ctrl-F 搜索
                 $("#dialogSearchForm").dialog("open");
                 return;
             }
 
-            if (event.ctrlKey === hotKeys.rename.ctrlKey
-                    && event.which === hotKeys.rename.which) {  // Ctrl-R 重命名
+            if (hotkeys._match(event, hotKeys.rename)) {  // Ctrl-R 重命名
                 if (wide.curNode.removable) {
                     $("#dialogRenamePrompt").dialog("open");
                 }
@@ -363,64 +360,56 @@ var hotkeys = {
     _bindDocument: function () {
         var hotKeys = this.defaultKeyMap;
         $(document).keydown(function (event) {
-            if (event.ctrlKey === hotKeys.goEditor.ctrlKey
-                    && event.which === hotKeys.goEditor.which) {  // Ctrl-0 焦点切换到当前编辑器
+            if (hotkeys._match(event, hotKeys.goEditor)) {  // Ctrl-0 焦点切换到当前编辑器
                 hotKeys.goEditor.fun();
                 event.preventDefault();
 
                 return;
             }
 
-            if (event.ctrlKey === hotKeys.goFileTree.ctrlKey
-                    && event.which === hotKeys.goFileTree.which) { // Ctrl-1 焦点切换到文件树
+            if (hotkeys._match(event, hotKeys.goFileTree)) { // Ctrl-1 焦点切换到文件树
                 hotKeys.goFileTree.fun();
                 event.preventDefault();
 
                 return;
             }
 
-            if (event.ctrlKey === hotKeys.goOutline.ctrlKey
-                    && event.which === hotKeys.goOutline.which) { // Ctrl-2 焦点切换到大纲
+            if (hotkeys._match(event, hotKeys.goOutline)) { // Ctrl-2 焦点切换到大纲
                 hotKeys.goOutline.fun();
                 event.preventDefault();
 
                 return;
             }
 
-            if (event.ctrlKey === hotKeys.goOutput.ctrlKey
-                    && event.which === hotKeys.goOutput.which) { // Ctrl-4 焦点切换到输出窗口   
+            if (hotkeys._match(event, hotKeys.goOutput)) { // Ctrl-4 焦点切换到输出窗口   
                 hotKeys.goOutput.fun();
                 event.preventDefault();
 
                 return;
             }
 
-            if (event.ctrlKey === hotKeys.goSearch.ctrlKey
-                    && event.which === hotKeys.goSearch.which) { // Ctrl-5 焦点切换到搜索窗口  
+            if (hotkeys._match(event, hotKeys.goSearch)) { // Ctrl-5 焦点切换到搜索窗口  
                 hotKeys.goSearch.fun();
                 event.preventDefault();
 
                 return;
             }
 
-            if (event.ctrlKey === hotKeys.goNotification.ctrlKey
-                    && event.which === hotKeys.goNotification.which) { // Ctrl-6 焦点切换到通知窗口  
+            if (hotkeys._match(event, hotKeys.goNotification)) { // Ctrl-6 焦点切换到通知窗口  
                 hotKeys.goNotification.fun();
                 event.preventDefault();
 
                 return;
             }
 
-            if (event.ctrlKey === hotKeys.closeCurEditor.ctrlKey
-                    && event.which === hotKeys.closeCurEditor.which) {  // Ctrl-Q 关闭当前编辑器   
+            if (hotkeys._match(event, hotKeys.closeCurEditor)) {  // Ctrl-Q 关闭当前编辑器   
                 $(".edit-panel .tabs > div.current").find(".ico-close").click();
                 event.preventDefault();
 
                 return;
             }
 
-            if (event.ctrlKey === hotKeys.changeEditor.ctrlKey
-                    && event.which === hotKeys.changeEditor.which) { // Ctrl-D 窗口组切换
+            if (hotkeys._match(event, hotKeys.changeEditor)) { // Ctrl-D 窗口组切换
                 if (document.activeElement.className === "notification"
                         || document.activeElement.className === "output"
                         || document.activeElement.className === "search") {
@@ -479,29 +468,104 @@ var hotkeys = {
                 return false;
             }
 
-            if (event.which === hotKeys.build.which) { // F5 Build
+            if (hotkeys._match(event, hotKeys.build)) { // F5 Build
                 menu.build();
                 event.preventDefault();
 
                 return;
             }
 
-            if (event.which === hotKeys.buildRun.which) { // F6 Build & Run
+            if (hotkeys._match(event, hotKeys.buildRun)) { // F6 Build & Run
                 menu.run();
                 event.preventDefault();
 
                 return;
             }
 
-            if (event.ctrlKey === hotKeys.goFile.ctrlKey
-                    && event.altKey === hotKeys.goFile.altKey
-                    && event.shiftKey === hotKeys.goFile.shiftKey
-                    && event.which === hotKeys.goFile.which) { // Shift-Alt-O 跳转到文件
+            if (hotkeys._match(event, hotKeys.goFile)) { // Shift-Alt-O 跳转到文件
                 $("#dialogGoFilePrompt").dialog("open");
             }
         });
     },
+    _actions: {
+        // 用户快捷键动作与 defaultKeyMap 的对应关系
+        focusEditor: "goEditor",
+        focusFileTree: "goFileTree",
+        focusOutline: "goOutline",
+        focusOutput: "goOutput",
+        focusSearch: "goSearch",
+        focusNotify: "goNotification",
+        clearOutput: "clearWindow",
+        switchTab: "changeEditor",
+        searchInTree: "search",
+        closeEditor: "closeCurEditor",
+        renameInTree: "rename",
+        goFile: "goFile",
+        build: "build",
+        buildRun: "buildRun"
+    },
+    _keyCodes: {
+        "Up": 38, "Down": 40, "Left": 37, "Right": 39, "Enter": 13, "Tab": 9, "Esc": 27, "Space": 32,
+        "Backspace": 8, "Delete": 46, "Insert": 45, "Home": 36, "End": 35, "PageUp": 33, "PageDown": 34,
+        "\\": 220, "/": 191, "-": 189, "=": 187, "[": 219, "]": 221, ";": 186, "'": 222, ",": 188, ".": 190,
+        "`": 192
+    },
+    _parseKey: function (combo) {
+        // 将 CodeMirror 形式的快捷键（如 Shift-Alt-F）解析为按键事件属性
+        var parts = combo.split(/-(?!$)/),
+                key = parts.pop(),
+                ret = {ctrlKey: false, altKey: false, shiftKey: false, metaKey: false, which: 0};
+
+        for (var i = 0; i < parts.length; i++) {
+            switch (parts[i]) {
+                case "Ctrl":
+                    ret.ctrlKey = true;
+                    break;
+                case "Alt":
+                    ret.altKey = true;
+                    break;
+                case "Shift":
+                    ret.shiftKey = true;
+                    break;
+                case "Cmd":
+                    ret.metaKey = true;
+                    break;
+            }
+        }
+
+        if (hotkeys._keyCodes[key]) {
+            ret.which = hotkeys._keyCodes[key];
+        } else if (/^F([1-9]|1[0-2])$/.test(key)) {
+            ret.which = 111 + parseInt(key.substr(1));
+        } else {
+            ret.which = key.toUpperCase().charCodeAt(0);
+        }
+
+        return ret;
+    },
+    _match: function (event, key) {
+        return event.ctrlKey === key.ctrlKey && event.altKey === key.altKey && event.shiftKey === key.shiftKey
+                && event.metaKey === !!key.metaKey && event.which === key.which;
+    },
+    _applyKeybindings: function () {
+        // 使用用户自定义的快捷键覆盖默认快捷键
+        var bindings = config.keybindings || {};
+        for (var action in hotkeys._actions) {
+            if (!bindings[action]) {
+                continue;
+            }
+
+            var key = hotkeys._parseKey(bindings[action]),
+                    hotKey = hotkeys.defaultKeyMap[hotkeys._actions[action]];
+            hotKey.ctrlKey = key.ctrlKey;
+            hotKey.altKey = key.altKey;
+            hotKey.shiftKey = key.shiftKey;
+            hotKey.metaKey = key.metaKey;
+            hotKey.which = key.which;
+        }
+    },
     init: function () {
+        this._applyKeybindings();
         this._bindFileTree();
         this._bindOutput();
         this._bindDocument();
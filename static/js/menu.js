@@ -27,6 +27,7 @@ var menu = {
         this._initPreference();
         this._initAbout();
         this._initShare();
+        this._initBuildTarget();
 
         // 点击子菜单后消失
         $(".menu .frame li").click(function () {
@@ -34,6 +35,43 @@ var menu = {
             menu.subMenu();
         });
     },
+    // 加载可构建运行的 main 包
+    _initBuildTarget: function () {
+        var $buildTarget = $("#buildTarget");
+
+        $buildTarget.mousedown(function () {
+            if ($buildTarget.data("loaded")) {
+                return;
+            }
+
+            menu._loadBuildTargets();
+        });
+
+        menu._loadBuildTargets();
+    },
+    _loadBuildTargets: function () {
+        $.ajax({
+            type: 'GET',
+            url: config.context + '/build/targets?sid=' + config.wideSessionId,
+            dataType: "json",
+            success: function (result) {
+                if (!result.succ) {
+                    return false;
+                }
+
+                var $buildTarget = $("#buildTarget"),
+                        selected = $buildTarget.data("loaded") ? $buildTarget.val() : result.data.selected,
+                        html = '<option value="">' + config.label.current_package + '</option>';
+                for (var i = 0, max = result.data.targets.length; i < max; i++) {
+                    var target = result.data.targets[i];
+                    html += '<option value="' + target + '" title="' + target + '">'
+                            + target.substr(target.lastIndexOf('/') + 1) + '</option>';
+                }
+
+                $buildTarget.html(html).val(selected).data("loaded", true);
+            }
+        });
+    },
     _initShare: function () {
         $(".menu .ico-share").hover(function () {
             $(".menu .share-panel").show();
@@ -170,6 +208,38 @@ var menu = {
             }
         });
     },
+    deleteAccount: function () {
+        // 输入用户名和密码确认后删除账号
+        var confirm = prompt(config.label.delete_account_confirm);
+        if (!confirm) {
+            return false;
+        }
+
+        var password = prompt(config.label.password_prompt);
+        if (null === password) {
+            return false;
+        }
+
+        var request = newWideRequest();
+        request.confirm = confirm;
+        request.password = password;
+
+        $.ajax({
+            type: 'POST',
+            url: config.context + '/user/delete',
+            data: JSON.stringify(request),
+            dataType: "json",
+            success: function (result) {
+                if (!result.succ) {
+                    $("#dialogAlert").dialog("open", result.msg);
+
+                    return false;
+                }
+
+                window.location.href = config.context + "/login";
+            }
+        });
+    },
     openAbout: function () {
         $("#dialogAbout").dialog("open");
     },
@@ -227,6 +297,35 @@ var menu = {
             }
         });
     },
+    // go generate.
+    gogenerate: function () {
+        menu.saveAllFiles();
+
+        var currentPath = editors.getCurrentPath();
+        if (!currentPath) {
+            return false;
+        }
+
+        if ($(".menu li.go-generate").hasClass("disabled")) {
+            return false;
+        }
+
+        var request = newWideRequest();
+        request.file = currentPath;
+        request.recursive = true;
+
+        $.ajax({
+            type: 'POST',
+            url: config.context + '/go/generate',
+            data: JSON.stringify(request),
+            dataType: "json",
+            beforeSend: function () {
+                bottomGroup.resetOutput();
+            },
+            success: function (result) {
+            }
+        });
+    },
     // go test.
     test: function () {
         menu.saveAllFiles();
@@ -252,6 +351,7 @@ var menu = {
                 bottomGroup.resetOutput();
             },
             success: function (result) {
+                menu._showRejected(result);
             }
         });
     },
@@ -284,6 +384,40 @@ var menu = {
         });
     },
     // Build & Run.
+    // go run，编译并运行，不保留可执行文件.
+    goRun: function () {
+        menu.saveAllFiles();
+
+        if ($("#buildRun").hasClass("ico-stop")) {
+            wide.stop();
+            return false;
+        }
+
+        var currentPath = editors.getCurrentPath();
+        if (!currentPath) {
+            return false;
+        }
+
+        var request = newWideRequest();
+        request.file = currentPath;
+        request.target = $("#buildTarget").val();
+
+        $.ajax({
+            type: 'POST',
+            url: config.context + '/go/run',
+            data: JSON.stringify(request),
+            dataType: "json",
+            beforeSend: function () {
+                bottomGroup.resetOutput();
+
+                $("#buildRun").addClass("ico-stop")
+                        .removeClass("ico-buildrun").attr("title", config.label.stop);
+            },
+            success: function (result) {
+                menu._showRejected(result);
+            }
+        });
+    },
     run: function () {
         menu.saveAllFiles();
 
@@ -305,6 +439,7 @@ var menu = {
         request.file = currentPath;
         request.code = wide.curEditor.getValue();
         request.nextCmd = "run";
+        request.target = $("#buildTarget").val();
 
         $.ajax({
             type: 'POST',
@@ -318,6 +453,7 @@ var menu = {
                         .removeClass("ico-buildrun").attr("title", config.label.stop);
             },
             success: function (result) {
+                menu._showRejected(result);
             }
         });
     },
@@ -338,6 +474,7 @@ var menu = {
         request.file = currentPath;
         request.code = wide.curEditor.getValue();
         request.nextCmd = ""; // build only, no following operation
+        request.target = $("#buildTarget").val();
 
         $.ajax({
             type: 'POST',
@@ -348,9 +485,21 @@ var menu = {
                 bottomGroup.resetOutput();
             },
             success: function (result) {
+                menu._showRejected(result);
             }
         });
     },
+    // 显示被拒绝的构建、运行、测试请求（例如并发数超限）.
+    _showRejected: function (result) {
+        if (result.succ || !result.msg) {
+            return;
+        }
+
+        bottomGroup.fillOutput($('.bottom-window-group .output > div').html()
+                + "<span class='stderr'>" + result.msg + "</span>\n");
+        $("#buildRun").removeClass("ico-stop")
+                .addClass("ico-buildrun").attr("title", config.label.build_n_run);
+    },
     _initPreference: function () {
         $("#dialogPreference").load(config.context + '/preference', function () {
             $("#dialogPreference input").keyup(function () {
@@ -364,7 +513,7 @@ var menu = {
                         isChange = true;
                     }
 
-                    if ($.trim($it.val()) === '') {
+                    if ($.trim($it.val()) === '' && !$it.data("optional")) {
                         emptys.push($it);
                     }
                 });
@@ -428,9 +577,16 @@ var menu = {
                             $GoBuildArgsForLinux = $dialogPreference.find("input[name=GoBuildArgsForLinux]"),
                             $GoBuildArgsForWindows = $dialogPreference.find("input[name=GoBuildArgsForWindows]"),
                             $GoBuildArgsForDarwin = $dialogPreference.find("input[name=GoBuildArgsForDarwin]"),
+                            $autoTest = $dialogPreference.find("select[name=autoTest]"),
+                            $lintConf = $dialogPreference.find("input[name=lintConf]"),
+                            $lineEnding = $dialogPreference.find("select[name=lineEnding]"),
+                            $startupCmd = $dialogPreference.find("input[name=startupCmd]"),
+                            $treeSort = $dialogPreference.find("select[name=treeSort]"),
+                            $treeMixed = $dialogPreference.find("select[name=treeMixed]"),
                             $workspace = $dialogPreference.find("input[name=workspace]"),
                             $password = $dialogPreference.find("input[name=password]"),
                             $email = $dialogPreference.find("input[name=email]"),
+                            $mailNotify = $dialogPreference.find("select[name=mailNotify]"),
                             $locale = $dialogPreference.find("select[name=locale]"),
                             $theme = $dialogPreference.find("select[name=theme]"),
                             $editorFontFamily = $dialogPreference.find("input[name=editorFontFamily]"),
@@ -447,9 +603,16 @@ var menu = {
                         "GoBuildArgsForLinux": $GoBuildArgsForLinux.val(),
                         "GoBuildArgsForWindows": $GoBuildArgsForWindows.val(),
                         "GoBuildArgsForDarwin": $GoBuildArgsForDarwin.val(),
+                        "autoTest": "true" === $autoTest.val(),
+                        "lintConf": $lintConf.val(),
+                        "lineEnding": $lineEnding.val(),
+                        "startupCmd": $startupCmd.val(),
+                        "treeSort": $treeSort.val(),
+                        "treeMixed": "true" === $treeMixed.val(),
                         "workspace": $workspace.val(),
                         "password": $password.val(),
                         "email": $email.val(),
+                        "mailNotify": "true" === $mailNotify.val(),
                         "locale": $locale.val(),
                         "theme": $theme.val(),
                         "editorFontFamily": $editorFontFamily.val(),
@@ -479,9 +642,16 @@ var menu = {
                             $GoBuildArgsForLinux.data("value", $GoBuildArgsForLinux.val());
                             $GoBuildArgsForWindows.data("value", $GoBuildArgsForWindows.val());
                             $GoBuildArgsForDarwin.data("value", $GoBuildArgsForDarwin.val());
+                            $autoTest.data("value", $autoTest.val());
+                            $lintConf.data("value", $lintConf.val());
+                            $lineEnding.data("value", $lineEnding.val());
+                            $startupCmd.data("value", $startupCmd.val());
+                            $treeSort.data("value", $treeSort.val());
+                            $treeMixed.data("value", $treeMixed.val());
                             $workspace.data("value", $workspace.val());
                             $password.data("value", $password.val());
                             $email.data("value", $email.val());
+                            $mailNotify.data("value", $mailNotify.val());
                             $locale.data("value", $locale.val());
                             $theme.data("value", $theme.val());
                             $editorFontFamily.data("value", $editorFontFamily.val());
@@ -49,6 +49,22 @@ var notification = {
                 return;
             }
 
+            if (data.cmd && "presence" === data.cmd) {
+                notification._updatePresence(data.presence);
+
+                return;
+            }
+
+            if (data.cmd && "clear-motd" === data.cmd) {
+                notification._showMOTD(null);
+
+                return;
+            }
+
+            if ("MOTD" === data.type) {
+                notification._showMOTD(data);
+            }
+
             notificationHTML += '<tr><td class="severity">' + data.severity
                     + '</td><td class="message">' + data.message
                     + '</td><td class="type">' + data.type + '</td></tr>';
@@ -64,5 +80,56 @@ var notification = {
         notificationWS.onerror = function (e) {
             console.log('[notification onerror]');
         };
+    },
+    _motdTimer: null,
+    _showMOTD: function (data) {
+        // 显示管理员发布的公告横幅，过期或清除后移除
+        clearTimeout(notification._motdTimer);
+        $(".motd").remove();
+
+        if (!data) {
+            return;
+        }
+
+        var $motd = $('<div class="motd"><span class="message"></span><span class="font-ico ico-close"></span></div>');
+        $motd.addClass(data.severity.toLowerCase()).find(".message").text(data.message);
+        $motd.find(".ico-close").click(function () {
+            $motd.remove();
+        });
+        $("body").prepend($motd);
+
+        if (data.expires) {
+            notification._motdTimer = setTimeout(function () {
+                $motd.remove();
+            }, Math.max(0, data.expires / 1000000 - new Date().getTime()));
+        }
+    },
+    presences: {},
+    _updatePresence: function (presence) {
+        // 标记在其他标签页/设备中也打开了的文件
+        if (0 === presence.files.length) {
+            delete notification.presences[presence.sid];
+        } else {
+            notification.presences[presence.sid] = presence;
+        }
+
+        $(".edit-panel .tabs > div").each(function () {
+            var $span = $(this).find("span:eq(0)"),
+                    path = $span.attr("title"),
+                    others = [];
+
+            for (var sid in notification.presences) {
+                var p = notification.presences[sid];
+                if (-1 !== p.files.indexOf(path)) {
+                    others.push(p.username + (p.path === path ? ' (' + (p.line + 1) + ':' + (p.ch + 1) + ')' : ''));
+                }
+            }
+
+            if (0 < others.length) {
+                $span.addClass("presence").attr("data-presence", config.label.also_open + ': ' + others.join(', '));
+            } else {
+                $span.removeClass("presence").removeAttr("data-presence");
+            }
+        });
     }
 };
\ No newline at end of file
@@ -198,7 +198,7 @@ var session = {
                 case 'create-file':
                     var node = tree.fileTree.getNodeByTId(tree.getTIdByPath(data.dir)),
                             name = data.path.replace(data.dir + '/', ''),
-                            mode = CodeMirror.findModeByFileName(name),
+                            mode = tree.findMode(name),
                             iconSkin = wide.getClassBySuffix(name.split(".")[1]);
 
                     if (data.type && data.type === 'f') {
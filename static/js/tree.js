@@ -23,6 +23,42 @@
  */
 var tree = {
     fileTree: undefined,
+    // 根据文件名获取编辑器模式，优先使用服务端配置的扩展名映射
+    findMode: function (fileName) {
+        var idx = fileName.lastIndexOf('.'),
+                ext = -1 === idx ? '' : fileName.substring(idx).toLowerCase(),
+                mime = config.editorModes && config.editorModes[ext];
+        if (mime) {
+            return CodeMirror.findModeByMIME(mime) || {mime: mime};
+        }
+
+        return CodeMirror.findModeByFileName(fileName);
+    },
+    // 根据 git 状态获取节点字体样式，符号链接使用斜体，未跟随的链接置灰
+    getGitStatusCss: function (treeId, treeNode) {
+        if (treeNode.truncated && treeNode.children && 0 < treeNode.children.length) { // 部分子节点未加载
+            return {'border-bottom': '1px dashed #999'};
+        }
+
+        if (treeNode.symlink) {
+            return treeNode.dangling ? {'font-style': 'italic', color: '#999'} : {'font-style': 'italic'};
+        }
+
+        var status = treeNode.gitStatus;
+        if (!status) {
+            return {};
+        }
+
+        if ('??' === status) { // untracked
+            return {color: '#3c9a3c'};
+        }
+
+        if (' ' !== status.charAt(1)) { // unstaged changes
+            return {color: 'D' === status.charAt(1) ? '#c33' : '#c68a1f'};
+        }
+
+        return {color: '#3b7bbf'}; // staged changes only
+    },
     // 递归获取当前节点展开中的最后一个节点
     getCurrentNodeLastNode: function (node) {
         var returnNode = node.children[node.children.length - 1];
@@ -152,6 +188,32 @@ var tree = {
 
         $("#dialogRenamePrompt").dialog("open");
     },
+    duplicate: function (it) {
+        if (it && $(it).hasClass("disabled")) {
+            return false;
+        }
+
+        var request = newWideRequest(),
+                node = wide.curNode;
+        request.path = node.path;
+
+        $.ajax({
+            type: 'POST',
+            url: config.context + '/file/copy',
+            data: JSON.stringify(request),
+            dataType: "json",
+            success: function (result) {
+                if (!result.succ) {
+                    $("#dialogAlert").dialog("open", result.msg);
+
+                    return false;
+                }
+
+                // 刷新父目录以显示副本
+                tree.fileTree.reAsyncChildNodes(node.getParentNode(), "refresh", false);
+            }
+        });
+    },
     export: function () {
         var request = newWideRequest(),
                 isSucc = false;
@@ -178,6 +240,10 @@ var tree = {
             window.open(config.context + '/file/zip?path=' + wide.curNode.path + ".zip");
         }
     },
+    exportTar: function () {
+        // tar.gz 直接流式下载，无需先创建
+        window.open(config.context + '/file/zip?format=tar.gz&path=' + encodeURIComponent(wide.curNode.path));
+    },
     crossCompile: function (platform) {
         var request = newWideRequest();
         request.path = wide.curNode.path;
@@ -278,7 +344,8 @@ var tree = {
                         },
                         view: {
                             showTitle: true,
-                            selectedMulti: false
+                            selectedMulti: false,
+                            fontCss: tree.getGitStatusCss
                         },
                         async: {
                             enable: true,
@@ -360,6 +427,11 @@ var tree = {
                                 }
                             },
                             onClick: function (event, treeId, treeNode, clickFlag) {
+                                if (treeNode && treeNode.truncated && treeNode.isParent) { // 超出树限制，单独加载该子树
+                                    treeNode.truncated = false;
+                                    tree.fileTree.reAsyncChildNodes(treeNode, "refresh", false);
+                                }
+
                                 if (treeNode) {
                                     wide.curNode = treeNode;
                                     tree.fileTree.selectNode(treeNode);
@@ -413,6 +485,10 @@ var tree = {
         if (!tree.isDir()) {
             var request = newWideRequest();
             request.path = treeNode.path;
+            if (tempCursor) { // 由服务端校验跳转位置
+                request.line = tempCursor.line + 1;
+                request.column = tempCursor.ch + 1;
+            }
 
             $.ajax({
                 async: false,
@@ -430,7 +506,7 @@ var tree = {
                     var data = result.data;
 
                     if (!data.mode) {
-                        var mode = CodeMirror.findModeByFileName(treeNode.path);
+                        var mode = tree.findMode(treeNode.path);
                         if (mode) {
                             data.mode = mode.mime;
                         } else {
@@ -448,6 +524,10 @@ var tree = {
                         return false;
                     }
 
+                    if (data.line) {
+                        tempCursor = CodeMirror.Pos(data.line - (data.startLine || 1), data.column - 1);
+                    }
+
                     if (!tempCursor) {
                         tempCursor = CodeMirror.Pos(0, 0);
                     }
@@ -473,7 +553,7 @@ var tree = {
             }
         });
 
-        $("#dialogSearchForm > input:eq(1)").keyup(function (event) {
+        $("#dialogSearchForm > input:eq(1), #dialogSearchForm > input:eq(2)").keyup(function (event) {
             var $okBtn = $(this).closest(".dialog-main").find(".dialog-footer > button:eq(0)");
             if (event.which === 13 && !$okBtn.prop("disabled")) {
                 $okBtn.click();
@@ -482,7 +562,7 @@ var tree = {
 
         $("#dialogSearchForm").dialog({
             "modal": true,
-            "height": 80,
+            "height": 110,
             "width": 260,
             "title": config.label.search,
             "okText": config.label.search,
@@ -490,6 +570,7 @@ var tree = {
             "afterOpen": function () {
                 $("#dialogSearchForm > input:eq(0)").val('').focus();
                 $("#dialogSearchForm > input:eq(1)").val('');
+                $("#dialogSearchForm > input:eq(2)").val('');
                 $("#dialogSearchForm").closest(".dialog-main").find(".dialog-footer > button:eq(0)").prop("disabled", true);
             },
             "ok": function () {
@@ -503,21 +584,39 @@ var tree = {
 
                 request.text = $("#dialogSearchForm > input:eq(0)").val();
                 request.extension = $("#dialogSearchForm > input:eq(1)").val();
+                request.exclude = $("#dialogSearchForm > input:eq(2)").val();
 
-                $.ajax({
-                    type: 'POST',
-                    url: config.context + '/file/search/text',
-                    data: JSON.stringify(request),
-                    dataType: "json",
-                    success: function (result) {
-                        if (!result.succ) {
-                            return;
+                tree.searchText(request);
+            }
+        });
+    },
+    // 搜索文本，结果分页返回，还有更多结果时可以加载下一页.
+    searchText: function (request) {
+        $.ajax({
+            type: 'POST',
+            url: config.context + '/file/search/text',
+            data: JSON.stringify(request),
+            dataType: "json",
+            success: function (result) {
+                if (!result.succ) {
+                    return;
+                }
+
+                $("#dialogSearchForm").dialog("close");
+
+                var data = result.data, more;
+                if (data.truncated) {
+                    more = {
+                        total: data.total + (data.estimated ? '+' : ''),
+                        next: function () {
+                            var nextRequest = $.extend({}, request);
+                            nextRequest.offset = data.offset + data.snippets.length;
+                            tree.searchText(nextRequest);
                         }
+                    };
+                }
 
-                        $("#dialogSearchForm").dialog("close");
-                        editors.appendSearch(result.data, 'founds', request.text);
-                    }
-                });
+                editors.appendSearch(data.snippets, 'founds', request.text, more);
             }
         });
     },
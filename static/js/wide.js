@@ -254,10 +254,16 @@ var wide = {
 
                 $("#dialogGoFilePrompt > input").bind("input", function () {
                     var name = $("#dialogGoFilePrompt > input").val();
+                    if ("" === name) {
+                        wide._loadRecentFiles();
+
+                        return;
+                    }
 
                     var request = newWideRequest();
                     request.path = '';
-                    request.name = '*' + name + '*';
+                    request.name = name;
+                    request.fuzzy = true;
                     if (wide.curNode) {
                         request.path = wide.curNode.path;
                     }
@@ -272,28 +278,7 @@ var wide = {
                                 return;
                             }
 
-                            var data = result.data;
-
-                            var goFileHTML = '';
-                            for (var i = 0, max = data.length; i < max; i++) {
-                                var path = data[i].path,
-                                        name = path.substr(path.lastIndexOf("/") + 1),
-                                        icoSkin = wide.getClassBySuffix(name.split(".")[1]);
-                                if (i === 0) {
-                                    goFileHTML += '<li data-index="' + i + '" class="selected" title="'
-                                            + path + '"><span class="'
-                                            + icoSkin + 'ico"></span>'
-                                            + name + '&nbsp;&nbsp;&nbsp;&nbsp;<span class="ft-small">'
-                                            + path + '</span></li>';
-                                } else {
-                                    goFileHTML += '<li data-index="' + i + '" title="'
-                                            + path + '"><span class="' + icoSkin + 'ico"></span>'
-                                            + name + '&nbsp;&nbsp;&nbsp;&nbsp;<span class="ft-small">'
-                                            + path + '</span></li>';
-                                }
-                            }
-
-                            $("#dialogGoFilePrompt > ul").html(goFileHTML);
+                            wide._fillGoFileList(result.data);
                         }
                     });
                 });
@@ -302,6 +287,7 @@ var wide = {
                 $("#dialogGoFilePrompt > input").val('').focus();
                 $("#dialogGoFilePrompt").closest(".dialog-main").find(".dialog-footer > button:eq(0)").prop("disabled", true);
                 $("#dialogGoFilePrompt .list").html('').data("index", 0);
+                wide._loadRecentFiles();
             },
             "ok": function () {
                 var tId = tree.getTIdByPath($("#dialogGoFilePrompt .selected .ft-small").text());
@@ -340,6 +326,82 @@ var wide = {
             }
         });
 
+        $("#dialogRunConfForm").dialog({
+            "modal": true,
+            "height": 260,
+            "width": 420,
+            "title": config.label.run_conf,
+            "okText": config.label.build_n_run,
+            "cancelText": config.label.cancel,
+            "afterOpen": function () {
+                var $textareas = $("#dialogRunConfForm > textarea");
+                $textareas.val('');
+
+                var request = newWideRequest();
+                request.path = editors.getCurrentPath();
+
+                $.ajax({
+                    type: 'POST',
+                    url: config.context + '/run/conf',
+                    data: JSON.stringify(request),
+                    dataType: "json",
+                    success: function (result) {
+                        if (!result.succ) {
+                            return false;
+                        }
+
+                        // one argument per line, spaces and quotes are passed as is
+                        $textareas.eq(0).val(result.data.Args.join('\n'));
+
+                        var env = [];
+                        for (var name in result.data.Env) {
+                            env.push(name + '=' + result.data.Env[name]);
+                        }
+                        $textareas.eq(1).val(env.join('\n')).focus();
+                    }
+                });
+            },
+            "ok": function () {
+                var $textareas = $("#dialogRunConfForm > textarea");
+                var request = newWideRequest();
+                request.path = editors.getCurrentPath();
+                request.args = [];
+                request.env = {};
+
+                var lines = $textareas.eq(0).val().split('\n');
+                for (var i = 0, max = lines.length; i < max; i++) {
+                    if ('' !== lines[i]) {
+                        request.args.push(lines[i]);
+                    }
+                }
+
+                lines = $textareas.eq(1).val().split('\n');
+                for (var j = 0, maxj = lines.length; j < maxj; j++) {
+                    var idx = lines[j].indexOf('=');
+                    if (0 < idx) {
+                        request.env[$.trim(lines[j].substring(0, idx))] = lines[j].substring(idx + 1);
+                    }
+                }
+
+                $.ajax({
+                    type: 'POST',
+                    url: config.context + '/run/conf',
+                    data: JSON.stringify(request),
+                    dataType: "json",
+                    success: function (result) {
+                        if (!result.succ) {
+                            $("#dialogAlert").dialog("open", result.msg);
+
+                            return false;
+                        }
+
+                        $("#dialogRunConfForm").dialog("close");
+                        menu.run();
+                    }
+                });
+            }
+        });
+
         $("#dialogGitClonePrompt").dialog({
             "modal": true,
             "height": 52,
@@ -391,7 +453,10 @@ var wide = {
                     type: 'POST',
                     url: config.context + '/run',
                     data: JSON.stringify(request),
-                    dataType: "json"
+                    dataType: "json",
+                    success: function (result) {
+                        menu._showRejected(result);
+                    }
                 });
             }
 
@@ -408,7 +473,10 @@ var wide = {
 
                     break;
                 case 'run-done':
-                    bottomGroup.fillOutput($('.bottom-window-group .output > div').html().replace(/<\/pre>$/g, data.output + '</pre>'));
+                    // 完整的运行日志可下载
+                    var logLink = data.pid ? '<a href="' + config.context + '/run/log?sid=' + config.wideSessionId
+                            + '" target="_blank">' + config.label.download_run_log + '</a>\n' : '';
+                    bottomGroup.fillOutput($('.bottom-window-group .output > div').html().replace(/<\/pre>$/g, data.output + logLink + '</pre>'));
 
                     wide.curProcessId = undefined;
                     $("#buildRun").removeClass("ico-stop")
@@ -421,13 +489,26 @@ var wide = {
                 case 'start-install':
                 case 'start-get':
                 case 'start-git_clone':
+                case 'start-git_commit':
+                case 'start-generate':
+                case 'start-startup':
                     bottomGroup.fillOutput(data.output);
 
                     break;
                 case 'go test':
+                    bottomGroup.fillOutput($('.bottom-window-group .output > div').html() + data.output);
+
+                    if (data.results) {
+                        bottomGroup.fillOutput($('.bottom-window-group .output > div').html()
+                                + wide._renderTestResults(data.results));
+                    }
+
+                    break;
                 case 'go vet':
                 case 'go install':
                 case 'go get':
+                case 'git commit':
+                case 'startup':
                     bottomGroup.fillOutput($('.bottom-window-group .output > div').html() + data.output);
 
                     break;
@@ -435,8 +516,26 @@ var wide = {
                     bottomGroup.fillOutput($('.bottom-window-group .output > div').html() + data.output);
                     tree.fileTree.reAsyncChildNodes(wide.curNode, "refresh", false);
 
+                    break;
+                case 'go generate':
+                    bottomGroup.fillOutput($('.bottom-window-group .output > div').html() + data.output);
+
+                    if (data.refresh) { // generators often create new files
+                        var node = tree.fileTree.getNodeByTId(tree.getTIdByPath(data.refresh));
+                        if (node) {
+                            tree.fileTree.reAsyncChildNodes(node, "refresh", false);
+                        }
+                    }
+
+                    break;
+                case 'build-progress':
+                    // 逐个包显示构建进度
+                    bottomGroup.fillOutput($('.bottom-window-group .output > div').html()
+                            + '<span class="build-' + data.status + '">' + data.status + ' ' + data.package + '</span>\n');
+
                     break;
                 case 'build':
+                case 'build-all':
                 case 'cross-build':
                     bottomGroup.fillOutput($('.bottom-window-group .output > div').html() + data.output);
 
@@ -446,8 +545,8 @@ var wide = {
                         for (var i = 0; i < data.lints.length; i++) {
                             var lint = data.lints[i];
 
-                            goLintFound.push({from: CodeMirror.Pos(lint.lineNo, 0),
-                                to: CodeMirror.Pos(lint.lineNo, 0),
+                            goLintFound.push({from: CodeMirror.Pos(lint.lineNo, lint.column),
+                                to: CodeMirror.Pos(lint.lineNo, lint.column),
                                 message: lint.msg, severity: lint.severity});
 
                             files[lint.file] = lint.file;
@@ -540,6 +639,48 @@ var wide = {
 
         this._initDialog();
     },
+    // 加载最近打开的文件列表.
+    _loadRecentFiles: function () {
+        $.ajax({
+            type: 'GET',
+            url: config.context + '/file/recent',
+            dataType: "json",
+            success: function (result) {
+                if (!result.succ || "" !== $("#dialogGoFilePrompt > input").val()) {
+                    return;
+                }
+
+                var data = [];
+                for (var i = 0, max = result.data.length; i < max; i++) {
+                    data.push({path: result.data[i]});
+                }
+
+                wide._fillGoFileList(data);
+            }
+        });
+    },
+    _fillGoFileList: function (data) {
+        var goFileHTML = '';
+        for (var i = 0, max = data.length; i < max; i++) {
+            var path = data[i].path,
+                    name = path.substr(path.lastIndexOf("/") + 1),
+                    icoSkin = wide.getClassBySuffix(name.split(".")[1]);
+            if (i === 0) {
+                goFileHTML += '<li data-index="' + i + '" class="selected" title="'
+                        + path + '"><span class="'
+                        + icoSkin + 'ico"></span>'
+                        + name + '&nbsp;&nbsp;&nbsp;&nbsp;<span class="ft-small">'
+                        + path + '</span></li>';
+            } else {
+                goFileHTML += '<li data-index="' + i + '" title="'
+                        + path + '"><span class="' + icoSkin + 'ico"></span>'
+                        + name + '&nbsp;&nbsp;&nbsp;&nbsp;<span class="ft-small">'
+                        + path + '</span></li>';
+            }
+        }
+
+        $("#dialogGoFilePrompt > ul").html(goFileHTML).data("index", 0);
+    },
     _save: function (path, editor) {
         if (!path) {
             return false;
@@ -555,6 +696,15 @@ var wide = {
             data: JSON.stringify(request),
             dataType: "json",
             success: function (result) {
+                if (result.succ && result.data && result.data.code !== editor.getValue()) {
+                    // 服务端按 .editorconfig 调整了内容
+                    var cursor = editor.getCursor();
+                    var scrollInfo = editor.getScrollInfo();
+                    editor.setValue(result.data.code);
+                    editor.setCursor(cursor);
+                    editor.scrollTo(null, scrollInfo.top);
+                }
+
                 // reset the save state
                 editor.doc.markClean();
                 $(".edit-panel .tabs > div").each(function () {
@@ -594,6 +744,7 @@ var wide = {
                     bottomGroup.resetOutput();
                 },
                 success: function (result) {
+                    menu._showRejected(result);
                 }
             });
 
@@ -605,6 +756,39 @@ var wide = {
 
         wide._save(path, wide.curEditor);
     },
+    // 渲染结构化的测试结果，子测试可展开.
+    _renderTestResults: function (results) {
+        var escape = function (text) {
+            return $('<div/>').text(text).html();
+        };
+
+        var renderTests = function (tests) {
+            var html = '<ul class="test-results">';
+            for (var i = 0; i < tests.length; i++) {
+                var test = tests[i];
+                html += '<li><details' + ('fail' === test.status ? ' open' : '') + '><summary class="test-'
+                        + test.status + '">' + escape(test.name) + ' [' + test.status
+                        + (test.flaky ? ', ' + config.label.test_flaky : '') + '] ('
+                        + test.elapsed.toFixed(2) + 's)</summary>';
+                if (test.children.length > 0) {
+                    html += renderTests(test.children);
+                }
+                html += '<pre>' + escape(test.output) + '</pre></details></li>';
+            }
+
+            return html + '</ul>';
+        };
+
+        if ('build-fail' === results.status) {
+            return '<pre class="stderr">' + escape(results.buildOutput) + '</pre>';
+        }
+
+        return '<div class="test-summary">' + config.label.test_passed + ': ' + results.passed + ', '
+                + config.label.test_failed + ': ' + results.failed + ', '
+                + config.label.test_skipped + ': ' + results.skipped + ', '
+                + config.label.test_flaky + ': ' + results.flaky + ' (' + results.elapsed.toFixed(2) + 's)</div>'
+                + renderTests(results.tests);
+    },
     stop: function () {
         if ($("#buildRun").hasClass("ico-buildrun")) {
             menu.run();
@@ -668,6 +852,12 @@ var wide = {
         request.cursorLine = cursor.line;
         request.cursorCh = cursor.ch;
 
+        if ("text/x-go" === mode && editor.somethingSelected()) {
+            wide._fmtSelection(path, editor);
+
+            return;
+        }
+
         var formatted = null;
 
         switch (mode) {
@@ -708,6 +898,39 @@ var wide = {
             wide._save(path, editor);
         }
     },
+    _fmtSelection: function (path, editor) {
+        // 仅格式化选中的行，结果替换回编辑器，不保存
+        var from = editor.getCursor("from"),
+            to = editor.getCursor("to");
+        var endLine = to.line;
+        if (0 === to.ch && endLine > from.line) {
+            endLine--;
+        }
+
+        var request = newWideRequest();
+        request.file = path;
+        request.code = editor.getValue();
+        request.startLine = from.line;
+        request.endLine = endLine;
+
+        $.ajax({
+            type: 'POST',
+            url: config.context + '/go/fmt',
+            data: JSON.stringify(request),
+            dataType: "json",
+            success: function (result) {
+                if (!result.succ || !result.data.formatted) {
+                    return;
+                }
+
+                var code = result.data.code.replace(/\n$/, "");
+                editor.replaceRange(code, {line: from.line, ch: 0},
+                        {line: endLine, ch: editor.getLine(endLine).length});
+                editor.setSelection({line: result.data.startLine, ch: 0},
+                        {line: result.data.endLine, ch: editor.getLine(result.data.endLine).length});
+            }
+        });
+    },
     getClassBySuffix: function (suffix) {
         var iconSkin = "ico-ztree-other ";
         switch (suffix) {
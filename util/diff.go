@@ -0,0 +1,306 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// Diff operations.
+const (
+	DiffEqual  = " " // the line is in both sides
+	DiffDelete = "-" // the line is only in the old side
+	DiffInsert = "+" // the line is only in the new side
+)
+
+// DiffLine represents a line of diff.
+type DiffLine struct {
+	Op        string `json:"op"`        // DiffEqual/DiffDelete/DiffInsert
+	Text      string `json:"text"`      // line content without the line separator
+	OldLineNo int    `json:"oldLineNo"` // 1-based line number in the old side, 0 if not in the old side
+	NewLineNo int    `json:"newLineNo"` // 1-based line number in the new side, 0 if not in the new side
+}
+
+// DiffHunk represents a hunk of diff, changed lines with their surrounding context lines.
+type DiffHunk struct {
+	OldStart int         `json:"oldStart"`
+	OldLines int         `json:"oldLines"`
+	NewStart int         `json:"newStart"`
+	NewLines int         `json:"newLines"`
+	Lines    []*DiffLine `json:"lines"`
+}
+
+type mydiff struct{}
+
+// Diff utilities.
+var Diff = mydiff{}
+
+// SplitLines splits the specified content into lines, a trailing line separator doesn't produce an empty line.
+func (*mydiff) SplitLines(content string) []string {
+	if "" == content {
+		return []string{}
+	}
+
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// Lines computes the diff of line slices a (old) and b (new) with Myers' algorithm.
+//
+// The search stops when the edit distance exceeds the specified maxEdits (0 means unlimited), the returned diff is then
+// deleting all the different lines and inserting the new ones, which is correct but not minimal, and exact is false.
+func (*mydiff) Lines(a, b []string, maxEdits int) (ret []*DiffLine, exact bool) {
+	ret = []*DiffLine{}
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for i := 0; i < prefix; i++ {
+		ret = append(ret, &DiffLine{Op: DiffEqual, Text: a[i], OldLineNo: i + 1, NewLineNo: i + 1})
+	}
+
+	edits, exact := myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], maxEdits)
+	for _, e := range edits {
+		line := &DiffLine{Op: e.op}
+		switch e.op {
+		case DiffEqual:
+			line.Text = a[prefix+e.x]
+			line.OldLineNo = prefix + e.x + 1
+			line.NewLineNo = prefix + e.y + 1
+		case DiffDelete:
+			line.Text = a[prefix+e.x]
+			line.OldLineNo = prefix + e.x + 1
+		case DiffInsert:
+			line.Text = b[prefix+e.y]
+			line.NewLineNo = prefix + e.y + 1
+		}
+
+		ret = append(ret, line)
+	}
+
+	for i := suffix; 0 < i; i-- {
+		ret = append(ret, &DiffLine{Op: DiffEqual, Text: a[len(a)-i], OldLineNo: len(a) - i + 1,
+			NewLineNo: len(b) - i + 1})
+	}
+
+	return ret, exact
+}
+
+// Hunks groups the specified diff lines into hunks, each changed line is surrounded by at most the specified count
+// of context lines, hunks with overlapping or adjacent context are merged.
+func (*mydiff) Hunks(lines []*DiffLine, context int) []*DiffHunk {
+	ret := []*DiffHunk{}
+
+	// ranges [start, end] of lines of hunks
+	ranges := [][2]int{}
+	for i, line := range lines {
+		if DiffEqual == line.Op {
+			continue
+		}
+
+		start, end := i-context, i+context
+		if 0 > start {
+			start = 0
+		}
+		if len(lines)-1 < end {
+			end = len(lines) - 1
+		}
+
+		if last := len(ranges) - 1; 0 <= last && start <= ranges[last][1]+1 {
+			ranges[last][1] = end
+		} else {
+			ranges = append(ranges, [2]int{start, end})
+		}
+	}
+
+	oldLines, newLines, cur := 0, 0, 0 // counts of old and new lines before lines[cur]
+	for _, r := range ranges {
+		for ; cur < r[0]; cur++ {
+			oldLines, newLines = countDiffLine(lines[cur], oldLines, newLines)
+		}
+
+		hunk := &DiffHunk{Lines: lines[r[0] : r[1]+1]}
+		oldStart, newStart := oldLines, newLines
+		for ; cur <= r[1]; cur++ {
+			oldLines, newLines = countDiffLine(lines[cur], oldLines, newLines)
+		}
+		hunk.OldLines, hunk.NewLines = oldLines-oldStart, newLines-newStart
+
+		// unified format uses the line before for an empty range
+		hunk.OldStart, hunk.NewStart = oldStart, newStart
+		if 0 < hunk.OldLines {
+			hunk.OldStart++
+		}
+		if 0 < hunk.NewLines {
+			hunk.NewStart++
+		}
+
+		ret = append(ret, hunk)
+	}
+
+	return ret
+}
+
+// Unified formats the specified hunks in unified diff format with the specified file names.
+func (*mydiff) Unified(oldName, newName string, hunks []*DiffHunk) string {
+	if 1 > len(hunks) {
+		return ""
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString("--- " + oldName + "\n")
+	buf.WriteString("+++ " + newName + "\n")
+
+	for _, hunk := range hunks {
+		buf.WriteString("@@ -" + unifiedRange(hunk.OldStart, hunk.OldLines) + " +" +
+			unifiedRange(hunk.NewStart, hunk.NewLines) + " @@\n")
+
+		for _, line := range hunk.Lines {
+			buf.WriteString(line.Op + line.Text + "\n")
+		}
+	}
+
+	return buf.String()
+}
+
+// unifiedRange formats the specified range in unified diff format, the count is omitted if it is 1.
+func unifiedRange(start, count int) string {
+	if 1 == count {
+		return strconv.Itoa(start)
+	}
+
+	return strconv.Itoa(start) + "," + strconv.Itoa(count)
+}
+
+// countDiffLine counts the specified line into the specified counts of old and new lines.
+func countDiffLine(line *DiffLine, oldLines, newLines int) (int, int) {
+	if DiffInsert != line.Op {
+		oldLines++
+	}
+	if DiffDelete != line.Op {
+		newLines++
+	}
+
+	return oldLines, newLines
+}
+
+// edit represents an edit of Myers' algorithm, x and y are 0-based indices in the old and new sides.
+type edit struct {
+	op   string
+	x, y int
+}
+
+// myers computes the shortest edit script of a and b.
+//
+// Refers to Eugene W. Myers, An O(ND) Difference Algorithm and Its Variations.
+func myers(a, b []string, maxEdits int) ([]edit, bool) {
+	n, m := len(a), len(b)
+	max := n + m
+	if 0 < maxEdits && maxEdits < max {
+		max = maxEdits
+	}
+
+	offset := max + 1
+	v := make([]int, 2*max+3) // v[offset+k] is the furthest x on diagonal k
+	trace := [][]int{}        // trace[d] is v[offset-d-1:offset+d+2] before step d, O(D^2) memory
+
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			x := 0
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // down, insertion
+			} else {
+				x = v[offset+k-1] + 1 // right, deletion
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				found = true
+
+				break
+			}
+		}
+	}
+
+	if !found {
+		ret := []edit{}
+		for x := 0; x < n; x++ {
+			ret = append(ret, edit{op: DiffDelete, x: x})
+		}
+		for y := 0; y < m; y++ {
+			ret = append(ret, edit{op: DiffInsert, y: y})
+		}
+
+		return ret, false
+	}
+
+	ret := []edit{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		o := d + 1 // offset of diagonal 0 in the snapshot
+		k := x - y
+
+		prevK := 0
+		if k == -d || (k != d && v[o+k-1] < v[o+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v[o+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ret = append(ret, edit{op: DiffEqual, x: x, y: y})
+		}
+
+		if 0 < d {
+			if x == prevX {
+				y--
+				ret = append(ret, edit{op: DiffInsert, x: x, y: y})
+			} else {
+				x--
+				ret = append(ret, edit{op: DiffDelete, x: x, y: y})
+			}
+		}
+	}
+
+	for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+		ret[i], ret[j] = ret[j], ret[i]
+	}
+
+	return ret, true
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	a := Diff.SplitLines("a\nb\nc\na\nb\nb\na\n")
+	b := Diff.SplitLines("c\nb\na\nb\na\nc\n")
+
+	lines, exact := Diff.Lines(a, b, 0)
+	if !exact {
+		t.Error("The diff should be exact")
+
+		return
+	}
+
+	edits := 0
+	for _, line := range lines {
+		if DiffEqual != line.Op {
+			edits++
+		}
+	}
+
+	if 5 != edits {
+		t.Errorf("The edit distance should be [5], got [%d]", edits)
+	}
+
+	// applying the diff must produce both sides
+	old, new := []string{}, []string{}
+	for _, line := range lines {
+		if DiffInsert != line.Op {
+			old = append(old, line.Text)
+		}
+		if DiffDelete != line.Op {
+			new = append(new, line.Text)
+		}
+	}
+
+	if !equalLines(a, old) || !equalLines(b, new) {
+		t.Errorf("The diff doesn't reproduce the sides: %v, %v", old, new)
+	}
+}
+
+func TestDiffLinesMaxEdits(t *testing.T) {
+	a := Diff.SplitLines("1\n2\n3\n4\n")
+	b := Diff.SplitLines("5\n6\n7\n8\n")
+
+	lines, exact := Diff.Lines(a, b, 2)
+	if exact {
+		t.Error("The diff should not be exact")
+
+		return
+	}
+
+	if 8 != len(lines) {
+		t.Errorf("The diff should contain [8] lines, got [%d]", len(lines))
+	}
+}
+
+func TestDiffUnified(t *testing.T) {
+	a := Diff.SplitLines("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+	b := Diff.SplitLines("1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n11\n")
+
+	lines, _ := Diff.Lines(a, b, 0)
+	hunks := Diff.Hunks(lines, 1)
+	if 2 != len(hunks) {
+		t.Errorf("Should be [2] hunks, got [%d]", len(hunks))
+
+		return
+	}
+
+	expected := "--- a\n+++ b\n@@ -4,3 +4,3 @@\n 4\n-5\n+five\n 6\n@@ -10 +10,2 @@\n 10\n+11\n"
+	if unified := Diff.Unified("a", "b", hunks); expected != unified {
+		t.Errorf("Expected [%s], got [%s]", expected, unified)
+	}
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
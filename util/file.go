@@ -15,6 +15,7 @@
 package util
 
 import (
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -85,6 +86,88 @@ func (*myfile) IsDir(path string) bool {
 	return fio.IsDir()
 }
 
+// ErrPathEscaped indicates a path resolves to somewhere outside of the base directory.
+var ErrPathEscaped = errors.New("path escapes from the base directory")
+
+// SafeJoin joins the specified path to the specified base directory, returns the cleaned path which is guaranteed to
+// be the base directory or under it.
+//
+// A relative path is joined to the base, an absolute path is accepted only if it's under the base. Symbolic links are
+// resolved (the nearest existing ancestor's if the path doesn't exist yet), so a link pointing outside of the base is
+// rejected the same as a ".." escape. A dangling link is rejected since it may be created outside by writing.
+func (*myfile) SafeJoin(base, path string) (string, error) {
+	if "" == base {
+		return "", errors.New("base directory is empty")
+	}
+
+	base = filepath.Clean(filepath.FromSlash(base))
+	ret := filepath.FromSlash(path)
+	if !filepath.IsAbs(ret) {
+		ret = filepath.Join(base, ret)
+	}
+	ret = filepath.Clean(ret)
+
+	if !isUnderDir(ret, base) {
+		return "", ErrPathEscaped
+	}
+
+	realBase, err := evalExistingSymlinks(base)
+	if nil != err {
+		return "", err
+	}
+
+	realPath, err := evalExistingSymlinks(ret)
+	if nil != err {
+		return "", err
+	}
+
+	if !isUnderDir(realPath, realBase) {
+		return "", ErrPathEscaped
+	}
+
+	return ret, nil
+}
+
+// evalExistingSymlinks resolves symbolic links of the specified path, the nonexistent tail of the path is kept as is.
+func evalExistingSymlinks(path string) (string, error) {
+	tail := ""
+	for {
+		real, err := filepath.EvalSymlinks(path)
+		if nil == err {
+			return filepath.Join(real, tail), nil
+		}
+
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		if _, err := os.Lstat(path); nil == err {
+			return "", errors.New("dangling symbolic link [" + path + "]")
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, tail), nil
+		}
+
+		tail = filepath.Join(filepath.Base(path), tail)
+		path = parent
+	}
+}
+
+// isUnderDir checks whether the specified cleaned path is the specified cleaned directory or under it.
+func isUnderDir(path, dir string) bool {
+	if path == dir {
+		return true
+	}
+
+	if !strings.HasSuffix(dir, string(os.PathSeparator)) {
+		dir += string(os.PathSeparator)
+	}
+
+	return strings.HasPrefix(path, dir)
+}
+
 // CopyFile copies the source file to the dest file.
 func (*myfile) CopyFile(source string, dest string) (err error) {
 	sourcefile, err := os.Open(source)
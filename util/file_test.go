@@ -15,6 +15,8 @@
 package util
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strconv"
 	"testing"
@@ -79,3 +81,60 @@ func TestCopyFile(t *testing.T) {
 		return
 	}
 }
+
+func TestSafeJoin(t *testing.T) {
+	root, err := ioutil.TempDir("", "wide-safejoin")
+	if nil != err {
+		t.Error(err)
+
+		return
+	}
+	defer os.RemoveAll(root)
+
+	workspace := filepath.Join(root, "workspace")
+	outside := filepath.Join(root, "outside")
+	os.MkdirAll(filepath.Join(workspace, "src", "pkg"), 0755)
+	os.MkdirAll(outside, 0755)
+	ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644)
+
+	if err := os.Symlink(outside, filepath.Join(workspace, "src", "out")); nil != err {
+		t.Skip("symbolic links are unsupported: ", err)
+	}
+	os.Symlink(filepath.Join(workspace, "src", "pkg"), filepath.Join(workspace, "src", "in"))
+	os.Symlink(filepath.Join(outside, "nonexistent"), filepath.Join(workspace, "src", "dangling"))
+
+	valid := map[string]string{
+		"src/pkg/main.go": filepath.Join(workspace, "src", "pkg", "main.go"),
+		"src/../src/pkg":  filepath.Join(workspace, "src", "pkg"),
+		"":                workspace,
+		filepath.Join(workspace, "src", "new.go"): filepath.Join(workspace, "src", "new.go"),
+		"src/in/main.go": filepath.Join(workspace, "src", "in", "main.go"),
+	}
+	for path, expected := range valid {
+		ret, err := File.SafeJoin(workspace, path)
+		if nil != err {
+			t.Errorf("[%s] should be valid: %v", path, err)
+
+			continue
+		}
+
+		if expected != ret {
+			t.Errorf("Expected [%s], actual [%s]", expected, ret)
+		}
+	}
+
+	invalid := []string{
+		"../outside/secret",
+		"src/../../outside",
+		filepath.Join(outside, "secret"),
+		workspace + "2/main.go",
+		"src/out/secret",
+		"src/out/new/main.go",
+		"src/dangling",
+	}
+	for _, path := range invalid {
+		if ret, err := File.SafeJoin(workspace, path); nil == err {
+			t.Errorf("[%s] should be rejected, actual [%s]", path, ret)
+		}
+	}
+}
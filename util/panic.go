@@ -16,8 +16,10 @@ package util
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"runtime"
 
@@ -42,6 +44,36 @@ func Recover() {
 	}
 }
 
+// RecoverRequest recovers a panic of handling the specified request.
+//
+// The panic is logged with the request id (see Request.GetID) and the stack trace. If nothing has been written to the
+// response (checked by method Written() bool of the response writer if it has one), responds 500 with the request id,
+// so that users could quote it in bug reports.
+func RecoverRequest(w http.ResponseWriter, r *http.Request) {
+	re := recover()
+	if nil == re {
+		return
+	}
+
+	id := Request.GetID(r)
+	stack := stack()
+	logger.Log(log.Error, "PANIC RECOVERED", log.Fields{"requestId": id, "method": r.Method, "uri": r.RequestURI,
+		"panic": fmt.Sprint(re), "stack": string(stack)})
+
+	if ww, ok := w.(interface {
+		Written() bool
+	}); ok && ww.Written() {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+
+	data, _ := json.Marshal(map[string]interface{}{"succ": false, "code": "500", "requestId": id,
+		"msg": "Internal Server Error, request id [" + id + "]"})
+	w.Write(data)
+}
+
 // stack implements Stack, skipping 2 frames.
 func stack() []byte {
 	buf := &bytes.Buffer{} // the returned data
@@ -0,0 +1,56 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// requestIDKey is the context key of request id.
+type requestIDKey struct{}
+
+type myrequest struct{}
+
+// Request utilities.
+var Request = myrequest{}
+
+// NewID generates a request id used to correlate log lines of a request.
+func (*myrequest) NewID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); nil != err {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	return hex.EncodeToString(buf)
+}
+
+// WithID returns a shallow copy of the specified request carrying the specified request id in its context.
+func (*myrequest) WithID(r *http.Request, id string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
+
+// GetID gets the request id of the specified request, returns "" if not set.
+func (*myrequest) GetID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return id
+	}
+
+	return ""
+}
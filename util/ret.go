@@ -45,7 +45,14 @@ func NewResult() *Result {
 }
 
 // RetResult writes HTTP response with "Content-Type, application/json".
+//
+// It's usually deferred, if the handler is panicking, nothing is written and the panic is left to the panic recovery
+// (see RecoverRequest) to respond 500.
 func RetResult(w http.ResponseWriter, r *http.Request, res *Result) {
+	if re := recover(); nil != re {
+		panic(re)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	data, err := json.Marshal(res)
@@ -60,6 +67,10 @@ func RetResult(w http.ResponseWriter, r *http.Request, res *Result) {
 
 // RetGzResult writes HTTP response with "Content-Type, application/json" and "Content-Encoding, gzip".
 func RetGzResult(w http.ResponseWriter, r *http.Request, res *Result) {
+	if re := recover(); nil != re { // see RetResult
+		panic(re)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Content-Encoding", "gzip")
 
@@ -0,0 +1,132 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"errors"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type mytemplate struct {
+	mutex     sync.RWMutex
+	dir       string
+	reload    bool
+	templates map[string]*parsedTemplate // <name, template>
+}
+
+// parsedTemplate represents a parsed template file.
+type parsedTemplate struct {
+	template *template.Template
+	modTime  time.Time // modification time of the file when parsed
+}
+
+// Template utilities.
+var Template = mytemplate{}
+
+// Load parses all HTML templates (*.html) under the specified directory, a template is named by its path relative
+// to the directory with slashes, such as "playground/index.html".
+//
+// If reload is true, a template is parsed again once its file is modified, for template authors.
+func (t *mytemplate) Load(dir string, reload bool) error {
+	templates := map[string]*parsedTemplate{}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if nil != err {
+			return err
+		}
+
+		if info.IsDir() || ".html" != filepath.Ext(path) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if nil != err {
+			return err
+		}
+
+		parsed, err := parseTemplate(path, info.ModTime())
+		if nil != err {
+			return err
+		}
+
+		templates[filepath.ToSlash(rel)] = parsed
+
+		return nil
+	})
+	if nil != err {
+		return err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.dir = dir
+	t.reload = reload
+	t.templates = templates
+
+	return nil
+}
+
+// Get gets the template specified by name.
+func (t *mytemplate) Get(name string) (*template.Template, error) {
+	t.mutex.RLock()
+	parsed := t.templates[name]
+	dir, reload := t.dir, t.reload
+	t.mutex.RUnlock()
+
+	if nil == parsed {
+		return nil, errors.New("template [" + name + "] not found")
+	}
+
+	if !reload {
+		return parsed.template, nil
+	}
+
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	info, err := os.Stat(path)
+	if nil != err {
+		return nil, err
+	}
+
+	if info.ModTime().Equal(parsed.modTime) {
+		return parsed.template, nil
+	}
+
+	parsed, err = parseTemplate(path, info.ModTime())
+	if nil != err {
+		return nil, err
+	}
+
+	t.mutex.Lock()
+	t.templates[name] = parsed
+	t.mutex.Unlock()
+
+	return parsed.template, nil
+}
+
+// parseTemplate parses the template file specified by path modified at the specified time.
+func parseTemplate(path string, modTime time.Time) (*parsedTemplate, error) {
+	tpl, err := template.ParseFiles(path)
+	if nil != err {
+		return nil, errors.New("parses template [" + path + "] failed: " + strings.TrimPrefix(err.Error(), "template: "))
+	}
+
+	return &parsedTemplate{template: tpl, modTime: modTime}, nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2014-2018, b3log.org & hacpai.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wide-template")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "sub", "index.html"), []byte("<b>{{.}}</b>"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "readme.txt"), []byte("{{"), 0644)
+
+	if err := Template.Load(dir, true); nil != err {
+		t.Fatal(err)
+	}
+
+	if _, err := Template.Get("readme.txt"); nil == err {
+		t.Error("Non-HTML file should not be loaded")
+	}
+
+	tpl, err := Template.Get("sub/index.html")
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	tpl.Execute(buf, "<wide>")
+	if "<b>&lt;wide&gt;</b>" != buf.String() {
+		t.Errorf("Expected [%s], got [%s]", "<b>&lt;wide&gt;</b>", buf.String())
+	}
+
+	// reloads the modified template
+	path := filepath.Join(dir, "sub", "index.html")
+	ioutil.WriteFile(path, []byte("<i>{{.}}</i>"), 0644)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(path, later, later)
+
+	tpl, err = Template.Get("sub/index.html")
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	tpl.Execute(buf, "wide")
+	if "<i>wide</i>" != buf.String() {
+		t.Errorf("Expected [%s], got [%s]", "<i>wide</i>", buf.String())
+	}
+}
+
+func TestTemplateLoadError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wide-template")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "broken.html"), []byte("{{.Foo"), 0644)
+
+	if err := Template.Load(dir, false); nil == err {
+		t.Error("Loading a broken template should fail")
+	}
+}
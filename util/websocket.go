@@ -17,6 +17,7 @@ package util
 import (
 	"errors"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -28,6 +29,7 @@ type WSChannel struct {
 	Conn    *websocket.Conn // websocket connection
 	Request *http.Request   // HTTP request related
 	Time    time.Time       // the latest use time
+	mutex   sync.Mutex      // serializes writes, a connection supports one concurrent writer only
 }
 
 // WriteJSON writes the JSON encoding of v to the channel.
@@ -42,6 +44,9 @@ func (c *WSChannel) WriteJSON(v interface{}) (ret error) {
 		}
 	}()
 
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	return c.Conn.WriteJSON(v)
 }
 